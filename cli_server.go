@@ -15,6 +15,9 @@ import (
 
 	"bufio"
 	"flag"
+
+	notes "self-improvement/internal/parser"
+	"self-improvement/internal/streaks"
 )
 
 // Question represents a question-answer pair with learning data
@@ -35,6 +38,8 @@ type Question struct {
 type LearningData struct {
 	Questions    map[string]*Question `json:"questions"`
 	LastUpdated  time.Time            `json:"last_updated"`
+	Days         map[string]*streaks.Day `json:"days,omitempty"` // Review activity per local date
+	Goal         *streaks.Goal           `json:"goal,omitempty"` // nil for the default goal
 }
 
 // SpacedRepetition manages the spaced repetition algorithm
@@ -146,6 +151,12 @@ func (sr *SpacedRepetition) GetQuestion(id string) *Question {
 
 // UpdateReview updates review results for a question
 func (sr *SpacedRepetition) UpdateReview(id string, feedback int) bool {
+	return sr.UpdateTimedReview(id, feedback, 0)
+}
+
+// UpdateTimedReview is UpdateReview for a review that took spent, counting it
+// towards the daily goal
+func (sr *SpacedRepetition) UpdateTimedReview(id string, feedback int, spent time.Duration) bool {
 	q, exists := sr.Data.Questions[id]
 	if !exists {
 		return false
@@ -205,10 +216,68 @@ func (sr *SpacedRepetition) UpdateReview(id string, feedback int) bool {
 		q.Level = min(4, q.Level+1)
 	}
 
+	sr.recordDay(now, feedback <= 2, spent)
 	sr.SaveData()
 	return true
 }
 
+// GetGoal returns the daily goal
+func (sr *SpacedRepetition) GetGoal() streaks.Goal {
+	if sr.Data.Goal != nil && sr.Data.Goal.Valid() {
+		return *sr.Data.Goal
+	}
+	return streaks.DefaultGoal
+}
+
+// recordDay adds a review to today's activity. A day counts for the streak
+// once the goal is reached or no question is due anymore.
+func (sr *SpacedRepetition) recordDay(now time.Time, correct bool, spent time.Duration) {
+	if sr.Data.Days == nil {
+		sr.Data.Days = make(map[string]*streaks.Day)
+	}
+	date := now.Format(streaks.DateLayout)
+	day, ok := sr.Data.Days[date]
+	if !ok {
+		day = &streaks.Day{Date: date}
+		sr.Data.Days[date] = day
+	}
+	day.Reviews++
+	if correct {
+		day.Correct++
+	}
+	day.StudyMs += spent.Milliseconds()
+	day.GoalMet = day.GoalMet || sr.GetGoal().Met(day.Reviews, day.StudyMs)
+	day.Cleared = day.Cleared || len(sr.GetDueQuestions()) == 0
+}
+
+// GetStreaks returns today's activity, the current and longest streak and
+// the achievements
+func (sr *SpacedRepetition) GetStreaks() (streaks.Day, int, int, []streaks.Achievement) {
+	today := time.Now().Format(streaks.DateLayout)
+	var days []streaks.Day
+	totals := streaks.Totals{}
+	for _, d := range sr.Data.Days {
+		days = append(days, *d)
+		if d.Perfect() {
+			totals.PerfectDays++
+		}
+	}
+	for _, q := range sr.Data.Questions {
+		totals.Reviews += int64(q.ReviewCount)
+		if q.Level == 1 {
+			totals.Mastered++
+		}
+	}
+	current, longest := streaks.Streaks(days, today)
+	totals.LongestStreak = longest
+
+	todayDay := streaks.Day{Date: today}
+	if d, ok := sr.Data.Days[today]; ok {
+		todayDay = *d
+	}
+	return todayDay, current, longest, streaks.Achievements(totals)
+}
+
 // DeleteQuestion removes a question from the knowledge base
 func (sr *SpacedRepetition) DeleteQuestion(id string) bool {
 	if _, exists := sr.Data.Questions[id]; exists {
@@ -492,9 +561,16 @@ var feedbackLabels = map[int]string{
 func main() {
 	var initFlag = flag.Bool("init", false, "Initialize knowledge base (parse .md files from configured directories)")
 	var statsFlag = flag.Bool("stats", false, "View learning statistics")
+	var convertFlag = flag.String("convert", "", "Convert heading-structured notes (.md) into # q/# a cards, asking to accept each candidate")
+	var outFlag = flag.String("out", "", "Output file for --convert (default: <notes>_qa.md next to the notes)")
+	var goalFlag = flag.String("goal", "", "Set the daily goal, e.g. cards:20 or minutes:15")
 	flag.Parse()
 
-	if *initFlag {
+	if *goalFlag != "" {
+		setGoalCmd(*goalFlag)
+	} else if *convertFlag != "" {
+		convertNotes(*convertFlag, *outFlag)
+	} else if *initFlag {
 		initDatabase()
 	} else if *statsFlag {
 		printStatsCmd()
@@ -600,6 +676,8 @@ func printFeedbackPrompt() {
 
 func printStatsFunc(sr *SpacedRepetition) {
 	stats := sr.GetStats()
+	goal := sr.GetGoal()
+	today, current, longest, achievements := sr.GetStreaks()
 
 	fmt.Println()
 	printColored("cyan", strings.Repeat("=", 60))
@@ -628,6 +706,56 @@ func printStatsFunc(sr *SpacedRepetition) {
 	fmt.Printf("正确率: ")
 	printColored("green", fmt.Sprintf("%v%%", stats["accuracy"]))
 	fmt.Println()
+
+	fmt.Printf("今日目标: ")
+	if goal.Type == streaks.GoalMinutes {
+		printColored("yellow", fmt.Sprintf("%d / %d 分钟", today.StudyMs/60000, goal.Target))
+	} else {
+		printColored("yellow", fmt.Sprintf("%d / %d 题", today.Reviews, goal.Target))
+	}
+	if today.Counts() {
+		printColored("green", " ✓")
+	}
+	fmt.Println()
+
+	fmt.Printf("连续学习: ")
+	printColored("green", fmt.Sprintf("%d 天", current))
+	fmt.Printf("（最长 %d 天）", longest)
+	fmt.Println()
+
+	fmt.Printf("成就: ")
+	var earned []string
+	for _, a := range achievements {
+		if a.Earned {
+			earned = append(earned, a.Name)
+		}
+	}
+	if len(earned) == 0 {
+		printColored("yellow", fmt.Sprintf("0 / %d", len(achievements)))
+	} else {
+		printColored("green", fmt.Sprintf("%d / %d  %s", len(earned), len(achievements), strings.Join(earned, "、")))
+	}
+	fmt.Println()
+}
+
+// setGoalCmd saves the daily goal given as type:target
+func setGoalCmd(value string) {
+	parts := strings.SplitN(value, ":", 2)
+	goal := streaks.Goal{Type: strings.TrimSpace(parts[0])}
+	if len(parts) == 2 {
+		fmt.Sscanf(strings.TrimSpace(parts[1]), "%d", &goal.Target)
+	}
+	if !goal.Valid() {
+		printColored("red", fmt.Sprintf("无效的目标: %s（示例: cards:20 或 minutes:15，目标 1-%d）\n", value, streaks.MaxGoalTarget))
+		return
+	}
+	sr := NewSpacedRepetition("data/learning_data.json")
+	sr.Data.Goal = &goal
+	if err := sr.SaveData(); err != nil {
+		printColored("red", fmt.Sprintf("保存失败: %v\n", err))
+		return
+	}
+	printColored("green", fmt.Sprintf("每日目标已设置为 %s:%d\n", goal.Type, goal.Target))
 }
 
 func initDatabase() {
@@ -710,6 +838,96 @@ func initDatabase() {
 	printStatsFunc(sr)
 }
 
+func convertNotes(path, out string) {
+	printHeader()
+
+	content, err := ioutil.ReadFile(path)
+	if err != nil {
+		printColored("red", fmt.Sprintf("错误读取文件: %v\n", err))
+		return
+	}
+
+	candidates := notes.ConvertNotes(string(content), path)
+	if len(candidates) == 0 {
+		printColored("red", "没有找到可转换的内容！笔记需要使用标题（## 标题）加正文，或定义列表的结构。\n")
+		return
+	}
+
+	if out == "" {
+		out = strings.TrimSuffix(path, filepath.Ext(path)) + "_qa.md"
+	}
+
+	printColored("cyan", fmt.Sprintf("找到 %d 个候选问题\n", len(candidates)))
+	printColored("cyan", "输入 'y' 接受，'n' 跳过，'all' 接受剩余全部，'q' 结束\n")
+	fmt.Println()
+
+	scanner := bufio.NewScanner(os.Stdin)
+	var accepted []*notes.Question
+	acceptAll := false
+
+loop:
+	for i, cand := range candidates {
+		if acceptAll {
+			accepted = append(accepted, cand.ToQuestion())
+			continue
+		}
+
+		printColored("cyan", strings.Repeat("─", 60))
+		fmt.Println()
+		printColored("yellow", fmt.Sprintf("[%d/%d] 第 %d 行 (%s)", i+1, len(candidates), cand.Line, cand.Kind))
+		fmt.Println()
+		printColored("white", "问题: "+cand.Question)
+		fmt.Println()
+		printColored("green", "答案: "+cand.Answer)
+		fmt.Println()
+
+		for {
+			printColored("cyan", ">>> ")
+			if !scanner.Scan() {
+				break loop
+			}
+			switch strings.TrimSpace(strings.ToLower(scanner.Text())) {
+			case "y", "yes", "是":
+				accepted = append(accepted, cand.ToQuestion())
+			case "n", "no", "否":
+			case "all", "全部":
+				acceptAll = true
+				accepted = append(accepted, cand.ToQuestion())
+			case "q", "quit", "退出":
+				break loop
+			default:
+				printColored("red", "输入 'y' 接受，'n' 跳过，'all' 接受剩余全部，'q' 结束\n")
+				continue
+			}
+			break
+		}
+	}
+
+	if len(accepted) == 0 {
+		printColored("yellow", "没有接受任何问题\n")
+		return
+	}
+
+	f, err := os.OpenFile(out, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		printColored("red", fmt.Sprintf("错误写入文件: %v\n", err))
+		return
+	}
+	defer f.Close()
+
+	if info, err := f.Stat(); err == nil && info.Size() > 0 {
+		f.WriteString("\n")
+	}
+	if _, err := f.WriteString(notes.FormatQA(accepted)); err != nil {
+		printColored("red", fmt.Sprintf("错误写入文件: %v\n", err))
+		return
+	}
+
+	fmt.Println()
+	printColored("green", fmt.Sprintf("已将 %d 个问题写入 %s\n", len(accepted), out))
+	printColored("yellow", "运行 --init 导入知识库\n")
+}
+
 func startTraining() {
 	printHeader()
 
@@ -745,6 +963,7 @@ func startTraining() {
 
 		// Show question
 		printQuestion(qData, index, total)
+		shownAt := time.Now()
 
 		// Wait for user input to see answer or delete
 		answerShown := false
@@ -843,7 +1062,7 @@ func startTraining() {
 
 			feedback, exists := feedbackMap[feedbackInput]
 			if exists {
-				sr.UpdateReview(qID, feedback)
+				sr.UpdateTimedReview(qID, feedback, time.Since(shownAt))
 				reviewed++
 
 				label := feedbackLabels[feedback]
@@ -878,4 +1097,4 @@ func printStatsCmd() {
 	printHeader()
 	sr := NewSpacedRepetition("data/learning_data.json")
 	printStatsFunc(sr)
-}
\ No newline at end of file
+}
\ No newline at end of file
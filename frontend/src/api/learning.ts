@@ -7,6 +7,9 @@ import type {
   ImportResult,
   FeedbackLevel,
   ForecastDay,
+  ReviewTiming,
+  StudySession,
+  SessionSummary,
   ApiResponse
 } from './types'
 
@@ -28,13 +31,24 @@ export const learningApi = {
   },
 
   // 提交复习反馈
-  updateReview(questionId: string, feedback: FeedbackLevel): Promise<ApiResponse<{ stats: Stats }>> {
+  updateReview(questionId: string, feedback: FeedbackLevel, timing?: ReviewTiming): Promise<ApiResponse<{ stats: Stats }>> {
     return api.post('/update-review', {
       question_id: questionId,
-      feedback
+      feedback,
+      ...timing
     })
   },
 
+  // 开始学习会话
+  startSession(): Promise<ApiResponse<StudySession>> {
+    return api.post('/sessions', { device: 'web' })
+  },
+
+  // 结束学习会话，返回本次总结
+  finishSession(id: number): Promise<ApiResponse<SessionSummary>> {
+    return api.post(`/sessions/${id}/finish`)
+  },
+
   // 删除问题
   deleteQuestion(questionId: string): Promise<ApiResponse<{ stats: Stats }>> {
     return api.post('/delete-question', {
@@ -11,14 +11,33 @@ export interface Question {
 }
 
 export interface Category {
+  id: number
   name: string
   label: string
+  color: string
+  description: string
+  sort_order: number
+  interval_multiplier: number
+  parent: string
+  depth: number
+  // total / due 包含子分类，own_total / own_due 只统计本分类
   total: number
   due: number
+  own_total: number
+  own_due: number
+  // 0-1，包含子分类；readiness 按卡片优先级加权
+  mastery: number
+  readiness: number
+  recall: number
+}
+
+export interface CategoryNode extends Category {
+  children: CategoryNode[]
 }
 
 export interface CategoriesData {
   categories: Category[]
+  tree: CategoryNode[]
 }
 
 export interface Stats {
@@ -61,6 +80,32 @@ export interface ImportResult {
 export interface ForecastDay {
   date: string
   count: number
+  // 按平均每题用时估算的复习分钟数
+  estimated_minutes: number
+}
+
+export interface ReviewTiming {
+  session_id?: number
+  question_ms: number
+  answer_ms: number
+}
+
+export interface StudySession {
+  id: number
+  device?: string
+  started_at: string
+  finished_at: string | null
+}
+
+export interface SessionSummary extends StudySession {
+  cards: number
+  reviews: number
+  correct: number
+  accuracy: number
+  new_cards: number
+  review_cards: number
+  study_ms: number
+  duration_ms: number
 }
 
 export interface ForecastData {
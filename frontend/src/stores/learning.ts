@@ -1,7 +1,7 @@
 import { defineStore } from 'pinia'
 import { ref, computed } from 'vue'
 import { learningApi } from '@/api/learning'
-import type { Question, Stats, Category, ForecastDay } from '@/api/types'
+import type { Question, Stats, Category, ForecastDay, SessionSummary } from '@/api/types'
 
 export const useLearningStore = defineStore('learning', () => {
   // 状态
@@ -20,6 +20,12 @@ export const useLearningStore = defineStore('learning', () => {
   const isAnswerVisible = ref(false)
   // Comma-separated category names for current review session
   const activeCategories = ref<string>('')
+  // Server-side study session, null when none is open
+  const sessionId = ref<number | null>(null)
+  const lastSession = ref<SessionSummary | null>(null)
+  // When the current question and its answer were shown (ms timestamps)
+  let questionShownAt = 0
+  let answerShownAt = 0
 
   // 计算属性
   const currentQuestion = computed(() => {
@@ -69,6 +75,9 @@ export const useLearningStore = defineStore('learning', () => {
         questions.value = response.data.questions || []
         currentQuestionIndex.value = 0
         isAnswerVisible.value = false
+        if (questions.value.length > 0) {
+          await startSession()
+        }
         return {
           count: questions.value.length,
           needsInit: false
@@ -94,9 +103,16 @@ export const useLearningStore = defineStore('learning', () => {
     if (!currentQuestion.value) return false
 
     try {
+      const now = Date.now()
+      const shownAt = answerShownAt || now
       const response = await learningApi.updateReview(
         currentQuestion.value.id,
-        feedback
+        feedback,
+        {
+          session_id: sessionId.value ?? undefined,
+          question_ms: Math.max(0, shownAt - questionShownAt),
+          answer_ms: Math.max(0, now - shownAt)
+        }
       )
 
       if (response.success && response.data && response.data.stats) {
@@ -217,16 +233,51 @@ export const useLearningStore = defineStore('learning', () => {
     }
   }
 
+  async function startSession() {
+    await finishSession()
+    try {
+      const response = await learningApi.startSession()
+      if (response.success && response.data) {
+        sessionId.value = response.data.id
+      }
+    } catch (error) {
+      // 会话只用于统计，失败时照常复习
+      console.error('开始学习会话失败:', error)
+    }
+    questionShownAt = Date.now()
+    answerShownAt = 0
+  }
+
+  async function finishSession() {
+    if (sessionId.value === null) return null
+    const id = sessionId.value
+    sessionId.value = null
+    try {
+      const response = await learningApi.finishSession(id)
+      if (response.success && response.data) {
+        lastSession.value = response.data
+        return response.data
+      }
+    } catch (error) {
+      console.error('结束学习会话失败:', error)
+    }
+    return null
+  }
+
   function showAnswer() {
     isAnswerVisible.value = true
+    answerShownAt = Date.now()
   }
 
   function nextQuestion() {
     currentQuestionIndex.value++
     isAnswerVisible.value = false
+    questionShownAt = Date.now()
+    answerShownAt = 0
   }
 
   function reset() {
+    finishSession()
     questions.value = []
     currentQuestionIndex.value = 0
     isAnswerVisible.value = false
@@ -241,6 +292,8 @@ export const useLearningStore = defineStore('learning', () => {
     currentQuestionIndex,
     isAnswerVisible,
     activeCategories,
+    sessionId,
+    lastSession,
 
     // Computed
     currentQuestion,
@@ -259,6 +312,8 @@ export const useLearningStore = defineStore('learning', () => {
     resetDemo,
     fetchForecast,
     addQuestion,
+    startSession,
+    finishSession,
     showAnswer,
     nextQuestion,
     reset
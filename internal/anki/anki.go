@@ -0,0 +1,348 @@
+// Package anki reads Anki deck packages (.apkg) without talking to AnkiWeb.
+//
+// An .apkg file is a zip archive holding a SQLite collection
+// ("collection.anki21" or the older "collection.anki2"), a "media" JSON file
+// mapping numbered entries to file names, and the media files themselves.
+package anki
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"self-improvement/internal/ziparchive"
+)
+
+// ErrUnsupportedFormat is returned for packages that only contain the
+// zstd-compressed collection written by recent Anki versions
+var ErrUnsupportedFormat = errors.New("unsupported .apkg format: re-export the deck from Anki with \"Support older Anki versions\" enabled")
+
+// ErrTooManyCards is returned for collections with more than MaxCards cards
+var ErrTooManyCards = errors.New("too many cards in the collection")
+
+// MaxCards caps the cards read from one collection
+const MaxCards = 20000
+
+// Note type kinds as stored in the collection's models JSON
+const (
+	noteTypeStandard = 0
+	noteTypeCloze    = 1
+)
+
+// cardTypeNew marks cards that were never studied in the cards table
+const cardTypeNew = 0
+
+// Card is one Anki card rendered to markdown with its scheduling state
+type Card struct {
+	ID       int64
+	NoteID   int64
+	Deck     string // Full deck name, e.g. "八股文::网络"
+	NoteType string
+	Question string
+	Answer   string
+	Tags     []string
+
+	New          bool      // Never reviewed
+	Due          time.Time // Zero for new cards
+	IntervalDays int
+	Reps         int
+	Lapses       int
+	Reviews      []Review // Oldest first
+}
+
+// Review is one entry of a card's review history
+type Review struct {
+	Time time.Time
+	// Ease is the answer button: 1 = Again, 2 = Hard, 3 = Good, 4 = Easy
+	Ease         int
+	IntervalDays int // Negative values are learning steps in seconds
+}
+
+// Package is an opened .apkg file
+type Package struct {
+	Cards []*Card
+
+	archive *ziparchive.Archive
+	media   map[string]string // File name to archive entry
+}
+
+// Media returns the content of a media file referenced from card fields
+func (p *Package) Media(name string) ([]byte, bool) {
+	entry, ok := p.media[name]
+	if !ok {
+		return nil, false
+	}
+	data, err := p.archive.ReadFile(entry)
+	if err != nil {
+		return nil, false
+	}
+	return data, true
+}
+
+type noteType struct {
+	Name   string `json:"name"`
+	Type   int    `json:"type"`
+	Fields []struct {
+		Name string `json:"name"`
+		Ord  int    `json:"ord"`
+	} `json:"flds"`
+	Templates []struct {
+		Name     string `json:"name"`
+		Ord      int    `json:"ord"`
+		Question string `json:"qfmt"`
+		Answer   string `json:"afmt"`
+	} `json:"tmpls"`
+}
+
+// Read parses an .apkg archive under the given limits; ziparchive.ErrTooLarge
+// and ziparchive.ErrTooManyEntries report archives beyond them. Media is read
+// lazily, so r must stay open for as long as the package is used.
+func Read(r io.ReaderAt, size int64, limits ziparchive.Limits) (*Package, error) {
+	a, err := ziparchive.Open(r, size, limits)
+	if errors.Is(err, ziparchive.ErrTooLarge) || errors.Is(err, ziparchive.ErrTooManyEntries) {
+		return nil, err
+	}
+	if err != nil {
+		return nil, fmt.Errorf("not a valid .apkg file: %w", err)
+	}
+
+	collection := "collection.anki21"
+	if !a.Has(collection) {
+		collection = "collection.anki2"
+	}
+	if !a.Has(collection) {
+		if a.Has("collection.anki21b") {
+			return nil, ErrUnsupportedFormat
+		}
+		return nil, errors.New("not a valid .apkg file: collection not found")
+	}
+
+	pkg := &Package{archive: a, media: make(map[string]string)}
+	if a.Has("media") {
+		var mapping map[string]string
+		if data, err := a.ReadFile("media"); err == nil && json.Unmarshal(data, &mapping) == nil {
+			for entry, name := range mapping {
+				if a.Has(entry) {
+					pkg.media[name] = entry
+				}
+			}
+		}
+	}
+
+	data, err := a.ReadFile(collection)
+	if err != nil {
+		return nil, err
+	}
+	// SQLite needs the collection on disk
+	tmp, err := os.CreateTemp("", "anki-collection-*.db")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	tmp.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := gorm.Open(sqlite.Open(tmp.Name()+"?mode=ro"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		return nil, err
+	}
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	defer sqlDB.Close()
+
+	pkg.Cards, err = readCards(db)
+	if err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
+func readCards(db *gorm.DB) ([]*Card, error) {
+	var col struct {
+		Crt    int64
+		Models string
+		Decks  string
+	}
+	if err := db.Raw("SELECT crt, models, decks FROM col LIMIT 1").Scan(&col).Error; err != nil {
+		return nil, fmt.Errorf("reading collection: %w", err)
+	}
+
+	var noteTypes map[string]*noteType
+	if err := json.Unmarshal([]byte(col.Models), &noteTypes); err != nil {
+		return nil, fmt.Errorf("reading note types: %w", err)
+	}
+	var decks map[string]struct {
+		Name string `json:"name"`
+	}
+	if err := json.Unmarshal([]byte(col.Decks), &decks); err != nil {
+		return nil, fmt.Errorf("reading decks: %w", err)
+	}
+
+	var count int64
+	if err := db.Raw("SELECT COUNT(*) FROM cards").Scan(&count).Error; err != nil {
+		return nil, fmt.Errorf("reading cards: %w", err)
+	}
+	if count > MaxCards {
+		return nil, ErrTooManyCards
+	}
+
+	var rows []struct {
+		ID     int64
+		Nid    int64
+		Did    int64
+		Ord    int
+		Type   int
+		Queue  int
+		Due    int64
+		Ivl    int
+		Reps   int
+		Lapses int
+		Mid    int64
+		Flds   string
+		Tags   string
+	}
+	err := db.Raw(`SELECT c.id, c.nid, c.did, c.ord, c.type, c.queue, c.due, c.ivl, c.reps, c.lapses,
+			n.mid, n.flds, n.tags
+		FROM cards c JOIN notes n ON n.id = c.nid
+		ORDER BY c.nid, c.ord`).Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("reading cards: %w", err)
+	}
+
+	reviews, err := readReviews(db)
+	if err != nil {
+		return nil, err
+	}
+
+	created := time.Unix(col.Crt, 0)
+	var cards []*Card
+	for _, row := range rows {
+		nt := noteTypes[strconv.FormatInt(row.Mid, 10)]
+		if nt == nil {
+			continue
+		}
+
+		fields := noteFields(nt, strings.Split(row.Flds, "\x1f"))
+		question, answer := renderCard(nt, fields, row.Ord)
+		question, answer = htmlToMarkdown(question), htmlToMarkdown(answer)
+		if question == "" || answer == "" {
+			continue
+		}
+
+		card := &Card{
+			ID:           row.ID,
+			NoteID:       row.Nid,
+			Deck:         decks[strconv.FormatInt(row.Did, 10)].Name,
+			NoteType:     nt.Name,
+			Question:     question,
+			Answer:       answer,
+			Tags:         strings.Fields(row.Tags),
+			New:          row.Type == cardTypeNew,
+			IntervalDays: row.Ivl,
+			Reps:         row.Reps,
+			Lapses:       row.Lapses,
+			Reviews:      reviews[row.ID],
+		}
+
+		// Review cards store the due day relative to the collection's
+		// creation, intraday learning cards a unix timestamp
+		switch {
+		case row.Type == cardTypeNew:
+		case row.Due > 1000000000:
+			card.Due = time.Unix(row.Due, 0)
+		default:
+			card.Due = created.AddDate(0, 0, int(row.Due))
+		}
+
+		cards = append(cards, card)
+	}
+
+	return cards, nil
+}
+
+func readReviews(db *gorm.DB) (map[int64][]Review, error) {
+	var rows []struct {
+		ID   int64
+		Cid  int64
+		Ease int
+		Ivl  int
+	}
+	// Ease 0 marks manual rescheduling rather than an answer
+	if err := db.Raw("SELECT id, cid, ease, ivl FROM revlog WHERE ease > 0 ORDER BY id").Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("reading review history: %w", err)
+	}
+
+	reviews := make(map[int64][]Review)
+	for _, row := range rows {
+		reviews[row.Cid] = append(reviews[row.Cid], Review{
+			Time:         time.UnixMilli(row.ID),
+			Ease:         row.Ease,
+			IntervalDays: row.Ivl,
+		})
+	}
+	return reviews, nil
+}
+
+// noteFields maps field names to values in field order
+func noteFields(nt *noteType, values []string) map[string]string {
+	fields := make(map[string]string)
+	sort.Slice(nt.Fields, func(i, j int) bool { return nt.Fields[i].Ord < nt.Fields[j].Ord })
+	for i, f := range nt.Fields {
+		if i < len(values) {
+			fields[f.Name] = values[i]
+		}
+	}
+	return fields
+}
+
+// renderCard renders the question and answer side of a card. Standard note
+// types use the template matching the card's ordinal, cloze note types always
+// use their single template with the ordinal selecting the cloze number.
+func renderCard(nt *noteType, fields map[string]string, ord int) (string, string) {
+	if len(nt.Templates) == 0 {
+		return "", ""
+	}
+
+	tmpl := nt.Templates[0]
+	if nt.Type == noteTypeStandard {
+		found := false
+		for _, t := range nt.Templates {
+			if t.Ord == ord {
+				tmpl, found = t, true
+				break
+			}
+		}
+		if !found {
+			return "", ""
+		}
+	}
+
+	cloze := ord + 1
+	question := renderTemplate(tmpl.Question, fields, cloze, false)
+	fields["FrontSide"] = ""
+	answer := renderTemplate(tmpl.Answer, fields, cloze, true)
+	delete(fields, "FrontSide")
+
+	// The answer template usually starts with the front side and a divider
+	answer = strings.TrimSpace(answer)
+	for _, divider := range []string{"<hr id=answer>", `<hr id="answer">`, "<hr>"} {
+		answer = strings.TrimSpace(strings.TrimPrefix(answer, divider))
+	}
+
+	return strings.TrimSpace(question), answer
+}
@@ -0,0 +1,114 @@
+package anki
+
+import (
+	"errors"
+	"os"
+	"testing"
+
+	"self-improvement/internal/ziparchive"
+)
+
+func readSample(t *testing.T) *Package {
+	t.Helper()
+	f, err := os.Open("../../test/sample-deck.apkg")
+	if err != nil {
+		t.Fatalf("open sample deck: %v", err)
+	}
+	t.Cleanup(func() { f.Close() })
+	info, _ := f.Stat()
+
+	pkg, err := Read(f, info.Size(), ziparchive.Limits{})
+	if err != nil {
+		t.Fatalf("Read failed: %v", err)
+	}
+	return pkg
+}
+
+func TestRead_SampleDeck(t *testing.T) {
+	pkg := readSample(t)
+
+	if len(pkg.Cards) != 4 {
+		t.Fatalf("expected 4 cards, got %d", len(pkg.Cards))
+	}
+
+	basic := pkg.Cards[0]
+	if basic.Question != "TCP 三次握手的过程？" {
+		t.Errorf("question mismatch: %q", basic.Question)
+	}
+	if basic.Answer != "SYN, SYN-ACK, ACK\n![](handshake.png)" {
+		t.Errorf("answer mismatch: %q", basic.Answer)
+	}
+	if basic.Deck != "八股文::网络" {
+		t.Errorf("deck mismatch: %q", basic.Deck)
+	}
+	if len(basic.Tags) != 2 || basic.Tags[0] != "网络" {
+		t.Errorf("tags mismatch: %v", basic.Tags)
+	}
+	if basic.New || basic.IntervalDays != 30 || basic.Reps != 5 {
+		t.Errorf("scheduling mismatch: %+v", basic)
+	}
+	if want := "2026-08-10"; basic.Due.UTC().Format("2006-01-02") != want {
+		t.Errorf("due mismatch: %v", basic.Due)
+	}
+	if len(basic.Reviews) != 4 || basic.Reviews[0].Ease != 1 || basic.Reviews[3].Ease != 4 {
+		t.Errorf("reviews mismatch: %+v", basic.Reviews)
+	}
+
+	reversed := pkg.Cards[1]
+	if reversed.Answer != "TCP 三次握手的过程？" || !reversed.New {
+		t.Errorf("reversed card mismatch: %+v", reversed)
+	}
+
+	if _, ok := pkg.Media("handshake.png"); !ok {
+		t.Error("media file not found")
+	}
+	if _, ok := pkg.Media("missing.png"); ok {
+		t.Error("unexpected media file")
+	}
+}
+
+func TestRead_Cloze(t *testing.T) {
+	pkg := readSample(t)
+
+	c1, c2 := pkg.Cards[2], pkg.Cards[3]
+	if c1.Question != "HTTP 默认端口是 [...]，HTTPS 是 443。" {
+		t.Errorf("c1 question mismatch: %q", c1.Question)
+	}
+	if c1.Answer != "HTTP 默认端口是 **80**，HTTPS 是 443。\n**常识** & 基础" {
+		t.Errorf("c1 answer mismatch: %q", c1.Answer)
+	}
+	if c2.Question != "HTTP 默认端口是 80，HTTPS 是 [端口号]。" {
+		t.Errorf("c2 question mismatch: %q", c2.Question)
+	}
+}
+
+func TestHTMLToMarkdown(t *testing.T) {
+	cases := map[string]string{
+		"a<br>b":                               "a\nb",
+		"<div>a</div><div>b</div>":             "a\n\nb",
+		`<img src="x.png" alt="">`:             "![](x.png)",
+		"<i>x</i> [sound:a.mp3]&lt;y&gt;":      "*x* <y>",
+		"<span style=\"color:red\">red</span>": "red",
+	}
+	for in, want := range cases {
+		if got := htmlToMarkdown(in); got != want {
+			t.Errorf("htmlToMarkdown(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestRead_Limits(t *testing.T) {
+	f, err := os.Open("../../test/sample-deck.apkg")
+	if err != nil {
+		t.Fatalf("open sample deck: %v", err)
+	}
+	defer f.Close()
+	info, _ := f.Stat()
+
+	if _, err := Read(f, info.Size(), ziparchive.Limits{MaxEntries: 1}); !errors.Is(err, ziparchive.ErrTooManyEntries) {
+		t.Errorf("expected ErrTooManyEntries, got %v", err)
+	}
+	if _, err := Read(f, info.Size(), ziparchive.Limits{MaxUncompressed: 64}); !errors.Is(err, ziparchive.ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+}
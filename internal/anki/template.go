@@ -0,0 +1,109 @@
+package anki
+
+import (
+	"html"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	sectionRe = regexp.MustCompile(`(?s)\{\{([#^])([^}]+)\}\}(.*?)\{\{/([^}]+)\}\}`)
+	fieldRe   = regexp.MustCompile(`\{\{([^#^/][^}]*)\}\}`)
+	clozeRe   = regexp.MustCompile(`(?s)\{\{c(\d+)::(.*?)(?:::(.*?))?\}\}`)
+)
+
+// renderTemplate fills an Anki card template. It supports field references,
+// {{#Field}}/{{^Field}} sections and the text, cloze and type filters, which
+// covers the note types Anki ships with.
+func renderTemplate(tmpl string, fields map[string]string, cloze int, answer bool) string {
+	// Sections may nest, so expand until nothing changes
+	for {
+		expanded := sectionRe.ReplaceAllStringFunc(tmpl, func(match string) string {
+			m := sectionRe.FindStringSubmatch(match)
+			name := strings.TrimSpace(m[2])
+			if name != strings.TrimSpace(m[4]) {
+				return match
+			}
+			nonEmpty := strings.TrimSpace(fields[name]) != ""
+			if (m[1] == "#") == nonEmpty {
+				return m[3]
+			}
+			return ""
+		})
+		if expanded == tmpl {
+			break
+		}
+		tmpl = expanded
+	}
+
+	return fieldRe.ReplaceAllStringFunc(tmpl, func(match string) string {
+		ref := strings.TrimSpace(fieldRe.FindStringSubmatch(match)[1])
+		parts := strings.Split(ref, ":")
+		name := parts[len(parts)-1]
+		filters := parts[:len(parts)-1]
+
+		value, ok := fields[name]
+		if !ok {
+			return ""
+		}
+		for _, filter := range filters {
+			switch filter {
+			case "cloze":
+				value = renderCloze(value, cloze, answer)
+			case "type":
+				value = ""
+			}
+		}
+		return value
+	})
+}
+
+// renderCloze hides (question side) or highlights (answer side) the deletion
+// with the given number and reveals all others
+func renderCloze(text string, cloze int, answer bool) string {
+	return clozeRe.ReplaceAllStringFunc(text, func(match string) string {
+		m := clozeRe.FindStringSubmatch(match)
+		n, _ := strconv.Atoi(m[1])
+		switch {
+		case n != cloze:
+			return m[2]
+		case answer:
+			return "<b>" + m[2] + "</b>"
+		case m[3] != "":
+			return "[" + m[3] + "]"
+		default:
+			return "[...]"
+		}
+	})
+}
+
+var (
+	soundRe     = regexp.MustCompile(`\[sound:[^\]]*\]`)
+	imgRe       = regexp.MustCompile(`(?i)<img\b[^>]*?\bsrc\s*=\s*["']?([^"' >]+)["']?[^>]*>`)
+	breakRe     = regexp.MustCompile(`(?i)(<br\s*/?>|</?(div|p|li|tr)\b[^>]*>)\n?`)
+	boldRe      = regexp.MustCompile(`(?i)</?(b|strong)>`)
+	italicRe    = regexp.MustCompile(`(?i)</?(i|em)>`)
+	tagRe       = regexp.MustCompile(`<[^>]+>`)
+	blankLineRe = regexp.MustCompile(`\n{3,}`)
+)
+
+// htmlToMarkdown turns the HTML Anki stores in fields into markdown
+func htmlToMarkdown(s string) string {
+	s = soundRe.ReplaceAllString(s, "")
+	s = imgRe.ReplaceAllString(s, "![]($1)")
+	s = breakRe.ReplaceAllString(s, "\n")
+	s = boldRe.ReplaceAllString(s, "**")
+	s = italicRe.ReplaceAllString(s, "*")
+	s = tagRe.ReplaceAllString(s, "")
+	s = html.UnescapeString(s)
+	s = strings.ReplaceAll(s, " ", " ")
+
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t\r")
+	}
+	s = strings.Join(lines, "\n")
+	s = blankLineRe.ReplaceAllString(s, "\n\n")
+	return strings.TrimSpace(s)
+}
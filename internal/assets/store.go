@@ -0,0 +1,131 @@
+package assets
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// URLPrefix is the API path under which stored assets are served
+const URLPrefix = "/api/assets/"
+
+// ErrInvalidHash is returned for hashes that are not a hex SHA-256 digest
+var ErrInvalidHash = errors.New("invalid asset hash")
+
+// Store keeps uploaded files on disk, addressed by the SHA-256 of their
+// content and namespaced per user so one user can never read another's files.
+type Store struct {
+	Root string
+}
+
+// NewStore creates a store rooted at the given directory
+func NewStore(root string) *Store {
+	return &Store{Root: root}
+}
+
+// Hash returns the content address of data
+func Hash(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// ValidHash reports whether s looks like a hash produced by Hash
+func ValidHash(s string) bool {
+	if len(s) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(s)
+	return err == nil
+}
+
+// URL returns the API URL for an asset
+func URL(hash string) string {
+	return URLPrefix + hash
+}
+
+// Referenced adds the hashes of all asset URLs in text to refs
+func Referenced(text string, refs map[string]bool) {
+	for {
+		i := strings.Index(text, URLPrefix)
+		if i < 0 {
+			return
+		}
+		text = text[i+len(URLPrefix):]
+		if n := sha256.Size * 2; len(text) >= n && ValidHash(text[:n]) {
+			refs[text[:n]] = true
+			text = text[n:]
+		}
+	}
+}
+
+// Path returns the file path of a user's asset
+func (s *Store) Path(userID uint, hash string) (string, error) {
+	if !ValidHash(hash) {
+		return "", ErrInvalidHash
+	}
+	return filepath.Join(s.Root, fmt.Sprint(userID), hash[:2], hash), nil
+}
+
+// Put stores data for a user and returns its hash. Storing the same content
+// twice is a no-op.
+func (s *Store) Put(userID uint, data []byte) (string, error) {
+	hash := Hash(data)
+	path, err := s.Path(userID, hash)
+	if err != nil {
+		return "", err
+	}
+
+	if _, err := os.Stat(path); err == nil {
+		return hash, nil
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return "", err
+	}
+
+	// Write to a temp file first so readers never see a partial asset
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".upload-*")
+	if err != nil {
+		return "", err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return "", err
+	}
+
+	return hash, nil
+}
+
+// Open opens a user's asset for reading
+func (s *Store) Open(userID uint, hash string) (*os.File, error) {
+	path, err := s.Path(userID, hash)
+	if err != nil {
+		return nil, err
+	}
+	return os.Open(path)
+}
+
+// Delete removes a user's asset. Deleting a missing asset is not an error.
+func (s *Store) Delete(userID uint, hash string) error {
+	path, err := s.Path(userID, hash)
+	if err != nil {
+		return err
+	}
+	if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
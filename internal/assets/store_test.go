@@ -0,0 +1,68 @@
+package assets
+
+import (
+	"io"
+	"os"
+	"testing"
+)
+
+func TestStore_PutOpenDelete(t *testing.T) {
+	s := NewStore(t.TempDir())
+
+	hash, err := s.Put(1, []byte("png-bytes"))
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if hash != Hash([]byte("png-bytes")) {
+		t.Errorf("unexpected hash %s", hash)
+	}
+
+	// Same content again is idempotent
+	if again, err := s.Put(1, []byte("png-bytes")); err != nil || again != hash {
+		t.Fatalf("second Put = %s, %v", again, err)
+	}
+
+	f, err := s.Open(1, hash)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	data, _ := io.ReadAll(f)
+	f.Close()
+	if string(data) != "png-bytes" {
+		t.Errorf("content mismatch: %s", data)
+	}
+
+	// Other users cannot see the asset
+	if _, err := s.Open(2, hash); !os.IsNotExist(err) {
+		t.Errorf("expected not-exist for other user, got %v", err)
+	}
+
+	if err := s.Delete(1, hash); err != nil {
+		t.Fatalf("Delete failed: %v", err)
+	}
+	if _, err := s.Open(1, hash); !os.IsNotExist(err) {
+		t.Errorf("expected not-exist after delete, got %v", err)
+	}
+	if err := s.Delete(1, hash); err != nil {
+		t.Errorf("deleting a missing asset should not fail: %v", err)
+	}
+}
+
+func TestStore_InvalidHash(t *testing.T) {
+	s := NewStore(t.TempDir())
+
+	for _, hash := range []string{"", "../../etc/passwd", "zz", Hash(nil)[:10]} {
+		if _, err := s.Open(1, hash); err != ErrInvalidHash {
+			t.Errorf("Open(%q) = %v, want ErrInvalidHash", hash, err)
+		}
+	}
+}
+
+func TestReferenced(t *testing.T) {
+	a, b := Hash([]byte("a")), Hash([]byte("b"))
+	refs := make(map[string]bool)
+	Referenced("![x]("+URL(a)+") [y]("+URL(b)+") "+URLPrefix+"short "+URL(a), refs)
+	if len(refs) != 2 || !refs[a] || !refs[b] {
+		t.Errorf("unexpected refs: %v", refs)
+	}
+}
@@ -0,0 +1,282 @@
+// Package backup defines the archive format of full account exports.
+//
+// A backup is a zip archive with
+//
+//	backup.json         the account data described by Backup
+//	sources/<path>      the user's markdown sources, relative to their question directory
+//	assets/<hash>       files referenced from questions (see internal/assets)
+//
+// The JSON schema is versioned; readers reject archives written by a newer
+// version instead of silently dropping data they do not understand.
+package backup
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"self-improvement/internal/ziparchive"
+)
+
+// Version is the schema version written by this package. Version 2 added
+// category settings, version 3 study sessions, review timings, the daily goal
+// and daily activity.
+const Version = 3
+
+// Archive entry names
+const (
+	manifestName = "backup.json"
+	sourcesDir   = "sources/"
+	assetsDir    = "assets/"
+)
+
+// ErrUnsupportedVersion is returned for archives written by a newer schema
+var ErrUnsupportedVersion = errors.New("backup was written by a newer version")
+
+// Backup is everything a user owns
+type Backup struct {
+	Version    int         `json:"version"`
+	ExportedAt time.Time   `json:"exported_at"`
+	User       User        `json:"user"`
+	Questions  []Question  `json:"questions"`
+	Categories []Category  `json:"categories"`
+	ReviewLogs []ReviewLog `json:"review_logs"`
+	Sessions   []Session   `json:"sessions,omitempty"`
+	Settings   *Settings   `json:"settings,omitempty"`
+	Activity   []Activity  `json:"activity,omitempty"`
+	Assets     []Asset     `json:"assets"`
+}
+
+// User describes the exported account
+type User struct {
+	Username  string    `json:"username"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Question is a card with its full scheduling state
+type Question struct {
+	ID           string     `json:"id"`
+	Question     string     `json:"question"`
+	Answer       string     `json:"answer"`
+	Source       string     `json:"source"`
+	Category     string     `json:"category"`
+	Tags         string     `json:"tags,omitempty"`
+	Level        int        `json:"level"`
+	Priority     int        `json:"priority,omitempty"` // 0 in older backups, restored as normal
+	NextReview   time.Time  `json:"next_review"`
+	ReviewCount  int        `json:"review_count"`
+	CorrectCount int        `json:"correct_count"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	LastReviewed *time.Time `json:"last_reviewed"`
+}
+
+// Category is a category with its settings and the number of cards filed
+// under it. Version 1 backups only hold Name and Total.
+type Category struct {
+	Name               string  `json:"name"`
+	Total              int     `json:"total"`
+	Label              string  `json:"label,omitempty"`
+	Color              string  `json:"color,omitempty"`
+	Description        string  `json:"description,omitempty"`
+	SortOrder          int     `json:"sort_order,omitempty"`
+	IntervalMultiplier float64 `json:"interval_multiplier,omitempty"`
+}
+
+// ReviewLog is one review, linked to Question.ID and, if it was made in a
+// study session, to Session.ID. Backups before version 3 carry no timings.
+type ReviewLog struct {
+	QuestionID    string    `json:"question_id"`
+	Feedback      int       `json:"feedback"`
+	IntervalHours float64   `json:"interval_hours"`
+	ReviewedAt    time.Time `json:"reviewed_at"`
+	Source        string    `json:"source"`
+	Device        string    `json:"device,omitempty"`
+	SessionID     uint      `json:"session_id,omitempty"`
+	QuestionMs    int       `json:"question_ms,omitempty"`
+	AnswerMs      int       `json:"answer_ms,omitempty"`
+	NewCard       bool      `json:"new_card,omitempty"`
+}
+
+// Session is a study session. ID only links review logs within the backup.
+type Session struct {
+	ID         uint       `json:"id"`
+	Device     string     `json:"device,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// Settings are the preferences streaks depend on
+type Settings struct {
+	Timezone   string `json:"timezone,omitempty"`
+	GoalType   string `json:"goal_type,omitempty"`
+	GoalTarget int    `json:"goal_target,omitempty"`
+}
+
+// Activity sums the reviews of one day in the user's timezone. Whether the
+// due queue was cleared cannot be told from the review logs, so it is kept
+// here for streaks and achievements.
+type Activity struct {
+	Date    string `json:"date"` // YYYY-MM-DD
+	Reviews int    `json:"reviews"`
+	Correct int    `json:"correct"`
+	StudyMs int64  `json:"study_ms"`
+	GoalMet bool   `json:"goal_met"`
+	Cleared bool   `json:"cleared"`
+}
+
+// Asset describes a file stored under assets/<hash>
+type Asset struct {
+	Hash     string `json:"hash"`
+	Name     string `json:"name"`
+	MimeType string `json:"mime_type"`
+	Size     int64  `json:"size"`
+}
+
+// Writer writes a backup archive
+type Writer struct {
+	zw *zip.Writer
+}
+
+// NewWriter starts a backup archive on w
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{zw: zip.NewWriter(w)}
+}
+
+// AddSource stores a markdown source under its path relative to the user's
+// question directory
+func (w *Writer) AddSource(name string, r io.Reader) error {
+	name, ok := cleanName(name)
+	if !ok {
+		return fmt.Errorf("invalid source path %q", name)
+	}
+	return w.add(sourcesDir+name, r)
+}
+
+// AddAsset stores the content of an asset
+func (w *Writer) AddAsset(hash string, r io.Reader) error {
+	return w.add(assetsDir+hash, r)
+}
+
+func (w *Writer) add(name string, r io.Reader) error {
+	f, err := w.zw.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	return err
+}
+
+// Close writes the manifest and finishes the archive
+func (w *Writer) Close(b *Backup) error {
+	b.Version = Version
+	f, err := w.zw.Create(manifestName)
+	if err != nil {
+		return err
+	}
+	enc := json.NewEncoder(f)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(b); err != nil {
+		return err
+	}
+	return w.zw.Close()
+}
+
+// Archive is an opened backup archive
+type Archive struct {
+	Backup *Backup
+	// Sources maps paths relative to the question directory to their entry
+	Sources map[string]*zip.File
+
+	assets map[string]*zip.File
+}
+
+// Open reads the manifest of a backup archive under the given limits;
+// ziparchive.ErrTooLarge and ziparchive.ErrTooManyEntries report archives
+// beyond them. MaxEntrySize is not applied here, callers cap each entry they
+// read.
+func Open(r io.ReaderAt, size int64, limits ziparchive.Limits) (*Archive, error) {
+	if limits.MaxSize > 0 && size > limits.MaxSize {
+		return nil, ziparchive.ErrTooLarge
+	}
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("not a valid backup: %w", err)
+	}
+	if limits.MaxEntries > 0 && len(zr.File) > limits.MaxEntries {
+		return nil, ziparchive.ErrTooManyEntries
+	}
+	var total uint64
+	for _, f := range zr.File {
+		total += f.UncompressedSize64
+	}
+	if limits.MaxUncompressed > 0 && total > uint64(limits.MaxUncompressed) {
+		return nil, ziparchive.ErrTooLarge
+	}
+
+	a := &Archive{
+		Sources: make(map[string]*zip.File),
+		assets:  make(map[string]*zip.File),
+	}
+	var manifest *zip.File
+	for _, f := range zr.File {
+		switch {
+		case f.Name == manifestName:
+			manifest = f
+		case strings.HasPrefix(f.Name, sourcesDir):
+			if name, ok := cleanName(strings.TrimPrefix(f.Name, sourcesDir)); ok && !f.FileInfo().IsDir() {
+				a.Sources[name] = f
+			}
+		case strings.HasPrefix(f.Name, assetsDir):
+			a.assets[strings.TrimPrefix(f.Name, assetsDir)] = f
+		}
+	}
+	if manifest == nil {
+		return nil, errors.New("not a valid backup: backup.json not found")
+	}
+
+	rc, err := manifest.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	// Declared sizes can be forged, so the manifest is read under the limit too
+	var manifestReader io.Reader = rc
+	if limits.MaxUncompressed > 0 {
+		manifestReader = io.LimitReader(rc, limits.MaxUncompressed)
+	}
+	var b Backup
+	if err := json.NewDecoder(manifestReader).Decode(&b); err != nil {
+		return nil, fmt.Errorf("not a valid backup: %w", err)
+	}
+	if b.Version > Version {
+		return nil, ErrUnsupportedVersion
+	}
+	a.Backup = &b
+	return a, nil
+}
+
+// Asset returns the stored content of an asset
+func (a *Archive) Asset(hash string) (io.ReadCloser, error) {
+	f, ok := a.assets[hash]
+	if !ok {
+		return nil, fmt.Errorf("asset %s not in backup", hash)
+	}
+	return f.Open()
+}
+
+// cleanName normalizes an archive path and rejects paths that would escape
+// the directory they are extracted to
+func cleanName(name string) (string, bool) {
+	name = path.Clean(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || path.IsAbs(name) || name == ".." || strings.HasPrefix(name, "../") {
+		return "", false
+	}
+	return name, true
+}
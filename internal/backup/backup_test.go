@@ -0,0 +1,126 @@
+package backup
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"self-improvement/internal/ziparchive"
+)
+
+func TestWriteOpen_RoundTrip(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+	if err := w.AddSource("go/gmp.md", strings.NewReader("# q\nGMP?\n# a\n调度模型")); err != nil {
+		t.Fatalf("AddSource failed: %v", err)
+	}
+	if err := w.AddAsset("abc", strings.NewReader("png")); err != nil {
+		t.Fatalf("AddAsset failed: %v", err)
+	}
+
+	next := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	err := w.Close(&Backup{
+		User:      User{Username: "alice"},
+		Questions: []Question{{ID: "q1", Question: "GMP?", Answer: "调度模型", Level: 2, NextReview: next}},
+	})
+	if err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+
+	a, err := Open(bytes.NewReader(buf.Bytes()), int64(buf.Len()), ziparchive.Limits{})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if a.Backup.Version != Version || a.Backup.User.Username != "alice" {
+		t.Errorf("manifest mismatch: %+v", a.Backup)
+	}
+	if q := a.Backup.Questions[0]; q.Level != 2 || !q.NextReview.Equal(next) {
+		t.Errorf("scheduling state lost: %+v", q)
+	}
+	if _, ok := a.Sources["go/gmp.md"]; !ok {
+		t.Errorf("source missing: %v", a.Sources)
+	}
+
+	rc, err := a.Asset("abc")
+	if err != nil {
+		t.Fatalf("Asset failed: %v", err)
+	}
+	data, _ := io.ReadAll(rc)
+	rc.Close()
+	if string(data) != "png" {
+		t.Errorf("asset mismatch: %q", data)
+	}
+	if _, err := a.Asset("missing"); err == nil {
+		t.Error("expected error for missing asset")
+	}
+}
+
+func writeZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range files {
+		f, _ := zw.Create(name)
+		f.Write([]byte(content))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func TestOpen_Invalid(t *testing.T) {
+	data := writeZip(t, map[string]string{"other.json": "{}"})
+	if _, err := Open(bytes.NewReader(data), int64(len(data)), ziparchive.Limits{}); err == nil {
+		t.Error("expected error without manifest")
+	}
+
+	manifest, _ := json.Marshal(Backup{Version: Version + 1})
+	data = writeZip(t, map[string]string{"backup.json": string(manifest)})
+	if _, err := Open(bytes.NewReader(data), int64(len(data)), ziparchive.Limits{}); !errors.Is(err, ErrUnsupportedVersion) {
+		t.Errorf("expected ErrUnsupportedVersion, got %v", err)
+	}
+}
+
+func TestOpen_RejectsEscapingSources(t *testing.T) {
+	manifest, _ := json.Marshal(Backup{Version: Version})
+	data := writeZip(t, map[string]string{
+		"backup.json":         string(manifest),
+		"sources/../evil.md":  "x",
+		"sources/ok/../a.md":  "y",
+		"sources//etc/passwd": "z",
+	})
+
+	a, err := Open(bytes.NewReader(data), int64(len(data)), ziparchive.Limits{})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if len(a.Sources) != 1 {
+		t.Fatalf("unexpected sources: %v", a.Sources)
+	}
+	if _, ok := a.Sources["a.md"]; !ok {
+		t.Errorf("expected cleaned path a.md: %v", a.Sources)
+	}
+}
+
+func TestOpen_Limits(t *testing.T) {
+	manifest, _ := json.Marshal(Backup{Version: Version})
+	data := writeZip(t, map[string]string{
+		"backup.json":  string(manifest),
+		"sources/a.md": strings.Repeat("x", 2048),
+		"sources/b.md": "y",
+	})
+
+	if _, err := Open(bytes.NewReader(data), int64(len(data)), ziparchive.Limits{MaxEntries: 2}); !errors.Is(err, ziparchive.ErrTooManyEntries) {
+		t.Errorf("expected ErrTooManyEntries, got %v", err)
+	}
+	if _, err := Open(bytes.NewReader(data), int64(len(data)), ziparchive.Limits{MaxUncompressed: 1024}); !errors.Is(err, ziparchive.ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge, got %v", err)
+	}
+	if _, err := Open(bytes.NewReader(data), int64(len(data)), ziparchive.Limits{MaxSize: 16}); !errors.Is(err, ziparchive.ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge for the archive size, got %v", err)
+	}
+}
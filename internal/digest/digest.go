@@ -0,0 +1,170 @@
+// Package digest builds the daily due-digest email and sends it over SMTP.
+//
+// A digest lists how many cards are due in each top-level category, the
+// user's streak and a few of their hardest due cards. It is sent once a day
+// at a local time the user picks, and every digest carries a link that turns
+// it off without logging in.
+package digest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	htmltemplate "html/template"
+	"strings"
+	"text/template"
+	"time"
+
+	"self-improvement/internal/streaks"
+)
+
+// DefaultTime is the local time digests are sent at unless the user picks
+// another
+const DefaultTime = "08:00"
+
+// TimeLayout is the format of send times
+const TimeLayout = "15:04"
+
+// ValidTime reports whether s is a send time such as "07:30"
+func ValidTime(s string) bool {
+	t, err := time.Parse(TimeLayout, s)
+	return err == nil && t.Format(TimeLayout) == s
+}
+
+// Due returns the date at now in loc and whether the send time at, empty for
+// DefaultTime, has passed on that date
+func Due(now time.Time, loc *time.Location, at string) (date string, due bool) {
+	if at == "" {
+		at = DefaultTime
+	}
+	local := now.In(loc)
+	return local.Format(streaks.DateLayout), local.Format(TimeLayout) >= at
+}
+
+// NewToken returns a random secret for unsubscribe links
+func NewToken() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Category is a top-level category with cards due
+type Category struct {
+	Label string
+	Due   int64
+}
+
+// Card is one of the hardest due cards
+type Card struct {
+	Question string
+	Category string
+	Level    int
+}
+
+// Digest is the content of one daily digest
+type Digest struct {
+	Username       string
+	Date           string
+	Due            int64
+	Categories     []Category
+	Hardest        []Card
+	Goal           streaks.Goal
+	Today          streaks.Day
+	CurrentStreak  int
+	LongestStreak  int
+	AppURL         string
+	UnsubscribeURL string
+}
+
+// Subject returns the subject line of the digest
+func (d *Digest) Subject() string {
+	subject := fmt.Sprintf("今日待复习 %d 张卡片", d.Due)
+	if d.CurrentStreak > 0 {
+		subject += fmt.Sprintf("，已连续学习 %d 天", d.CurrentStreak)
+	}
+	return subject
+}
+
+// Streak describes the streak and what today still needs
+func (d *Digest) Streak() string {
+	switch {
+	case d.Today.Counts():
+		return fmt.Sprintf("今天已完成目标，连续学习 %d 天。", d.CurrentStreak)
+	case d.CurrentStreak > 0:
+		return fmt.Sprintf("已连续学习 %d 天，今天%s即可保持连续记录。", d.CurrentStreak, goalText(d.Goal))
+	case d.LongestStreak > 0:
+		return fmt.Sprintf("连续记录已中断（最长 %d 天），今天%s即可重新开始。", d.LongestStreak, goalText(d.Goal))
+	default:
+		return fmt.Sprintf("今天%s，开始你的第一个连续学习日。", goalText(d.Goal))
+	}
+}
+
+func goalText(g streaks.Goal) string {
+	if g.Type == streaks.GoalMinutes {
+		return fmt.Sprintf("学习 %d 分钟", g.Target)
+	}
+	return fmt.Sprintf("复习 %d 张卡片", g.Target)
+}
+
+// levelNames are the names of the memory levels
+var levelNames = map[int]string{1: "熟练", 2: "一般", 3: "忘记", 4: "完全忘记"}
+
+var funcs = map[string]interface{}{
+	"level": func(l int) string { return levelNames[l] },
+}
+
+var textTemplate = template.Must(template.New("text").Funcs(funcs).Parse(`{{.Username}}，你好：
+
+{{.Date}} 共有 {{.Due}} 张卡片待复习。
+{{range .Categories}}
+  {{.Label}}：{{.Due}} 张{{end}}
+
+{{.Streak}}
+{{if .Hardest}}
+最难的待复习卡片：
+{{range .Hardest}}
+  - {{.Question}}（{{.Category}}，{{level .Level}}）{{end}}
+{{end}}
+开始复习：{{.AppURL}}
+
+不想再收到每日摘要？退订：{{.UnsubscribeURL}}
+`))
+
+var htmlTemplate = htmltemplate.Must(htmltemplate.New("html").Funcs(funcs).Parse(`<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif; color: #333;">
+<p>{{.Username}}，你好：</p>
+<p>{{.Date}} 共有 <strong>{{.Due}}</strong> 张卡片待复习。</p>
+<table cellpadding="4">
+{{range .Categories}}<tr><td>{{.Label}}</td><td align="right">{{.Due}} 张</td></tr>
+{{end}}</table>
+<p>{{.Streak}}</p>
+{{if .Hardest}}<p>最难的待复习卡片：</p>
+<ul>
+{{range .Hardest}}<li>{{.Question}} <small>（{{.Category}}，{{level .Level}}）</small></li>
+{{end}}</ul>
+{{end}}<p><a href="{{.AppURL}}">开始复习</a></p>
+<p style="font-size: 12px; color: #999;">不想再收到每日摘要？<a href="{{.UnsubscribeURL}}">退订</a></p>
+</body>
+</html>
+`))
+
+// Message renders the digest as an email from from to to
+func (d *Digest) Message(from, to string) (*Message, error) {
+	var text, html bytes.Buffer
+	if err := textTemplate.Execute(&text, d); err != nil {
+		return nil, err
+	}
+	if err := htmlTemplate.Execute(&html, d); err != nil {
+		return nil, err
+	}
+	return &Message{
+		From:        from,
+		To:          to,
+		Subject:     d.Subject(),
+		Text:        strings.TrimSpace(text.String()) + "\n",
+		HTML:        html.String(),
+		Unsubscribe: d.UnsubscribeURL,
+	}, nil
+}
@@ -0,0 +1,234 @@
+package digest
+
+import (
+	"bytes"
+	"encoding/base64"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"self-improvement/internal/streaks"
+)
+
+// smtpServer is a minimal SMTP server that accepts every message
+type smtpServer struct {
+	ln   net.Listener
+	mu   sync.Mutex
+	from string
+	to   []string
+	data []byte
+}
+
+func startSMTP(t *testing.T) *smtpServer {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed: %v", err)
+	}
+	s := &smtpServer{ln: ln}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(textproto.NewConn(conn))
+		}
+	}()
+	return s
+}
+
+func (s *smtpServer) serve(c *textproto.Conn) {
+	defer c.Close()
+	c.PrintfLine("220 localhost ESMTP")
+	for {
+		line, err := c.ReadLine()
+		if err != nil {
+			return
+		}
+		cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+		switch cmd {
+		case "EHLO", "HELO":
+			c.PrintfLine("250-localhost")
+			c.PrintfLine("250 8BITMIME")
+		case "MAIL":
+			s.mu.Lock()
+			s.from = pathOf(line)
+			s.mu.Unlock()
+			c.PrintfLine("250 OK")
+		case "RCPT":
+			s.mu.Lock()
+			s.to = append(s.to, pathOf(line))
+			s.mu.Unlock()
+			c.PrintfLine("250 OK")
+		case "DATA":
+			c.PrintfLine("354 Go ahead")
+			data, err := c.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			s.mu.Lock()
+			s.data = data
+			s.mu.Unlock()
+			c.PrintfLine("250 OK")
+		case "QUIT":
+			c.PrintfLine("221 Bye")
+			return
+		default:
+			c.PrintfLine("502 Not implemented")
+		}
+	}
+}
+
+// pathOf returns the address in the angle brackets of a MAIL or RCPT command
+func pathOf(line string) string {
+	start, end := strings.Index(line, "<"), strings.Index(line, ">")
+	if start < 0 || end < start {
+		return ""
+	}
+	return line[start+1 : end]
+}
+
+func sampleDigest() *Digest {
+	return &Digest{
+		Username:       "alice",
+		Date:           "2026-10-19",
+		Due:            12,
+		Categories:     []Category{{Label: "存储", Due: 9}, {Label: "编程语言", Due: 3}},
+		Hardest:        []Card{{Question: "<b>CRUSH</b> 是什么？", Category: "存储/ceph", Level: 4}},
+		Goal:           streaks.Goal{Type: streaks.GoalCards, Target: 20},
+		CurrentStreak:  3,
+		LongestStreak:  5,
+		AppURL:         "http://localhost:4430",
+		UnsubscribeURL: "http://localhost:4430/api/digest/unsubscribe?token=abc",
+	}
+}
+
+// parts returns the decoded bodies of a message by content type
+func parts(t *testing.T, msg *mail.Message) map[string]string {
+	t.Helper()
+	_, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	if err != nil {
+		t.Fatalf("bad content type: %v", err)
+	}
+	bodies := make(map[string]string)
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		p, err := r.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatalf("bad part: %v", err)
+		}
+		raw, _ := io.ReadAll(base64.NewDecoder(base64.StdEncoding, p))
+		typ, _, _ := mime.ParseMediaType(p.Header.Get("Content-Type"))
+		bodies[typ] = string(raw)
+	}
+	return bodies
+}
+
+func TestDue(t *testing.T) {
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	now := time.Date(2026, 10, 19, 0, 30, 0, 0, time.UTC) // 08:30 in Shanghai
+
+	date, due := Due(now, shanghai, "08:00")
+	if date != "2026-10-19" || !due {
+		t.Errorf("expected due on 2026-10-19, got %s %v", date, due)
+	}
+	if _, due := Due(now, shanghai, "09:00"); due {
+		t.Error("expected not due before the send time")
+	}
+	if _, due := Due(now, shanghai, ""); !due {
+		t.Error("expected the default time to have passed")
+	}
+	if date, _ := Due(now, time.UTC, ""); date != "2026-10-19" {
+		t.Errorf("unexpected UTC date %s", date)
+	}
+}
+
+func TestValidTime(t *testing.T) {
+	for s, want := range map[string]bool{"08:00": true, "23:59": true, "8:00": false, "24:00": false, "08:60": false, "": false} {
+		if ValidTime(s) != want {
+			t.Errorf("ValidTime(%q): expected %v", s, want)
+		}
+	}
+}
+
+func TestStreak(t *testing.T) {
+	d := sampleDigest()
+	if s := d.Streak(); !strings.Contains(s, "连续学习 3 天") || !strings.Contains(s, "复习 20 张卡片") {
+		t.Errorf("unexpected streak line %q", s)
+	}
+	d.Today = streaks.Day{Reviews: 20, GoalMet: true}
+	if s := d.Streak(); !strings.HasPrefix(s, "今天已完成目标") {
+		t.Errorf("unexpected streak line once the goal is met %q", s)
+	}
+	d.Today, d.CurrentStreak = streaks.Day{}, 0
+	if s := d.Streak(); !strings.Contains(s, "最长 5 天") {
+		t.Errorf("unexpected streak line after a break %q", s)
+	}
+}
+
+func TestMessage(t *testing.T) {
+	m, err := sampleDigest().Message(DefaultFrom, "alice@example.com")
+	if err != nil {
+		t.Fatalf("Message failed: %v", err)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(m.Bytes()))
+	if err != nil {
+		t.Fatalf("invalid message: %v", err)
+	}
+	subject, _ := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if subject != "今日待复习 12 张卡片，已连续学习 3 天" {
+		t.Errorf("unexpected subject %q", subject)
+	}
+	if msg.Header.Get("List-Unsubscribe") != "<http://localhost:4430/api/digest/unsubscribe?token=abc>" {
+		t.Errorf("unexpected List-Unsubscribe %q", msg.Header.Get("List-Unsubscribe"))
+	}
+
+	bodies := parts(t, msg)
+	text, html := bodies["text/plain"], bodies["text/html"]
+	if !strings.Contains(text, "存储：9 张") || !strings.Contains(text, "<b>CRUSH</b> 是什么？（存储/ceph，完全忘记）") {
+		t.Errorf("unexpected text body:\n%s", text)
+	}
+	if !strings.Contains(html, "&lt;b&gt;CRUSH&lt;/b&gt;") || !strings.Contains(html, `href="http://localhost:4430/api/digest/unsubscribe?token=abc"`) {
+		t.Errorf("unexpected html body:\n%s", html)
+	}
+}
+
+func TestSMTPMailer(t *testing.T) {
+	srv := startSMTP(t)
+	host, port, _ := net.SplitHostPort(srv.ln.Addr().String())
+	p, _ := strconv.Atoi(port)
+	mailer := &SMTPMailer{Config: Config{Host: host, Port: p}}
+
+	m, _ := sampleDigest().Message("Study <study@example.com>", "alice@example.com")
+	if err := mailer.Send(m); err != nil {
+		t.Fatalf("Send failed: %v", err)
+	}
+	srv.mu.Lock()
+	defer srv.mu.Unlock()
+	if srv.from != "study@example.com" || len(srv.to) != 1 || srv.to[0] != "alice@example.com" {
+		t.Errorf("unexpected envelope from %q to %v", srv.from, srv.to)
+	}
+	msg, err := mail.ReadMessage(bytes.NewReader(srv.data))
+	if err != nil || msg.Header.Get("To") != "alice@example.com" {
+		t.Errorf("unexpected message received: %v %s", err, srv.data)
+	}
+
+	// Authentication needs a server that offers it
+	mailer.Config.Username = "user"
+	if err := mailer.Send(m); err == nil {
+		t.Error("expected an error without AUTH support")
+	}
+}
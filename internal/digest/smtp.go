@@ -0,0 +1,179 @@
+package digest
+
+import (
+	"bytes"
+	"crypto/tls"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Default SMTP settings
+const (
+	DefaultPort = 587
+	DefaultFrom = "self-improvement <noreply@localhost>"
+	// DefaultBaseURL is where links in emails point unless PUBLIC_URL is set
+	DefaultBaseURL = "http://localhost:4430"
+)
+
+// timeout bounds a whole SMTP conversation
+const timeout = 30 * time.Second
+
+// Config is the SMTP server emails are sent through
+type Config struct {
+	Host     string
+	Port     int
+	Username string // Empty to send without authentication
+	Password string
+	From     string
+	BaseURL  string // Public URL of the server, for links in emails
+}
+
+// ConfigFromEnv reads SMTP_HOST, SMTP_PORT, SMTP_USERNAME, SMTP_PASSWORD,
+// SMTP_FROM and PUBLIC_URL
+func ConfigFromEnv() Config {
+	c := Config{
+		Host:     os.Getenv("SMTP_HOST"),
+		Port:     DefaultPort,
+		Username: os.Getenv("SMTP_USERNAME"),
+		Password: os.Getenv("SMTP_PASSWORD"),
+		From:     os.Getenv("SMTP_FROM"),
+		BaseURL:  os.Getenv("PUBLIC_URL"),
+	}
+	if p, err := strconv.Atoi(os.Getenv("SMTP_PORT")); err == nil && p > 0 {
+		c.Port = p
+	}
+	if c.From == "" {
+		c.From = DefaultFrom
+	}
+	if c.BaseURL == "" {
+		c.BaseURL = DefaultBaseURL
+	}
+	return c
+}
+
+// Message is an email with a plain text and an HTML body
+type Message struct {
+	From        string
+	To          string
+	Subject     string
+	Text        string
+	HTML        string
+	Unsubscribe string // URL for the List-Unsubscribe header, empty for none
+}
+
+// Bytes returns the message in MIME format
+func (m *Message) Bytes() []byte {
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, p := range []struct{ typ, content string }{{"text/plain", m.Text}, {"text/html", m.HTML}} {
+		w, _ := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {p.typ + "; charset=utf-8"},
+			"Content-Transfer-Encoding": {"base64"},
+		})
+		writeBase64(w, []byte(p.content))
+	}
+	parts.Close()
+
+	var msg bytes.Buffer
+	header := func(k, v string) { fmt.Fprintf(&msg, "%s: %s\r\n", k, v) }
+	header("From", m.From)
+	header("To", m.To)
+	header("Subject", mime.QEncoding.Encode("utf-8", m.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+	if m.Unsubscribe != "" {
+		header("List-Unsubscribe", "<"+m.Unsubscribe+">")
+		header("List-Unsubscribe-Post", "List-Unsubscribe=One-Click")
+	}
+	header("Content-Type", "multipart/alternative; boundary="+parts.Boundary())
+	msg.WriteString("\r\n")
+	msg.Write(body.Bytes())
+	return msg.Bytes()
+}
+
+// writeBase64 writes b base64 encoded in lines of 76 characters
+func writeBase64(w io.Writer, b []byte) {
+	s := base64.StdEncoding.EncodeToString(b)
+	for len(s) > 76 {
+		io.WriteString(w, s[:76]+"\r\n")
+		s = s[76:]
+	}
+	io.WriteString(w, s+"\r\n")
+}
+
+// Mailer sends emails
+type Mailer interface {
+	Send(m *Message) error
+}
+
+// SMTPMailer sends emails through an SMTP server. It upgrades the connection
+// with STARTTLS when the server offers it, so a plain local test server such
+// as Mailpit or MailHog works too.
+type SMTPMailer struct {
+	Config Config
+}
+
+// Send delivers the message to its recipient
+func (s *SMTPMailer) Send(m *Message) error {
+	from, err := mail.ParseAddress(m.From)
+	if err != nil {
+		return fmt.Errorf("invalid sender: %w", err)
+	}
+	addr := net.JoinHostPort(s.Config.Host, strconv.Itoa(s.Config.Port))
+	conn, err := net.DialTimeout("tcp", addr, timeout)
+	if err != nil {
+		return err
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	c, err := smtp.NewClient(conn, s.Config.Host)
+	if err != nil {
+		conn.Close()
+		return err
+	}
+	defer c.Close()
+
+	if err := c.Hello("localhost"); err != nil {
+		return err
+	}
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err := c.StartTLS(&tls.Config{ServerName: s.Config.Host}); err != nil {
+			return err
+		}
+	}
+	if s.Config.Username != "" {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return errors.New("smtp server does not support authentication")
+		}
+		if err := c.Auth(smtp.PlainAuth("", s.Config.Username, s.Config.Password, s.Config.Host)); err != nil {
+			return err
+		}
+	}
+	if err := c.Mail(from.Address); err != nil {
+		return err
+	}
+	if err := c.Rcpt(m.To); err != nil {
+		return err
+	}
+	w, err := c.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(m.Bytes()); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return c.Quit()
+}
@@ -0,0 +1,136 @@
+// Package gitrepo reads files and history from a local git repository by
+// running the git command line tool. Files are always read from commits,
+// never from the working tree, so uncommitted edits are ignored.
+package gitrepo
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// ErrNotRepository is returned by Open for paths outside a git work tree
+var ErrNotRepository = errors.New("not a git repository")
+
+// Repo is a local git repository
+type Repo struct {
+	Path string
+}
+
+// Change is one file changed between two commits
+type Change struct {
+	// Status is 'A' (added), 'M' (modified) or 'D' (deleted). Renames are
+	// reported as a deletion of the old path and an addition of the new one.
+	Status byte
+	Path   string
+}
+
+// Open checks that path is a git repository
+func Open(path string) (*Repo, error) {
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return nil, err
+	}
+	r := &Repo{Path: abs}
+	if _, err := r.git("rev-parse", "--git-dir"); err != nil {
+		return nil, ErrNotRepository
+	}
+	return r, nil
+}
+
+func (r *Repo) git(args ...string) ([]byte, error) {
+	cmd := exec.Command("git", append([]string{"-C", r.Path}, args...)...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg == "" {
+			msg = err.Error()
+		}
+		return nil, fmt.Errorf("git %s: %s", args[0], msg)
+	}
+	return out, nil
+}
+
+// CurrentBranch returns the branch checked out in the work tree
+func (r *Repo) CurrentBranch() (string, error) {
+	out, err := r.git("symbolic-ref", "--short", "HEAD")
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// Head returns the commit hash the branch points to
+func (r *Repo) Head(branch string) (string, error) {
+	if strings.HasPrefix(branch, "-") {
+		return "", fmt.Errorf("invalid branch %q", branch)
+	}
+	out, err := r.git("rev-parse", "--verify", "--quiet", branch+"^{commit}")
+	if err != nil {
+		return "", fmt.Errorf("branch %q not found", branch)
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+// IsAncestor reports whether ancestor is reachable from commit
+func (r *Repo) IsAncestor(ancestor, commit string) bool {
+	_, err := r.git("merge-base", "--is-ancestor", ancestor, commit)
+	return err == nil
+}
+
+// ListFiles returns all file paths in a commit
+func (r *Repo) ListFiles(commit string) ([]string, error) {
+	out, err := r.git("ls-tree", "-r", "-z", "--name-only", commit)
+	if err != nil {
+		return nil, err
+	}
+	return splitNull(out), nil
+}
+
+// Diff returns the files changed between two commits
+func (r *Repo) Diff(from, to string) ([]Change, error) {
+	out, err := r.git("diff", "--name-status", "-z", "--no-renames", from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	fields := splitNull(out)
+	var changes []Change
+	for i := 0; i+1 < len(fields); i += 2 {
+		status := fields[i][0]
+		if status != 'A' && status != 'D' {
+			status = 'M'
+		}
+		changes = append(changes, Change{Status: status, Path: fields[i+1]})
+	}
+	return changes, nil
+}
+
+// ReadFile returns the content of a file at a commit
+func (r *Repo) ReadFile(commit, path string) ([]byte, error) {
+	return r.git("show", commit+":"+path)
+}
+
+// LastChange returns the last commit up to commit that touched path
+func (r *Repo) LastChange(commit, path string) (string, error) {
+	out, err := r.git("log", "-1", "--format=%H", commit, "--", path)
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func splitNull(out []byte) []string {
+	var fields []string
+	for _, f := range strings.Split(string(out), "\x00") {
+		if f != "" {
+			fields = append(fields, f)
+		}
+	}
+	return fields
+}
@@ -0,0 +1,96 @@
+package gitrepo
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func run(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func commitFile(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	os.MkdirAll(filepath.Dir(path), 0755)
+	os.WriteFile(path, []byte(content), 0644)
+	run(t, dir, "add", "-A")
+	run(t, dir, "commit", "-q", "-m", "update "+name)
+	return run(t, dir, "rev-parse", "HEAD")
+}
+
+func TestRepo(t *testing.T) {
+	dir := t.TempDir()
+	run(t, dir, "init", "-q", "-b", "main")
+
+	first := commitFile(t, dir, "go/a.md", "a1")
+	commitFile(t, dir, "go/b.md", "b1")
+
+	repo, err := Open(dir)
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if branch, _ := repo.CurrentBranch(); branch != "main" {
+		t.Errorf("expected main, got %q", branch)
+	}
+
+	os.Remove(filepath.Join(dir, "go", "b.md"))
+	run(t, dir, "add", "-A")
+	run(t, dir, "commit", "-q", "-m", "remove b")
+	head := commitFile(t, dir, "go/a.md", "a2")
+
+	if h, err := repo.Head("main"); err != nil || h != head {
+		t.Errorf("Head = %q, %v", h, err)
+	}
+	if _, err := repo.Head("nope"); err == nil {
+		t.Error("expected error for unknown branch")
+	}
+	if !repo.IsAncestor(first, head) || repo.IsAncestor(head, first) {
+		t.Error("IsAncestor mismatch")
+	}
+
+	files, _ := repo.ListFiles(head)
+	if len(files) != 1 || files[0] != "go/a.md" {
+		t.Errorf("unexpected files: %v", files)
+	}
+
+	changes, err := repo.Diff(first, head)
+	if err != nil {
+		t.Fatalf("Diff failed: %v", err)
+	}
+	got := map[string]byte{}
+	for _, c := range changes {
+		got[c.Path] = c.Status
+	}
+	if len(got) != 1 || got["go/a.md"] != 'M' {
+		t.Errorf("unexpected changes: %v", got)
+	}
+
+	if content, _ := repo.ReadFile(first, "go/a.md"); string(content) != "a1" {
+		t.Errorf("unexpected content at first commit: %q", content)
+	}
+	if last, _ := repo.LastChange(head, "go/a.md"); last != head {
+		t.Errorf("LastChange = %q, want %q", last, head)
+	}
+
+	// Uncommitted edits are ignored
+	os.WriteFile(filepath.Join(dir, "go", "a.md"), []byte("dirty"), 0644)
+	if content, _ := repo.ReadFile(head, "go/a.md"); string(content) != "a2" {
+		t.Errorf("working tree leaked into ReadFile: %q", content)
+	}
+}
+
+func TestOpen_NotRepository(t *testing.T) {
+	if _, err := Open(t.TempDir()); err != ErrNotRepository {
+		t.Errorf("expected ErrNotRepository, got %v", err)
+	}
+}
@@ -0,0 +1,126 @@
+// Package ical writes calendars of all-day events in the iCalendar format
+// (RFC 5545), for feeds that calendar apps subscribe to.
+package ical
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DateLayout is the format of Event.Date
+const DateLayout = "2006-01-02"
+
+// maxLine is the longest content line in octets before it is folded
+const maxLine = 75
+
+// Event is an all-day event
+type Event struct {
+	UID         string // Stable across feed refreshes so apps update the event in place
+	Date        string // YYYY-MM-DD
+	Summary     string
+	Description string
+	// Alarm reminds this long before the start of the day, 0 for no reminder
+	Alarm time.Duration
+}
+
+// Calendar is a named list of events
+type Calendar struct {
+	Name    string
+	Refresh time.Duration // How often apps should refetch the feed, 0 to leave it to them
+	Events  []Event
+}
+
+// Bytes returns the calendar in iCalendar format. stamp is the time the feed
+// was generated.
+func (c *Calendar) Bytes(stamp time.Time) []byte {
+	var b bytes.Buffer
+	line := func(s string) { writeFolded(&b, s) }
+	line("BEGIN:VCALENDAR")
+	line("VERSION:2.0")
+	line("PRODID:-//self-improvement//KnowLoop//ZH")
+	line("CALSCALE:GREGORIAN")
+	line("METHOD:PUBLISH")
+	if c.Name != "" {
+		line("X-WR-CALNAME:" + Escape(c.Name))
+	}
+	if c.Refresh > 0 {
+		line("REFRESH-INTERVAL;VALUE=DURATION:" + Duration(c.Refresh))
+		line("X-PUBLISHED-TTL:" + Duration(c.Refresh))
+	}
+	dtstamp := stamp.UTC().Format("20060102T150405Z")
+	for _, e := range c.Events {
+		start, err := time.Parse(DateLayout, e.Date)
+		if err != nil {
+			continue
+		}
+		line("BEGIN:VEVENT")
+		line("UID:" + Escape(e.UID))
+		line("DTSTAMP:" + dtstamp)
+		line("DTSTART;VALUE=DATE:" + start.Format("20060102"))
+		line("DTEND;VALUE=DATE:" + start.AddDate(0, 0, 1).Format("20060102"))
+		line("SUMMARY:" + Escape(e.Summary))
+		if e.Description != "" {
+			line("DESCRIPTION:" + Escape(e.Description))
+		}
+		line("TRANSP:TRANSPARENT")
+		if e.Alarm > 0 {
+			line("BEGIN:VALARM")
+			line("ACTION:DISPLAY")
+			line("DESCRIPTION:" + Escape(e.Summary))
+			line("TRIGGER:-" + Duration(e.Alarm))
+			line("END:VALARM")
+		}
+		line("END:VEVENT")
+	}
+	line("END:VCALENDAR")
+	return b.Bytes()
+}
+
+// Escape escapes a TEXT value
+func Escape(s string) string {
+	return strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`).Replace(s)
+}
+
+// Duration formats a positive duration of whole minutes, e.g. "PT15H" or
+// "P1DT30M"
+func Duration(d time.Duration) string {
+	days := int(d / (24 * time.Hour))
+	d -= time.Duration(days) * 24 * time.Hour
+	hours := int(d / time.Hour)
+	minutes := int((d - time.Duration(hours)*time.Hour) / time.Minute)
+
+	var b strings.Builder
+	b.WriteString("P")
+	if days > 0 {
+		b.WriteString(strconv.Itoa(days) + "D")
+	}
+	if hours > 0 || minutes > 0 || days == 0 {
+		b.WriteString("T")
+		if hours > 0 {
+			b.WriteString(strconv.Itoa(hours) + "H")
+		}
+		if minutes > 0 || hours == 0 {
+			b.WriteString(strconv.Itoa(minutes) + "M")
+		}
+	}
+	return b.String()
+}
+
+// writeFolded writes a content line, folded into lines of at most maxLine
+// octets without splitting a UTF-8 character, and ends it with CRLF
+func writeFolded(b *bytes.Buffer, s string) {
+	limit := maxLine
+	for len(s) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(s[cut]) {
+			cut--
+		}
+		b.WriteString(s[:cut] + "\r\n ")
+		s = s[cut:]
+		limit = maxLine - 1 // The leading space counts
+	}
+	b.WriteString(s + "\r\n")
+}
@@ -0,0 +1,75 @@
+package ical
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBytes(t *testing.T) {
+	c := &Calendar{
+		Name:    "KnowLoop 复习计划",
+		Refresh: time.Hour,
+		Events: []Event{
+			{UID: "review-2026-10-19@knowloop", Date: "2026-10-19", Summary: "KnowLoop: 42 reviews (存储 12, 编程语言 30)"},
+			{UID: "exam-1@knowloop", Date: "2026-12-31", Summary: "考试", Description: "范围;第一行\n第二行", Alarm: 15 * time.Hour},
+			{UID: "bad", Date: "31/12/2026", Summary: "ignored"},
+		},
+	}
+	out := string(c.Bytes(time.Date(2026, 10, 19, 1, 2, 3, 0, time.UTC)))
+
+	for _, want := range []string{
+		"BEGIN:VCALENDAR\r\nVERSION:2.0\r\n",
+		"X-WR-CALNAME:KnowLoop 复习计划\r\n",
+		"REFRESH-INTERVAL;VALUE=DURATION:PT1H\r\n",
+		"DTSTAMP:20261019T010203Z\r\n",
+		"DTSTART;VALUE=DATE:20261019\r\nDTEND;VALUE=DATE:20261020\r\n",
+		`SUMMARY:KnowLoop: 42 reviews (存储 12\, 编程语言 30)` + "\r\n",
+		`DESCRIPTION:范围\;第一行\n第二行` + "\r\n",
+		"TRIGGER:-PT15H\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("expected %q in:\n%s", want, out)
+		}
+	}
+	if strings.Count(out, "BEGIN:VEVENT") != 2 || strings.Contains(out, "ignored") {
+		t.Errorf("expected the event with an invalid date to be left out:\n%s", out)
+	}
+}
+
+func TestFolding(t *testing.T) {
+	c := &Calendar{Events: []Event{{UID: "x", Date: "2026-10-19", Summary: strings.Repeat("复习", 40)}}}
+	out := string(c.Bytes(time.Now()))
+	var unfolded []string
+	for _, l := range strings.Split(strings.TrimSuffix(out, "\r\n"), "\r\n") {
+		if len(l) > maxLine {
+			t.Errorf("line longer than %d octets: %q", maxLine, l)
+		}
+		if strings.HasPrefix(l, " ") {
+			unfolded[len(unfolded)-1] += l[1:]
+			continue
+		}
+		unfolded = append(unfolded, l)
+	}
+	found := false
+	for _, l := range unfolded {
+		found = found || l == "SUMMARY:"+strings.Repeat("复习", 40)
+	}
+	if !found {
+		t.Errorf("folded summary does not unfold to the original:\n%s", out)
+	}
+}
+
+func TestDuration(t *testing.T) {
+	for d, want := range map[time.Duration]string{
+		15 * time.Hour:                "PT15H",
+		24 * time.Hour:                "P1D",
+		26*time.Hour + 30*time.Minute: "P1DT2H30M",
+		45 * time.Minute:              "PT45M",
+	} {
+		if got := Duration(d); got != want {
+			t.Errorf("Duration(%v): expected %s, got %s", d, want, got)
+		}
+	}
+}
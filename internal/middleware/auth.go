@@ -27,6 +27,13 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
+// TokenLifetime is how long a login token stays valid
+const TokenLifetime = 24 * time.Hour
+
+// AssetCookie carries the login token for requests that cannot set headers,
+// such as <img> tags loading /api/assets
+const AssetCookie = "asset_token"
+
 // AuthMiddleware validates JWT token
 func AuthMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -48,26 +55,59 @@ func AuthMiddleware() gin.HandlerFunc {
 			tokenString = authHeader
 		}
 
-		claims := &Claims{}
-		token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
-			return jwtSecret(), nil
-		})
+		authenticate(c, tokenString)
+	}
+}
 
-		if err != nil || !token.Valid {
+// AssetAuthMiddleware validates the JWT token like AuthMiddleware, falling
+// back to the AssetCookie when there is no Authorization header
+func AssetAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		tokenString := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if tokenString == "" {
+			tokenString, _ = c.Cookie(AssetCookie)
+		}
+		if tokenString == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{
 				"success": false,
-				"error":   "Invalid or expired token",
+				"error":   "Authorization header is required",
 			})
 			c.Abort()
 			return
 		}
 
-		// Add user info to context
-		c.Set("user_id", claims.UserID)
-		c.Set("username", claims.Username)
+		authenticate(c, tokenString)
+	}
+}
 
-		c.Next()
+// authenticate adds the user of a valid token to the context, or aborts
+func authenticate(c *gin.Context, tokenString string) {
+	claims := &Claims{}
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return jwtSecret(), nil
+	})
+
+	if err != nil || !token.Valid {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"error":   "Invalid or expired token",
+		})
+		c.Abort()
+		return
 	}
+
+	// Add user info to context
+	c.Set("user_id", claims.UserID)
+	c.Set("username", claims.Username)
+
+	c.Next()
+}
+
+// SetAssetCookie stores a login token in the AssetCookie. The cookie is only
+// sent to /api/assets and is not readable from scripts.
+func SetAssetCookie(c *gin.Context, token string) {
+	c.SetSameSite(http.SameSiteStrictMode)
+	c.SetCookie(AssetCookie, token, int(TokenLifetime/time.Second), "/api/assets", "", c.Request.TLS != nil, true)
 }
 
 // GenerateToken creates a JWT token for a user
@@ -76,7 +116,7 @@ func GenerateToken(userID uint, username string) (string, error) {
 		return "", errors.New("JWT_SECRET environment variable not set")
 	}
 
-	expirationTime := time.Now().Add(24 * time.Hour) // Token expires in 24 hours
+	expirationTime := time.Now().Add(TokenLifetime)
 	claims := &Claims{
 		UserID:   userID,
 		Username: username,
@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+// Asset is a file referenced from question markdown, stored content-addressed
+// per user (see internal/assets)
+type Asset struct {
+	UserID    uint      `json:"user_id" gorm:"primaryKey"`
+	Hash      string    `json:"hash" gorm:"primaryKey"` // SHA-256 of the content
+	Name      string    `json:"name"`                   // Original file name
+	MimeType  string    `json:"mime_type"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName sets the table name for Asset model
+func (Asset) TableName() string {
+	return "assets"
+}
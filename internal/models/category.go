@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+// Category holds display settings and scheduler defaults for the questions
+// whose Question.Category equals Name
+type Category struct {
+	ID          uint   `json:"id" gorm:"primaryKey"`
+	UserID      uint   `json:"user_id" gorm:"not null;uniqueIndex:idx_categories_user_name"`
+	Name        string `json:"name" gorm:"not null;uniqueIndex:idx_categories_user_name"`
+	Label       string `json:"label"`
+	Color       string `json:"color"` // "#rrggbb", empty for the default
+	Description string `json:"description"`
+	SortOrder   int    `json:"sort_order"`
+	// IntervalMultiplier scales review intervals of the category's questions,
+	// e.g. 0.5 to review a hard subject twice as often
+	IntervalMultiplier float64   `json:"interval_multiplier" gorm:"default:1"`
+	CreatedAt          time.Time `json:"created_at"`
+	UpdatedAt          time.Time `json:"updated_at"`
+}
+
+// TableName sets the table name for Category model
+func (Category) TableName() string {
+	return "categories"
+}
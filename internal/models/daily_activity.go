@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// DailyActivity sums a user's reviews of one day in their timezone
+type DailyActivity struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_daily_activities_user_date"`
+	Date      string    `json:"date" gorm:"not null;uniqueIndex:idx_daily_activities_user_date"` // YYYY-MM-DD
+	Reviews   int       `json:"reviews"`
+	Correct   int       `json:"correct"`
+	StudyMs   int64     `json:"study_ms"`
+	GoalMet   bool      `json:"goal_met"` // The daily goal of that day was reached
+	Cleared   bool      `json:"cleared"`  // No card was due after a review that day
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName sets the table name for DailyActivity model
+func (DailyActivity) TableName() string {
+	return "daily_activities"
+}
@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Deck publishes one of the author's categories, including its
+// subcategories, as a read-only deck that others can subscribe to
+type Deck struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	UserID      uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_decks_user_category"`
+	Category    string    `json:"category" gorm:"not null;uniqueIndex:idx_decks_user_category"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	Tags        string    `json:"tags"`                // 逗号分隔的标签
+	Public      bool      `json:"public" gorm:"index"` // 是否在卡组目录中公开
+	ShareCode   string    `json:"share_code" gorm:"not null;uniqueIndex"`
+	CreatedAt   time.Time `json:"created_at"`
+	UpdatedAt   time.Time `json:"updated_at"`
+}
+
+// TableName sets the table name for Deck model
+func (Deck) TableName() string {
+	return "decks"
+}
+
+// DeckSubscription links a subscriber to a deck. The subscriber's cards are
+// questions with DeckID set, filed below Category.
+type DeckSubscription struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	DeckID    uint      `json:"deck_id" gorm:"not null;uniqueIndex:idx_deck_subscriptions_user_deck"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_deck_subscriptions_user_deck"`
+	Category  string    `json:"category"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName sets the table name for DeckSubscription model
+func (DeckSubscription) TableName() string {
+	return "deck_subscriptions"
+}
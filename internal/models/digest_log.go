@@ -0,0 +1,29 @@
+package models
+
+import "time"
+
+// Digest send outcomes
+const (
+	DigestSent    = "sent"
+	DigestFailed  = "failed"
+	DigestSkipped = "skipped" // Nothing was due, so no email was sent
+)
+
+// DigestLog records one daily digest sent, or attempted, to a user
+type DigestLog struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;index:idx_digest_logs_user_date"`
+	Date      string    `json:"date" gorm:"index:idx_digest_logs_user_date"` // YYYY-MM-DD in the user's timezone
+	Email     string    `json:"email"`
+	Subject   string    `json:"subject"`
+	Due       int64     `json:"due"` // Cards due when the digest was built
+	Status    string    `json:"status"`
+	Error     string    `json:"error"`
+	Manual    bool      `json:"manual"` // Sent on request rather than on schedule
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// TableName sets the table name for DigestLog model
+func (DigestLog) TableName() string {
+	return "digest_logs"
+}
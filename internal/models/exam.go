@@ -0,0 +1,21 @@
+package models
+
+import "time"
+
+// Exam is an exam or interview date a user prepares for. It shows up as a
+// deadline in the calendar feed.
+type Exam struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	UserID     uint      `json:"user_id" gorm:"not null;index"`
+	Title      string    `json:"title" gorm:"not null"`
+	Date       string    `json:"date" gorm:"not null"` // YYYY-MM-DD in the user's timezone
+	Categories string    `json:"-"`                    // Comma-separated categories it covers, empty for all
+	Note       string    `json:"note"`
+	CreatedAt  time.Time `json:"created_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// TableName sets the table name for Exam model
+func (Exam) TableName() string {
+	return "exams"
+}
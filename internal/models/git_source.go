@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// GitSource is a local git repository registered as a question source. Only
+// committed files on Branch are imported.
+type GitSource struct {
+	ID           uint       `json:"id" gorm:"primaryKey"`
+	UserID       uint       `json:"user_id" gorm:"not null;index"`
+	Path         string     `json:"path" gorm:"not null"`   // Absolute path of the work tree
+	Branch       string     `json:"branch" gorm:"not null"` // Branch to import from
+	LastCommit   string     `json:"last_commit"`            // Commit of the last sync, empty before the first one
+	LastSyncedAt *time.Time `json:"last_synced_at"`
+	CreatedAt    time.Time  `json:"created_at"`
+}
+
+// TableName sets the table name for GitSource model
+func (GitSource) TableName() string {
+	return "git_sources"
+}
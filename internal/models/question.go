@@ -13,13 +13,18 @@ type Question struct {
 	QuestionText string         `json:"question" gorm:"not null"`
 	AnswerText   string         `json:"answer" gorm:"not null"`
 	Source       string         `json:"source"`
-	Category     string         `json:"category" gorm:"index"` // 从 source 路径提取的分类
-	Level        int            `json:"level"`                 // 1-4: 1=proficient, 2=fair, 3=forgotten, 4=completely forgotten
-	NextReview   time.Time      `json:"next_review"`           // Next review scheduled time
-	ReviewCount  int            `json:"review_count"`          // Total number of reviews
-	CorrectCount int            `json:"correct_count"`         // Number of correct answers
-	CreatedAt    time.Time      `json:"created_at"`            // When question was added
-	LastReviewed *time.Time     `json:"last_reviewed"`         // When last reviewed (nil if never)
+	Category     string         `json:"category" gorm:"index"`            // 从 source 路径提取的分类
+	Tags         string         `json:"tags"`                             // 逗号分隔的标签
+	SourceCommit string         `json:"commit"`                           // 内容最后一次变更所在的 git 提交（仅 git 来源）
+	DeckID       uint           `json:"deck_id,omitempty" gorm:"index"`   // 订阅的共享卡组，0 表示自己的问题
+	OriginID     string         `json:"origin_id,omitempty" gorm:"index"` // 共享卡组中作者的问题 ID，内容从该问题读取
+	Level        int            `json:"level"`                            // 1-4: 1=proficient, 2=fair, 3=forgotten, 4=completely forgotten
+	Priority     int            `json:"priority" gorm:"default:2"`        // 1=low, 2=normal, 3=high; weights interview readiness
+	NextReview   time.Time      `json:"next_review"`                      // Next review scheduled time
+	ReviewCount  int            `json:"review_count"`                     // Total number of reviews
+	CorrectCount int            `json:"correct_count"`                    // Number of correct answers
+	CreatedAt    time.Time      `json:"created_at"`                       // When question was added
+	LastReviewed *time.Time     `json:"last_reviewed"`                    // When last reviewed (nil if never)
 	UpdatedAt    time.Time      `json:"updated_at"`
 	DeletedAt    gorm.DeletedAt `json:"deleted_at,omitempty" gorm:"index"`
 
@@ -0,0 +1,28 @@
+package models
+
+import "time"
+
+// ReviewLog records a single review of a question
+type ReviewLog struct {
+	ID            uint      `json:"id" gorm:"primaryKey"`
+	UserID        uint      `json:"user_id" gorm:"not null;index;uniqueIndex:idx_review_logs_client_key"`
+	QuestionID    string    `json:"question_id" gorm:"not null;index"`
+	Feedback      int       `json:"feedback"`       // 1-4, same scale as UpdateReview
+	IntervalHours float64   `json:"interval_hours"` // Interval scheduled by this review
+	ReviewedAt    time.Time `json:"reviewed_at" gorm:"index"`
+	Source        string    `json:"source"` // "app", or the importer that produced it (e.g. "anki")
+	// ClientKey is the idempotency key of a review synced from a client,
+	// nil for reviews made online
+	ClientKey *string `json:"client_key,omitempty" gorm:"uniqueIndex:idx_review_logs_client_key"`
+	Device    string  `json:"device,omitempty"` // Client that made the review, if reported
+	// SessionID is the study session the review was made in, 0 for none
+	SessionID  uint `json:"session_id,omitempty" gorm:"index"`
+	QuestionMs int  `json:"question_ms,omitempty"` // Time spent before showing the answer
+	AnswerMs   int  `json:"answer_ms,omitempty"`   // Time spent on the answer before giving feedback
+	NewCard    bool `json:"new_card,omitempty"`    // First review of the card
+}
+
+// TableName sets the table name for ReviewLog model
+func (ReviewLog) TableName() string {
+	return "review_logs"
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// SchemaMigration records a one-time data migration that has been applied
+type SchemaMigration struct {
+	Name      string    `json:"name" gorm:"primaryKey"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// TableName sets the table name for SchemaMigration model
+func (SchemaMigration) TableName() string {
+	return "schema_migrations"
+}
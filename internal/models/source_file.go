@@ -0,0 +1,20 @@
+package models
+
+import "time"
+
+// SourceFile records the last synced state of a markdown file in a user's
+// question directory, so the watcher only re-parses files that changed
+type SourceFile struct {
+	UserID    uint      `json:"user_id" gorm:"primaryKey"`
+	Path      string    `json:"path" gorm:"primaryKey"` // Same as Question.Source
+	ModTime   time.Time `json:"mod_time"`
+	Size      int64     `json:"size"`
+	Hash      string    `json:"hash"` // SHA-256 of the content
+	Questions int       `json:"questions"`
+	SyncedAt  time.Time `json:"synced_at"`
+}
+
+// TableName sets the table name for SourceFile model
+func (SourceFile) TableName() string {
+	return "source_files"
+}
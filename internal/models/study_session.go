@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+// StudySession groups the reviews of one sitting
+type StudySession struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	UserID     uint       `json:"user_id" gorm:"not null;index"`
+	Device     string     `json:"device,omitempty"`
+	StartedAt  time.Time  `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"` // nil while the session is open
+}
+
+// TableName sets the table name for StudySession model
+func (StudySession) TableName() string {
+	return "study_sessions"
+}
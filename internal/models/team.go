@@ -0,0 +1,66 @@
+package models
+
+import "time"
+
+// Team member roles
+const (
+	TeamRoleOwner  = "owner"  // Created the team, manages admins
+	TeamRoleAdmin  = "admin"  // Manages members and assignments, sees member progress
+	TeamRoleMember = "member" // Sees aggregate progress only
+)
+
+// Team membership states
+const (
+	TeamMemberInvited = "invited" // Added by an owner or admin, not accepted yet
+	TeamMemberActive  = "active"
+)
+
+// Team groups users that study assigned decks together
+type Team struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"not null"`
+	OwnerID   uint      `json:"owner_id" gorm:"not null;index"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName sets the table name for Team model
+func (Team) TableName() string {
+	return "teams"
+}
+
+// TeamMember is a user's membership in a team. Invited users only become
+// members, and get the team's decks, once they accept.
+type TeamMember struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	TeamID    uint      `json:"team_id" gorm:"not null;uniqueIndex:idx_team_members_team_user"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_team_members_team_user;index"`
+	Role      string    `json:"role" gorm:"not null"`
+	Status    string    `json:"status" gorm:"not null;default:active"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Active reports whether the user accepted the membership
+func (m *TeamMember) Active() bool {
+	return m.Status == TeamMemberActive
+}
+
+// TableName sets the table name for TeamMember model
+func (TeamMember) TableName() string {
+	return "team_members"
+}
+
+// TeamAssignment assigns a deck to all members of a team
+type TeamAssignment struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	TeamID    uint       `json:"team_id" gorm:"not null;uniqueIndex:idx_team_assignments_team_deck"`
+	DeckID    uint       `json:"deck_id" gorm:"not null;uniqueIndex:idx_team_assignments_team_deck"`
+	Deadline  *time.Time `json:"deadline"` // nil for no deadline
+	CreatedBy uint       `json:"created_by"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// TableName sets the table name for TeamAssignment model
+func (TeamAssignment) TableName() string {
+	return "team_assignments"
+}
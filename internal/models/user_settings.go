@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+// UserSettings holds per-user preferences. A missing row means defaults.
+type UserSettings struct {
+	UserID        uint      `json:"user_id" gorm:"primaryKey"`
+	WatchEnabled  bool      `json:"watch_enabled"` // Re-sync questions/<username>/ automatically
+	Timezone      string    `json:"timezone"`      // IANA name days are counted in, empty for the server's
+	GoalType      string    `json:"goal_type"`     // cards or minutes, empty for the default goal
+	GoalTarget    int       `json:"goal_target"`
+	DigestEnabled bool      `json:"digest_enabled"` // Send the daily digest email
+	DigestEmail   string    `json:"digest_email"`
+	DigestTime    string    `json:"digest_time"`    // HH:MM in Timezone, empty for the default
+	DigestToken   string    `json:"-" gorm:"index"` // Secret of the unsubscribe link
+	CalendarToken string    `json:"-" gorm:"index"` // Secret of the calendar feed URL, empty until first requested
+	UpdatedAt     time.Time `json:"updated_at"`
+}
+
+// TableName sets the table name for UserSettings model
+func (UserSettings) TableName() string {
+	return "user_settings"
+}
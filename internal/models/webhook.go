@@ -0,0 +1,51 @@
+package models
+
+import "time"
+
+// Webhook delivery states
+const (
+	WebhookDeliveryPending   = "pending" // Waiting for its first attempt or a retry
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed" // Gave up after the last retry
+)
+
+// Webhook is a URL a user subscribed to some of their learning events
+type Webhook struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	URL       string    `json:"url" gorm:"not null"`
+	Secret    string    `json:"-" gorm:"not null"` // HMAC key for the signature header
+	Events    string    `json:"-"`                 // Comma-separated event names
+	Active    bool      `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// TableName sets the table name for Webhook model
+func (Webhook) TableName() string {
+	return "webhooks"
+}
+
+// WebhookDelivery is one event sent to a webhook, with the outcome of its
+// latest attempt
+type WebhookDelivery struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	WebhookID     uint       `json:"webhook_id" gorm:"not null;index"`
+	UserID        uint       `json:"user_id" gorm:"not null;index"`
+	Event         string     `json:"event"`
+	Payload       string     `json:"payload"` // The signed request body
+	Status        string     `json:"status" gorm:"index"`
+	Attempts      int        `json:"attempts"`
+	StatusCode    int        `json:"status_code"` // 0 when no response was received
+	Response      string     `json:"response"`    // Start of the response body
+	Error         string     `json:"error"`
+	DurationMs    int64      `json:"duration_ms"`
+	NextAttemptAt *time.Time `json:"next_attempt_at"` // Set while a retry is scheduled
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// TableName sets the table name for WebhookDelivery model
+func (WebhookDelivery) TableName() string {
+	return "webhook_deliveries"
+}
@@ -0,0 +1,169 @@
+package parser
+
+import (
+	"regexp"
+	"strings"
+)
+
+// Candidate kinds produced by ConvertNotes
+const (
+	CandidateHeading    = "heading"
+	CandidateDefinition = "definition"
+)
+
+// Candidate is a question/answer pair proposed from plain notes. Candidates
+// are meant to be reviewed before they are imported.
+type Candidate struct {
+	Question   string `json:"question"`
+	Answer     string `json:"answer"`
+	Kind       string `json:"kind"`
+	Line       int    `json:"line"` // 1-based line the candidate starts at
+	SourceFile string `json:"source"`
+}
+
+// ToQuestion converts an accepted candidate
+func (c *Candidate) ToQuestion() *Question {
+	return &Question{QuestionText: c.Question, AnswerText: c.Answer, SourceFile: c.SourceFile}
+}
+
+var (
+	noteHeadingRe = regexp.MustCompile(`^(#{1,6})\s+(.+?)\s*#*\s*$`)
+	// "- **术语**: 解释", "**术语** — 解释"
+	boldDefinitionRe = regexp.MustCompile(`^\s*(?:[-*+]\s+)?\*\*(.+?)\*\*\s*(?:[:：]|——|—|-)\s*(.+)$`)
+	// Second line of "术语\n: 解释" definition lists
+	definitionLineRe = regexp.MustCompile(`^:\s+(.+)$`)
+	qaMarkerRe       = regexp.MustCompile(`(?i)^(q|a|question|answer)$`)
+)
+
+type noteSection struct {
+	level   int
+	title   string
+	line    int
+	body    []string
+	parents []string
+}
+
+// ConvertNotes turns heading-structured notes into candidates. Every heading
+// with body text becomes a candidate whose question is the heading, with the
+// parent heading as context. Definition list items ("术语\n: 解释") and bold
+// term lists ("- **术语**: 解释") inside the body become candidates of their own.
+func ConvertNotes(content, sourceFile string) []*Candidate {
+	_, body := splitFrontMatter(content)
+	// Report line numbers of the original file, front matter included
+	offset := strings.Count(content, "\n") - strings.Count(body, "\n")
+	lines := strings.Split(strings.ReplaceAll(body, "\r\n", "\n"), "\n")
+
+	var sections []*noteSection
+	var stack []*noteSection
+	current := &noteSection{}
+	inFence := false
+
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		if !inFence {
+			if m := noteHeadingRe.FindStringSubmatch(line); m != nil {
+				section := &noteSection{level: len(m[1]), title: strings.TrimSpace(m[2]), line: offset + i + 1}
+				for len(stack) > 0 && stack[len(stack)-1].level >= section.level {
+					stack = stack[:len(stack)-1]
+				}
+				for _, s := range stack {
+					section.parents = append(section.parents, s.title)
+				}
+				stack = append(stack, section)
+				sections = append(sections, section)
+				current = section
+				continue
+			}
+		}
+		current.body = append(current.body, line)
+	}
+
+	var candidates []*Candidate
+	for _, s := range sections {
+		if qaMarkerRe.MatchString(s.title) {
+			continue
+		}
+
+		answer := strings.TrimSpace(strings.Join(s.body, "\n"))
+		if answer != "" {
+			candidates = append(candidates, &Candidate{
+				Question:   noteQuestion(s),
+				Answer:     answer,
+				Kind:       CandidateHeading,
+				Line:       s.line,
+				SourceFile: sourceFile,
+			})
+		}
+		candidates = append(candidates, definitions(s, sourceFile)...)
+	}
+
+	return candidates
+}
+
+// noteQuestion phrases a heading as a question, keeping the nearest parent
+// heading as context since titles like "原理" are ambiguous on their own
+func noteQuestion(s *noteSection) string {
+	title := strings.TrimRight(s.title, "：:")
+	if len(s.parents) > 0 {
+		parent := s.parents[len(s.parents)-1]
+		if !strings.Contains(title, parent) {
+			title = parent + " - " + title
+		}
+	}
+	if strings.HasSuffix(title, "？") || strings.HasSuffix(title, "?") {
+		return title
+	}
+	return title + "？"
+}
+
+func definitions(s *noteSection, sourceFile string) []*Candidate {
+	var candidates []*Candidate
+	inFence := false
+	for i, line := range s.body {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+		if inFence {
+			continue
+		}
+
+		var term, definition string
+		if m := boldDefinitionRe.FindStringSubmatch(line); m != nil {
+			term, definition = m[1], m[2]
+		} else if m := definitionLineRe.FindStringSubmatch(line); m != nil && i > 0 {
+			term, definition = s.body[i-1], m[1]
+		}
+		term, definition = strings.TrimSpace(term), strings.TrimSpace(definition)
+		if term == "" || definition == "" {
+			continue
+		}
+
+		candidates = append(candidates, &Candidate{
+			Question:   term + "？",
+			Answer:     definition,
+			Kind:       CandidateDefinition,
+			Line:       s.line + i + 1,
+			SourceFile: sourceFile,
+		})
+	}
+	return candidates
+}
+
+// FormatQA renders questions in the "# q" / "# a" format so converted notes
+// can be saved next to the rest of the knowledge base
+func FormatQA(questions []*Question) string {
+	var b strings.Builder
+	for i, q := range questions {
+		if i > 0 {
+			b.WriteString("\n")
+		}
+		b.WriteString("# q\n")
+		b.WriteString(strings.TrimSpace(q.QuestionText))
+		b.WriteString("\n# a\n")
+		b.WriteString(strings.TrimSpace(q.AnswerText))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
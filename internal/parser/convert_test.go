@@ -0,0 +1,101 @@
+package parser
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestConvertNotes_Headings(t *testing.T) {
+	content := `---
+title: Redis
+---
+# Redis
+
+## 持久化
+Redis 支持 RDB 和 AOF 两种持久化方式。
+
+### RDB
+定时生成快照。
+
+` + "```" + `
+## 代码块里的标题不算
+` + "```" + `
+
+## 为什么快？
+单线程、内存操作、IO 多路复用。
+
+## 空章节
+`
+
+	candidates := ConvertNotes(content, "redis.md")
+	if len(candidates) != 3 {
+		for _, c := range candidates {
+			t.Logf("%+v", c)
+		}
+		t.Fatalf("expected 3 candidates, got %d", len(candidates))
+	}
+
+	if c := candidates[0]; c.Question != "Redis - 持久化？" || c.Answer != "Redis 支持 RDB 和 AOF 两种持久化方式。" || c.Kind != CandidateHeading {
+		t.Errorf("unexpected first candidate: %+v", c)
+	}
+	if c := candidates[1]; c.Question != "持久化 - RDB？" || !strings.Contains(c.Answer, "## 代码块里的标题不算") {
+		t.Errorf("unexpected nested candidate: %+v", c)
+	}
+	if c := candidates[2]; c.Question != "Redis - 为什么快？" || c.Line != 16 {
+		t.Errorf("unexpected question heading: %+v", c)
+	}
+}
+
+func TestConvertNotes_Definitions(t *testing.T) {
+	content := `## 术语
+- **GMP**: Go 调度模型
+- **GC**：垃圾回收
+
+Channel
+: goroutine 之间通信的管道
+`
+
+	candidates := ConvertNotes(content, "go.md")
+
+	var defs []*Candidate
+	for _, c := range candidates {
+		if c.Kind == CandidateDefinition {
+			defs = append(defs, c)
+		}
+	}
+	if len(defs) != 3 {
+		t.Fatalf("expected 3 definitions, got %d", len(defs))
+	}
+	if defs[0].Question != "GMP？" || defs[0].Answer != "Go 调度模型" || defs[0].Line != 2 {
+		t.Errorf("unexpected definition: %+v", defs[0])
+	}
+	if defs[1].Answer != "垃圾回收" {
+		t.Errorf("fullwidth colon not handled: %+v", defs[1])
+	}
+	if defs[2].Question != "Channel？" || defs[2].Answer != "goroutine 之间通信的管道" {
+		t.Errorf("definition list not handled: %+v", defs[2])
+	}
+}
+
+func TestConvertNotes_SkipsQAMarkers(t *testing.T) {
+	if got := ConvertNotes("# q\n问题\n# a\n答案", "qa.md"); len(got) != 0 {
+		t.Errorf("expected no candidates for q/a files, got %d", len(got))
+	}
+}
+
+func TestFormatQA_RoundTrip(t *testing.T) {
+	candidates := ConvertNotes("## 闭包\n能访问外部作用域变量的函数\n\n## 原型链\n继承机制", "js.md")
+	var questions []*Question
+	for _, c := range candidates {
+		questions = append(questions, c.ToQuestion())
+	}
+
+	qp := &QuestionParser{}
+	parsed := qp.ParseContent(FormatQA(questions), "js.md")
+	if len(parsed) != 2 {
+		t.Fatalf("expected 2 questions, got %d", len(parsed))
+	}
+	if parsed[1].QuestionText != "原型链？" || parsed[1].AnswerText != "继承机制" {
+		t.Errorf("round trip mismatch: %+v", parsed[1])
+	}
+}
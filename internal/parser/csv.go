@@ -0,0 +1,245 @@
+package parser
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf8"
+
+	"self-improvement/internal/models"
+)
+
+// CSV columns a card can be mapped to
+const (
+	ColumnQuestion = "question"
+	ColumnAnswer   = "answer"
+	ColumnCategory = "category"
+	ColumnTags     = "tags"
+	ColumnSource   = "source"
+	// ColumnSkip ignores a column on import
+	ColumnSkip = "-"
+)
+
+// DefaultCSVColumns is the column layout used for exports
+var DefaultCSVColumns = []string{ColumnQuestion, ColumnAnswer, ColumnCategory, ColumnTags, ColumnSource}
+
+// columnAliases maps header names found in common exports to columns
+var columnAliases = map[string]string{
+	"question": ColumnQuestion, "front": ColumnQuestion, "term": ColumnQuestion, "问题": ColumnQuestion, "正面": ColumnQuestion,
+	"answer": ColumnAnswer, "back": ColumnAnswer, "definition": ColumnAnswer, "答案": ColumnAnswer, "背面": ColumnAnswer,
+	"category": ColumnCategory, "deck": ColumnCategory, "分类": ColumnCategory,
+	"tags": ColumnTags, "tag": ColumnTags, "标签": ColumnTags,
+	"source": ColumnSource, "来源": ColumnSource,
+}
+
+const utf8BOM = "\ufeff"
+
+// CSVOptions configures reading and writing CSV files
+type CSVOptions struct {
+	Comma rune
+	// Columns names the column each field maps to. When empty on import the
+	// header row is used if it names the columns, otherwise the first two
+	// fields are question and answer.
+	Columns []string
+	// Header tells whether the first row holds column names
+	Header bool
+}
+
+// ParseDelimiter accepts a delimiter character or one of the names
+// "comma", "tab", "semicolon" and "pipe"
+func ParseDelimiter(s string) (rune, error) {
+	switch strings.ToLower(s) {
+	case "comma", ",":
+		return ',', nil
+	case "tab", "\\t", "\t":
+		return '\t', nil
+	case "semicolon", ";":
+		return ';', nil
+	case "pipe", "|":
+		return '|', nil
+	}
+	if r, size := utf8.DecodeRuneInString(s); size == len(s) && r != utf8.RuneError && r != '"' && r != '\r' && r != '\n' {
+		return r, nil
+	}
+	return 0, fmt.Errorf("invalid delimiter %q", s)
+}
+
+// ParseColumns parses a comma separated column mapping such as
+// "question,answer,-,tags"
+func ParseColumns(spec string) ([]string, error) {
+	var columns []string
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" || name == "skip" {
+			name = ColumnSkip
+		}
+		if name != ColumnSkip {
+			column, ok := columnAliases[name]
+			if !ok {
+				return nil, fmt.Errorf("unknown column %q", name)
+			}
+			name = column
+		}
+		columns = append(columns, name)
+	}
+	return columns, validateColumns(columns)
+}
+
+func validateColumns(columns []string) error {
+	seen := make(map[string]bool)
+	for _, c := range columns {
+		if c == ColumnSkip {
+			continue
+		}
+		if seen[c] {
+			return fmt.Errorf("column %q mapped twice", c)
+		}
+		seen[c] = true
+	}
+	if !seen[ColumnQuestion] || !seen[ColumnAnswer] {
+		return errors.New("question and answer columns are required")
+	}
+	return nil
+}
+
+// headerColumns maps a header row to columns, or returns nil if the row does
+// not name both the question and answer column
+func headerColumns(row []string) []string {
+	columns := make([]string, len(row))
+	for i, name := range row {
+		column, ok := columnAliases[strings.ToLower(strings.TrimSpace(name))]
+		if !ok {
+			column = ColumnSkip
+		}
+		columns[i] = column
+	}
+	if validateColumns(columns) != nil {
+		return nil
+	}
+	return columns
+}
+
+// ParseCSV reads cards from a CSV file. sourceFile is used as the source of
+// rows without a source column. Rows with an empty question or answer are
+// skipped.
+func ParseCSV(r io.Reader, sourceFile string, opts CSVOptions) ([]*Question, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(strings.NewReader(strings.TrimPrefix(string(data), utf8BOM)))
+	if opts.Comma != 0 {
+		reader.Comma = opts.Comma
+	}
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	columns := opts.Columns
+	if opts.Header && len(rows) > 0 {
+		if columns == nil {
+			columns = headerColumns(rows[0])
+			if columns == nil {
+				return nil, errors.New("header does not name the question and answer columns")
+			}
+		}
+		rows = rows[1:]
+	}
+	if columns == nil {
+		columns = []string{ColumnQuestion, ColumnAnswer}
+	}
+
+	var questions []*Question
+	for _, row := range rows {
+		q := &Question{SourceFile: sourceFile}
+		for i, value := range row {
+			if i >= len(columns) {
+				break
+			}
+			value = strings.TrimSpace(strings.ReplaceAll(value, "\r\n", "\n"))
+			switch columns[i] {
+			case ColumnQuestion:
+				q.QuestionText = value
+			case ColumnAnswer:
+				q.AnswerText = value
+			case ColumnCategory:
+				q.Category = value
+			case ColumnTags:
+				q.Tags = SplitTags(value)
+			case ColumnSource:
+				if value != "" {
+					q.SourceFile = value
+				}
+			}
+		}
+		if q.QuestionText == "" || q.AnswerText == "" {
+			continue
+		}
+		questions = append(questions, q)
+	}
+
+	return questions, nil
+}
+
+// SplitTags splits a tag list separated by commas, semicolons or whitespace
+func SplitTags(s string) []string {
+	return strings.FieldsFunc(s, func(r rune) bool {
+		return r == ',' || r == ';' || r == '，' || r == ' ' || r == '\t' || r == '\n'
+	})
+}
+
+// WriteCSV writes questions as CSV with a header row. The output starts with
+// a UTF-8 byte order mark so spreadsheet programs detect the encoding.
+func WriteCSV(w io.Writer, questions []*models.Question, opts CSVOptions) error {
+	columns := opts.Columns
+	if columns == nil {
+		columns = DefaultCSVColumns
+	}
+
+	if _, err := io.WriteString(w, utf8BOM); err != nil {
+		return err
+	}
+	writer := csv.NewWriter(w)
+	if opts.Comma != 0 {
+		writer.Comma = opts.Comma
+	}
+
+	if opts.Header {
+		if err := writer.Write(columns); err != nil {
+			return err
+		}
+	}
+
+	row := make([]string, len(columns))
+	for _, q := range questions {
+		for i, column := range columns {
+			switch column {
+			case ColumnQuestion:
+				row[i] = q.QuestionText
+			case ColumnAnswer:
+				row[i] = q.AnswerText
+			case ColumnCategory:
+				row[i] = q.Category
+			case ColumnTags:
+				row[i] = q.Tags
+			case ColumnSource:
+				row[i] = q.Source
+			default:
+				row[i] = ""
+			}
+		}
+		if err := writer.Write(row); err != nil {
+			return err
+		}
+	}
+
+	writer.Flush()
+	return writer.Error()
+}
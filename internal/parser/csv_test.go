@@ -0,0 +1,114 @@
+package parser
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"self-improvement/internal/models"
+)
+
+func TestParseCSV_Header(t *testing.T) {
+	content := "\ufeff问题,答案,标签,备注\n" +
+		"什么是闭包？,\"闭包是函数。\n\n```js\nfunction f() {}\n```\",\"js, 基础\",忽略\n" +
+		",没有问题的行,,\n"
+
+	questions, err := ParseCSV(strings.NewReader(content), "csv:cards.csv", CSVOptions{Header: true})
+	if err != nil {
+		t.Fatalf("ParseCSV failed: %v", err)
+	}
+	if len(questions) != 1 {
+		t.Fatalf("expected 1 question, got %d", len(questions))
+	}
+
+	q := questions[0]
+	if q.QuestionText != "什么是闭包？" {
+		t.Errorf("question mismatch: %q", q.QuestionText)
+	}
+	if q.AnswerText != "闭包是函数。\n\n```js\nfunction f() {}\n```" {
+		t.Errorf("answer mismatch: %q", q.AnswerText)
+	}
+	if len(q.Tags) != 2 || q.Tags[1] != "基础" {
+		t.Errorf("tags mismatch: %v", q.Tags)
+	}
+	if q.SourceFile != "csv:cards.csv" {
+		t.Errorf("source mismatch: %q", q.SourceFile)
+	}
+}
+
+func TestParseCSV_QuizletTSV(t *testing.T) {
+	content := "TCP\t传输控制协议\nUDP\t用户数据报协议\n"
+
+	questions, err := ParseCSV(strings.NewReader(content), "quizlet.tsv", CSVOptions{Comma: '\t'})
+	if err != nil {
+		t.Fatalf("ParseCSV failed: %v", err)
+	}
+	if len(questions) != 2 || questions[1].AnswerText != "用户数据报协议" {
+		t.Fatalf("unexpected questions: %+v", questions)
+	}
+}
+
+func TestParseCSV_BadHeader(t *testing.T) {
+	if _, err := ParseCSV(strings.NewReader("a,b\n1,2\n"), "x.csv", CSVOptions{Header: true}); err == nil {
+		t.Error("expected error for header without question/answer")
+	}
+}
+
+func TestParseColumns(t *testing.T) {
+	columns, err := ParseColumns("answer, skip, Question,tags")
+	if err != nil {
+		t.Fatalf("ParseColumns failed: %v", err)
+	}
+	if strings.Join(columns, ",") != "answer,-,question,tags" {
+		t.Errorf("unexpected columns: %v", columns)
+	}
+
+	for _, spec := range []string{"question", "question,answer,question", "question,answer,nope"} {
+		if _, err := ParseColumns(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+}
+
+func TestParseDelimiter(t *testing.T) {
+	cases := map[string]rune{"tab": '\t', ",": ',', "semicolon": ';', "|": '|'}
+	for in, want := range cases {
+		if got, err := ParseDelimiter(in); err != nil || got != want {
+			t.Errorf("ParseDelimiter(%q) = %q, %v", in, got, err)
+		}
+	}
+	if _, err := ParseDelimiter(`"`); err == nil {
+		t.Error("expected error for quote delimiter")
+	}
+}
+
+func TestWriteCSV_RoundTrip(t *testing.T) {
+	questions := []*models.Question{{
+		QuestionText: "多行\n问题，带逗号",
+		AnswerText:   "答案里有 \"引号\"",
+		Category:     "go",
+		Tags:         "a,b",
+		Source:       "questions/go/a.md",
+	}}
+
+	var buf bytes.Buffer
+	opts := CSVOptions{Comma: ';', Header: true}
+	if err := WriteCSV(&buf, questions, opts); err != nil {
+		t.Fatalf("WriteCSV failed: %v", err)
+	}
+
+	parsed, err := ParseCSV(&buf, "export.csv", opts)
+	if err != nil {
+		t.Fatalf("ParseCSV failed: %v", err)
+	}
+	if len(parsed) != 1 {
+		t.Fatalf("expected 1 question, got %d", len(parsed))
+	}
+	q := parsed[0]
+	if q.QuestionText != questions[0].QuestionText || q.AnswerText != questions[0].AnswerText {
+		t.Errorf("text mismatch: %+v", q)
+	}
+	if q.Category != "go" || q.SourceFile != "questions/go/a.md" || len(q.Tags) != 2 {
+		t.Errorf("metadata mismatch: %+v", q)
+	}
+}
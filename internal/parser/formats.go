@@ -0,0 +1,457 @@
+package parser
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Format is a named question/answer syntax. ParseContent picks formats by
+// front matter, by directory configuration or by Detect, so new syntaxes only
+// need to be registered here.
+type Format interface {
+	// Name is the identifier used in question_input_linux and front matter.
+	Name() string
+	// Detect reports whether the content looks like it uses this format.
+	Detect(content string) bool
+	// Parse extracts question-answer pairs from the content.
+	Parse(content, sourceFile string) []*Question
+}
+
+var formats = struct {
+	sync.RWMutex
+	order  []string
+	byName map[string]Format
+}{byName: make(map[string]Format)}
+
+// RegisterFormat makes a format available to all parsers. Formats are tried
+// in registration order during auto-detection. It panics if the name is
+// already taken.
+func RegisterFormat(f Format) {
+	formats.Lock()
+	defer formats.Unlock()
+
+	name := f.Name()
+	if _, dup := formats.byName[name]; dup {
+		panic("parser: RegisterFormat called twice for format " + name)
+	}
+	formats.byName[name] = f
+	formats.order = append(formats.order, name)
+}
+
+// LookupFormat returns the registered format with the given name
+func LookupFormat(name string) (Format, bool) {
+	formats.RLock()
+	defer formats.RUnlock()
+
+	f, ok := formats.byName[name]
+	return f, ok
+}
+
+// FormatNames returns the names of all registered formats, sorted
+func FormatNames() []string {
+	formats.RLock()
+	defer formats.RUnlock()
+
+	names := append([]string(nil), formats.order...)
+	sort.Strings(names)
+	return names
+}
+
+// DetectFormat returns the first registered format that recognises the content
+func DetectFormat(content string) (Format, bool) {
+	formats.RLock()
+	defer formats.RUnlock()
+
+	for _, name := range formats.order {
+		if f := formats.byName[name]; f.Detect(content) {
+			return f, true
+		}
+	}
+	return nil, false
+}
+
+// resolveFormats maps format names to registered formats
+func resolveFormats(names []string) ([]Format, error) {
+	var result []Format
+	for _, name := range names {
+		f, ok := LookupFormat(name)
+		if !ok {
+			return nil, fmt.Errorf("unknown question format %q (available: %s)", name, strings.Join(FormatNames(), ", "))
+		}
+		result = append(result, f)
+	}
+	return result, nil
+}
+
+// splitFormatList splits "a, b" or "[a, b]" into format names
+func splitFormatList(raw string) []string {
+	raw = strings.Trim(strings.TrimSpace(raw), "[]")
+	var names []string
+	for _, name := range strings.Split(raw, ",") {
+		name = strings.Trim(strings.TrimSpace(name), `"'`)
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+// splitFrontMatter separates a leading "---" delimited block of "key: value"
+// lines from the body. Content without front matter is returned unchanged.
+func splitFrontMatter(content string) (map[string]string, string) {
+	trimmed := strings.TrimPrefix(content, "\ufeff")
+	if !strings.HasPrefix(trimmed, "---") {
+		return nil, content
+	}
+
+	lines := strings.Split(trimmed, "\n")
+	if strings.TrimSpace(lines[0]) != "---" {
+		return nil, content
+	}
+
+	for i := 1; i < len(lines); i++ {
+		if strings.TrimSpace(lines[i]) != "---" {
+			continue
+		}
+
+		meta := make(map[string]string)
+		for _, line := range lines[1:i] {
+			key, value, ok := strings.Cut(line, ":")
+			if !ok {
+				continue
+			}
+			meta[strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(value), `"'`)
+		}
+		return meta, strings.Join(lines[i+1:], "\n")
+	}
+
+	return nil, content
+}
+
+func init() {
+	RegisterFormat(qaFormat{})
+	RegisterFormat(headingFormat{})
+	RegisterFormat(calloutFormat{})
+	RegisterFormat(prefixFormat{})
+}
+
+// qaFormat is the original "# q" / "# a" H1 heading syntax
+type qaFormat struct{}
+
+func (qaFormat) Name() string { return "qa" }
+
+func isQAQuestionMarker(trimmed string) bool {
+	return trimmed == "# q" || strings.HasPrefix(trimmed, "# q ") ||
+		trimmed == "# question" || strings.HasPrefix(trimmed, "# question ")
+}
+
+func isQAAnswerMarker(trimmed string) bool {
+	return trimmed == "# a" || strings.HasPrefix(trimmed, "# a ") ||
+		trimmed == "# answer" || strings.HasPrefix(trimmed, "# answer ")
+}
+
+func (qaFormat) Detect(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		if isQAQuestionMarker(strings.TrimSpace(line)) {
+			return true
+		}
+	}
+	return false
+}
+
+func (qaFormat) Parse(content, sourceFile string) []*Question {
+	var questions []*Question
+
+	// Find all positions of # q and # a markers
+	lines := strings.Split(content, "\n")
+
+	var qPositions []int
+	var aPositions []int
+
+	for i, line := range lines {
+		trimmed := strings.TrimSpace(line)
+		if isQAQuestionMarker(trimmed) {
+			qPositions = append(qPositions, i)
+		} else if isQAAnswerMarker(trimmed) {
+			aPositions = append(aPositions, i)
+		}
+	}
+
+	// Match questions with answers
+	for i, qPos := range qPositions {
+		if i < len(aPositions) {
+			aPos := aPositions[i]
+
+			// Extract question text (from qPos+1 to aPos)
+			qText := strings.Join(lines[qPos+1:aPos], "\n")
+			qText = strings.TrimSpace(qText)
+
+			// Extract answer text (from aPos+1 to next question or end)
+			nextQPos := len(lines)
+			if i+1 < len(qPositions) {
+				nextQPos = qPositions[i+1]
+			}
+			aText := strings.Join(lines[aPos+1:nextQPos], "\n")
+			aText = strings.TrimSpace(aText)
+
+			if qText != "" && aText != "" {
+				questions = append(questions, &Question{
+					QuestionText: qText,
+					AnswerText:   aText,
+					SourceFile:   sourceFile,
+				})
+			}
+		}
+	}
+
+	return questions
+}
+
+// markerFunc reports whether a line starts a question or answer and returns
+// the text that follows the marker on the same line.
+type markerFunc func(line string) (string, bool)
+
+// scanMarkedPairs walks the lines of content and collects the text following
+// each question marker up to the next answer marker, and the text following
+// that answer marker up to the next question marker. Lines inside fenced code
+// blocks are never treated as markers.
+func scanMarkedPairs(content, sourceFile string, isQuestion, isAnswer markerFunc) []*Question {
+	var questions []*Question
+	var qLines, aLines []string
+	state := 0 // 0 = before first question, 1 = in question, 2 = in answer
+	inFence := false
+
+	flush := func() {
+		qText := strings.TrimSpace(strings.Join(qLines, "\n"))
+		aText := strings.TrimSpace(strings.Join(aLines, "\n"))
+		if state == 2 && qText != "" && aText != "" {
+			questions = append(questions, &Question{
+				QuestionText: qText,
+				AnswerText:   aText,
+				SourceFile:   sourceFile,
+			})
+		}
+		qLines, aLines = nil, nil
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+		}
+
+		if !inFence {
+			if rest, ok := isQuestion(line); ok {
+				flush()
+				state = 1
+				qLines = append(qLines, rest)
+				continue
+			}
+			if rest, ok := isAnswer(line); ok && state == 1 {
+				state = 2
+				aLines = append(aLines, rest)
+				continue
+			}
+		}
+
+		switch state {
+		case 1:
+			qLines = append(qLines, line)
+		case 2:
+			aLines = append(aLines, line)
+		}
+	}
+	flush()
+
+	return questions
+}
+
+func hasMarkedPair(content string, isQuestion, isAnswer markerFunc) bool {
+	seenQuestion := false
+	for _, line := range strings.Split(content, "\n") {
+		if _, ok := isQuestion(line); ok {
+			seenQuestion = true
+		} else if _, ok := isAnswer(line); ok && seenQuestion {
+			return true
+		}
+	}
+	return false
+}
+
+// headingFormat handles "## Q: question" headings answered by "**A:** answer"
+type headingFormat struct{}
+
+var (
+	headingQuestionRe = regexp.MustCompile(`^#{1,6}\s*[Qq]\s*[:：]\s*(.*)$`)
+	headingAnswerRe   = regexp.MustCompile(`^\*\*[Aa]\s*(?:[:：]\s*\*\*|\*\*\s*[:：])\s*(.*)$`)
+)
+
+func headingQuestion(line string) (string, bool) {
+	m := headingQuestionRe.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+func headingAnswer(line string) (string, bool) {
+	m := headingAnswerRe.FindStringSubmatch(strings.TrimSpace(line))
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+func (headingFormat) Name() string { return "heading" }
+
+func (headingFormat) Detect(content string) bool {
+	return hasMarkedPair(content, headingQuestion, headingAnswer)
+}
+
+func (headingFormat) Parse(content, sourceFile string) []*Question {
+	return scanMarkedPairs(content, sourceFile, headingQuestion, headingAnswer)
+}
+
+// prefixFormat handles plain "Q:" / "A:" line prefixes
+type prefixFormat struct{}
+
+var (
+	prefixQuestionRe = regexp.MustCompile(`^Q\s*[:：]\s?(.*)$`)
+	prefixAnswerRe   = regexp.MustCompile(`^A\s*[:：]\s?(.*)$`)
+)
+
+func prefixQuestion(line string) (string, bool) {
+	m := prefixQuestionRe.FindStringSubmatch(strings.TrimRight(line, "\r"))
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+func prefixAnswer(line string) (string, bool) {
+	m := prefixAnswerRe.FindStringSubmatch(strings.TrimRight(line, "\r"))
+	if m == nil {
+		return "", false
+	}
+	return m[1], true
+}
+
+func (prefixFormat) Name() string { return "prefix" }
+
+func (prefixFormat) Detect(content string) bool {
+	return hasMarkedPair(content, prefixQuestion, prefixAnswer)
+}
+
+func (prefixFormat) Parse(content, sourceFile string) []*Question {
+	return scanMarkedPairs(content, sourceFile, prefixQuestion, prefixAnswer)
+}
+
+// calloutFormat handles Obsidian "> [!question]" callouts. The callout title
+// is the question and its body the answer, unless an "> [!answer]" callout or
+// plain text follows it, in which case the whole callout is the question.
+type calloutFormat struct{}
+
+var calloutHeaderRe = regexp.MustCompile(`^>\s*\[!(\w+)\][+-]?\s*(.*)$`)
+
+type callout struct {
+	kind  string
+	title string
+	body  []string
+}
+
+func (calloutFormat) Name() string { return "callout" }
+
+func (calloutFormat) Detect(content string) bool {
+	for _, line := range strings.Split(content, "\n") {
+		if m := calloutHeaderRe.FindStringSubmatch(strings.TrimSpace(line)); m != nil && isQuestionCallout(m[1]) {
+			return true
+		}
+	}
+	return false
+}
+
+func isQuestionCallout(kind string) bool {
+	kind = strings.ToLower(kind)
+	return kind == "question" || kind == "faq" || kind == "help"
+}
+
+func isAnswerCallout(kind string) bool {
+	return strings.ToLower(kind) == "answer"
+}
+
+func (calloutFormat) Parse(content, sourceFile string) []*Question {
+	// Split the content into callout blocks and runs of plain text
+	type block struct {
+		callout *callout
+		text    []string
+	}
+	var blocks []block
+	var current *block
+
+	for _, line := range strings.Split(content, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if m := calloutHeaderRe.FindStringSubmatch(trimmed); m != nil {
+			blocks = append(blocks, block{callout: &callout{kind: m[1], title: m[2]}})
+			current = &blocks[len(blocks)-1]
+			continue
+		}
+		if current != nil && current.callout != nil && strings.HasPrefix(trimmed, ">") {
+			body := strings.TrimPrefix(trimmed, ">")
+			current.callout.body = append(current.callout.body, strings.TrimPrefix(body, " "))
+			continue
+		}
+		if current == nil || current.callout != nil {
+			blocks = append(blocks, block{})
+			current = &blocks[len(blocks)-1]
+		}
+		current.text = append(current.text, line)
+	}
+
+	var questions []*Question
+	for i, b := range blocks {
+		if b.callout == nil || !isQuestionCallout(b.callout.kind) {
+			continue
+		}
+
+		title := strings.TrimSpace(b.callout.title)
+		body := strings.TrimSpace(strings.Join(b.callout.body, "\n"))
+
+		// Look for an explicit answer: the next callout if it is an answer
+		// callout, otherwise the plain text up to the next callout
+		var answer string
+		for j := i + 1; j < len(blocks); j++ {
+			next := blocks[j]
+			if next.callout != nil {
+				if isAnswerCallout(next.callout.kind) {
+					answer = strings.TrimSpace(strings.TrimSpace(next.callout.title) + "\n" + strings.Join(next.callout.body, "\n"))
+				}
+				break
+			}
+			if text := strings.TrimSpace(strings.Join(next.text, "\n")); text != "" {
+				answer = text
+				break
+			}
+		}
+
+		var qText, aText string
+		switch {
+		case answer != "":
+			qText = strings.TrimSpace(title + "\n" + body)
+			aText = answer
+		case title != "":
+			qText, aText = title, body
+		}
+
+		if qText != "" && aText != "" {
+			questions = append(questions, &Question{
+				QuestionText: qText,
+				AnswerText:   aText,
+				SourceFile:   sourceFile,
+			})
+		}
+	}
+
+	return questions
+}
@@ -0,0 +1,191 @@
+package parser
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseContent_HeadingFormat(t *testing.T) {
+	qp := &QuestionParser{}
+
+	content := `## Q: 什么是闭包？
+**A:** 闭包是指有权访问另一个函数作用域中变量的函数。
+
+## Q: 什么是原型链？
+补充说明
+**A：** 原型链是 JavaScript 中实现继承的机制。
+` + "```" + `
+## Q: 代码块里的不算
+` + "```"
+
+	questions := qp.ParseContent(content, "test.md")
+
+	if len(questions) != 2 {
+		t.Fatalf("expected 2 questions, got %d", len(questions))
+	}
+	if questions[0].QuestionText != "什么是闭包？" {
+		t.Errorf("q1 mismatch: %s", questions[0].QuestionText)
+	}
+	if questions[0].AnswerText != "闭包是指有权访问另一个函数作用域中变量的函数。" {
+		t.Errorf("a1 mismatch: %s", questions[0].AnswerText)
+	}
+	if questions[1].QuestionText != "什么是原型链？\n补充说明" {
+		t.Errorf("q2 mismatch: %s", questions[1].QuestionText)
+	}
+}
+
+func TestParseContent_PrefixFormat(t *testing.T) {
+	qp := &QuestionParser{}
+
+	content := `Q: 什么是 GIL？
+A: Python 解释器中的互斥锁。
+第二行答案
+
+Q：TCP 三次握手？
+A：SYN, SYN-ACK, ACK`
+
+	questions := qp.ParseContent(content, "test.md")
+
+	if len(questions) != 2 {
+		t.Fatalf("expected 2 questions, got %d", len(questions))
+	}
+	if questions[0].AnswerText != "Python 解释器中的互斥锁。\n第二行答案" {
+		t.Errorf("a1 mismatch: %q", questions[0].AnswerText)
+	}
+	if questions[1].QuestionText != "TCP 三次握手？" {
+		t.Errorf("q2 mismatch: %s", questions[1].QuestionText)
+	}
+}
+
+func TestParseContent_CalloutFormat(t *testing.T) {
+	qp := &QuestionParser{}
+
+	content := `> [!question]- 什么是闭包？
+> 闭包是指有权访问另一个函数作用域中变量的函数。
+
+> [!question] 什么是原型链？
+> [!answer]
+> 原型链是 JavaScript 中实现继承的机制。
+
+> [!note] 普通笔记
+> 不应被解析`
+
+	questions := qp.ParseContent(content, "test.md")
+
+	if len(questions) != 2 {
+		t.Fatalf("expected 2 questions, got %d", len(questions))
+	}
+	if questions[0].QuestionText != "什么是闭包？" {
+		t.Errorf("q1 mismatch: %s", questions[0].QuestionText)
+	}
+	if questions[0].AnswerText != "闭包是指有权访问另一个函数作用域中变量的函数。" {
+		t.Errorf("a1 mismatch: %s", questions[0].AnswerText)
+	}
+	if questions[1].AnswerText != "原型链是 JavaScript 中实现继承的机制。" {
+		t.Errorf("a2 mismatch: %q", questions[1].AnswerText)
+	}
+}
+
+func TestParseContent_FrontMatterFormat(t *testing.T) {
+	qp := &QuestionParser{}
+
+	// Without front matter the "# q" markers win auto-detection
+	content := `---
+title: 混合笔记
+format: prefix
+---
+# q
+不会被解析
+# a
+因为 front matter 指定了 prefix
+
+Q: 前缀问题
+A: 前缀答案`
+
+	questions := qp.ParseContent(content, "test.md")
+
+	if len(questions) != 1 {
+		t.Fatalf("expected 1 question, got %d", len(questions))
+	}
+	if questions[0].QuestionText != "前缀问题" {
+		t.Errorf("q mismatch: %s", questions[0].QuestionText)
+	}
+}
+
+func TestParseContent_DirFormats(t *testing.T) {
+	qp := &QuestionParser{DirFormats: map[string][]string{
+		"notes":        {"prefix"},
+		"notes/mixed":  {"qa", "prefix"},
+		"notes/legacy": {"qa"},
+	}}
+
+	content := "# q\nQ1\n# a\nA1\n\nQ: Q2\nA: A2"
+
+	if got := len(qp.ParseContent(content, "notes/a.md")); got != 1 {
+		t.Errorf("notes: expected 1 question, got %d", got)
+	}
+	if got := len(qp.ParseContent(content, "notes/mixed/a.md")); got != 2 {
+		t.Errorf("notes/mixed: expected 2 questions, got %d", got)
+	}
+	if got := len(qp.ParseContent(content, "notes/legacy/deep/a.md")); got != 1 {
+		t.Errorf("notes/legacy: expected 1 question, got %d", got)
+	}
+	if got := len(qp.ParseContent(content, "other/a.md")); got != 1 {
+		t.Errorf("other: expected 1 auto-detected question, got %d", got)
+	}
+}
+
+func TestNewQuestionParser_FormatConfig(t *testing.T) {
+	dir := t.TempDir()
+	wd, _ := os.Getwd()
+	os.Chdir(dir)
+	defer os.Chdir(wd)
+
+	notes := filepath.Join(dir, "notes")
+	os.WriteFile("question_input_linux", []byte("questions\n"+notes+" format=prefix, callout\n"), 0644)
+	os.WriteFile("question_input", []byte("questions\n"+notes+" format=prefix, callout\n"), 0644)
+
+	qp, err := NewQuestionParser()
+	if err != nil {
+		t.Fatalf("NewQuestionParser failed: %v", err)
+	}
+	if len(qp.QuestionsDirs) != 2 || qp.QuestionsDirs[1] != notes {
+		t.Fatalf("unexpected dirs: %v", qp.QuestionsDirs)
+	}
+	if names := qp.DirFormats[notes]; len(names) != 2 || names[0] != "prefix" || names[1] != "callout" {
+		t.Errorf("unexpected formats: %v", names)
+	}
+
+	os.WriteFile("question_input_linux", []byte(notes+" format=nope\n"), 0644)
+	os.WriteFile("question_input", []byte(notes+" format=nope\n"), 0644)
+	if _, err := NewQuestionParser(); err == nil {
+		t.Error("expected error for unknown format")
+	}
+	// Explicit directories do not read the config file
+	if _, err := NewQuestionParser(notes); err != nil {
+		t.Errorf("expected explicit dirs to ignore the config, got %v", err)
+	}
+}
+
+type reverseFormat struct{}
+
+func (reverseFormat) Name() string               { return "test-reverse" }
+func (reverseFormat) Detect(content string) bool { return false }
+func (reverseFormat) Parse(content, sourceFile string) []*Question {
+	return []*Question{{QuestionText: "R", AnswerText: content, SourceFile: sourceFile}}
+}
+
+func TestRegisterFormat_Custom(t *testing.T) {
+	RegisterFormat(reverseFormat{})
+
+	if _, ok := LookupFormat("test-reverse"); !ok {
+		t.Fatal("registered format not found")
+	}
+
+	qp := &QuestionParser{}
+	questions := qp.ParseContent("---\nformat: test-reverse\n---\nbody", "test.md")
+	if len(questions) != 1 || questions[0].AnswerText != "body" {
+		t.Fatalf("custom format not used: %+v", questions)
+	}
+}
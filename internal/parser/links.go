@@ -0,0 +1,70 @@
+package parser
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+var (
+	// [text](target "title") and ![alt](target)
+	markdownLinkRe = regexp.MustCompile(`(!?\[[^\]]*\]\()(<[^>]*>|[^)\s]+)((?:\s+"[^"]*")?\))`)
+	// <img src="target">
+	htmlImageRe = regexp.MustCompile(`(?i)(<img\b[^>]*?\bsrc\s*=\s*["'])([^"']+)(["'])`)
+)
+
+// RewriteLinks replaces the targets of relative markdown links, images and
+// HTML <img> sources outside fenced code blocks. rewrite is called with the
+// local path of each target (unescaped, without query or fragment) and
+// returns the replacement URL, or false to leave the link untouched.
+func RewriteLinks(text string, rewrite func(path string) (string, bool)) string {
+	replace := func(re *regexp.Regexp, s string) string {
+		return re.ReplaceAllStringFunc(s, func(match string) string {
+			m := re.FindStringSubmatch(match)
+			path, ok := LocalLinkPath(m[2])
+			if !ok {
+				return match
+			}
+			replacement, ok := rewrite(path)
+			if !ok {
+				return match
+			}
+			return m[1] + replacement + m[3]
+		})
+	}
+
+	lines := strings.Split(text, "\n")
+	inFence := false
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			inFence = !inFence
+			continue
+		}
+		if inFence {
+			continue
+		}
+		line = replace(markdownLinkRe, line)
+		lines[i] = replace(htmlImageRe, line)
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// LocalLinkPath returns the file path a link target refers to, or false if the
+// target is absolute, an anchor or uses a URL scheme.
+func LocalLinkPath(target string) (string, bool) {
+	target = strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(target), "<"), ">")
+	if target == "" || strings.HasPrefix(target, "#") || strings.HasPrefix(target, "/") ||
+		strings.HasPrefix(target, `\`) {
+		return "", false
+	}
+
+	u, err := url.Parse(target)
+	if err != nil || u.Scheme != "" || u.Host != "" {
+		return "", false
+	}
+	if u.Path == "" {
+		return "", false
+	}
+	return u.Path, true
+}
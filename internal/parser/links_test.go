@@ -0,0 +1,30 @@
+package parser
+
+import "testing"
+
+func TestRewriteLinks(t *testing.T) {
+	text := "看图 ![架构](img/arch%20v2.png \"title\") 和 [文档](docs/a.pdf#page=2)\n" +
+		"<img width=\"100\" src='img/b.png'>\n" +
+		"外链 ![x](https://example.com/x.png) 锚点 [y](#top) 绝对 ![z](/etc/z.png)\n" +
+		"```\n![code](img/arch.png)\n```"
+
+	var seen []string
+	got := RewriteLinks(text, func(path string) (string, bool) {
+		seen = append(seen, path)
+		if path == "docs/a.pdf" {
+			return "", false
+		}
+		return "/api/assets/" + path, true
+	})
+
+	want := "看图 ![架构](/api/assets/img/arch v2.png \"title\") 和 [文档](docs/a.pdf#page=2)\n" +
+		"<img width=\"100\" src='/api/assets/img/b.png'>\n" +
+		"外链 ![x](https://example.com/x.png) 锚点 [y](#top) 绝对 ![z](/etc/z.png)\n" +
+		"```\n![code](img/arch.png)\n```"
+	if got != want {
+		t.Errorf("unexpected result:\n%s", got)
+	}
+	if len(seen) != 3 {
+		t.Errorf("expected 3 rewrite calls, got %v", seen)
+	}
+}
@@ -2,6 +2,7 @@ package parser
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -18,48 +19,35 @@ type Question struct {
 	QuestionText string
 	AnswerText   string
 	SourceFile   string
+	// Category overrides the category derived from SourceFile when set
+	Category string
+	Tags     []string
 }
 
 // QuestionParser handles parsing of Markdown files
 type QuestionParser struct {
 	QuestionsDirs []string
+	// DirFormats lists the formats enabled for each configured directory.
+	// Files below a directory without an entry fall back to auto-detection.
+	DirFormats map[string][]string
 }
 
 // NewQuestionParser creates a new question parser
 func NewQuestionParser(questionsDir ...string) (*QuestionParser, error) {
 	var dirs []string
+	dirFormats := make(map[string][]string)
 
-	if len(questionsDir) == 0 {
-		// Determine config file based on platform
-		configFile := "question_input"
-		if runtime.GOOS != "windows" {
-			configFile = "question_input_linux"
+	if len(questionsDir) > 0 {
+		dirs = questionsDir
+	} else {
+		var err error
+		if dirs, err = readQuestionConfig(dirFormats); err != nil {
+			return nil, err
 		}
-
-		if _, err := os.Stat(configFile); err == nil {
-			file, err := os.Open(configFile)
-			if err != nil {
-				return nil, err
-			}
-			defer file.Close()
-
-			scanner := bufio.NewScanner(file)
-			for scanner.Scan() {
-				line := strings.TrimSpace(scanner.Text())
-				if line != "" {
-					dirs = append(dirs, line)
-				}
-			}
-
-			if len(dirs) == 0 {
-				dirs = []string{"questions"}
-			}
-		} else {
+		if len(dirs) == 0 {
 			// Default to questions directory
 			dirs = []string{"questions"}
 		}
-	} else {
-		dirs = questionsDir
 	}
 
 	// Create directories if they don't exist
@@ -69,9 +57,48 @@ func NewQuestionParser(questionsDir ...string) (*QuestionParser, error) {
 
 	return &QuestionParser{
 		QuestionsDirs: dirs,
+		DirFormats:    dirFormats,
 	}, nil
 }
 
+// readQuestionConfig reads the configured directories from the platform's
+// config file, recording any format=name[,name] suffix in dirFormats
+func readQuestionConfig(dirFormats map[string][]string) ([]string, error) {
+	configFile := "question_input"
+	if runtime.GOOS != "windows" {
+		configFile = "question_input_linux"
+	}
+	if _, err := os.Stat(configFile); err != nil {
+		return nil, nil
+	}
+	file, err := os.Open(configFile)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var dirs []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		dir, names := line, []string(nil)
+		if idx := strings.LastIndex(line, " format="); idx >= 0 {
+			dir = strings.TrimSpace(line[:idx])
+			names = splitFormatList(line[idx+len(" format="):])
+			if _, err := resolveFormats(names); err != nil {
+				return nil, fmt.Errorf("%s: %w", configFile, err)
+			}
+			dirFormats[filepath.Clean(dir)] = names
+		}
+		dirs = append(dirs, dir)
+	}
+	return dirs, nil
+}
+
 // ParseFile parses a single markdown file
 func (qp *QuestionParser) ParseFile(filePath string) ([]*Question, error) {
 	content, err := ioutil.ReadFile(filePath)
@@ -82,96 +109,64 @@ func (qp *QuestionParser) ParseFile(filePath string) ([]*Question, error) {
 	return qp.ParseContent(string(content), filePath), nil
 }
 
-// ParseContent parses content directly from a string
+// ParseContent parses content directly from a string. The formats used are
+// taken from the file's front matter ("format: heading"), then from the
+// directory configuration, and otherwise detected from the content.
 func (qp *QuestionParser) ParseContent(content, sourceFile string) []*Question {
-	var questions []*Question
-
-	// Find question and answer markers using simple string splitting
-	// This approach is more compatible with Go's regexp limitations
+	meta, body := splitFrontMatter(content)
 
-	// Find all positions of # q and # a markers
-	lines := strings.Split(content, "\n")
+	var questions []*Question
+	for _, f := range qp.formatsFor(meta, body, sourceFile) {
+		questions = append(questions, f.Parse(body, sourceFile)...)
+	}
 
-	var qPositions []int
-	var aPositions []int
+	return questions
+}
 
-	for i, line := range lines {
-		trimmed := strings.TrimSpace(line)
-		if trimmed == "# q" || strings.HasPrefix(trimmed, "# q ") ||
-			trimmed == "# question" || strings.HasPrefix(trimmed, "# question ") {
-			qPositions = append(qPositions, i)
-		} else if trimmed == "# a" || strings.HasPrefix(trimmed, "# a ") ||
-			trimmed == "# answer" || strings.HasPrefix(trimmed, "# answer ") {
-			aPositions = append(aPositions, i)
+// formatsFor picks the formats used to parse one file
+func (qp *QuestionParser) formatsFor(meta map[string]string, body, sourceFile string) []Format {
+	if names := splitFormatList(meta["format"]); len(names) > 0 {
+		if fs, err := resolveFormats(names); err == nil {
+			return fs
 		}
 	}
 
-	// Match questions with answers
-	for i, qPos := range qPositions {
-		if i < len(aPositions) {
-			aPos := aPositions[i]
-
-			// Extract question text (from qPos+1 to aPos)
-			qText := strings.Join(lines[qPos+1:aPos], "\n")
-			qText = strings.TrimSpace(qText)
-
-			// Extract answer text (from aPos+1 to next question or end)
-			nextQPos := len(lines)
-			if i+1 < len(qPositions) {
-				nextQPos = qPositions[i+1]
-			}
-			aText := strings.Join(lines[aPos+1:nextQPos], "\n")
-			aText = strings.TrimSpace(aText)
-
-			if qText != "" && aText != "" {
-				questions = append(questions, &Question{
-					QuestionText: qText,
-					AnswerText:   aText,
-					SourceFile:   sourceFile,
-				})
-			}
+	if names := qp.dirFormatsFor(sourceFile); len(names) > 0 {
+		if fs, err := resolveFormats(names); err == nil {
+			return fs
 		}
 	}
 
-	return questions
+	if f, ok := DetectFormat(body); ok {
+		return []Format{f}
+	}
+	return nil
 }
 
-// ParseAllFiles parses all markdown files in configured directories
-func (qp *QuestionParser) ParseAllFiles() ([]*Question, error) {
-	var allQuestions []*Question
-
-	for _, dir := range qp.QuestionsDirs {
-		fmt.Printf("\nScanning directory: %s (recursively)\n", dir)
-
-		// Walk through directory recursively to find .md files
-		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
-			if err != nil {
-				return err
-			}
-
-			if !info.IsDir() && strings.HasSuffix(strings.ToLower(path), ".md") {
-				fmt.Printf("Parsing: %s\n", path)
-
-				questions, err := qp.ParseFile(path)
-				if err != nil {
-					return err
-				}
-
-				count := len(questions)
-				fmt.Printf("  Extracted %d questions\n", count)
-
-				allQuestions = append(allQuestions, questions...)
-			}
-
-			return nil
-		})
+// dirFormatsFor returns the formats configured for the closest directory
+// containing the file
+func (qp *QuestionParser) dirFormatsFor(sourceFile string) []string {
+	if len(qp.DirFormats) == 0 {
+		return nil
+	}
 
-		if err != nil {
-			return nil, err
+	path := filepath.Clean(sourceFile)
+	best := ""
+	var names []string
+	for dir, dirNames := range qp.DirFormats {
+		if dir != "." && !strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			continue
+		}
+		if len(dir) > len(best) || names == nil {
+			best, names = dir, dirNames
 		}
 	}
+	return names
+}
 
-	return allQuestions, nil
+// ParseAllFiles parses all markdown files in configured directories
+func (qp *QuestionParser) ParseAllFiles() ([]*Question, error) {
+	return qp.ParseAllFilesContext(context.Background(), ScanOptions{})
 }
 
 // ConvertToModel converts parser questions to model questions for a specific user
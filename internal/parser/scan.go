@@ -0,0 +1,181 @@
+package parser
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// Progress event types
+const (
+	ProgressFile  = "file"  // A file was parsed
+	ProgressError = "error" // A file could not be read
+	ProgressDone  = "done"  // Scanning finished
+)
+
+// ProgressEvent reports scanning progress. Counters are running totals.
+type ProgressEvent struct {
+	Type           string `json:"type"`
+	File           string `json:"file,omitempty"`
+	Questions      int    `json:"questions"` // Questions found in File
+	Error          string `json:"error,omitempty"`
+	FilesScanned   int    `json:"files_scanned"`
+	FilesTotal     int    `json:"files_total"`
+	QuestionsFound int    `json:"questions_found"`
+	Errors         int    `json:"errors"`
+}
+
+// ScanOptions configures ParseAllFilesContext
+type ScanOptions struct {
+	// Workers bounds the number of files parsed concurrently. Zero means
+	// one worker per CPU.
+	Workers int
+	// Progress is called for every parsed file and once at the end. Calls
+	// are made from a single goroutine, in completion order.
+	Progress func(ProgressEvent)
+}
+
+type scanResult struct {
+	index     int
+	questions []*Question
+	err       error
+}
+
+// ParseAllFilesContext parses all markdown files in the configured directories
+// with a bounded pool of workers. Files that cannot be read are reported as
+// error events and skipped. Questions are returned in file order regardless
+// of which worker finished first. Cancelling ctx stops the scan.
+func (qp *QuestionParser) ParseAllFilesContext(ctx context.Context, opts ScanOptions) ([]*Question, error) {
+	files, err := qp.markdownFiles()
+	if err != nil {
+		return nil, err
+	}
+	return qp.scan(ctx, files, qp.ParseFile, opts)
+}
+
+// ParseFilesContext works like ParseAllFilesContext for files that are not on
+// disk, such as zip archive entries. read returns the content of a name, which
+// is also used as the questions' source file.
+func (qp *QuestionParser) ParseFilesContext(ctx context.Context, names []string, read func(name string) ([]byte, error), opts ScanOptions) ([]*Question, error) {
+	return qp.scan(ctx, names, func(name string) ([]*Question, error) {
+		content, err := read(name)
+		if err != nil {
+			return nil, err
+		}
+		return qp.ParseContent(string(content), name), nil
+	}, opts)
+}
+
+func (qp *QuestionParser) scan(ctx context.Context, files []string, parse func(string) ([]*Question, error), opts ScanOptions) ([]*Question, error) {
+	workers := opts.Workers
+	if workers <= 0 {
+		workers = runtime.NumCPU()
+	}
+	if workers > len(files) {
+		workers = len(files)
+	}
+
+	jobs := make(chan int)
+	results := make(chan scanResult)
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for index := range jobs {
+				questions, err := parse(files[index])
+				select {
+				case results <- scanResult{index: index, questions: questions, err: err}:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	go func() {
+		defer close(jobs)
+		for i := range files {
+			select {
+			case jobs <- i:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	go func() {
+		wg.Wait()
+		close(results)
+	}()
+
+	progress := ProgressEvent{FilesTotal: len(files)}
+	report := func(ev ProgressEvent) {
+		if opts.Progress != nil {
+			opts.Progress(ev)
+		}
+	}
+
+	byFile := make([][]*Question, len(files))
+	for result := range results {
+		progress.FilesScanned++
+		ev := progress
+		ev.File = files[result.index]
+		if result.err != nil {
+			progress.Errors++
+			ev.Type = ProgressError
+			ev.Error = result.err.Error()
+			ev.Errors = progress.Errors
+		} else {
+			byFile[result.index] = result.questions
+			progress.QuestionsFound += len(result.questions)
+			ev.Type = ProgressFile
+			ev.Questions = len(result.questions)
+			ev.QuestionsFound = progress.QuestionsFound
+		}
+		report(ev)
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	var allQuestions []*Question
+	for _, questions := range byFile {
+		allQuestions = append(allQuestions, questions...)
+	}
+
+	done := progress
+	done.Type = ProgressDone
+	report(done)
+
+	return allQuestions, nil
+}
+
+// markdownFiles lists the .md files below the configured directories
+func (qp *QuestionParser) markdownFiles() ([]string, error) {
+	var files []string
+	for _, dir := range qp.QuestionsDirs {
+		err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				// The configured directory itself must be readable,
+				// unreadable entries below it are skipped
+				if path == dir {
+					return err
+				}
+				return nil
+			}
+			if !info.IsDir() && strings.HasSuffix(strings.ToLower(path), ".md") {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
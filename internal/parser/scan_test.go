@@ -0,0 +1,108 @@
+package parser
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeQAFiles(t *testing.T, dir string, n int) {
+	t.Helper()
+	for i := 0; i < n; i++ {
+		sub := filepath.Join(dir, fmt.Sprintf("d%d", i%3))
+		os.MkdirAll(sub, 0755)
+		content := fmt.Sprintf("# q\n问题 %d\n# a\n答案 %d\n", i, i)
+		os.WriteFile(filepath.Join(sub, fmt.Sprintf("f%02d.md", i)), []byte(content), 0644)
+	}
+}
+
+func TestParseAllFilesContext_Progress(t *testing.T) {
+	dir := t.TempDir()
+	writeQAFiles(t, dir, 20)
+	os.WriteFile(filepath.Join(dir, "notes.txt"), []byte("# q\n忽略\n# a\n忽略"), 0644)
+
+	qp := &QuestionParser{QuestionsDirs: []string{dir}}
+	var events []ProgressEvent
+	questions, err := qp.ParseAllFilesContext(context.Background(), ScanOptions{
+		Workers:  4,
+		Progress: func(ev ProgressEvent) { events = append(events, ev) },
+	})
+	if err != nil {
+		t.Fatalf("ParseAllFilesContext failed: %v", err)
+	}
+	if len(questions) != 20 {
+		t.Fatalf("expected 20 questions, got %d", len(questions))
+	}
+
+	// Results keep file order even though workers finish in any order
+	serial, _ := (&QuestionParser{QuestionsDirs: []string{dir}}).ParseAllFilesContext(context.Background(), ScanOptions{Workers: 1})
+	for i := range questions {
+		if questions[i].QuestionText != serial[i].QuestionText {
+			t.Fatalf("order differs at %d: %s vs %s", i, questions[i].QuestionText, serial[i].QuestionText)
+		}
+	}
+
+	if len(events) != 21 {
+		t.Fatalf("expected 20 file events and 1 done event, got %d", len(events))
+	}
+	last := events[len(events)-1]
+	if last.Type != ProgressDone || last.FilesScanned != 20 || last.FilesTotal != 20 || last.QuestionsFound != 20 {
+		t.Errorf("unexpected done event: %+v", last)
+	}
+	for i, ev := range events[:20] {
+		if ev.Type != ProgressFile || ev.FilesScanned != i+1 || ev.Questions != 1 {
+			t.Errorf("unexpected file event %d: %+v", i, ev)
+		}
+	}
+}
+
+func TestParseAllFilesContext_FileError(t *testing.T) {
+	dir := t.TempDir()
+	writeQAFiles(t, dir, 2)
+	// A dangling symlink passes the walk but cannot be read
+	os.Symlink(filepath.Join(dir, "missing"), filepath.Join(dir, "broken.md"))
+
+	qp := &QuestionParser{QuestionsDirs: []string{dir}}
+	var errorEvents int
+	questions, err := qp.ParseAllFilesContext(context.Background(), ScanOptions{
+		Progress: func(ev ProgressEvent) {
+			if ev.Type == ProgressError {
+				errorEvents++
+			}
+		},
+	})
+	if err != nil {
+		t.Fatalf("file errors should not abort the scan: %v", err)
+	}
+	if len(questions) != 2 || errorEvents != 1 {
+		t.Errorf("expected 2 questions and 1 error, got %d and %d", len(questions), errorEvents)
+	}
+}
+
+func TestParseAllFilesContext_Cancel(t *testing.T) {
+	dir := t.TempDir()
+	writeQAFiles(t, dir, 50)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	qp := &QuestionParser{QuestionsDirs: []string{dir}}
+	_, err := qp.ParseAllFilesContext(ctx, ScanOptions{
+		Workers: 2,
+		Progress: func(ev ProgressEvent) {
+			if ev.FilesScanned == 5 {
+				cancel()
+			}
+		},
+	})
+	if err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+}
+
+func TestParseAllFilesContext_MissingDir(t *testing.T) {
+	qp := &QuestionParser{QuestionsDirs: []string{filepath.Join(t.TempDir(), "nope")}}
+	if _, err := qp.ParseAllFiles(); err == nil {
+		t.Error("expected error for missing directory")
+	}
+}
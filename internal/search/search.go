@@ -0,0 +1,292 @@
+// Package search provides full-text search over a user's questions.
+//
+// Questions are indexed in an SQLite FTS5 table in tokenized form (see
+// Tokenize), ranked with BM25 and highlighted against the original text.
+// Triggers on the questions table queue every insert, edit and delete, and
+// the queue is applied before each search, so the index stays in sync no
+// matter which code path changed a question. FTS5 needs the sqlite_fts5
+// build tag; without it searches fall back to scanning with LIKE.
+//
+// Cards of subscribed decks hold no text of their own; they are matched
+// against the author's question they point to (Question.OriginID).
+package search
+
+import (
+	"sort"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"self-improvement/internal/models"
+)
+
+// SnippetWidth is the length of answer snippets in characters
+const SnippetWidth = 80
+
+// Engine names reported by Index.Engine
+const (
+	EngineFTS5 = "fts5"
+	EngineLike = "like"
+)
+
+var schema = []string{
+	`CREATE VIRTUAL TABLE IF NOT EXISTS questions_fts USING fts5(question_id UNINDEXED, user_id UNINDEXED, question, answer)`,
+	`CREATE TABLE IF NOT EXISTS questions_fts_pending (question_id TEXT PRIMARY KEY, user_id INTEGER NOT NULL)`,
+	`CREATE INDEX IF NOT EXISTS idx_questions_fts_pending_user ON questions_fts_pending(user_id)`,
+	`CREATE TRIGGER IF NOT EXISTS questions_fts_insert AFTER INSERT ON questions BEGIN
+		INSERT OR REPLACE INTO questions_fts_pending (question_id, user_id) VALUES (new.id, new.user_id);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS questions_fts_update AFTER UPDATE OF question_text, answer_text, deleted_at ON questions BEGIN
+		INSERT OR REPLACE INTO questions_fts_pending (question_id, user_id) VALUES (new.id, new.user_id);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS questions_fts_delete AFTER DELETE ON questions BEGIN
+		INSERT OR REPLACE INTO questions_fts_pending (question_id, user_id) VALUES (old.id, old.user_id);
+	END`,
+}
+
+// Index searches questions
+type Index struct {
+	DB  *gorm.DB
+	fts bool
+}
+
+// Options narrows and pages a search
+type Options struct {
+	Category string
+	Offset   int
+	Limit    int
+}
+
+// Result is one matching question
+type Result struct {
+	Question          *models.Question
+	Score             float64 // Higher is better
+	QuestionHighlight string  // Full question text with matches marked
+	AnswerSnippet     string  // Part of the answer around the first match
+}
+
+// New sets up the index. Existing questions are queued for indexing the first
+// time the FTS5 table is created.
+func New(db *gorm.DB) (*Index, error) {
+	var exists int64
+	db.Raw("SELECT COUNT(*) FROM sqlite_master WHERE name = 'questions_fts'").Scan(&exists)
+
+	if err := db.Exec(schema[0]).Error; err != nil {
+		// SQLite built without FTS5
+		return &Index{DB: db}, nil
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, stmt := range schema[1:] {
+			if err := tx.Exec(stmt).Error; err != nil {
+				return err
+			}
+		}
+		if exists == 0 {
+			return tx.Exec(`INSERT OR REPLACE INTO questions_fts_pending (question_id, user_id)
+				SELECT id, user_id FROM questions WHERE deleted_at IS NULL`).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &Index{DB: db, fts: true}, nil
+}
+
+// Engine returns the search implementation in use
+func (ix *Index) Engine() string {
+	if ix.fts {
+		return EngineFTS5
+	}
+	return EngineLike
+}
+
+// Flush applies the queued changes of a user's questions, and of the
+// authors' questions behind the user's subscribed cards, to the index
+func (ix *Index) Flush(userID uint) error {
+	if !ix.fts {
+		return nil
+	}
+	return ix.DB.Transaction(func(tx *gorm.DB) error {
+		var ids []string
+		err := tx.Raw(`SELECT question_id FROM questions_fts_pending WHERE user_id = ? OR question_id IN
+			(SELECT origin_id FROM questions WHERE user_id = ? AND origin_id <> '' AND deleted_at IS NULL)`, userID, userID).Scan(&ids).Error
+		if err != nil {
+			return err
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		if err := tx.Exec("DELETE FROM questions_fts WHERE question_id IN ?", ids).Error; err != nil {
+			return err
+		}
+		var questions []models.Question
+		if err := tx.Where("id IN ?", ids).Find(&questions).Error; err != nil {
+			return err
+		}
+		for _, q := range questions {
+			err := tx.Exec("INSERT INTO questions_fts (question_id, user_id, question, answer) VALUES (?, ?, ?, ?)",
+				q.ID, q.UserID, Tokenize(q.QuestionText), Tokenize(q.AnswerText)).Error
+			if err != nil {
+				return err
+			}
+		}
+		return tx.Exec("DELETE FROM questions_fts_pending WHERE question_id IN ?", ids).Error
+	})
+}
+
+// Search returns the user's questions containing all terms of the query,
+// best matches first, and the total number of matches
+func (ix *Index) Search(userID uint, query string, opts Options) ([]*Result, int64, error) {
+	terms := Terms(query)
+	if len(terms) == 0 {
+		return nil, 0, nil
+	}
+
+	var results []*Result
+	var total int64
+	var err error
+	if expr, ok := matchExpr(terms); ix.fts && ok {
+		if err := ix.Flush(userID); err != nil {
+			return nil, 0, err
+		}
+		results, total, err = ix.searchFTS(userID, expr, opts)
+	} else {
+		results, total, err = ix.searchLike(userID, terms, opts)
+	}
+	if err != nil {
+		return nil, 0, err
+	}
+
+	for _, r := range results {
+		r.QuestionHighlight = Snippet(r.Question.QuestionText, terms, len([]rune(r.Question.QuestionText)))
+		r.AnswerSnippet = Snippet(r.Question.AnswerText, terms, SnippetWidth)
+	}
+	return results, total, nil
+}
+
+func (ix *Index) searchFTS(userID uint, expr string, opts Options) ([]*Result, int64, error) {
+	from := `FROM questions_fts f JOIN questions q ON q.id = f.question_id OR q.origin_id = f.question_id
+		WHERE questions_fts MATCH ? AND q.user_id = ? AND q.deleted_at IS NULL`
+	args := []interface{}{expr, userID}
+	if opts.Category != "" {
+		from += " AND q.category = ?"
+		args = append(args, opts.Category)
+	}
+
+	var total int64
+	if err := ix.DB.Raw("SELECT COUNT(*) "+from, args...).Scan(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	var rows []struct {
+		ID   string
+		Rank float64
+	}
+	// Question matches weigh twice as much as answer matches
+	err := ix.DB.Raw("SELECT q.id AS id, bm25(questions_fts, 0, 0, 2.0, 1.0) AS rank "+from+" ORDER BY rank LIMIT ? OFFSET ?",
+		append(args, opts.Limit, opts.Offset)...).Scan(&rows).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	if len(rows) == 0 {
+		return nil, total, nil
+	}
+
+	ids := make([]string, len(rows))
+	for i, row := range rows {
+		ids[i] = row.ID
+	}
+	var questions []*models.Question
+	if err := ix.DB.Where("user_id = ? AND id IN ?", userID, ids).Find(&questions).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := ix.fillOrigins(questions); err != nil {
+		return nil, 0, err
+	}
+	byID := make(map[string]*models.Question)
+	for _, q := range questions {
+		byID[q.ID] = q
+	}
+
+	var results []*Result
+	for _, row := range rows {
+		if q := byID[row.ID]; q != nil {
+			// bm25 is negative, more negative is better
+			results = append(results, &Result{Question: q, Score: -row.Rank})
+		}
+	}
+	return results, total, nil
+}
+
+// searchLike scans the user's questions when FTS5 is unavailable or the
+// query cannot be expressed against the index
+func (ix *Index) searchLike(userID uint, terms []string, opts Options) ([]*Result, int64, error) {
+	query := ix.DB.Model(&models.Question{}).
+		Joins("LEFT JOIN questions o ON o.id = questions.origin_id AND questions.origin_id <> '' AND o.deleted_at IS NULL").
+		Where("questions.user_id = ?", userID)
+	if opts.Category != "" {
+		query = query.Where("questions.category = ?", opts.Category)
+	}
+	for _, t := range terms {
+		pattern := "%" + escapeLike(t) + "%"
+		query = query.Where(`(questions.question_text LIKE ? ESCAPE '\' OR questions.answer_text LIKE ? ESCAPE '\'
+			OR o.question_text LIKE ? ESCAPE '\' OR o.answer_text LIKE ? ESCAPE '\')`, pattern, pattern, pattern, pattern)
+	}
+
+	var questions []*models.Question
+	if err := query.Find(&questions).Error; err != nil {
+		return nil, 0, err
+	}
+	if err := ix.fillOrigins(questions); err != nil {
+		return nil, 0, err
+	}
+
+	results := make([]*Result, len(questions))
+	for i, q := range questions {
+		results[i] = &Result{Question: q, Score: score(q.QuestionText, q.AnswerText, terms)}
+	}
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Score > results[j].Score })
+
+	total := int64(len(results))
+	if opts.Offset >= len(results) {
+		return nil, total, nil
+	}
+	results = results[opts.Offset:]
+	if opts.Limit > 0 && len(results) > opts.Limit {
+		results = results[:opts.Limit]
+	}
+	return results, total, nil
+}
+
+// fillOrigins copies the author's text into subscribed cards
+func (ix *Index) fillOrigins(questions []*models.Question) error {
+	var ids []string
+	for _, q := range questions {
+		if q.OriginID != "" {
+			ids = append(ids, q.OriginID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	var origins []*models.Question
+	if err := ix.DB.Select("id, question_text, answer_text").Where("id IN ?", ids).Find(&origins).Error; err != nil {
+		return err
+	}
+	byID := make(map[string]*models.Question, len(origins))
+	for _, o := range origins {
+		byID[o.ID] = o
+	}
+	for _, q := range questions {
+		if o, ok := byID[q.OriginID]; ok {
+			q.QuestionText, q.AnswerText = o.QuestionText, o.AnswerText
+		}
+	}
+	return nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
@@ -0,0 +1,188 @@
+package search
+
+import (
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"self-improvement/internal/models"
+)
+
+func setupIndex(t *testing.T) (*gorm.DB, *Index) {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("failed to open test db: %v", err)
+	}
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.Question{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	ix, err := New(db)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	t.Logf("search engine: %s", ix.Engine())
+	return db, ix
+}
+
+func addQuestion(t *testing.T, db *gorm.DB, userID uint, id, question, answer, category string) {
+	t.Helper()
+	q := models.Question{ID: id, UserID: userID, QuestionText: question, AnswerText: answer, Category: category, NextReview: time.Now()}
+	if err := db.Create(&q).Error; err != nil {
+		t.Fatalf("create question: %v", err)
+	}
+}
+
+func ids(results []*Result) []string {
+	var out []string
+	for _, r := range results {
+		out = append(out, r.Question.ID)
+	}
+	return out
+}
+
+func TestTokenize(t *testing.T) {
+	tests := map[string]string{
+		"什么是闭包？":              "什么 么是 是闭 闭包",
+		"Go 的 goroutine 调度":   "go 的 goroutine 调度",
+		"TCP三次握手":             "tcp 三次 次握 握手",
+		"HTTP/2 multiplexing": "http 2 multiplexing",
+		"":                    "",
+	}
+	for in, want := range tests {
+		if got := Tokenize(in); got != want {
+			t.Errorf("Tokenize(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestMatchExpr(t *testing.T) {
+	expr, ok := matchExpr(Terms(`闭包 Gorout "TCP握手"`))
+	if !ok || expr != `"闭包" AND "gorout"* AND "tcp 握手"` {
+		t.Errorf("unexpected expression: %q %v", expr, ok)
+	}
+	if _, ok := matchExpr([]string{"锁"}); ok {
+		t.Error("single CJK character should not use the index")
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	text := strings.Repeat("前言", 50) + "Goroutine 由 GMP 模型调度" + strings.Repeat("后记", 50)
+	s := Snippet(text, []string{"goroutine", "gmp"}, 30)
+	if !strings.Contains(s, "<mark>Goroutine</mark> 由 <mark>GMP</mark>") {
+		t.Errorf("matches not highlighted: %q", s)
+	}
+	if !strings.HasPrefix(s, "…") || !strings.HasSuffix(s, "…") {
+		t.Errorf("expected ellipses around a cut snippet: %q", s)
+	}
+	if s := Snippet("short", []string{"x"}, 30); s != "short" {
+		t.Errorf("unexpected snippet without match: %q", s)
+	}
+}
+
+func TestSearch(t *testing.T) {
+	db, ix := setupIndex(t)
+
+	addQuestion(t, db, 1, "q1", "什么是闭包？", "闭包是能访问外部函数变量的函数。", "js")
+	addQuestion(t, db, 1, "q2", "Go 的 goroutine 如何调度？", "GMP 模型，闭包捕获循环变量需要注意。", "go")
+	addQuestion(t, db, 1, "q3", "TCP 三次握手", "SYN, SYN-ACK, ACK", "network")
+	addQuestion(t, db, 2, "q4", "闭包的用途", "其他用户的问题", "js")
+
+	results, total, err := ix.Search(1, "闭包", Options{Limit: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	// A match in the question ranks above a match in the answer
+	if total != 2 || !reflect.DeepEqual(ids(results), []string{"q1", "q2"}) {
+		t.Errorf("unexpected results: %d %v", total, ids(results))
+	}
+	if results[0].QuestionHighlight != "什么是<mark>闭包</mark>？" {
+		t.Errorf("unexpected highlight: %q", results[0].QuestionHighlight)
+	}
+	if !strings.Contains(results[1].AnswerSnippet, "<mark>闭包</mark>") {
+		t.Errorf("answer snippet not highlighted: %q", results[1].AnswerSnippet)
+	}
+
+	if results, _, _ := ix.Search(1, "GOROUT", Options{Limit: 10}); !reflect.DeepEqual(ids(results), []string{"q2"}) {
+		t.Errorf("prefix search mismatch: %v", ids(results))
+	}
+	if results, _, _ := ix.Search(1, "握手 syn", Options{Limit: 10}); !reflect.DeepEqual(ids(results), []string{"q3"}) {
+		t.Errorf("mixed search mismatch: %v", ids(results))
+	}
+	if _, total, _ := ix.Search(1, "闭包", Options{Category: "go", Limit: 10}); total != 1 {
+		t.Errorf("category filter mismatch: %d", total)
+	}
+	if results, _, _ := ix.Search(1, "握", Options{Limit: 10}); !reflect.DeepEqual(ids(results), []string{"q3"}) {
+		t.Errorf("single character search mismatch: %v", ids(results))
+	}
+	if results, total, _ := ix.Search(1, "闭包", Options{Offset: 1, Limit: 1}); total != 2 || !reflect.DeepEqual(ids(results), []string{"q2"}) {
+		t.Errorf("paging mismatch: %d %v", total, ids(results))
+	}
+
+	// Edits and deletes are picked up by the next search
+	db.Model(&models.Question{}).Where("id = ?", "q3").Update("answer_text", "闭包无关")
+	db.Where("id = ?", "q1").Delete(&models.Question{})
+	results, _, _ = ix.Search(1, "闭包", Options{Limit: 10})
+	got := ids(results)
+	sort.Strings(got)
+	if !reflect.DeepEqual(got, []string{"q2", "q3"}) {
+		t.Errorf("index out of sync after edit and delete: %v", ids(results))
+	}
+}
+
+func TestSearch_SubscribedCards(t *testing.T) {
+	db, ix := setupIndex(t)
+
+	addQuestion(t, db, 1, "q1", "什么是闭包？", "闭包是能访问外部函数变量的函数。", "js")
+	sub := models.Question{ID: "s1", UserID: 2, Category: "订阅/js", DeckID: 1, OriginID: "q1", NextReview: time.Now()}
+	if err := db.Create(&sub).Error; err != nil {
+		t.Fatalf("create subscribed card: %v", err)
+	}
+
+	results, total, err := ix.Search(2, "闭包", Options{Limit: 10})
+	if err != nil {
+		t.Fatalf("Search failed: %v", err)
+	}
+	if total != 1 || !reflect.DeepEqual(ids(results), []string{"s1"}) {
+		t.Fatalf("expected the subscribed card, got %d %v", total, ids(results))
+	}
+	if results[0].Question.QuestionText != "什么是闭包？" || results[0].QuestionHighlight != "什么是<mark>闭包</mark>？" {
+		t.Errorf("subscribed card without the author's text: %+v", results[0])
+	}
+	if _, total, _ := ix.Search(2, "闭包", Options{Category: "订阅/js", Limit: 10}); total != 1 {
+		t.Errorf("category filter should use the subscriber's category: %d", total)
+	}
+
+	// The author's edits reach the subscriber's searches
+	db.Model(&models.Question{}).Where("id = ?", "q1").Update("question_text", "什么是协程？")
+	if results, _, _ := ix.Search(2, "协程", Options{Limit: 10}); !reflect.DeepEqual(ids(results), []string{"s1"}) {
+		t.Errorf("author's edit not picked up: %v", ids(results))
+	}
+	if _, total, _ := ix.Search(1, "协程", Options{Limit: 10}); total != 1 {
+		t.Errorf("author should still find their card once: %d", total)
+	}
+}
+
+func TestNew_IndexesExistingQuestions(t *testing.T) {
+	db, _ := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	db.AutoMigrate(&models.Question{})
+	addQuestion(t, db, 1, "q1", "Redis 持久化", "RDB 和 AOF", "db")
+
+	ix, err := New(db)
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	if _, total, _ := ix.Search(1, "持久化", Options{Limit: 10}); total != 1 {
+		t.Errorf("existing question not indexed")
+	}
+}
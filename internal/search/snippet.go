@@ -0,0 +1,117 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Markers wrapped around matched terms in snippets
+const (
+	MarkStart = "<mark>"
+	MarkEnd   = "</mark>"
+)
+
+// matches returns the rune ranges of all case-insensitive term occurrences
+func matches(text []rune, terms []string) [][2]int {
+	lower := make([]rune, len(text))
+	for i, r := range text {
+		lower[i] = unicode.ToLower(r)
+	}
+
+	marked := make([]bool, len(text)+1)
+	for _, term := range terms {
+		t := []rune(term)
+		if len(t) == 0 {
+			continue
+		}
+		for i := 0; i+len(t) <= len(lower); i++ {
+			if string(lower[i:i+len(t)]) == term {
+				for j := i; j < i+len(t); j++ {
+					marked[j] = true
+				}
+			}
+		}
+	}
+
+	var ranges [][2]int
+	for i := 0; i < len(text); i++ {
+		if !marked[i] {
+			continue
+		}
+		start := i
+		for i < len(text) && marked[i] {
+			i++
+		}
+		ranges = append(ranges, [2]int{start, i})
+	}
+	return ranges
+}
+
+// Snippet returns about width characters of text around the first match with
+// all matches highlighted. Text without matches is cut from the start.
+func Snippet(text string, terms []string, width int) string {
+	runes := []rune(strings.Join(strings.Fields(text), " "))
+	ranges := matches(runes, terms)
+
+	start := 0
+	if len(ranges) > 0 {
+		start = ranges[0][0] - width/4
+		if start < 0 {
+			start = 0
+		}
+	}
+	end := start + width
+	if end > len(runes) {
+		end = len(runes)
+		if start = end - width; start < 0 {
+			start = 0
+		}
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for _, r := range ranges {
+		if r[1] <= start || r[0] >= end {
+			continue
+		}
+		from, to := max(r[0], start), min(r[1], end)
+		b.WriteString(string(runes[pos:from]))
+		b.WriteString(MarkStart + string(runes[from:to]) + MarkEnd)
+		pos = to
+	}
+	b.WriteString(string(runes[pos:end]))
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
+
+// score ranks a match for the fallback search: occurrences in the question
+// weigh twice as much as occurrences in the answer
+func score(question, answer string, terms []string) float64 {
+	count := func(text string) int {
+		n := 0
+		for _, r := range matches([]rune(text), terms) {
+			n += r[1] - r[0]
+		}
+		return n
+	}
+	return float64(2*count(question) + count(answer))
+}
+
+func min(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func max(a, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
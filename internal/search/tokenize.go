@@ -0,0 +1,105 @@
+package search
+
+import (
+	"strings"
+	"unicode"
+)
+
+// isCJK reports whether r belongs to a script written without spaces
+func isCJK(r rune) bool {
+	return unicode.In(r, unicode.Han, unicode.Hiragana, unicode.Katakana, unicode.Hangul)
+}
+
+func isWordRune(r rune) bool {
+	return unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_'
+}
+
+// Tokenize converts text to the form stored in the index. Words in scripts
+// with spaces are kept whole and lowercased; runs of CJK characters become
+// overlapping bigrams ("闭包函数" -> "闭包 包函 函数") so that any substring of two
+// or more characters can be matched as a phrase without a word dictionary.
+func Tokenize(text string) string {
+	return strings.Join(tokens(text), " ")
+}
+
+func tokens(text string) []string {
+	var out []string
+	var word []rune
+	var cjk []rune
+
+	flushWord := func() {
+		if len(word) > 0 {
+			out = append(out, string(word))
+			word = word[:0]
+		}
+	}
+	flushCJK := func() {
+		switch {
+		case len(cjk) == 1:
+			out = append(out, string(cjk))
+		case len(cjk) > 1:
+			for i := 0; i+1 < len(cjk); i++ {
+				out = append(out, string(cjk[i:i+2]))
+			}
+		}
+		cjk = cjk[:0]
+	}
+
+	for _, r := range strings.ToLower(text) {
+		switch {
+		case isCJK(r):
+			flushWord()
+			cjk = append(cjk, r)
+		case isWordRune(r):
+			flushCJK()
+			word = append(word, r)
+		default:
+			flushWord()
+			flushCJK()
+		}
+	}
+	flushWord()
+	flushCJK()
+	return out
+}
+
+// Terms splits a search query into lowercased terms
+func Terms(query string) []string {
+	var terms []string
+	seen := make(map[string]bool)
+	for _, t := range strings.Fields(strings.ToLower(query)) {
+		t = strings.Trim(t, "\"'*()")
+		if t != "" && !seen[t] {
+			seen[t] = true
+			terms = append(terms, t)
+		}
+	}
+	return terms
+}
+
+// matchExpr builds an FTS5 MATCH expression requiring all terms. Each term is
+// a phrase of its tokens; a trailing word is matched as a prefix so partial
+// identifiers ("gorout") still find "goroutine". ok is false when a term
+// cannot be expressed against the bigram index (a single CJK character).
+func matchExpr(terms []string) (expr string, ok bool) {
+	var parts []string
+	for _, term := range terms {
+		toks := tokens(term)
+		if len(toks) == 0 {
+			continue
+		}
+		last := []rune(toks[len(toks)-1])
+		if len(toks) == 1 && len(last) == 1 && isCJK(last[0]) {
+			return "", false
+		}
+		phrase := `"` + strings.Join(toks, " ") + `"`
+		if !isCJK(last[len(last)-1]) {
+			phrase += "*"
+		}
+		parts = append(parts, phrase)
+	}
+	if len(parts) == 0 {
+		return "", false
+	}
+	return strings.Join(parts, " AND "), true
+}
@@ -0,0 +1,36 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// getAnalyticsHandler returns the review heatmap, retention by interval,
+// weekly accuracy per category, and the level and maturity breakdown.
+// Query: days (heatmap, 1-366, default 365), weeks (accuracy trend, 1-52,
+// default 12).
+func getAnalyticsHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	days, err := strconv.Atoi(c.DefaultQuery("days", "365"))
+	if err != nil || days < 1 || days > 366 {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "days 必须在 1 到 366 之间"})
+		return
+	}
+	weeks, err := strconv.Atoi(c.DefaultQuery("weeks", "12"))
+	if err != nil || weeks < 1 || weeks > 52 {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "weeks 必须在 1 到 52 之间"})
+		return
+	}
+
+	analytics, err := sr.GetAnalytics(userID, days, weeks)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取学习分析失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: analytics})
+}
@@ -0,0 +1,68 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestE2E_Analytics(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "analyticsuser")
+
+	for _, q := range []map[string]string{
+		{"question": "Q1", "answer": "A1", "category": "go/concurrency"},
+		{"question": "Q2", "answer": "A2", "category": "linux"},
+	} {
+		apiJSON(t, router, token, "POST", "/api/add-question", q)
+	}
+	_, resp := apiJSON(t, router, token, "GET", "/api/questions?page_size=10&sort=created_at", nil)
+	first := resp.Data.(map[string]interface{})["questions"].([]interface{})[0].(map[string]interface{})
+	reviewQuestion(t, router, token, first["id"].(string), 1)
+	reviewQuestion(t, router, token, first["id"].(string), 4)
+
+	code, resp := apiJSON(t, router, token, "GET", "/api/analytics", nil)
+	if code != http.StatusOK {
+		t.Fatalf("analytics failed: %d %v", code, resp)
+	}
+	data := resp.Data.(map[string]interface{})
+
+	heatmap := data["heatmap"].([]interface{})
+	if len(heatmap) != 1 {
+		t.Fatalf("expected one day in the heatmap, got %v", heatmap)
+	}
+	today := heatmap[0].(map[string]interface{})
+	if today["date"] != time.Now().Format("2006-01-02") || today["reviews"].(float64) != 2 || today["correct"].(float64) != 1 {
+		t.Errorf("unexpected heatmap day: %v", today)
+	}
+
+	// Only the second review follows an interval, and it failed
+	var reviews, passed float64
+	for _, item := range data["retention"].([]interface{}) {
+		b := item.(map[string]interface{})
+		reviews += b["reviews"].(float64)
+		passed += b["passed"].(float64)
+	}
+	if reviews != 1 || passed != 0 {
+		t.Errorf("unexpected retention: %v", data["retention"])
+	}
+
+	trend := data["accuracy_trend"].([]interface{})
+	if len(trend) != 1 || trend[0].(map[string]interface{})["category"] != "go" {
+		t.Errorf("expected a trend for go only, got %v", trend)
+	}
+	if len(data["levels"].([]interface{})) != 4 {
+		t.Errorf("expected 4 levels, got %v", data["levels"])
+	}
+	maturity := data["maturity"].(map[string]interface{})
+	if maturity["new"].(float64) != 1 || maturity["learning"].(float64) != 1 {
+		t.Errorf("unexpected maturity: %v", maturity)
+	}
+
+	if code, _ := apiJSON(t, router, token, "GET", "/api/analytics?days=0", nil); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for days=0, got %d", code)
+	}
+	if code, _ := apiJSON(t, router, token, "GET", "/api/analytics?weeks=53", nil); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for weeks=53, got %d", code)
+	}
+}
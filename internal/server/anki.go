@@ -0,0 +1,229 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"self-improvement/internal/anki"
+	"self-improvement/internal/models"
+	"self-improvement/internal/parser"
+	"self-improvement/internal/spacedrepetition"
+	"self-improvement/internal/ziparchive"
+)
+
+// ankiFeedback maps Anki's answer buttons to our feedback levels. Hard still
+// counts as remembered, Again means the card was forgotten.
+var ankiFeedback = map[int]int{
+	1: 3, // Again
+	2: 2, // Hard
+	3: 1, // Good
+	4: 1, // Easy
+}
+
+// ankiLevel derives a memory level from the card's current interval
+func ankiLevel(intervalDays int) int {
+	switch {
+	case intervalDays >= 21:
+		return 1
+	case intervalDays >= 7:
+		return 2
+	case intervalDays >= 1:
+		return 3
+	default:
+		return 4
+	}
+}
+
+// ankiProgress converts a card's scheduling state and review history
+func ankiProgress(card *anki.Card) (*models.Question, []models.ReviewLog) {
+	state := &models.Question{
+		Level:      ankiLevel(card.IntervalDays),
+		NextReview: card.Due,
+	}
+	if state.NextReview.IsZero() {
+		state.NextReview = time.Now()
+	}
+
+	var logs []models.ReviewLog
+	for _, r := range card.Reviews {
+		feedback, ok := ankiFeedback[r.Ease]
+		if !ok {
+			continue
+		}
+
+		// Negative intervals are learning steps in seconds
+		hours := float64(r.IntervalDays) * 24
+		if r.IntervalDays < 0 {
+			hours = float64(-r.IntervalDays) / 3600
+		}
+
+		logs = append(logs, models.ReviewLog{
+			Feedback:      feedback,
+			IntervalHours: hours,
+			ReviewedAt:    r.Time,
+			Source:        "anki",
+		})
+		state.ReviewCount++
+		if feedback <= 2 {
+			state.CorrectCount++
+		}
+		reviewed := r.Time
+		state.LastReviewed = &reviewed
+	}
+
+	return state, logs
+}
+
+// ankiCategory turns a deck name like "八股文::网络" into "八股文/网络"
+func ankiCategory(deck string) string {
+	if deck == "" || deck == "Default" {
+		return ""
+	}
+	return strings.ReplaceAll(deck, "::", "/")
+}
+
+// ankiLimits returns the zip upload limits for .apkg packages. The SQLite
+// collection is a single entry that may be larger than an asset.
+func ankiLimits() ziparchive.Limits {
+	limits := zipLimits()
+	limits.MaxEntrySize = limits.MaxUncompressed
+	return limits
+}
+
+func importAnkiHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	limits := ankiLimits()
+	// Leave room for the multipart envelope around the package
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limits.MaxSize+1<<20)
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, Response{Success: false, Error: fmt.Sprintf("文件过大，最大 %d MB", limits.MaxSize>>20)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "请上传文件"})
+		return
+	}
+
+	if strings.ToLower(filepath.Ext(file.Filename)) != ".apkg" {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "只支持 .apkg 格式的 Anki 卡组"})
+		return
+	}
+
+	withHistory, _ := strconv.ParseBool(c.PostForm("with_history"))
+
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "无法打开上传的文件"})
+		return
+	}
+	defer src.Close()
+
+	pkg, err := anki.Read(src, file.Size, limits)
+	switch {
+	case errors.Is(err, ziparchive.ErrTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, Response{Success: false, Error: fmt.Sprintf("文件过大：卡组最大 %d MB，解压后最大 %d MB", limits.MaxSize>>20, limits.MaxUncompressed>>20)})
+		return
+	case errors.Is(err, ziparchive.ErrTooManyEntries):
+		c.JSON(http.StatusRequestEntityTooLarge, Response{Success: false, Error: fmt.Sprintf("卡组中的文件过多，最多 %d 个", limits.MaxEntries)})
+		return
+	case errors.Is(err, anki.ErrTooManyCards):
+		c.JSON(http.StatusRequestEntityTooLarge, Response{Success: false, Error: fmt.Sprintf("卡组中的卡片过多，最多 %d 张", anki.MaxCards)})
+		return
+	case errors.Is(err, anki.ErrUnsupportedFormat):
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "不支持新版 Anki 卡组格式，请在 Anki 导出时勾选「支持旧版本 Anki」"})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "无法解析 Anki 卡组，请确保文件未损坏"})
+		return
+	}
+
+	if len(pkg.Cards) == 0 {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "卡组中没有找到有效的卡片"})
+		return
+	}
+
+	sourceFile := "anki:" + filepath.Base(file.Filename)
+	questions := make([]*parser.Question, 0, len(pkg.Cards))
+	for _, card := range pkg.Cards {
+		questions = append(questions, &parser.Question{
+			QuestionText: card.Question,
+			AnswerText:   card.Answer,
+			SourceFile:   sourceFile,
+			Category:     ankiCategory(card.Deck),
+			Tags:         card.Tags,
+		})
+	}
+
+	unlock := lockAssets(userID)
+	storeQuestionAssets(userID, questions, func(_, link string) (string, []byte, bool) {
+		data, ok := pkg.Media(link)
+		return link, data, ok
+	})
+
+	// 只有本次新建的题目才继承 Anki 的进度，已有题目保持原有排期
+	existing := make(map[string]bool)
+	if withHistory {
+		for _, q := range questions {
+			found, _ := sr.FindQuestionByText(userID, q.QuestionText)
+			existing[q.QuestionText] = found != nil
+		}
+	}
+
+	imported, skipped, duplicates, err := importQuestions(userID, questions)
+	unlock()
+	gcAssets(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "导入问题失败"})
+		return
+	}
+
+	withProgress := 0
+	if withHistory {
+		seen := make(map[string]bool)
+		for i, card := range pkg.Cards {
+			text := questions[i].QuestionText
+			if card.New || existing[text] || seen[text] {
+				continue
+			}
+			seen[text] = true
+
+			state, logs := ankiProgress(card)
+			qID := fmt.Sprintf("q_%d_%s", userID, spacedrepetition.Hash(text))
+			if err := sr.ImportProgress(userID, qID, state, logs); err != nil {
+				continue
+			}
+			withProgress++
+		}
+	}
+	emitImport(userID, importEvent{Source: "anki", Imported: imported, Skipped: skipped, Duplicates: duplicates})
+
+	stats, err := sr.GetStats(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取统计失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"message":       "成功从 Anki 导入 " + strconv.Itoa(imported) + " 个新问题到知识库！",
+			"imported":      imported,
+			"skipped":       skipped,
+			"duplicates":    duplicates,
+			"with_progress": withProgress,
+			"stats":         stats,
+		},
+	})
+}
@@ -0,0 +1,154 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"self-improvement/internal/models"
+)
+
+func importAnki(t *testing.T, router *gin.Engine, token, filename string, data []byte, withHistory bool) *httptest.ResponseRecorder {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", filename)
+	part.Write(data)
+	if withHistory {
+		writer.WriteField("with_history", "true")
+	}
+	writer.Close()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/import/anki", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func readSampleDeck(t *testing.T) []byte {
+	t.Helper()
+	data, err := os.ReadFile("../../test/sample-deck.apkg")
+	if err != nil {
+		t.Fatalf("read sample deck: %v", err)
+	}
+	return data
+}
+
+func TestE2E_ImportAnki(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "ankiuser")
+
+	w := importAnki(t, router, token, "sample-deck.apkg", readSampleDeck(t), false)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp Response
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	data := resp.Data.(map[string]interface{})
+	if data["imported"].(float64) != 4 {
+		t.Errorf("expected 4 imported, got %v", data["imported"])
+	}
+
+	var q models.Question
+	if err := db.Where("question_text = ?", "TCP 三次握手的过程？").First(&q).Error; err != nil {
+		t.Fatalf("basic card not imported: %v", err)
+	}
+	if q.Category != "八股文/网络" {
+		t.Errorf("expected deck as category, got %q", q.Category)
+	}
+	if !strings.Contains(q.AnswerText, "![](/api/assets/") {
+		t.Errorf("media link not rewritten: %s", q.AnswerText)
+	}
+	if q.ReviewCount != 0 {
+		t.Errorf("history should not be imported by default, got %d reviews", q.ReviewCount)
+	}
+
+	// Importing again only skips
+	w = importAnki(t, router, token, "sample-deck.apkg", readSampleDeck(t), false)
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	data = resp.Data.(map[string]interface{})
+	if data["imported"].(float64) != 0 || data["skipped"].(float64) != 4 {
+		t.Errorf("expected all cards skipped, got %v", data)
+	}
+}
+
+func TestE2E_ImportAnki_WithHistory(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "ankihistory")
+
+	w := importAnki(t, router, token, "sample-deck.apkg", readSampleDeck(t), true)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var q models.Question
+	db.Where("question_text = ?", "TCP 三次握手的过程？").First(&q)
+	if q.ReviewCount != 4 || q.CorrectCount != 3 || q.Level != 1 {
+		t.Errorf("progress not imported: reviews=%d correct=%d level=%d", q.ReviewCount, q.CorrectCount, q.Level)
+	}
+	if q.LastReviewed == nil {
+		t.Error("expected last reviewed time")
+	}
+
+	var logs int64
+	db.Model(&models.ReviewLog{}).Where("question_id = ? AND source = ?", q.ID, "anki").Count(&logs)
+	if logs != 4 {
+		t.Errorf("expected 4 review logs, got %d", logs)
+	}
+
+	if got := q.NextReview.UTC().Format("2006-01-02"); got != "2026-08-10" {
+		t.Errorf("expected Anki due date, got %s", got)
+	}
+
+	// Cards never studied in Anki keep the fresh state
+	var fresh models.Question
+	db.Where("question_text LIKE ?", "HTTP 默认端口是 [...]%").First(&fresh)
+	if fresh.ReviewCount != 0 || fresh.Level != 4 {
+		t.Errorf("new card should stay fresh: %+v", fresh)
+	}
+}
+
+func TestE2E_ImportAnki_BadFile(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "ankibad")
+
+	if w := importAnki(t, router, token, "deck.zip", readSampleDeck(t), false); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for wrong extension, got %d", w.Code)
+	}
+	if w := importAnki(t, router, token, "deck.apkg", []byte("not a zip"), false); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for corrupt file, got %d", w.Code)
+	}
+
+	newFormat := buildZip(t, map[string]string{"collection.anki21b": "zstd"})
+	w := importAnki(t, router, token, "deck.apkg", newFormat, false)
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "旧版本") {
+		t.Errorf("expected unsupported format error, got %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func TestE2E_ImportAnki_Limits(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "ankilimits")
+
+	os.Setenv("ZIP_MAX_ENTRIES", "2")
+	if w := importAnki(t, router, token, "deck.apkg", readSampleDeck(t), false); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for too many entries, got %d", w.Code)
+	}
+	os.Unsetenv("ZIP_MAX_ENTRIES")
+
+	os.Setenv("ZIP_MAX_SIZE_MB", "1")
+	defer os.Unsetenv("ZIP_MAX_SIZE_MB")
+	if w := importAnki(t, router, token, "deck.apkg", make([]byte, 3<<20), false); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for an oversized upload, got %d", w.Code)
+	}
+}
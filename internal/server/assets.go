@@ -0,0 +1,182 @@
+package server
+
+import (
+	"mime"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"self-improvement/internal/assets"
+	"self-improvement/internal/models"
+	"self-improvement/internal/parser"
+)
+
+// maxAssetSize caps a single file referenced from imported markdown
+const maxAssetSize = 10 << 20
+
+var assetStore *assets.Store
+
+// assetLocks serializes asset writes and garbage collection per user, so a
+// collection never removes an asset whose question is still being imported
+var assetLocks sync.Map
+
+func lockAssets(userID uint) func() {
+	m, _ := assetLocks.LoadOrStore(userID, &sync.Mutex{})
+	mu := m.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+func assetDir() string {
+	if dir := os.Getenv("ASSET_DIR"); dir != "" {
+		return dir
+	}
+	return filepath.Join("data", "assets")
+}
+
+// assetSource resolves a link found in a question from sourceFile to the
+// referenced file. key identifies the file across questions of one import.
+type assetSource func(sourceFile, link string) (key string, data []byte, ok bool)
+
+// storeQuestionAssets copies files referenced by relative links into the
+// user's asset store and rewrites the links to their /api/assets URLs.
+// Links that cannot be resolved are left as they are.
+func storeQuestionAssets(userID uint, questions []*parser.Question, source assetSource) {
+	urls := make(map[string]string)
+
+	for _, q := range questions {
+		sourceFile := q.SourceFile
+		rewrite := func(link string) (string, bool) {
+			ext := strings.ToLower(filepath.Ext(link))
+			if ext == ".md" || ext == ".markdown" {
+				return "", false
+			}
+
+			key, data, ok := source(sourceFile, link)
+			if !ok || len(data) > maxAssetSize {
+				return "", false
+			}
+			if url, ok := urls[key]; ok {
+				return url, true
+			}
+
+			hash, err := assetStore.Put(userID, data)
+			if err != nil {
+				return "", false
+			}
+
+			mimeType := mime.TypeByExtension(ext)
+			if mimeType == "" {
+				mimeType = http.DetectContentType(data)
+			}
+			asset := models.Asset{
+				UserID:   userID,
+				Hash:     hash,
+				Name:     filepath.Base(key),
+				MimeType: mimeType,
+				Size:     int64(len(data)),
+			}
+			if err := db.Where("user_id = ? AND hash = ?", userID, hash).FirstOrCreate(&asset).Error; err != nil {
+				return "", false
+			}
+
+			urls[key] = assets.URL(hash)
+			return urls[key], true
+		}
+
+		q.QuestionText = parser.RewriteLinks(q.QuestionText, rewrite)
+		q.AnswerText = parser.RewriteLinks(q.AnswerText, rewrite)
+	}
+}
+
+// gcAssets deletes the user's assets that no question links to anymore
+func gcAssets(userID uint) {
+	defer lockAssets(userID)()
+
+	var list []models.Asset
+	if err := db.Where("user_id = ?", userID).Find(&list).Error; err != nil {
+		return
+	}
+
+	// Collect every referenced hash in one pass over the user's questions
+	refs := make(map[string]bool)
+	var batch []models.Question
+	err := db.Select("id, question_text, answer_text").Where("user_id = ?", userID).
+		FindInBatches(&batch, 500, func(tx *gorm.DB, _ int) error {
+			for _, q := range batch {
+				assets.Referenced(q.QuestionText, refs)
+				assets.Referenced(q.AnswerText, refs)
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return
+	}
+
+	for _, a := range list {
+		if refs[a.Hash] {
+			continue
+		}
+
+		if err := db.Where("user_id = ? AND hash = ?", userID, a.Hash).Delete(&models.Asset{}).Error; err != nil {
+			continue
+		}
+		assetStore.Delete(userID, a.Hash)
+	}
+}
+
+func getAssetHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	hash := c.Param("hash")
+	if !assets.ValidHash(hash) {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "资源不存在"})
+		return
+	}
+
+	var asset models.Asset
+	if err := db.Where("user_id = ? AND hash = ?", userID, hash).First(&asset).Error; err != nil {
+		// Subscribers read the author's assets of the cards they study
+		ownerID, ok := sharedAssetOwner(userID, hash)
+		if !ok || db.Where("user_id = ? AND hash = ?", ownerID, hash).First(&asset).Error != nil {
+			c.JSON(http.StatusNotFound, Response{Success: false, Error: "资源不存在"})
+			return
+		}
+	}
+
+	path, err := assetStore.Path(asset.UserID, hash)
+	if err != nil {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "资源不存在"})
+		return
+	}
+	if _, err := os.Stat(path); err != nil {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "资源不存在"})
+		return
+	}
+
+	c.Header("Content-Type", asset.MimeType)
+	c.Header("Cache-Control", "private, max-age=31536000, immutable")
+	c.File(path)
+}
+
+// sharedAssetOwner returns the author of a card the user subscribes to whose
+// text references the asset
+func sharedAssetOwner(userID uint, hash string) (uint, bool) {
+	pattern := "%" + assets.URL(hash) + "%"
+	var owners []uint
+	err := db.Table("questions AS s").
+		Joins("JOIN questions AS o ON o.id = s.origin_id AND o.deleted_at IS NULL").
+		Where("s.user_id = ? AND s.origin_id <> '' AND s.deleted_at IS NULL", userID).
+		Where("o.question_text LIKE ? OR o.answer_text LIKE ?", pattern, pattern).
+		Limit(1).Pluck("o.user_id", &owners).Error
+	if err != nil || len(owners) == 0 {
+		return 0, false
+	}
+	return owners[0], true
+}
@@ -0,0 +1,197 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"self-improvement/internal/assets"
+	"self-improvement/internal/middleware"
+)
+
+func buildZip(t *testing.T, files map[string]string) []byte {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for name, content := range files {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatalf("zip create: %v", err)
+		}
+		w.Write([]byte(content))
+	}
+	zw.Close()
+	return buf.Bytes()
+}
+
+func uploadZip(t *testing.T, router *gin.Engine, token string, zipData []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "kb.zip")
+	part.Write(zipData)
+	writer.Close()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/upload-zip", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func getDue(t *testing.T, router *gin.Engine, token string) []interface{} {
+	t.Helper()
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/due-questions", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+
+	var resp Response
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	data, ok := resp.Data.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	questions, _ := data["questions"].([]interface{})
+	return questions
+}
+
+func getAsset(router *gin.Engine, token, hash string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/assets/"+hash, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestE2E_UploadZip_Assets(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "assetuser")
+	otherToken := registerAndGetToken(t, router, "assetother")
+
+	png := "\x89PNG\r\n\x1a\nfake-image"
+	zipData := buildZip(t, map[string]string{
+		"kb/storage/ceph.md":      "# q\nCeph 架构？\n# a\n见图 ![arch](img/arch.png)\n\n# q\n无图问题\n# a\n链接 [外部](https://example.com/a.png)",
+		"kb/storage/img/arch.png": png,
+		"kb/escape.md":            "# q\n越界？\n# a\n![x](../../etc/passwd.png)",
+	})
+
+	w := uploadZip(t, router, token, zipData)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	hash := assets.Hash([]byte(png))
+	var withImage map[string]interface{}
+	for _, q := range getDue(t, router, token) {
+		m := q.(map[string]interface{})
+		if m["question"] == "Ceph 架构？" {
+			withImage = m
+		}
+		if m["question"] == "越界？" && !strings.Contains(m["answer"].(string), "../../etc/passwd.png") {
+			t.Errorf("link outside archive should be untouched: %s", m["answer"])
+		}
+	}
+	if withImage == nil {
+		t.Fatal("question with image not imported")
+	}
+	if want := "见图 ![arch](/api/assets/" + hash + ")"; withImage["answer"] != want {
+		t.Errorf("answer not rewritten: %s", withImage["answer"])
+	}
+
+	w = getAsset(router, token, hash)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 for asset, got %d", w.Code)
+	}
+	if w.Body.String() != png {
+		t.Error("asset content mismatch")
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "image/png" {
+		t.Errorf("expected image/png, got %s", ct)
+	}
+
+	if w := getAsset(router, otherToken, hash); w.Code != http.StatusNotFound {
+		t.Errorf("other user should not read asset, got %d", w.Code)
+	}
+	if w := getAsset(router, token, "not-a-hash"); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for invalid hash, got %d", w.Code)
+	}
+
+	// Deleting the only referencing question garbage-collects the asset
+	w = httptest.NewRecorder()
+	body, _ := json.Marshal(map[string]string{"question_id": withImage["id"].(string)})
+	req := httptest.NewRequest("POST", "/api/delete-question", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("delete failed: %d", w.Code)
+	}
+
+	if w := getAsset(router, token, hash); w.Code != http.StatusNotFound {
+		t.Errorf("expected asset to be collected, got %d", w.Code)
+	}
+}
+
+func TestE2E_Assets_Cookie(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "cookieuser")
+	png := "\x89PNG\r\n\x1a\ncookie-image"
+	uploadZip(t, router, token, buildZip(t, map[string]string{
+		"kb/a.md":  "# q\n图？\n# a\n![a](a.png)",
+		"kb/a.png": png,
+	}))
+
+	// Logging in sets the cookie that <img> requests send instead of a header
+	w := httptest.NewRecorder()
+	body, _ := json.Marshal(map[string]string{"username": "cookieuser", "password": "testpass123"})
+	req := httptest.NewRequest("POST", "/api/login", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	router.ServeHTTP(w, req)
+	var cookie *http.Cookie
+	for _, c := range w.Result().Cookies() {
+		if c.Name == middleware.AssetCookie {
+			cookie = c
+		}
+	}
+	if cookie == nil || !cookie.HttpOnly || cookie.Path != "/api/assets" {
+		t.Fatalf("expected an HttpOnly asset cookie, got %+v", cookie)
+	}
+
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/api/assets/"+assets.Hash([]byte(png)), nil)
+	req.AddCookie(cookie)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK || w.Body.String() != png {
+		t.Errorf("expected the asset with the cookie, got %d", w.Code)
+	}
+
+	// The cookie does not authenticate other endpoints
+	w = httptest.NewRecorder()
+	req = httptest.NewRequest("GET", "/api/profile", nil)
+	req.AddCookie(cookie)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 for the profile with only the cookie, got %d", w.Code)
+	}
+}
+
+func TestE2E_Assets_NoAuth(t *testing.T) {
+	router := setupE2E(t)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/assets/"+assets.Hash([]byte("x")), nil)
+	router.ServeHTTP(w, req)
+
+	if w.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401, got %d", w.Code)
+	}
+}
@@ -0,0 +1,570 @@
+package server
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"self-improvement/internal/backup"
+	"self-improvement/internal/models"
+	"self-improvement/internal/spacedrepetition"
+	"self-improvement/internal/streaks"
+	"self-improvement/internal/ziparchive"
+)
+
+// maxSourceSize caps a single markdown source restored from a backup
+const maxSourceSize = 10 << 20
+
+// exportAccountHandler returns a zip archive of everything the user owns
+// (see internal/backup for the layout)
+func exportAccountHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	var user models.User
+	if err := db.First(&user, userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "User not found"})
+		return
+	}
+
+	b, err := collectBackup(&user)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "导出失败"})
+		return
+	}
+
+	// Build the archive in memory so a failure can still be reported as JSON
+	var buf bytes.Buffer
+	w := backup.NewWriter(&buf)
+	if err := addBackupFiles(w, &user, b); err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "导出失败"})
+		return
+	}
+	if err := w.Close(b); err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "导出失败"})
+		return
+	}
+
+	filename := fmt.Sprintf("%s-backup-%s.zip", user.Username, time.Now().Format("20060102"))
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Data(http.StatusOK, "application/zip", buf.Bytes())
+}
+
+func collectBackup(user *models.User) (*backup.Backup, error) {
+	b := &backup.Backup{
+		ExportedAt: time.Now(),
+		User:       backup.User{Username: user.Username, CreatedAt: user.CreatedAt},
+	}
+
+	// Subscribed cards are exported with their current content and restored
+	// as own questions
+	var questions []*models.Question
+	if err := db.Where("user_id = ?", user.ID).Order("created_at ASC").Find(&questions).Error; err != nil {
+		return nil, err
+	}
+	if err := sr.FillSharedContent(questions); err != nil {
+		return nil, err
+	}
+	for _, q := range questions {
+		b.Questions = append(b.Questions, backup.Question{
+			ID:           q.ID,
+			Question:     q.QuestionText,
+			Answer:       q.AnswerText,
+			Source:       q.Source,
+			Category:     q.Category,
+			Tags:         q.Tags,
+			Level:        q.Level,
+			Priority:     q.Priority,
+			NextReview:   q.NextReview,
+			ReviewCount:  q.ReviewCount,
+			CorrectCount: q.CorrectCount,
+			CreatedAt:    q.CreatedAt,
+			UpdatedAt:    q.UpdatedAt,
+			LastReviewed: q.LastReviewed,
+		})
+	}
+
+	categories, err := sr.GetCategories(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	byName := make(map[string]int)
+	for _, cat := range categories {
+		byName[cat["name"].(string)] = len(b.Categories)
+		b.Categories = append(b.Categories, backup.Category{
+			Name:  cat["name"].(string),
+			Total: int(cat["total"].(int64)),
+		})
+	}
+	settings, err := sr.ListCategories(user.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range settings {
+		i, ok := byName[s.Name]
+		if !ok {
+			i = len(b.Categories)
+			b.Categories = append(b.Categories, backup.Category{Name: s.Name})
+		}
+		bc := &b.Categories[i]
+		bc.Label, bc.Color, bc.Description = s.Label, s.Color, s.Description
+		bc.SortOrder, bc.IntervalMultiplier = s.SortOrder, s.IntervalMultiplier
+	}
+
+	var logs []models.ReviewLog
+	if err := db.Where("user_id = ?", user.ID).Order("reviewed_at ASC").Find(&logs).Error; err != nil {
+		return nil, err
+	}
+	for _, l := range logs {
+		b.ReviewLogs = append(b.ReviewLogs, backup.ReviewLog{
+			QuestionID:    l.QuestionID,
+			Feedback:      l.Feedback,
+			IntervalHours: l.IntervalHours,
+			ReviewedAt:    l.ReviewedAt,
+			Source:        l.Source,
+			Device:        l.Device,
+			SessionID:     l.SessionID,
+			QuestionMs:    l.QuestionMs,
+			AnswerMs:      l.AnswerMs,
+			NewCard:       l.NewCard,
+		})
+	}
+
+	var sessions []models.StudySession
+	if err := db.Where("user_id = ?", user.ID).Order("started_at ASC").Find(&sessions).Error; err != nil {
+		return nil, err
+	}
+	for _, s := range sessions {
+		b.Sessions = append(b.Sessions, backup.Session{
+			ID:         s.ID,
+			Device:     s.Device,
+			StartedAt:  s.StartedAt,
+			FinishedAt: s.FinishedAt,
+		})
+	}
+
+	var prefs models.UserSettings
+	if db.Where("user_id = ?", user.ID).Limit(1).Find(&prefs).RowsAffected > 0 {
+		b.Settings = &backup.Settings{Timezone: prefs.Timezone, GoalType: prefs.GoalType, GoalTarget: prefs.GoalTarget}
+	}
+	var days []models.DailyActivity
+	if err := db.Where("user_id = ?", user.ID).Order("date ASC").Find(&days).Error; err != nil {
+		return nil, err
+	}
+	for _, d := range days {
+		b.Activity = append(b.Activity, backup.Activity{
+			Date:    d.Date,
+			Reviews: d.Reviews,
+			Correct: d.Correct,
+			StudyMs: d.StudyMs,
+			GoalMet: d.GoalMet,
+			Cleared: d.Cleared,
+		})
+	}
+
+	var list []models.Asset
+	if err := db.Where("user_id = ?", user.ID).Find(&list).Error; err != nil {
+		return nil, err
+	}
+	for _, a := range list {
+		b.Assets = append(b.Assets, backup.Asset{Hash: a.Hash, Name: a.Name, MimeType: a.MimeType, Size: a.Size})
+	}
+
+	return b, nil
+}
+
+// addBackupFiles adds the user's markdown sources and asset contents
+func addBackupFiles(w *backup.Writer, user *models.User, b *backup.Backup) error {
+	for _, a := range b.Assets {
+		f, err := assetStore.Open(user.ID, a.Hash)
+		if err != nil {
+			continue
+		}
+		err = w.AddAsset(a.Hash, f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	root := userQuestionsDir(user.Username)
+	err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || !d.Type().IsRegular() {
+			return nil
+		}
+		rel, err := filepath.Rel(root, path)
+		if err != nil {
+			return nil
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return nil
+		}
+		defer f.Close()
+		return w.AddSource(filepath.ToSlash(rel), f)
+	})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// importBackupHandler restores an archive written by exportAccountHandler.
+// Questions keep their scheduling state; questions whose text already exists
+// in the account are skipped like in every other import.
+func importBackupHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+	username, _ := c.Get("username")
+
+	limits := zipLimits()
+	// Leave room for the multipart envelope around the archive
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limits.MaxSize+1<<20)
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, Response{Success: false, Error: fmt.Sprintf("文件过大，最大 %d MB", limits.MaxSize>>20)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "请上传文件"})
+		return
+	}
+
+	if filepath.Ext(file.Filename) != ".zip" {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "只支持 .zip 格式的备份文件"})
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "无法打开上传的文件"})
+		return
+	}
+	defer src.Close()
+
+	archive, err := backup.Open(src, file.Size, limits)
+	switch {
+	case errors.Is(err, ziparchive.ErrTooLarge):
+		c.JSON(http.StatusRequestEntityTooLarge, Response{Success: false, Error: fmt.Sprintf("文件过大：备份最大 %d MB，解压后最大 %d MB", limits.MaxSize>>20, limits.MaxUncompressed>>20)})
+		return
+	case errors.Is(err, ziparchive.ErrTooManyEntries):
+		c.JSON(http.StatusRequestEntityTooLarge, Response{Success: false, Error: fmt.Sprintf("备份中的文件过多，最多 %d 个", limits.MaxEntries)})
+		return
+	case errors.Is(err, backup.ErrUnsupportedVersion):
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "备份文件由更新的版本生成，请先升级服务器"})
+		return
+	case err != nil:
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "无法解析备份文件，请确保文件未损坏"})
+		return
+	}
+
+	unlock := lockAssets(userID)
+	restoredAssets := restoreBackupAssets(userID, archive)
+	result, err := restoreBackupQuestions(userID, username.(string), archive.Backup)
+	unlock()
+	gcAssets(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "恢复备份失败"})
+		return
+	}
+	result["assets"] = restoredAssets
+	result["sources"] = restoreBackupSources(username.(string), archive)
+	emitImport(userID, importEvent{Source: "backup", Imported: result["imported"].(int), Skipped: result["skipped"].(int), Duplicates: result["duplicates"].(int)})
+
+	stats, err := sr.GetStats(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取统计失败"})
+		return
+	}
+	result["stats"] = stats
+	result["message"] = fmt.Sprintf("成功从备份恢复 %d 个问题！", result["imported"])
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: result})
+}
+
+func restoreBackupAssets(userID uint, archive *backup.Archive) int {
+	restored := 0
+	for _, a := range archive.Backup.Assets {
+		rc, err := archive.Asset(a.Hash)
+		if err != nil {
+			continue
+		}
+		content, err := io.ReadAll(io.LimitReader(rc, maxAssetSize+1))
+		rc.Close()
+		if err != nil || len(content) > maxAssetSize {
+			continue
+		}
+
+		// The content address must match, otherwise links would point elsewhere
+		hash, err := assetStore.Put(userID, content)
+		if err != nil || hash != a.Hash {
+			continue
+		}
+		asset := models.Asset{
+			UserID:   userID,
+			Hash:     hash,
+			Name:     a.Name,
+			MimeType: a.MimeType,
+			Size:     int64(len(content)),
+		}
+		if err := db.Where("user_id = ? AND hash = ?", userID, hash).FirstOrCreate(&asset).Error; err != nil {
+			continue
+		}
+		restored++
+	}
+	return restored
+}
+
+func restoreBackupQuestions(userID uint, username string, b *backup.Backup) (map[string]interface{}, error) {
+	imported, skipped, duplicates, restoredLogs, restoredCategories, restoredSessions := 0, 0, 0, 0, 0, 0
+
+	// Sources of the old account point into its question directory
+	oldPrefix := filepath.ToSlash(userQuestionsDir(b.User.Username)) + "/"
+	newPrefix := filepath.ToSlash(userQuestionsDir(username)) + "/"
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		ids := make(map[string]string)
+		seen := make(map[string]bool)
+		for _, bq := range b.Questions {
+			if bq.Question == "" || seen[bq.Question] {
+				duplicates++
+				continue
+			}
+			seen[bq.Question] = true
+
+			// Also matches cards the user has through a subscription
+			if existing, _ := spacedrepetition.NewSpacedRepetition(tx).FindQuestionByText(userID, bq.Question); existing != nil {
+				skipped++
+				continue
+			}
+
+			source := bq.Source
+			if b.User.Username != "" && strings.HasPrefix(source, oldPrefix) {
+				source = newPrefix + strings.TrimPrefix(source, oldPrefix)
+			}
+			level := bq.Level
+			if level < 1 || level > 4 {
+				level = 4
+			}
+			priority := bq.Priority
+			if priority < spacedrepetition.PriorityLow || priority > spacedrepetition.PriorityHigh {
+				priority = spacedrepetition.PriorityNormal
+			}
+
+			q := models.Question{
+				ID:           fmt.Sprintf("q_%d_%s", userID, spacedrepetition.Hash(bq.Question)),
+				UserID:       userID,
+				QuestionText: bq.Question,
+				AnswerText:   bq.Answer,
+				Source:       source,
+				Category:     bq.Category,
+				Tags:         bq.Tags,
+				Level:        level,
+				Priority:     priority,
+				NextReview:   bq.NextReview,
+				ReviewCount:  bq.ReviewCount,
+				CorrectCount: bq.CorrectCount,
+				CreatedAt:    bq.CreatedAt,
+				LastReviewed: bq.LastReviewed,
+			}
+			if q.Category == "" {
+				q.Category = extractUserCategory(username, source)
+			}
+			// The ID comes from the text, so a card deleted since the backup
+			// still holds it; bring that row back with the backed-up state
+			var deleted int64
+			tx.Unscoped().Model(&models.Question{}).Where("id = ?", q.ID).Count(&deleted)
+			var save *gorm.DB
+			if deleted > 0 {
+				save = tx.Unscoped().Select("*").Where("id = ?", q.ID).Updates(&q)
+			} else {
+				save = tx.Create(&q)
+			}
+			if save.Error != nil {
+				return save.Error
+			}
+			ids[bq.ID] = q.ID
+			imported++
+		}
+
+		// Days are counted in the restored timezone
+		if err := restoreBackupSettings(tx, userID, b.Settings); err != nil {
+			return err
+		}
+
+		// Sessions get new IDs; only those with restored reviews are kept
+		sessions := make(map[uint]backup.Session)
+		for _, s := range b.Sessions {
+			sessions[s.ID] = s
+		}
+		sessionIDs := make(map[uint]uint)
+		var logs []*models.ReviewLog
+		for _, l := range b.ReviewLogs {
+			id, ok := ids[l.QuestionID]
+			if !ok {
+				continue
+			}
+			var sessionID uint
+			if s, ok := sessions[l.SessionID]; ok && l.SessionID != 0 {
+				if sessionID, ok = sessionIDs[s.ID]; !ok {
+					restored := models.StudySession{UserID: userID, Device: s.Device, StartedAt: s.StartedAt, FinishedAt: s.FinishedAt}
+					if err := tx.Create(&restored).Error; err != nil {
+						return err
+					}
+					sessionID = restored.ID
+					sessionIDs[s.ID] = sessionID
+				}
+			}
+			logs = append(logs, &models.ReviewLog{
+				UserID:        userID,
+				QuestionID:    id,
+				Feedback:      l.Feedback,
+				IntervalHours: l.IntervalHours,
+				ReviewedAt:    l.ReviewedAt,
+				Source:        l.Source,
+				Device:        l.Device,
+				SessionID:     sessionID,
+				QuestionMs:    l.QuestionMs,
+				AnswerMs:      l.AnswerMs,
+				NewCard:       l.NewCard,
+			})
+		}
+		restoredSessions = len(sessionIDs)
+		if len(logs) > 0 {
+			if err := tx.CreateInBatches(logs, 100).Error; err != nil {
+				return err
+			}
+		}
+		restoredLogs = len(logs)
+
+		var days []*models.DailyActivity
+		for _, d := range b.Activity {
+			days = append(days, &models.DailyActivity{
+				Date:    d.Date,
+				Reviews: d.Reviews,
+				Correct: d.Correct,
+				StudyMs: d.StudyMs,
+				GoalMet: d.GoalMet,
+				Cleared: d.Cleared,
+			})
+		}
+		if err := spacedrepetition.NewSpacedRepetition(tx).RestoreActivity(userID, days, logs); err != nil {
+			return err
+		}
+
+		var err error
+		restoredCategories, err = restoreBackupCategories(tx, userID, b.Categories)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return map[string]interface{}{
+		"imported":    imported,
+		"skipped":     skipped,
+		"duplicates":  duplicates,
+		"review_logs": restoredLogs,
+		"categories":  restoredCategories,
+		"sessions":    restoredSessions,
+	}, nil
+}
+
+// restoreBackupSettings fills in the timezone and daily goal from the backup
+// where the account has none of its own
+func restoreBackupSettings(tx *gorm.DB, userID uint, bs *backup.Settings) error {
+	if bs == nil {
+		return nil
+	}
+	settings := models.UserSettings{UserID: userID}
+	tx.Where("user_id = ?", userID).Limit(1).Find(&settings)
+	if _, err := time.LoadLocation(bs.Timezone); settings.Timezone == "" && bs.Timezone != "" && err == nil {
+		settings.Timezone = bs.Timezone
+	}
+	if goal := (streaks.Goal{Type: bs.GoalType, Target: bs.GoalTarget}); settings.GoalType == "" && goal.Valid() {
+		settings.GoalType, settings.GoalTarget = goal.Type, goal.Target
+	}
+	return tx.Save(&settings).Error
+}
+
+// restoreBackupCategories saves the backed-up category settings, replacing
+// the settings of categories that already exist. Entries of version 1
+// backups carry no settings and are left to EnsureCategories.
+func restoreBackupCategories(tx *gorm.DB, userID uint, categories []backup.Category) (int, error) {
+	restored := 0
+	for _, bc := range categories {
+		if bc.IntervalMultiplier == 0 {
+			continue
+		}
+		bc := bc
+		req := CategoryRequest{
+			Name:               &bc.Name,
+			Label:              &bc.Label,
+			Color:              &bc.Color,
+			Description:        &bc.Description,
+			SortOrder:          &bc.SortOrder,
+			IntervalMultiplier: &bc.IntervalMultiplier,
+		}
+		cat := models.Category{UserID: userID}
+		if msg := req.apply(&cat); msg != "" {
+			continue
+		}
+		err := tx.Clauses(clause.OnConflict{
+			Columns:   []clause.Column{{Name: "user_id"}, {Name: "name"}},
+			DoUpdates: clause.AssignmentColumns([]string{"label", "color", "description", "sort_order", "interval_multiplier", "updated_at"}),
+		}).Create(&cat).Error
+		if err != nil {
+			return restored, err
+		}
+		restored++
+	}
+	return restored, nil
+}
+
+// restoreBackupSources writes markdown sources into the user's question
+// directory. Existing files are never overwritten.
+func restoreBackupSources(username string, archive *backup.Archive) int {
+	root := userQuestionsDir(username)
+	restored := 0
+	for name, f := range archive.Sources {
+		if f.UncompressedSize64 > maxSourceSize {
+			continue
+		}
+		dst := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(dst), 0755); err != nil {
+			continue
+		}
+		out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+		if err != nil {
+			continue
+		}
+		rc, err := f.Open()
+		if err != nil {
+			out.Close()
+			os.Remove(dst)
+			continue
+		}
+		_, err = io.Copy(out, io.LimitReader(rc, maxSourceSize))
+		rc.Close()
+		out.Close()
+		if err != nil {
+			os.Remove(dst)
+			continue
+		}
+		restored++
+	}
+	return restored
+}
@@ -0,0 +1,351 @@
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"self-improvement/internal/assets"
+	"self-improvement/internal/backup"
+	"self-improvement/internal/models"
+	"self-improvement/internal/ziparchive"
+)
+
+func importBackup(t *testing.T, router *gin.Engine, token string, data []byte) *httptest.ResponseRecorder {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "backup.zip")
+	part.Write(data)
+	writer.Close()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/import-backup", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func reviewQuestion(t *testing.T, router *gin.Engine, token, id string, feedback int) {
+	t.Helper()
+	body, _ := json.Marshal(map[string]interface{}{"question_id": id, "feedback": feedback})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/update-review", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("review failed: %d: %s", w.Code, w.Body.String())
+	}
+}
+
+func userByName(t *testing.T, username string) models.User {
+	t.Helper()
+	var user models.User
+	if err := db.Where("username = ?", username).First(&user).Error; err != nil {
+		t.Fatalf("user %s not found: %v", username, err)
+	}
+	return user
+}
+
+func TestE2E_ExportImportBackup(t *testing.T) {
+	router := setupE2E(t)
+
+	wd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(wd)
+
+	token := registerAndGetToken(t, router, "bkuser")
+	os.MkdirAll(filepath.Join("questions", "bkuser", "go"), 0755)
+	os.WriteFile(filepath.Join("questions", "bkuser", "go", "gmp.md"), []byte("# q\nGMP?\n# a\n调度模型"), 0644)
+
+	png := "\x89PNG\r\n\x1a\nbackup-image"
+	uploadZip(t, router, token, buildZip(t, map[string]string{
+		"kb/net/tcp.md":      "# q\nTCP 握手？\n# a\n![图](img/tcp.png)",
+		"kb/net/img/tcp.png": png,
+	}))
+	addQuestion(t, router, token, "手动问题", "手动答案")
+
+	owner := userByName(t, "bkuser")
+	var reviewed models.Question
+	db.Where("user_id = ? AND question_text = ?", owner.ID, "手动问题").First(&reviewed)
+	reviewQuestion(t, router, token, reviewed.ID, 1)
+	reviewQuestion(t, router, token, reviewed.ID, 2)
+	db.First(&reviewed, "id = ?", reviewed.ID)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/export", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("export failed: %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); ct != "application/zip" {
+		t.Errorf("unexpected content type: %s", ct)
+	}
+	exported := w.Body.Bytes()
+
+	archive, err := backup.Open(bytes.NewReader(exported), int64(len(exported)), ziparchive.Limits{})
+	if err != nil {
+		t.Fatalf("exported archive unreadable: %v", err)
+	}
+	b := archive.Backup
+	if b.Version != backup.Version || b.User.Username != "bkuser" {
+		t.Errorf("manifest mismatch: version=%d user=%s", b.Version, b.User.Username)
+	}
+	if len(b.Questions) != 2 || len(b.ReviewLogs) != 2 || len(b.Assets) != 1 || len(b.Categories) == 0 {
+		t.Errorf("unexpected counts: q=%d logs=%d assets=%d cats=%d", len(b.Questions), len(b.ReviewLogs), len(b.Assets), len(b.Categories))
+	}
+	if _, ok := archive.Sources["go/gmp.md"]; !ok {
+		t.Errorf("markdown source missing: %v", archive.Sources)
+	}
+
+	// Restore into a fresh account
+	newToken := registerAndGetToken(t, router, "bkrestore")
+	w = importBackup(t, router, newToken, exported)
+	if w.Code != http.StatusOK {
+		t.Fatalf("restore failed: %d: %s", w.Code, w.Body.String())
+	}
+	var resp Response
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	data := resp.Data.(map[string]interface{})
+	if data["imported"].(float64) != 2 || data["review_logs"].(float64) != 2 || data["assets"].(float64) != 1 || data["sources"].(float64) != 1 {
+		t.Errorf("unexpected restore report: %v", data)
+	}
+
+	restoredUser := userByName(t, "bkrestore")
+	var restored models.Question
+	if err := db.Where("user_id = ? AND question_text = ?", restoredUser.ID, "手动问题").First(&restored).Error; err != nil {
+		t.Fatalf("restored question not found: %v", err)
+	}
+	if restored.Level != reviewed.Level || !restored.NextReview.Equal(reviewed.NextReview) ||
+		restored.ReviewCount != 2 || restored.CorrectCount != reviewed.CorrectCount {
+		t.Errorf("scheduling state not preserved: got %+v, want %+v", restored, reviewed)
+	}
+	if restored.LastReviewed == nil || restored.NextReview.Before(time.Now()) {
+		t.Errorf("unexpected restored times: %+v", restored)
+	}
+
+	hash := assets.Hash([]byte(png))
+	if w := getAsset(router, newToken, hash); w.Code != http.StatusOK || w.Body.String() != png {
+		t.Errorf("restored asset not served: %d", w.Code)
+	}
+	if content, err := os.ReadFile(filepath.Join("questions", "bkrestore", "go", "gmp.md")); err != nil || string(content) != "# q\nGMP?\n# a\n调度模型" {
+		t.Errorf("source not restored: %v", err)
+	}
+
+	// Restoring twice skips everything
+	w = importBackup(t, router, newToken, exported)
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	data = resp.Data.(map[string]interface{})
+	if data["imported"].(float64) != 0 || data["skipped"].(float64) != 2 {
+		t.Errorf("expected all skipped on second restore: %v", data)
+	}
+}
+
+func exportBackup(t *testing.T, router *gin.Engine, token string) []byte {
+	t.Helper()
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/export", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("export failed: %d: %s", w.Code, w.Body.String())
+	}
+	return w.Body.Bytes()
+}
+
+func TestE2E_ImportBackup_CategorySettings(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "bkcats")
+	addQuestion(t, router, token, "问题", "答案")
+	code, resp := apiJSON(t, router, token, "POST", "/api/categories", map[string]interface{}{
+		"name": "存储/ceph", "label": "Ceph", "color": "#336699", "description": "分布式存储",
+		"sort_order": 3, "interval_multiplier": 0.5,
+	})
+	if code != http.StatusOK {
+		t.Fatalf("create category failed: %d %v", code, resp)
+	}
+	exported := exportBackup(t, router, token)
+
+	newToken := registerAndGetToken(t, router, "bkcatsrestore")
+	w := importBackup(t, router, newToken, exported)
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp.Data.(map[string]interface{})["categories"].(float64) < 1 {
+		t.Fatalf("restore failed: %d %v", w.Code, resp)
+	}
+
+	var cat models.Category
+	if err := db.Where("user_id = ? AND name = ?", userByName(t, "bkcatsrestore").ID, "存储/ceph").First(&cat).Error; err != nil {
+		t.Fatalf("category not restored: %v", err)
+	}
+	if cat.Label != "Ceph" || cat.Color != "#336699" || cat.Description != "分布式存储" ||
+		cat.SortOrder != 3 || cat.IntervalMultiplier != 0.5 {
+		t.Errorf("category settings not preserved: %+v", cat)
+	}
+}
+
+func TestE2E_ImportBackup_Timings(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "bktimes")
+	addQuestion(t, router, token, "问题", "答案")
+	id := userQuestions(t, userByName(t, "bktimes").ID)["问题"].ID
+	_, resp := apiJSON(t, router, token, "POST", "/api/sessions", map[string]string{"device": "web"})
+	session := resp.Data.(map[string]interface{})["id"].(float64)
+	apiJSON(t, router, token, "POST", "/api/update-review", map[string]interface{}{
+		"question_id": id, "feedback": 1, "session_id": session, "question_ms": 6000, "answer_ms": 4000,
+	})
+	apiJSON(t, router, token, "POST", fmt.Sprintf("/api/sessions/%v/finish", session), nil)
+	exported := exportBackup(t, router, token)
+
+	newToken := registerAndGetToken(t, router, "bktimesrestore")
+	w := importBackup(t, router, newToken, exported)
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	if w.Code != http.StatusOK || resp.Data.(map[string]interface{})["sessions"].(float64) != 1 {
+		t.Fatalf("restore failed: %d %v", w.Code, resp)
+	}
+
+	restored := userByName(t, "bktimesrestore")
+	var log models.ReviewLog
+	db.Where("user_id = ?", restored.ID).First(&log)
+	var s models.StudySession
+	if err := db.Where("user_id = ? AND id = ?", restored.ID, log.SessionID).First(&s).Error; err != nil || s.Device != "web" || s.FinishedAt == nil {
+		t.Errorf("session not restored: %+v %v", s, err)
+	}
+	if log.QuestionMs != 6000 || log.AnswerMs != 4000 || !log.NewCard {
+		t.Errorf("timings not restored: %+v", log)
+	}
+	if cardMs, timed := sr.CardTime(restored.ID); cardMs != 10000 || timed != 1 {
+		t.Errorf("expected the card time restored, got %d from %d reviews", cardMs, timed)
+	}
+	_, resp = apiJSON(t, router, newToken, "GET", fmt.Sprintf("/api/sessions/%d", s.ID), nil)
+	if summary := resp.Data.(map[string]interface{}); summary["reviews"].(float64) != 1 {
+		t.Errorf("unexpected restored session summary: %v", summary)
+	}
+}
+
+func TestE2E_ImportBackup_Streaks(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "bkstreak")
+	owner := userByName(t, "bkstreak")
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+	db.Create(&models.UserSettings{UserID: owner.ID, Timezone: "Asia/Shanghai", GoalType: "cards", GoalTarget: 5})
+	// A week of perfect days earns the streak and perfect day achievements
+	for d := 1; d <= 7; d++ {
+		date := time.Now().In(shanghai).AddDate(0, 0, -d).Format("2006-01-02")
+		db.Create(&models.DailyActivity{UserID: owner.ID, Date: date, Reviews: 20, Correct: 20, GoalMet: true, Cleared: true})
+	}
+	before, _ := sr.GetStreakStatus(owner.ID)
+	if before.CurrentStreak != 7 {
+		t.Fatalf("expected a 7 day streak before export, got %+v", before)
+	}
+	exported := exportBackup(t, router, token)
+
+	// Restoring twice does not add up the days
+	newToken := registerAndGetToken(t, router, "bkstreakrestore")
+	for i := 0; i < 2; i++ {
+		if w := importBackup(t, router, newToken, exported); w.Code != http.StatusOK {
+			t.Fatalf("restore failed: %d: %s", w.Code, w.Body.String())
+		}
+	}
+	restored := userByName(t, "bkstreakrestore")
+	after, _ := sr.GetStreakStatus(restored.ID)
+	if after.Timezone != "Asia/Shanghai" || after.Goal.Target != 5 ||
+		after.CurrentStreak != before.CurrentStreak || after.LongestStreak != before.LongestStreak {
+		t.Errorf("streak not preserved: got %+v, want %+v", after, before)
+	}
+	earned := make(map[string]bool)
+	for i, a := range after.Achievements {
+		if a.Earned != before.Achievements[i].Earned {
+			t.Errorf("achievement %s not preserved", a.ID)
+		}
+		earned[a.ID] = a.Earned
+	}
+	var days []models.DailyActivity
+	db.Where("user_id = ?", restored.ID).Find(&days)
+	if len(days) != 7 || days[0].Reviews != 20 || !earned["streak_7"] || !earned["perfect_day"] {
+		t.Errorf("unexpected restored activity: %+v (achievements %v)", days, earned)
+	}
+}
+
+func TestE2E_ImportBackup_DeletedSince(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "bkdeleted")
+	addQuestion(t, router, token, "删掉的问题", "答案")
+	addQuestion(t, router, token, "保留的问题", "答案")
+	user := userByName(t, "bkdeleted")
+	var q models.Question
+	db.Where("user_id = ? AND question_text = ?", user.ID, "删掉的问题").First(&q)
+	reviewQuestion(t, router, token, q.ID, 1)
+
+	exported := exportBackup(t, router, token)
+	if err := sr.DeleteQuestion(user.ID, q.ID); err != nil {
+		t.Fatalf("delete failed: %v", err)
+	}
+
+	w := importBackup(t, router, token, exported)
+	if w.Code != http.StatusOK {
+		t.Fatalf("restore failed: %d: %s", w.Code, w.Body.String())
+	}
+	var resp Response
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	data := resp.Data.(map[string]interface{})
+	if data["imported"].(float64) != 1 || data["skipped"].(float64) != 1 || data["review_logs"].(float64) != 1 {
+		t.Errorf("unexpected restore report: %v", data)
+	}
+	var restored models.Question
+	if err := db.Where("id = ?", q.ID).First(&restored).Error; err != nil || restored.ReviewCount != 1 {
+		t.Errorf("deleted question not restored: %v %+v", err, restored)
+	}
+}
+
+func TestE2E_ImportBackup_Limits(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "bklimits")
+	addQuestion(t, router, token, "Q", "A")
+	exported := exportBackup(t, router, token)
+
+	os.Setenv("ZIP_MAX_ENTRIES", "1")
+	if w := importBackup(t, router, token, buildZip(t, map[string]string{"backup.json": "{}", "sources/a.md": "x"})); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for too many entries, got %d", w.Code)
+	}
+	os.Unsetenv("ZIP_MAX_ENTRIES")
+
+	os.Setenv("ZIP_MAX_SIZE_MB", "1")
+	defer os.Unsetenv("ZIP_MAX_SIZE_MB")
+	if w := importBackup(t, router, token, make([]byte, 3<<20)); w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for an oversized upload, got %d", w.Code)
+	}
+	if w := importBackup(t, router, token, exported); w.Code != http.StatusOK {
+		t.Errorf("expected a small backup to restore, got %d", w.Code)
+	}
+}
+
+func TestE2E_ImportBackup_Invalid(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "bkinvalid")
+
+	if w := importBackup(t, router, token, buildZip(t, map[string]string{"a.md": "x"})); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without manifest, got %d", w.Code)
+	}
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	f, _ := zw.Create("backup.json")
+	f.Write([]byte(`{"version": 999}`))
+	zw.Close()
+	if w := importBackup(t, router, token, buf.Bytes()); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for newer version, got %d", w.Code)
+	}
+}
@@ -0,0 +1,402 @@
+package server
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+
+	"self-improvement/internal/digest"
+	"self-improvement/internal/ical"
+	"self-improvement/internal/models"
+	"self-improvement/internal/spacedrepetition"
+)
+
+const (
+	// defaultCalendarDays is how many days of reviews the feed covers
+	defaultCalendarDays = 30
+	// calendarRefresh is how often calendar apps are asked to refetch the feed
+	calendarRefresh = time.Hour
+	// deadlineAlarm reminds of exams and deadlines at 9:00 the day before
+	deadlineAlarm = 15 * time.Hour
+
+	maxExamTitleLen = 100
+	maxExamNoteLen  = 1000
+)
+
+// ExamRequest creates an exam or changes some of its fields
+type ExamRequest struct {
+	Title      *string  `json:"title"`
+	Date       *string  `json:"date"`       // YYYY-MM-DD
+	Categories []string `json:"categories"` // Empty for all categories
+	Note       *string  `json:"note"`
+}
+
+// apply validates the request and copies the given fields to exam
+func (req *ExamRequest) apply(exam *models.Exam) string {
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" {
+			return "title 不能为空"
+		}
+		if utf8.RuneCountInString(title) > maxExamTitleLen {
+			return "title 最多 " + strconv.Itoa(maxExamTitleLen) + " 个字符"
+		}
+		exam.Title = title
+	}
+	if req.Date != nil {
+		date, err := time.Parse(ical.DateLayout, *req.Date)
+		if err != nil {
+			return "date 必须是 YYYY-MM-DD 格式"
+		}
+		loc, _ := sr.UserGoal(exam.UserID)
+		if date.Format(ical.DateLayout) < time.Now().In(loc).Format(ical.DateLayout) {
+			return "考试日期不能早于今天"
+		}
+		exam.Date = *req.Date
+	}
+	if req.Categories != nil {
+		_, all, err := sr.CategoryTree(exam.UserID)
+		if err != nil {
+			return "获取分类失败"
+		}
+		known := make(map[string]bool, len(all))
+		for _, n := range all {
+			known[n.Name] = true
+		}
+		var names []string
+		seen := make(map[string]bool)
+		for _, name := range req.Categories {
+			if !known[name] {
+				return "分类不存在：" + name
+			}
+			if !seen[name] {
+				seen[name] = true
+				names = append(names, name)
+			}
+		}
+		exam.Categories = strings.Join(names, ",")
+	}
+	if req.Note != nil {
+		if utf8.RuneCountInString(*req.Note) > maxExamNoteLen {
+			return "note 最多 " + strconv.Itoa(maxExamNoteLen) + " 个字符"
+		}
+		exam.Note = *req.Note
+	}
+	return ""
+}
+
+// examCategories returns the categories an exam covers, none for all
+func examCategories(exam *models.Exam) []string {
+	if exam.Categories == "" {
+		return []string{}
+	}
+	return strings.Split(exam.Categories, ",")
+}
+
+func examData(exam *models.Exam) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         exam.ID,
+		"title":      exam.Title,
+		"date":       exam.Date,
+		"categories": examCategories(exam),
+		"note":       exam.Note,
+		"created_at": exam.CreatedAt,
+		"updated_at": exam.UpdatedAt,
+	}
+}
+
+func findExam(c *gin.Context, userID uint) (*models.Exam, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "无效的考试 ID"})
+		return nil, false
+	}
+	var exam models.Exam
+	if err := db.Where("user_id = ? AND id = ?", userID, id).First(&exam).Error; err != nil {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "考试不存在"})
+		return nil, false
+	}
+	return &exam, true
+}
+
+// listExamsHandler returns the user's exams, earliest first
+func listExamsHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	var exams []models.Exam
+	if err := db.Where("user_id = ?", userID).Order("date ASC, id ASC").Find(&exams).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取考试失败"})
+		return
+	}
+	data := make([]map[string]interface{}, len(exams))
+	for i := range exams {
+		data[i] = examData(&exams[i])
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: data})
+}
+
+func createExamHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	var req ExamRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Title == nil || req.Date == nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "Invalid request format"})
+		return
+	}
+	exam := &models.Exam{UserID: userID}
+	if msg := req.apply(exam); msg != "" {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: msg})
+		return
+	}
+	if err := db.Create(exam).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "创建考试失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "考试已添加", Data: examData(exam)})
+}
+
+func updateExamHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	exam, ok := findExam(c, userID)
+	if !ok {
+		return
+	}
+	var req ExamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "Invalid request format"})
+		return
+	}
+	if msg := req.apply(exam); msg != "" {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: msg})
+		return
+	}
+	// Select saves cleared categories and notes too
+	if err := db.Model(exam).Select("title", "date", "categories", "note").Updates(exam).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "更新考试失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "考试已更新", Data: examData(exam)})
+}
+
+func deleteExamHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	exam, ok := findExam(c, userID)
+	if !ok {
+		return
+	}
+	if err := db.Delete(exam).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "删除考试失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "考试已删除"})
+}
+
+// calendarData returns the feed URLs for a token. Links use PUBLIC_URL, see
+// digest.ConfigFromEnv.
+func calendarData(token string) map[string]interface{} {
+	feed := digestConfig.BaseURL + "/api/calendar/" + token + ".ics"
+	webcal := feed
+	if i := strings.Index(feed, "://"); i >= 0 {
+		webcal = "webcal" + feed[i:]
+	}
+	return map[string]interface{}{
+		"url":        feed,
+		"webcal_url": webcal,
+		"days":       defaultCalendarDays,
+		"max_days":   spacedrepetition.MaxForecastDays,
+	}
+}
+
+func saveCalendarToken(userID uint, token string) error {
+	settings := models.UserSettings{UserID: userID, CalendarToken: token}
+	return db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"calendar_token", "updated_at"}),
+	}).Create(&settings).Error
+}
+
+// getCalendarHandler returns the user's feed URL, creating its token on first
+// use
+func getCalendarHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	var settings models.UserSettings
+	db.Where("user_id = ?", userID).Limit(1).Find(&settings)
+	if settings.CalendarToken == "" {
+		settings.CalendarToken = digest.NewToken()
+		if err := saveCalendarToken(userID, settings.CalendarToken); err != nil {
+			c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "保存设置失败"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: calendarData(settings.CalendarToken)})
+}
+
+// rotateCalendarHandler replaces the feed token. Calendars subscribed to the
+// old URL stop updating.
+func rotateCalendarHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	token := digest.NewToken()
+	if err := saveCalendarToken(userID, token); err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "保存设置失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "订阅地址已更新，旧地址已失效", Data: calendarData(token)})
+}
+
+// calendarFeedHandler serves the .ics feed. It needs no login since calendar
+// apps cannot send one; the token in the URL identifies the user.
+// Query: days (1-365, default 30).
+func calendarFeedHandler(c *gin.Context) {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+	var settings models.UserSettings
+	if token == "" || db.Where("calendar_token = ?", token).Limit(1).Find(&settings).RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "订阅地址无效"})
+		return
+	}
+	days, err := strconv.Atoi(c.DefaultQuery("days", strconv.Itoa(defaultCalendarDays)))
+	if err != nil || days < 1 || days > spacedrepetition.MaxForecastDays {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "days 必须在 1 到 " + strconv.Itoa(spacedrepetition.MaxForecastDays) + " 之间"})
+		return
+	}
+
+	cal, err := buildCalendar(settings.UserID, days)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "生成日历失败"})
+		return
+	}
+
+	c.Header("Content-Disposition", `inline; filename="knowloop.ics"`)
+	c.Data(http.StatusOK, "text/calendar; charset=utf-8", cal.Bytes(time.Now()))
+}
+
+// buildCalendar returns an all-day event for each of the next days with
+// reviews due, and one for each exam and team assignment deadline
+func buildCalendar(userID uint, days int) (*ical.Calendar, error) {
+	forecast, err := sr.GetCalendarForecast(userID, days)
+	if err != nil {
+		return nil, err
+	}
+	roots, _, err := sr.CategoryTree(userID)
+	if err != nil {
+		return nil, err
+	}
+	labels := make(map[string]string, len(roots))
+	names := make([]string, len(roots))
+	for i, r := range roots {
+		labels[r.Name] = r.Label
+		names[i] = r.Name
+	}
+
+	cal := &ical.Calendar{Name: "KnowLoop 复习计划", Refresh: calendarRefresh}
+	for _, day := range forecast {
+		count := day["count"].(int64)
+		if count == 0 {
+			continue
+		}
+		date := day["date"].(string)
+		cal.Events = append(cal.Events, ical.Event{
+			UID:         fmt.Sprintf("review-%s-%d@knowloop", date, userID),
+			Date:        date,
+			Summary:     reviewSummary(count, day["categories"].(map[string]int64), labels),
+			Description: fmt.Sprintf("预计用时 %d 分钟", day["estimated_minutes"]),
+		})
+	}
+
+	var exams []models.Exam
+	if err := db.Where("user_id = ?", userID).Order("date ASC, id ASC").Find(&exams).Error; err != nil {
+		return nil, err
+	}
+	for i := range exams {
+		exam := &exams[i]
+		scope, cats := "全部分类", examCategories(exam)
+		if len(cats) > 0 {
+			scope = strings.Join(cats, "、")
+		} else {
+			cats = names
+		}
+		_, overall, err := sr.GetMasteryReport(userID, cats, 0)
+		if err != nil {
+			return nil, err
+		}
+		desc := fmt.Sprintf("复习范围：%s\n当前就绪度：%d%%", scope, int(math.Round(overall.Readiness*100)))
+		if exam.Note != "" {
+			desc += "\n" + exam.Note
+		}
+		cal.Events = append(cal.Events, ical.Event{
+			UID:         fmt.Sprintf("exam-%d@knowloop", exam.ID),
+			Date:        exam.Date,
+			Summary:     "KnowLoop: 考试 " + exam.Title,
+			Description: desc,
+			Alarm:       deadlineAlarm,
+		})
+	}
+
+	deadlines, err := sr.AssignmentDeadlines(userID)
+	if err != nil {
+		return nil, err
+	}
+	loc, _ := sr.UserGoal(userID)
+	for _, d := range deadlines {
+		at := d.Deadline.In(loc)
+		cal.Events = append(cal.Events, ical.Event{
+			UID:         fmt.Sprintf("assignment-%d-%d@knowloop", d.ID, userID),
+			Date:        at.Format(ical.DateLayout),
+			Summary:     fmt.Sprintf("KnowLoop: 截止 %s（%s）", d.Deck, d.Team),
+			Description: "团队作业截止时间 " + at.Format("15:04"),
+			Alarm:       deadlineAlarm,
+		})
+	}
+	return cal, nil
+}
+
+// reviewSummary is the title of a day's review event, e.g.
+// "KnowLoop: 42 reviews (存储 12, 编程语言 30)", busiest category first
+func reviewSummary(count int64, categories map[string]int64, labels map[string]string) string {
+	type part struct {
+		label string
+		n     int64
+	}
+	parts := make([]part, 0, len(categories))
+	for name, n := range categories {
+		label := labels[name]
+		if label == "" {
+			label = name
+		}
+		parts = append(parts, part{label, n})
+	}
+	sort.Slice(parts, func(i, j int) bool {
+		if parts[i].n != parts[j].n {
+			return parts[i].n > parts[j].n
+		}
+		return parts[i].label < parts[j].label
+	})
+	items := make([]string, len(parts))
+	for i, p := range parts {
+		items[i] = fmt.Sprintf("%s %d", p.label, p.n)
+	}
+	return fmt.Sprintf("KnowLoop: %d reviews (%s)", count, strings.Join(items, ", "))
+}
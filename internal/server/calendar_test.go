@@ -0,0 +1,131 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"self-improvement/internal/models"
+)
+
+// fetchCalendar returns the status and unfolded body of a feed URL
+func fetchCalendar(t *testing.T, router http.Handler, feed string) (int, string) {
+	t.Helper()
+	u, err := url.Parse(feed)
+	if err != nil {
+		t.Fatalf("bad feed URL %q: %v", feed, err)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", u.RequestURI(), nil))
+	return w.Code, strings.ReplaceAll(w.Body.String(), "\r\n ", "")
+}
+
+func TestE2E_Exams(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "examuser")
+	addQuestion(t, router, token, "Q1", "A")
+	user := userByName(t, "examuser")
+	db.Model(&models.Question{}).Where("user_id = ?", user.ID).Update("category", "存储/ceph")
+
+	nextWeek := time.Now().AddDate(0, 0, 7).Format("2006-01-02")
+	for _, body := range []map[string]interface{}{
+		{"date": nextWeek},
+		{"title": "期末", "date": "2026/10/19"},
+		{"title": " ", "date": nextWeek},
+		{"title": "期末", "date": "2000-01-01"},
+		{"title": "期末", "date": nextWeek, "categories": []string{"不存在"}},
+	} {
+		if code, _ := apiJSON(t, router, token, "POST", "/api/exams", body); code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %v, got %d", body, code)
+		}
+	}
+
+	code, resp := apiJSON(t, router, token, "POST", "/api/exams", map[string]interface{}{
+		"title": "期末", "date": nextWeek, "categories": []string{"存储", "存储/ceph", "存储"},
+	})
+	if code != http.StatusOK {
+		t.Fatalf("create failed: %d %v", code, resp)
+	}
+	exam := resp.Data.(map[string]interface{})
+	if cats := exam["categories"].([]interface{}); len(cats) != 2 {
+		t.Errorf("expected duplicate categories dropped, got %v", cats)
+	}
+
+	id := fmt.Sprintf("/api/exams/%v", exam["id"])
+	code, resp = apiJSON(t, router, token, "PATCH", id, map[string]interface{}{"note": "带计算器", "categories": []string{}})
+	exam = resp.Data.(map[string]interface{})
+	if code != http.StatusOK || exam["title"] != "期末" || exam["note"] != "带计算器" || len(exam["categories"].([]interface{})) != 0 {
+		t.Errorf("unexpected update: %d %v", code, resp)
+	}
+
+	other := registerAndGetToken(t, router, "examother")
+	if code, _ := apiJSON(t, router, other, "DELETE", id, nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 for another user's exam, got %d", code)
+	}
+	if code, _ := apiJSON(t, router, token, "DELETE", id, nil); code != http.StatusOK {
+		t.Errorf("delete failed: %d", code)
+	}
+	_, resp = apiJSON(t, router, token, "GET", "/api/exams", nil)
+	if len(resp.Data.([]interface{})) != 0 {
+		t.Errorf("expected no exams, got %v", resp.Data)
+	}
+}
+
+func TestE2E_CalendarFeed(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "caluser")
+	for _, q := range []string{"Q1", "Q2", "Q3"} {
+		addQuestion(t, router, token, q, "A")
+	}
+	user := userByName(t, "caluser")
+	db.Model(&models.Question{}).Where("user_id = ? AND question_text IN ?", user.ID, []string{"Q1", "Q2"}).Update("category", "存储/ceph")
+	db.Model(&models.Question{}).Where("user_id = ? AND question_text = ?", user.ID, "Q3").Update("category", "编程语言")
+
+	code, resp := apiJSON(t, router, token, "GET", "/api/calendar", nil)
+	data := resp.Data.(map[string]interface{})
+	feed := data["url"].(string)
+	if code != http.StatusOK || !strings.HasSuffix(feed, ".ics") || !strings.HasPrefix(data["webcal_url"].(string), "webcal://") {
+		t.Fatalf("unexpected calendar: %d %v", code, data)
+	}
+	if _, resp := apiJSON(t, router, token, "GET", "/api/calendar", nil); resp.Data.(map[string]interface{})["url"] != feed {
+		t.Errorf("expected the same URL until rotated")
+	}
+
+	nextWeek := time.Now().AddDate(0, 0, 7).Format("2006-01-02")
+	apiJSON(t, router, token, "POST", "/api/exams", map[string]interface{}{"title": "期末", "date": nextWeek, "categories": []string{"存储"}})
+
+	code, body := fetchCalendar(t, router, feed)
+	if code != http.StatusOK || !strings.HasPrefix(body, "BEGIN:VCALENDAR\r\n") {
+		t.Fatalf("unexpected feed: %d %s", code, body)
+	}
+	for _, want := range []string{
+		`SUMMARY:KnowLoop: 3 reviews (存储 2\, 编程语言 1)`,
+		"SUMMARY:KnowLoop: 考试 期末",
+		"DTSTART;VALUE=DATE:" + strings.ReplaceAll(nextWeek, "-", ""),
+		`DESCRIPTION:复习范围：存储\n当前就绪度：`,
+		"TRIGGER:-PT15H",
+	} {
+		if !strings.Contains(body, want) {
+			t.Errorf("expected %q in feed:\n%s", want, body)
+		}
+	}
+	if code, _ := fetchCalendar(t, router, feed+"?days=400"); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for too many days, got %d", code)
+	}
+
+	code, resp = apiJSON(t, router, token, "POST", "/api/calendar/rotate", nil)
+	rotated := resp.Data.(map[string]interface{})["url"].(string)
+	if code != http.StatusOK || rotated == feed {
+		t.Fatalf("rotate failed: %d %v", code, resp)
+	}
+	if code, _ := fetchCalendar(t, router, feed); code != http.StatusNotFound {
+		t.Errorf("expected 404 for the old URL, got %d", code)
+	}
+	if code, _ := fetchCalendar(t, router, rotated); code != http.StatusOK {
+		t.Errorf("expected the new URL to work, got %d", code)
+	}
+}
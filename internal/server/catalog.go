@@ -0,0 +1,139 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"self-improvement/internal/models"
+	"self-improvement/internal/spacedrepetition"
+)
+
+const catalogPreviewSize = 5
+
+func catalogEntryData(e *spacedrepetition.CatalogEntry, userID uint, subscribed map[uint]bool) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          e.ID,
+		"title":       e.Title,
+		"description": e.Description,
+		"tags":        splitDeckTags(e.Tags),
+		"author":      e.Author,
+		"cards":       e.Cards,
+		"subscribers": e.Subscribers,
+		"created_at":  e.CreatedAt,
+		"updated_at":  e.UpdatedAt,
+		"subscribed":  subscribed[e.ID],
+		"own":         e.UserID == userID,
+	}
+}
+
+func subscribedDecks(userID uint) map[uint]bool {
+	var ids []uint
+	db.Model(&models.DeckSubscription{}).Where("user_id = ?", userID).Pluck("deck_id", &ids)
+	result := make(map[uint]bool, len(ids))
+	for _, id := range ids {
+		result[id] = true
+	}
+	return result
+}
+
+// listCatalogHandler lists public decks.
+// Query: q, tag, sort (popular|cards|newest|title), page, page_size.
+func listCatalogHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultPageSize)))
+	if pageSize < 1 || pageSize > maxPageSize {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "page_size 必须在 1 到 " + strconv.Itoa(maxPageSize) + " 之间"})
+		return
+	}
+	sort := c.DefaultQuery("sort", spacedrepetition.CatalogSorts[0])
+	valid := false
+	for _, s := range spacedrepetition.CatalogSorts {
+		valid = valid || s == sort
+	}
+	if !valid {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "sort 必须是 " + strings.Join(spacedrepetition.CatalogSorts, "、") + " 之一"})
+		return
+	}
+
+	entries, total, err := sr.ListCatalog(spacedrepetition.CatalogFilter{
+		Query:  c.Query("q"),
+		Tag:    c.Query("tag"),
+		Sort:   sort,
+		Offset: (page - 1) * pageSize,
+		Limit:  pageSize,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取卡组目录失败"})
+		return
+	}
+
+	subscribed := subscribedDecks(userID)
+	decks := make([]map[string]interface{}, len(entries))
+	for i, e := range entries {
+		decks[i] = catalogEntryData(e, userID, subscribed)
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: map[string]interface{}{
+		"decks": decks, "total": total, "page": page, "page_size": pageSize,
+	}})
+}
+
+// findCatalogDeck returns a public deck, or one of the user's own
+func findCatalogDeck(c *gin.Context, userID uint) (*spacedrepetition.CatalogEntry, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "无效的卡组 ID"})
+		return nil, false
+	}
+	e, err := sr.GetCatalogEntry(uint(id))
+	if err != nil || (!e.Public && e.UserID != userID) {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "卡组不存在"})
+		return nil, false
+	}
+	return e, true
+}
+
+// getCatalogDeckHandler shows a public deck with a preview of its first cards
+func getCatalogDeckHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	e, ok := findCatalogDeck(c, userID)
+	if !ok {
+		return
+	}
+	samples, err := sr.SampleCards(&e.Deck, catalogPreviewSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取卡组失败"})
+		return
+	}
+
+	preview := make([]map[string]interface{}, len(samples))
+	for i, q := range samples {
+		preview[i] = map[string]interface{}{"question": q.QuestionText, "answer": q.AnswerText}
+	}
+	data := catalogEntryData(e, userID, subscribedDecks(userID))
+	data["preview"] = preview
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: data})
+}
+
+func subscribeCatalogHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	e, ok := findCatalogDeck(c, userID)
+	if !ok {
+		return
+	}
+	subscribeDeck(c, userID, &e.Deck)
+}
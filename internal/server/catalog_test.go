@@ -0,0 +1,95 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestE2E_DeckCatalog(t *testing.T) {
+	router := setupE2E(t)
+	author := registerAndGetToken(t, router, "catalogauthor")
+	reader := registerAndGetToken(t, router, "catalogreader")
+
+	for i := 0; i < 7; i++ {
+		q := map[string]string{"question": fmt.Sprintf("Linux 问题 %d", i), "answer": "答案", "category": "linux"}
+		apiJSON(t, router, author, "POST", "/api/add-question", q)
+	}
+	// The reader already studies one of them
+	apiJSON(t, router, reader, "POST", "/api/add-question", map[string]string{"question": "Linux 问题 0", "answer": "我的答案"})
+
+	code, resp := apiJSON(t, router, author, "POST", "/api/decks", map[string]interface{}{
+		"category": "linux", "title": "Linux 运维", "description": "常用命令", "tags": []string{"linux, 运维", "linux"},
+	})
+	if code != http.StatusOK {
+		t.Fatalf("publish failed: %d %v", code, resp)
+	}
+	deckID := int(resp.Data.(map[string]interface{})["id"].(float64))
+
+	// Not public yet
+	_, resp = apiJSON(t, router, reader, "GET", "/api/catalog", nil)
+	if n := resp.Data.(map[string]interface{})["total"].(float64); n != 0 {
+		t.Errorf("expected an empty catalog, got %v", n)
+	}
+	if code, _ := apiJSON(t, router, reader, "GET", fmt.Sprintf("/api/catalog/%d", deckID), nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 for a private deck, got %d", code)
+	}
+
+	code, resp = apiJSON(t, router, author, "PATCH", fmt.Sprintf("/api/decks/%d", deckID), map[string]interface{}{"public": true})
+	if code != http.StatusOK {
+		t.Fatalf("update failed: %d %v", code, resp)
+	}
+	if code, _ := apiJSON(t, router, reader, "PATCH", fmt.Sprintf("/api/decks/%d", deckID), map[string]interface{}{"public": false}); code != http.StatusNotFound {
+		t.Errorf("expected 404 for another user's deck, got %d", code)
+	}
+
+	code, resp = apiJSON(t, router, reader, "GET", "/api/catalog?q=运维&sort=newest", nil)
+	if code != http.StatusOK {
+		t.Fatalf("catalog failed: %d %v", code, resp)
+	}
+	decks := resp.Data.(map[string]interface{})["decks"].([]interface{})
+	if len(decks) != 1 {
+		t.Fatalf("expected 1 deck, got %v", resp.Data)
+	}
+	entry := decks[0].(map[string]interface{})
+	tags := entry["tags"].([]interface{})
+	if entry["author"] != "catalogauthor" || entry["cards"].(float64) != 7 || len(tags) != 2 || entry["subscribed"] != false {
+		t.Errorf("unexpected entry: %v", entry)
+	}
+	if code, _ := apiJSON(t, router, reader, "GET", "/api/catalog?sort=random", nil); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an unknown sort, got %d", code)
+	}
+
+	code, resp = apiJSON(t, router, reader, "GET", fmt.Sprintf("/api/catalog/%d", deckID), nil)
+	if code != http.StatusOK {
+		t.Fatalf("preview failed: %d %v", code, resp)
+	}
+	preview := resp.Data.(map[string]interface{})["preview"].([]interface{})
+	if len(preview) != catalogPreviewSize || preview[0].(map[string]interface{})["question"] != "Linux 问题 0" {
+		t.Errorf("unexpected preview: %v", preview)
+	}
+
+	code, resp = apiJSON(t, router, reader, "POST", fmt.Sprintf("/api/catalog/%d/subscribe", deckID), map[string]string{"category": "运维"})
+	if code != http.StatusOK {
+		t.Fatalf("subscribe failed: %d %v", code, resp)
+	}
+	data := resp.Data.(map[string]interface{})
+	if data["added"].(float64) != 6 || data["linked"].(float64) != 1 {
+		t.Errorf("expected 6 added and 1 linked, got %v", data)
+	}
+	_, resp = apiJSON(t, router, reader, "GET", "/api/questions", nil)
+	if n := resp.Data.(map[string]interface{})["total"].(float64); n != 7 {
+		t.Errorf("expected 7 questions without duplicates, got %v", n)
+	}
+
+	// Adding a subscribed question again is a duplicate as well
+	if code, _ := apiJSON(t, router, reader, "POST", "/api/add-question", map[string]string{"question": "Linux 问题 3", "answer": "x"}); code != http.StatusConflict {
+		t.Errorf("expected 409 for a subscribed question, got %d", code)
+	}
+
+	_, resp = apiJSON(t, router, reader, "GET", "/api/catalog", nil)
+	entry = resp.Data.(map[string]interface{})["decks"].([]interface{})[0].(map[string]interface{})
+	if entry["subscribed"] != true || entry["subscribers"].(float64) != 1 {
+		t.Errorf("subscription not reflected: %v", entry)
+	}
+}
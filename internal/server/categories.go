@@ -0,0 +1,442 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"self-improvement/internal/models"
+	"self-improvement/internal/spacedrepetition"
+)
+
+const (
+	maxCategoryNameLength = 64
+	maxMoveQuestions      = 1000
+	defaultWeakestCards   = 5
+	maxWeakestCards       = 50
+)
+
+var colorPattern = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// categoryLabelMap holds the initial labels of the built-in question
+// directories. Users can change them afterwards.
+var categoryLabelMap = map[string]string{
+	"00_summaries": "总结",
+	"01_storage":   "存储",
+	"01_调试命令集合.md": "调试命令集",
+	"03_languages": "编程语言",
+	"05_problems":  "问题案例",
+	"06_career":    "职场修炼",
+	"08_tools":     "工具",
+	"09_ai":        "AI",
+	"10_billing":   "计费",
+}
+
+// categoryLabel returns the initial label of a category, the label of its
+// last path segment for subcategories
+func categoryLabel(name string) string {
+	if label, ok := categoryLabelMap[name]; ok {
+		return label
+	}
+	segment := name[strings.LastIndex(name, spacedrepetition.CategorySeparator)+1:]
+	if label, ok := categoryLabelMap[segment]; ok {
+		return label
+	}
+	return segment
+}
+
+// CategoryRequest creates a category or changes some of its fields
+type CategoryRequest struct {
+	Name               *string  `json:"name"`
+	Label              *string  `json:"label"`
+	Color              *string  `json:"color"`
+	Description        *string  `json:"description"`
+	SortOrder          *int     `json:"sort_order"`
+	IntervalMultiplier *float64 `json:"interval_multiplier"`
+}
+
+// MergeCategoryRequest names the category that absorbs the merged one
+type MergeCategoryRequest struct {
+	Into uint `json:"into" binding:"required"`
+}
+
+// MoveQuestionsRequest lists the questions moved into a category
+type MoveQuestionsRequest struct {
+	QuestionIDs []string `json:"question_ids" binding:"required"`
+}
+
+// apply validates the request and copies the given fields to cat
+func (req *CategoryRequest) apply(cat *models.Category) string {
+	if req.Name != nil {
+		name := strings.TrimSpace(*req.Name)
+		if msg := validateCategoryName(name); msg != "" {
+			return msg
+		}
+		cat.Name = name
+	}
+	if req.Label != nil {
+		cat.Label = strings.TrimSpace(*req.Label)
+	}
+	if req.Color != nil {
+		color := strings.TrimSpace(*req.Color)
+		if color != "" && !colorPattern.MatchString(color) {
+			return "颜色格式应为 #rrggbb"
+		}
+		cat.Color = strings.ToLower(color)
+	}
+	if req.Description != nil {
+		cat.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.SortOrder != nil {
+		cat.SortOrder = *req.SortOrder
+	}
+	if req.IntervalMultiplier != nil {
+		m := *req.IntervalMultiplier
+		if m < spacedrepetition.MinIntervalMultiplier || m > spacedrepetition.MaxIntervalMultiplier {
+			return "复习间隔倍数应在 0.1 到 10 之间"
+		}
+		cat.IntervalMultiplier = m
+	}
+	if cat.Label == "" {
+		cat.Label = categoryLabel(cat.Name)
+	}
+	return ""
+}
+
+func validateCategoryName(name string) string {
+	switch {
+	case name == "":
+		return "分类名称不能为空"
+	case utf8.RuneCountInString(name) > maxCategoryNameLength:
+		return "分类名称过长"
+	case strings.Contains(name, ","):
+		// The due-questions filter takes comma-separated names
+		return "分类名称不能包含逗号"
+	}
+	for _, segment := range strings.Split(name, spacedrepetition.CategorySeparator) {
+		if segment == "" || strings.TrimSpace(segment) != segment {
+			return "分类路径格式不正确"
+		}
+	}
+	return ""
+}
+
+func findCategory(c *gin.Context, userID uint, param string) (*models.Category, bool) {
+	id, err := strconv.ParseUint(param, 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "无效的分类 ID"})
+		return nil, false
+	}
+	cat, err := sr.GetCategory(userID, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "分类不存在"})
+		return nil, false
+	}
+	return cat, true
+}
+
+func categoryData(cat *models.Category) map[string]interface{} {
+	return map[string]interface{}{
+		"id":                  cat.ID,
+		"name":                cat.Name,
+		"label":               cat.Label,
+		"color":               cat.Color,
+		"description":         cat.Description,
+		"sort_order":          cat.SortOrder,
+		"interval_multiplier": cat.IntervalMultiplier,
+		"parent":              spacedrepetition.CategoryParent(cat.Name),
+	}
+}
+
+func categoryNodeData(n *spacedrepetition.CategoryNode, withChildren bool) map[string]interface{} {
+	data := categoryData(n.Category)
+	data["depth"] = n.Depth
+	data["total"] = n.Total
+	data["due"] = n.Due
+	data["own_total"] = n.OwnTotal
+	data["own_due"] = n.OwnDue
+	data["mastery"] = n.Mastery.Mastery
+	data["readiness"] = n.Mastery.Readiness
+	data["recall"] = n.Mastery.Recall
+	if withChildren {
+		children := make([]map[string]interface{}, len(n.Children))
+		for i, child := range n.Children {
+			children[i] = categoryNodeData(child, true)
+		}
+		data["children"] = children
+	}
+	return data
+}
+
+// getCategoriesHandler returns the category tree both flattened, parents
+// before children, and nested. Totals, due counts and mastery include
+// subcategories.
+func getCategoriesHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	sr.RefreshSubscriptions(userID)
+	if err := sr.EnsureCategories(userID, categoryLabel); err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "Failed to get categories"})
+		return
+	}
+	roots, all, err := sr.CategoryTree(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "Failed to get categories"})
+		return
+	}
+
+	categories := make([]map[string]interface{}, len(all))
+	for i, n := range all {
+		categories[i] = categoryNodeData(n, false)
+	}
+	tree := make([]map[string]interface{}, len(roots))
+	for i, n := range roots {
+		tree[i] = categoryNodeData(n, true)
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: map[string]interface{}{"categories": categories, "tree": tree}})
+}
+
+func createCategoryHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Name == nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "Invalid request format"})
+		return
+	}
+
+	cat := &models.Category{UserID: userID, IntervalMultiplier: 1}
+	if msg := req.apply(cat); msg != "" {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: msg})
+		return
+	}
+	err := sr.CreateCategory(cat)
+	if errors.Is(err, spacedrepetition.ErrCategoryExists) {
+		c.JSON(http.StatusConflict, Response{Success: false, Error: "分类已存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "创建分类失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "分类已创建", Data: categoryData(cat)})
+}
+
+// updateCategoryHandler changes a category's settings. A new name renames the
+// category on all of its questions.
+func updateCategoryHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	cat, ok := findCategory(c, userID, c.Param("id"))
+	if !ok {
+		return
+	}
+	var req CategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "Invalid request format"})
+		return
+	}
+	if msg := req.apply(cat); msg != "" {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: msg})
+		return
+	}
+
+	err := sr.UpdateCategory(cat)
+	if errors.Is(err, spacedrepetition.ErrCategoryExists) {
+		c.JSON(http.StatusConflict, Response{Success: false, Error: "分类已存在，请使用合并"})
+		return
+	}
+	if errors.Is(err, spacedrepetition.ErrCategoryCycle) {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "不能移动到自己的子分类下"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "更新分类失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "分类已更新", Data: categoryData(cat)})
+}
+
+// mergeCategoryHandler moves all questions of a category into another one and
+// deletes it
+func mergeCategoryHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	cat, ok := findCategory(c, userID, c.Param("id"))
+	if !ok {
+		return
+	}
+	var req MergeCategoryRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "Invalid request format"})
+		return
+	}
+	if req.Into == cat.ID {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "不能合并到自身"})
+		return
+	}
+
+	err := sr.MergeCategories(userID, cat.ID, req.Into)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "分类不存在"})
+		return
+	}
+	if errors.Is(err, spacedrepetition.ErrCategoryCycle) {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "不能合并到自己的子分类"})
+		return
+	}
+	if errors.Is(err, spacedrepetition.ErrCategoryPublished) {
+		c.JSON(http.StatusConflict, Response{Success: false, Error: "分类已发布为卡组，请先取消发布"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "合并分类失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "分类已合并"})
+}
+
+// deleteCategoryHandler deletes a category with its subcategories and moves
+// their questions to the category given by move_to, 未分类 by default
+func deleteCategoryHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	cat, ok := findCategory(c, userID, c.Param("id"))
+	if !ok {
+		return
+	}
+	moveTo := spacedrepetition.DefaultCategory
+	if raw := c.Query("move_to"); raw != "" {
+		target, ok := findCategory(c, userID, raw)
+		if !ok {
+			return
+		}
+		moveTo = target.Name
+	}
+	err := sr.DeleteCategory(userID, cat.ID, moveTo)
+	if errors.Is(err, spacedrepetition.ErrCategoryCycle) {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "问题不能移动到被删除的分类"})
+		return
+	}
+	if errors.Is(err, spacedrepetition.ErrCategoryPublished) {
+		c.JSON(http.StatusConflict, Response{Success: false, Error: "分类已发布为卡组，请先取消发布"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "删除分类失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "分类已删除", Data: map[string]interface{}{"moved_to": moveTo}})
+}
+
+// moveQuestionsHandler moves questions into a category in bulk
+func moveQuestionsHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	cat, ok := findCategory(c, userID, c.Param("id"))
+	if !ok {
+		return
+	}
+	var req MoveQuestionsRequest
+	if err := c.ShouldBindJSON(&req); err != nil || len(req.QuestionIDs) == 0 {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "Invalid request format"})
+		return
+	}
+	if len(req.QuestionIDs) > maxMoveQuestions {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "一次最多移动 1000 个问题"})
+		return
+	}
+
+	moved, err := sr.MoveQuestions(userID, req.QuestionIDs, cat.Name)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "移动问题失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: map[string]interface{}{"moved": moved, "category": cat.Name}})
+}
+
+// categoryReportHandler reports how ready the user is in each of the
+// categories given by categories, comma-separated, or in every top-level
+// category, together with the weakest reviewed cards of each.
+// Query: categories, weakest (0-50, default 5).
+func categoryReportHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	weakest, err := strconv.Atoi(c.DefaultQuery("weakest", strconv.Itoa(defaultWeakestCards)))
+	if err != nil || weakest < 0 || weakest > maxWeakestCards {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "weakest 必须在 0 到 " + strconv.Itoa(maxWeakestCards) + " 之间"})
+		return
+	}
+
+	sr.RefreshSubscriptions(userID)
+	if err := sr.EnsureCategories(userID, categoryLabel); err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "生成报告失败"})
+		return
+	}
+	roots, all, err := sr.CategoryTree(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "生成报告失败"})
+		return
+	}
+	nodes := make(map[string]*spacedrepetition.CategoryNode, len(all))
+	for _, n := range all {
+		nodes[n.Name] = n
+	}
+
+	var names []string
+	if raw := c.Query("categories"); raw != "" {
+		for _, name := range splitCategories(raw) {
+			if _, ok := nodes[name]; !ok {
+				c.JSON(http.StatusNotFound, Response{Success: false, Error: "分类不存在：" + name})
+				return
+			}
+			names = append(names, name)
+		}
+	} else {
+		for _, n := range roots {
+			names = append(names, n.Name)
+		}
+	}
+
+	reports, overall, err := sr.GetMasteryReport(userID, names, weakest)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "生成报告失败"})
+		return
+	}
+	list := make([]map[string]interface{}, len(reports))
+	for i, r := range reports {
+		n := nodes[r.Category]
+		list[i] = map[string]interface{}{
+			"id":         n.ID,
+			"name":       r.Category,
+			"label":      n.Label,
+			"cards":      r.Cards,
+			"unreviewed": r.Unreviewed,
+			"recall":     r.Recall,
+			"mastery":    r.Mastery,
+			"readiness":  r.Readiness,
+			"weakest":    r.Weakest,
+		}
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: map[string]interface{}{"categories": list, "overall": overall}})
+}
@@ -0,0 +1,282 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func categoriesByName(t *testing.T, router *gin.Engine, token string) map[string]map[string]interface{} {
+	t.Helper()
+	code, resp := apiJSON(t, router, token, "GET", "/api/categories", nil)
+	if code != http.StatusOK {
+		t.Fatalf("list categories failed: %d %v", code, resp)
+	}
+	result := make(map[string]map[string]interface{})
+	for _, item := range resp.Data.(map[string]interface{})["categories"].([]interface{}) {
+		cat := item.(map[string]interface{})
+		result[cat["name"].(string)] = cat
+	}
+	return result
+}
+
+func categoryPath(cat map[string]interface{}, suffix string) string {
+	return fmt.Sprintf("/api/categories/%d%s", int(cat["id"].(float64)), suffix)
+}
+
+func TestE2E_CategoryManagement(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "catuser")
+	other := registerAndGetToken(t, router, "catother")
+
+	for _, q := range []map[string]string{
+		{"question": "Q1", "answer": "A1", "category": "01_storage"},
+		{"question": "Q2", "answer": "A2", "category": "01_storage"},
+		{"question": "Q3", "answer": "A3", "category": "ceph"},
+	} {
+		if code, resp := apiJSON(t, router, token, "POST", "/api/add-question", q); code != http.StatusOK {
+			t.Fatalf("add question failed: %d %v", code, resp)
+		}
+	}
+
+	// Categories that only exist on questions get rows with default labels
+	cats := categoriesByName(t, router, token)
+	storage := cats["01_storage"]
+	if storage == nil || storage["label"] != "存储" || storage["total"].(float64) != 2 || storage["due"].(float64) != 2 {
+		t.Fatalf("unexpected storage category: %v", storage)
+	}
+	if cats["ceph"]["interval_multiplier"].(float64) != 1 {
+		t.Errorf("unexpected default multiplier: %v", cats["ceph"])
+	}
+
+	code, resp := apiJSON(t, router, token, "POST", "/api/categories", map[string]interface{}{
+		"name": "linux", "label": "Linux", "color": "#FF8800", "sort_order": -1,
+	})
+	if code != http.StatusOK {
+		t.Fatalf("create failed: %d %v", code, resp)
+	}
+	if code, _ := apiJSON(t, router, token, "POST", "/api/categories", map[string]string{"name": "linux"}); code != http.StatusConflict {
+		t.Errorf("expected 409 for duplicate name, got %d", code)
+	}
+	for _, body := range []map[string]interface{}{
+		{"name": " "},
+		{"name": "a,b"},
+		{"name": "x", "color": "red"},
+		{"name": "y", "interval_multiplier": 20},
+	} {
+		if code, _ := apiJSON(t, router, token, "POST", "/api/categories", body); code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %v, got %d", body, code)
+		}
+	}
+
+	code, resp = apiJSON(t, router, token, "GET", "/api/categories", nil)
+	first := resp.Data.(map[string]interface{})["categories"].([]interface{})[0].(map[string]interface{})
+	if first["name"] != "linux" || first["color"] != "#ff8800" || first["total"].(float64) != 0 {
+		t.Errorf("expected linux first by sort order: %v", first)
+	}
+
+	// Another user cannot see or change the categories
+	if code, _ := apiJSON(t, router, other, "PATCH", categoryPath(storage, ""), map[string]string{"label": "x"}); code != http.StatusNotFound {
+		t.Errorf("expected 404 for another user, got %d", code)
+	}
+
+	// Rename moves the questions
+	code, resp = apiJSON(t, router, token, "PATCH", categoryPath(storage, ""), map[string]interface{}{
+		"name": "storage", "interval_multiplier": 0.5,
+	})
+	if code != http.StatusOK {
+		t.Fatalf("rename failed: %d %v", code, resp)
+	}
+	if code, _ := apiJSON(t, router, token, "PATCH", categoryPath(storage, ""), map[string]string{"name": "ceph"}); code != http.StatusConflict {
+		t.Errorf("expected 409 for renaming onto an existing category, got %d", code)
+	}
+	cats = categoriesByName(t, router, token)
+	if cats["01_storage"] != nil || cats["storage"]["total"].(float64) != 2 || cats["storage"]["label"] != "存储" {
+		t.Fatalf("rename not applied: %v", cats)
+	}
+	_, resp = apiJSON(t, router, token, "GET", "/api/due-questions?category=storage", nil)
+	if n := len(resp.Data.(map[string]interface{})["questions"].([]interface{})); n != 2 {
+		t.Errorf("expected 2 due questions in renamed category, got %d", n)
+	}
+
+	// Merge ceph into storage
+	code, resp = apiJSON(t, router, token, "POST", categoryPath(cats["ceph"], "/merge"), map[string]interface{}{
+		"into": cats["storage"]["id"],
+	})
+	if code != http.StatusOK {
+		t.Fatalf("merge failed: %d %v", code, resp)
+	}
+	cats = categoriesByName(t, router, token)
+	if cats["ceph"] != nil || cats["storage"]["total"].(float64) != 3 {
+		t.Fatalf("merge not applied: %v", cats)
+	}
+
+	// Bulk move two questions into linux
+	_, resp = apiJSON(t, router, token, "GET", "/api/questions?category=storage&sort=created_at", nil)
+	list := resp.Data.(map[string]interface{})["questions"].([]interface{})
+	ids := []string{list[0].(map[string]interface{})["id"].(string), list[1].(map[string]interface{})["id"].(string)}
+	code, resp = apiJSON(t, router, token, "POST", categoryPath(cats["linux"], "/questions"), map[string]interface{}{
+		"question_ids": append(ids, "q_missing"),
+	})
+	if code != http.StatusOK || resp.Data.(map[string]interface{})["moved"].(float64) != 2 {
+		t.Fatalf("move failed: %d %v", code, resp)
+	}
+
+	// Delete keeps the questions in 未分类
+	code, resp = apiJSON(t, router, token, "DELETE", categoryPath(cats["linux"], ""), nil)
+	if code != http.StatusOK {
+		t.Fatalf("delete failed: %d %v", code, resp)
+	}
+	cats = categoriesByName(t, router, token)
+	if cats["linux"] != nil || cats["未分类"]["total"].(float64) != 2 || cats["storage"]["total"].(float64) != 1 {
+		t.Errorf("delete did not move questions: %v", cats)
+	}
+	if code, _ := apiJSON(t, router, token, "DELETE", categoryPath(cats["未分类"], ""), nil); code != http.StatusBadRequest {
+		t.Errorf("expected 400 when deleting into itself, got %d", code)
+	}
+}
+
+func TestE2E_CategoryTree(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "treeuser")
+
+	for i, category := range []string{"06_career/07_八股文/cpp", "06_career/07_八股文/cpp", "06_career/07_八股文", "06_career/interview", "go"} {
+		q := map[string]string{"question": fmt.Sprintf("Q%d", i), "answer": "A", "category": category}
+		if code, resp := apiJSON(t, router, token, "POST", "/api/add-question", q); code != http.StatusOK {
+			t.Fatalf("add question failed: %d %v", code, resp)
+		}
+	}
+
+	code, resp := apiJSON(t, router, token, "GET", "/api/categories", nil)
+	if code != http.StatusOK {
+		t.Fatalf("list categories failed: %d %v", code, resp)
+	}
+	data := resp.Data.(map[string]interface{})
+	tree := data["tree"].([]interface{})
+	career := tree[0].(map[string]interface{})
+	if career["name"] != "06_career" || career["label"] != "职场修炼" || career["total"].(float64) != 4 || career["own_total"].(float64) != 0 {
+		t.Fatalf("unexpected root: %v", career)
+	}
+	bagu := career["children"].([]interface{})[0].(map[string]interface{})
+	if bagu["label"] != "07_八股文" || bagu["parent"] != "06_career" || bagu["due"].(float64) != 3 {
+		t.Errorf("unexpected child: %v", bagu)
+	}
+	cpp := bagu["children"].([]interface{})[0].(map[string]interface{})
+	if cpp["name"] != "06_career/07_八股文/cpp" || cpp["depth"].(float64) != 2 || cpp["total"].(float64) != 2 {
+		t.Errorf("unexpected leaf: %v", cpp)
+	}
+
+	flat := categoriesByName(t, router, token)
+	if len(flat) != 5 || flat["06_career/07_八股文"]["total"].(float64) != 3 {
+		t.Errorf("unexpected flat list: %v", flat)
+	}
+
+	// A parent filter includes all descendants
+	_, resp = apiJSON(t, router, token, "GET", "/api/due-questions?category=06_career", nil)
+	if n := len(resp.Data.(map[string]interface{})["questions"].([]interface{})); n != 4 {
+		t.Errorf("expected 4 due questions below 06_career, got %d", n)
+	}
+	_, resp = apiJSON(t, router, token, "GET", "/api/due-questions?categories=06_career/07_八股文,go", nil)
+	if n := len(resp.Data.(map[string]interface{})["questions"].([]interface{})); n != 4 {
+		t.Errorf("expected 4 due questions, got %d", n)
+	}
+
+	// Renaming the root moves the subtree
+	code, resp = apiJSON(t, router, token, "PATCH", categoryPath(flat["06_career"], ""), map[string]string{"name": "career"})
+	if code != http.StatusOK {
+		t.Fatalf("rename failed: %d %v", code, resp)
+	}
+	flat = categoriesByName(t, router, token)
+	if flat["career/07_八股文/cpp"] == nil || flat["career"]["total"].(float64) != 4 || flat["06_career/interview"] != nil {
+		t.Errorf("subtree not renamed: %v", flat)
+	}
+	if code, _ := apiJSON(t, router, token, "PATCH", categoryPath(flat["career"], ""), map[string]string{"name": "career/x"}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for moving below itself, got %d", code)
+	}
+	if code, _ := apiJSON(t, router, token, "POST", "/api/categories", map[string]string{"name": "a//b"}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an empty path segment, got %d", code)
+	}
+}
+
+func TestE2E_CategoryReport(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "readyuser")
+
+	for _, q := range []map[string]string{
+		{"question": "Q1", "answer": "A1", "category": "01_storage/ceph"},
+		{"question": "Q2", "answer": "A2", "category": "01_storage"},
+		{"question": "Q3", "answer": "A3", "category": "03_languages"},
+	} {
+		if code, resp := apiJSON(t, router, token, "POST", "/api/add-question", q); code != http.StatusOK {
+			t.Fatalf("add question failed: %d %v", code, resp)
+		}
+	}
+	_, resp := apiJSON(t, router, token, "GET", "/api/questions?sort=category&page_size=10", nil)
+	ids := make(map[string]string)
+	for _, item := range resp.Data.(map[string]interface{})["questions"].([]interface{}) {
+		q := item.(map[string]interface{})
+		ids[q["question"].(string)] = q["id"].(string)
+		if q["priority"].(float64) != 2 {
+			t.Errorf("expected normal priority by default, got %v", q["priority"])
+		}
+	}
+
+	code, resp := apiJSON(t, router, token, "PATCH", "/api/questions/"+ids["Q2"], map[string]int{"priority": 3})
+	if code != http.StatusOK || resp.Data.(map[string]interface{})["priority"].(float64) != 3 {
+		t.Fatalf("set priority failed: %d %v", code, resp)
+	}
+	if code, _ := apiJSON(t, router, token, "PATCH", "/api/questions/"+ids["Q2"], map[string]int{"priority": 5}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for priority 5, got %d", code)
+	}
+	reviewQuestion(t, router, token, ids["Q1"], 1)
+	reviewQuestion(t, router, token, ids["Q2"], 4)
+
+	cats := categoriesByName(t, router, token)
+	storage := cats["01_storage"]
+	if storage["mastery"].(float64) <= 0 || storage["readiness"].(float64) >= storage["mastery"].(float64) {
+		t.Errorf("expected storage mastery with readiness below it, got %v", storage)
+	}
+	if cats["03_languages"]["mastery"].(float64) != 0 {
+		t.Errorf("expected no mastery without reviews, got %v", cats["03_languages"])
+	}
+
+	// Top-level categories by default
+	code, resp = apiJSON(t, router, token, "GET", "/api/categories/report?weakest=1", nil)
+	if code != http.StatusOK {
+		t.Fatalf("report failed: %d %v", code, resp)
+	}
+	data := resp.Data.(map[string]interface{})
+	reports := data["categories"].([]interface{})
+	if len(reports) != 2 {
+		t.Fatalf("expected 2 top-level categories, got %v", reports)
+	}
+	var storageReport map[string]interface{}
+	for _, item := range reports {
+		if r := item.(map[string]interface{}); r["name"] == "01_storage" {
+			storageReport = r
+		}
+	}
+	if storageReport == nil || storageReport["label"] != "存储" || storageReport["cards"].(float64) != 2 {
+		t.Fatalf("unexpected storage report %v", storageReport)
+	}
+	weakest := storageReport["weakest"].([]interface{})
+	if len(weakest) != 1 || weakest[0].(map[string]interface{})["question"] != "Q2" {
+		t.Errorf("expected Q2 as the weakest card, got %v", weakest)
+	}
+	if overall := data["overall"].(map[string]interface{}); overall["cards"].(float64) != 3 || overall["unreviewed"].(float64) != 1 {
+		t.Errorf("unexpected overall stats %v", overall)
+	}
+
+	code, resp = apiJSON(t, router, token, "GET", "/api/categories/report?categories=03_languages", nil)
+	if code != http.StatusOK || len(resp.Data.(map[string]interface{})["categories"].([]interface{})) != 1 {
+		t.Errorf("expected one category report, got %d %v", code, resp)
+	}
+	if code, _ := apiJSON(t, router, token, "GET", "/api/categories/report?categories=nope", nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 for unknown category, got %d", code)
+	}
+	if code, _ := apiJSON(t, router, token, "GET", "/api/categories/report?weakest=100", nil); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for weakest=100, got %d", code)
+	}
+}
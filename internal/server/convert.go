@@ -0,0 +1,126 @@
+package server
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"self-improvement/internal/parser"
+)
+
+// ConvertImportRequest carries the candidates the user accepted after preview
+type ConvertImportRequest struct {
+	Candidates []parser.Candidate `json:"candidates" binding:"required"`
+}
+
+// convertPreviewHandler proposes cards from plain notes without importing
+// anything. Candidates whose question is already in the knowledge base are
+// flagged so the client can reject them by default.
+func convertPreviewHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "请上传文件"})
+		return
+	}
+
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if ext != ".md" && ext != ".markdown" {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "只支持 .md 或 .markdown 格式的文件"})
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "无法打开上传的文件"})
+		return
+	}
+	defer src.Close()
+
+	content, err := io.ReadAll(src)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "读取文件失败"})
+		return
+	}
+
+	candidates := parser.ConvertNotes(string(content), file.Filename)
+	if len(candidates) == 0 {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "没有找到可转换的内容！笔记需要使用标题（## 标题）加正文，或定义列表的结构。"})
+		return
+	}
+
+	var result []map[string]interface{}
+	for _, cand := range candidates {
+		existing, _ := sr.FindQuestionByText(userID, cand.Question)
+		result = append(result, map[string]interface{}{
+			"question": cand.Question,
+			"answer":   cand.Answer,
+			"kind":     cand.Kind,
+			"line":     cand.Line,
+			"source":   cand.SourceFile,
+			"exists":   existing != nil,
+		})
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    map[string]interface{}{"candidates": result, "total": len(result)},
+	})
+}
+
+// convertImportHandler imports the accepted candidates
+func convertImportHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	var req ConvertImportRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "Invalid request format"})
+		return
+	}
+
+	var questions []*parser.Question
+	for i := range req.Candidates {
+		cand := &req.Candidates[i]
+		cand.Question = strings.TrimSpace(cand.Question)
+		cand.Answer = strings.TrimSpace(cand.Answer)
+		if cand.Question == "" || cand.Answer == "" {
+			continue
+		}
+		questions = append(questions, cand.ToQuestion())
+	}
+	if len(questions) == 0 {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "没有选择要导入的问题"})
+		return
+	}
+
+	imported, skipped, duplicates, err := importQuestions(userID, questions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "导入问题失败"})
+		return
+	}
+	emitImport(userID, importEvent{Source: "convert", Imported: imported, Skipped: skipped, Duplicates: duplicates})
+
+	stats, err := sr.GetStats(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取统计失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"message":    "成功导入 " + strconv.Itoa(imported) + " 个新问题到知识库！",
+			"imported":   imported,
+			"skipped":    skipped,
+			"duplicates": duplicates,
+			"stats":      stats,
+		},
+	})
+}
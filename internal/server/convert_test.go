@@ -0,0 +1,99 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func previewConvert(t *testing.T, router *gin.Engine, token, filename, content string) *httptest.ResponseRecorder {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", filename)
+	part.Write([]byte(content))
+	writer.Close()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/convert/preview", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestE2E_ConvertPreviewAndImport(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "convertuser")
+	addQuestion(t, router, token, "Go - GC？", "已有答案")
+
+	notes := "# Go\n\n## GMP\nG、M、P 协作调度 goroutine。\n\n## GC\n三色标记清除。\n\n## 术语\n- **逃逸分析**: 决定变量分配在栈还是堆\n"
+	w := previewConvert(t, router, token, "go.md", notes)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp Response
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	data := resp.Data.(map[string]interface{})
+	candidates := data["candidates"].([]interface{})
+	if len(candidates) != 4 {
+		t.Fatalf("expected 4 candidates, got %d", len(candidates))
+	}
+
+	// Preview must not import anything
+	if due := getDue(t, router, token); len(due) != 1 {
+		t.Errorf("preview imported questions: %d due", len(due))
+	}
+
+	var accepted []map[string]interface{}
+	for _, c := range candidates {
+		m := c.(map[string]interface{})
+		switch m["question"] {
+		case "Go - GC？":
+			if m["exists"] != true {
+				t.Error("existing question not flagged")
+			}
+			accepted = append(accepted, m)
+		case "Go - GMP？", "逃逸分析？":
+			accepted = append(accepted, m)
+		}
+	}
+
+	// The user rejected the "术语" section card
+	body, _ := json.Marshal(map[string]interface{}{"candidates": accepted})
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/convert/import", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("import failed: %d: %s", w.Code, w.Body.String())
+	}
+
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	data = resp.Data.(map[string]interface{})
+	if data["imported"].(float64) != 2 || data["skipped"].(float64) != 1 {
+		t.Errorf("unexpected report: %v", data)
+	}
+	if due := getDue(t, router, token); len(due) != 3 {
+		t.Errorf("expected 3 questions after import, got %d", len(due))
+	}
+}
+
+func TestE2E_ConvertPreview_NoCandidates(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "convertempty")
+
+	if w := previewConvert(t, router, token, "plain.md", "只有一段没有标题的文字"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400, got %d", w.Code)
+	}
+	if w := previewConvert(t, router, token, "notes.txt", "## 标题\n正文"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for extension, got %d", w.Code)
+	}
+}
@@ -0,0 +1,155 @@
+package server
+
+import (
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"self-improvement/internal/models"
+	"self-improvement/internal/parser"
+)
+
+// csvOptions reads the delimiter, column mapping and header flag shared by
+// CSV import and export. ext picks the default delimiter.
+func csvOptions(get func(string) string, ext string) (parser.CSVOptions, error) {
+	opts := parser.CSVOptions{Comma: ',', Header: true}
+	if ext == ".tsv" {
+		opts.Comma = '\t'
+	}
+
+	if d := get("delimiter"); d != "" {
+		comma, err := parser.ParseDelimiter(d)
+		if err != nil {
+			return opts, err
+		}
+		opts.Comma = comma
+	}
+	if h := get("header"); h != "" {
+		header, err := strconv.ParseBool(h)
+		if err != nil {
+			return opts, err
+		}
+		opts.Header = header
+	}
+	if spec := get("columns"); spec != "" {
+		columns, err := parser.ParseColumns(spec)
+		if err != nil {
+			return opts, err
+		}
+		opts.Columns = columns
+	}
+	return opts, nil
+}
+
+func importCSVHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "请上传文件"})
+		return
+	}
+
+	ext := strings.ToLower(filepath.Ext(file.Filename))
+	if ext != ".csv" && ext != ".tsv" && ext != ".txt" {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "只支持 .csv、.tsv 或 .txt 格式的文件"})
+		return
+	}
+
+	opts, err := csvOptions(c.PostForm, ext)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "导入参数错误: " + err.Error()})
+		return
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "无法打开上传的文件"})
+		return
+	}
+	defer src.Close()
+
+	questions, err := parser.ParseCSV(src, "csv:"+filepath.Base(file.Filename), opts)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "无法解析 CSV 文件: " + err.Error()})
+		return
+	}
+	if len(questions) == 0 {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "文件中没有找到有效的问题！请确保每行都包含问题和答案。"})
+		return
+	}
+
+	imported, skipped, duplicates, err := importQuestions(userID, questions)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "导入问题失败"})
+		return
+	}
+	emitImport(userID, importEvent{Source: "csv", Imported: imported, Skipped: skipped, Duplicates: duplicates})
+
+	stats, err := sr.GetStats(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取统计失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"message":    "成功导入 " + strconv.Itoa(imported) + " 个新问题到知识库！",
+			"imported":   imported,
+			"skipped":    skipped,
+			"duplicates": duplicates,
+			"stats":      stats,
+		},
+	})
+}
+
+func exportCSVHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	format := strings.ToLower(c.DefaultQuery("format", "csv"))
+	if format != "csv" && format != "tsv" {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "format 只能是 csv 或 tsv"})
+		return
+	}
+
+	opts, err := csvOptions(c.Query, "."+format)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "导出参数错误: " + err.Error()})
+		return
+	}
+
+	query := db.Where("user_id = ?", userID)
+	if category := c.Query("category"); category != "" {
+		query = query.Where("category IN ?", splitCategories(category))
+	}
+
+	var questions []*models.Question
+	if err := query.Order("category ASC, created_at ASC").Find(&questions).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "导出失败"})
+		return
+	}
+	if err := sr.FillSharedContent(questions); err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "导出失败"})
+		return
+	}
+
+	filename := "questions-" + time.Now().Format("20060102") + "." + format
+	contentType := "text/csv; charset=utf-8"
+	if format == "tsv" {
+		contentType = "text/tab-separated-values; charset=utf-8"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", `attachment; filename="`+filename+`"`)
+	c.Status(http.StatusOK)
+
+	if err := parser.WriteCSV(c.Writer, questions, opts); err != nil {
+		c.Error(err)
+	}
+}
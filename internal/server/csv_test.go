@@ -0,0 +1,153 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"self-improvement/internal/models"
+)
+
+func importCSV(t *testing.T, router *gin.Engine, token, filename, content string, fields map[string]string) *httptest.ResponseRecorder {
+	t.Helper()
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", filename)
+	part.Write([]byte(content))
+	for k, v := range fields {
+		writer.WriteField(k, v)
+	}
+	writer.Close()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/import/csv", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func exportCSV(router *gin.Engine, token, query string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/export/csv"+query, nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func TestE2E_ImportCSV(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "csvuser")
+	addQuestion(t, router, token, "已存在的问题", "旧答案")
+
+	content := "question,answer,category,tags\n" +
+		"什么是 GMP？,\"G、M、P 三者协作：\n- G: goroutine\n- M: 线程\",go,\"runtime, 调度\"\n" +
+		"已存在的问题,新答案,,\n" +
+		"什么是 GMP？,重复行,,\n"
+
+	w := importCSV(t, router, token, "cards.csv", content, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp Response
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	data := resp.Data.(map[string]interface{})
+	if data["imported"].(float64) != 1 || data["skipped"].(float64) != 1 || data["duplicates"].(float64) != 1 {
+		t.Errorf("unexpected report: %v", data)
+	}
+
+	var q models.Question
+	db.Where("question_text = ?", "什么是 GMP？").First(&q)
+	if q.Category != "go" || q.Tags != "runtime,调度" {
+		t.Errorf("metadata mismatch: category=%q tags=%q", q.Category, q.Tags)
+	}
+	if !strings.Contains(q.AnswerText, "\n- M: 线程") {
+		t.Errorf("multi-line answer mismatch: %q", q.AnswerText)
+	}
+}
+
+func TestE2E_ImportCSV_TSVMapping(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "tsvuser")
+
+	// Quizlet style: definition first, no header
+	content := "传输控制协议\tTCP\n用户数据报协议\tUDP\n"
+	w := importCSV(t, router, token, "quizlet.txt", content, map[string]string{
+		"delimiter": "tab",
+		"header":    "false",
+		"columns":   "answer,question",
+	})
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var q models.Question
+	if err := db.Where("question_text = ?", "UDP").First(&q).Error; err != nil {
+		t.Fatalf("mapped question not found: %v", err)
+	}
+	if q.AnswerText != "用户数据报协议" {
+		t.Errorf("answer mismatch: %q", q.AnswerText)
+	}
+}
+
+func TestE2E_ImportCSV_BadRequest(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "csvbad")
+
+	if w := importCSV(t, router, token, "cards.xlsx", "a,b", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for extension, got %d", w.Code)
+	}
+	if w := importCSV(t, router, token, "cards.csv", "a,b", map[string]string{"columns": "question"}); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for incomplete mapping, got %d", w.Code)
+	}
+	if w := importCSV(t, router, token, "cards.csv", "foo,bar\n1,2\n", nil); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown header, got %d", w.Code)
+	}
+}
+
+func TestE2E_ExportCSV_RoundTrip(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "csvexport")
+	addQuestion(t, router, token, "多行问题\n第二行", "答案, 带逗号和 \"引号\"")
+	addQuestion(t, router, token, "另一个问题", "另一个答案")
+
+	w := exportCSV(router, token, "?format=tsv")
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/tab-separated-values") {
+		t.Errorf("unexpected content type: %s", ct)
+	}
+	if !strings.Contains(w.Header().Get("Content-Disposition"), ".tsv") {
+		t.Errorf("unexpected disposition: %s", w.Header().Get("Content-Disposition"))
+	}
+	exported := w.Body.String()
+
+	// Importing the export into another account restores the same cards
+	other := registerAndGetToken(t, router, "csvimport")
+	w = importCSV(t, router, other, "export.tsv", exported, nil)
+	if w.Code != http.StatusOK {
+		t.Fatalf("re-import failed: %d: %s", w.Code, w.Body.String())
+	}
+
+	var user models.User
+	db.Where("username = ?", "csvimport").First(&user)
+	var q models.Question
+	if err := db.Where("user_id = ? AND question_text = ?", user.ID, "多行问题\n第二行").First(&q).Error; err != nil {
+		t.Fatalf("round-tripped question not found: %v", err)
+	}
+	if q.AnswerText != "答案, 带逗号和 \"引号\"" {
+		t.Errorf("answer mismatch: %q", q.AnswerText)
+	}
+
+	if w := exportCSV(router, token, "?delimiter=%22"); w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 for bad delimiter, got %d", w.Code)
+	}
+}
@@ -0,0 +1,381 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"self-improvement/internal/models"
+	"self-improvement/internal/parser"
+	"self-improvement/internal/spacedrepetition"
+)
+
+const maxDeckTags = 10
+
+// PublishDeckRequest publishes one of the user's categories as a deck
+type PublishDeckRequest struct {
+	Category    string   `json:"category" binding:"required"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	Public      bool     `json:"public"` // List the deck in the catalog
+}
+
+// UpdateDeckRequest changes some of a deck's fields
+type UpdateDeckRequest struct {
+	Title       *string   `json:"title"`
+	Description *string   `json:"description"`
+	Tags        *[]string `json:"tags"`
+	Public      *bool     `json:"public"`
+}
+
+// SubscribeRequest names the category subscribed cards are filed below
+type SubscribeRequest struct {
+	Category string `json:"category"`
+}
+
+// deckTags normalizes tags to a comma-separated list without duplicates
+func deckTags(tags []string) (string, bool) {
+	var list []string
+	seen := make(map[string]bool)
+	for _, t := range tags {
+		for _, tag := range parser.SplitTags(t) {
+			if !seen[tag] {
+				seen[tag] = true
+				list = append(list, tag)
+			}
+		}
+	}
+	return strings.Join(list, ","), len(list) <= maxDeckTags
+}
+
+func splitDeckTags(tags string) []string {
+	if tags == "" {
+		return []string{}
+	}
+	return strings.Split(tags, ",")
+}
+
+func deckAuthor(userID uint) string {
+	var user models.User
+	if err := db.Select("username").First(&user, userID).Error; err != nil {
+		return ""
+	}
+	return user.Username
+}
+
+func deckData(d *models.Deck) map[string]interface{} {
+	return map[string]interface{}{
+		"id":          d.ID,
+		"category":    d.Category,
+		"title":       d.Title,
+		"description": d.Description,
+		"tags":        splitDeckTags(d.Tags),
+		"public":      d.Public,
+		"share_code":  d.ShareCode,
+		"cards":       sr.DeckCards(d),
+		"subscribers": sr.DeckSubscribers(d.ID),
+		"created_at":  d.CreatedAt,
+	}
+}
+
+func listDecksHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	decks, err := sr.ListDecks(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取卡组失败"})
+		return
+	}
+	list := make([]map[string]interface{}, len(decks))
+	for i, d := range decks {
+		list[i] = deckData(d)
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: map[string]interface{}{"decks": list}})
+}
+
+// publishDeckHandler publishes a category, including its subcategories, as a
+// read-only deck and returns its share code
+func publishDeckHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	var req PublishDeckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "Invalid request format"})
+		return
+	}
+	d := &models.Deck{
+		UserID:      userID,
+		Category:    strings.TrimSpace(req.Category),
+		Title:       strings.TrimSpace(req.Title),
+		Description: strings.TrimSpace(req.Description),
+		Public:      req.Public,
+	}
+	tags, ok := deckTags(req.Tags)
+	if !ok {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "标签最多 10 个"})
+		return
+	}
+	d.Tags = tags
+	if d.Title == "" {
+		d.Title = categoryLabel(d.Category)
+	}
+	if sr.DeckCards(d) == 0 {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "该分类下没有可以分享的问题"})
+		return
+	}
+
+	err := sr.PublishDeck(d)
+	if errors.Is(err, spacedrepetition.ErrDeckExists) {
+		c.JSON(http.StatusConflict, Response{Success: false, Error: "该分类已经发布"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "发布卡组失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "卡组已发布", Data: deckData(d)})
+}
+
+// updateDeckHandler changes a deck's title, description, tags or whether it
+// is listed in the catalog
+func updateDeckHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	d, ok := findOwnDeck(c, userID)
+	if !ok {
+		return
+	}
+	var req UpdateDeckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "Invalid request format"})
+		return
+	}
+	if req.Title != nil {
+		title := strings.TrimSpace(*req.Title)
+		if title == "" {
+			c.JSON(http.StatusBadRequest, Response{Success: false, Error: "标题不能为空"})
+			return
+		}
+		d.Title = title
+	}
+	if req.Description != nil {
+		d.Description = strings.TrimSpace(*req.Description)
+	}
+	if req.Tags != nil {
+		tags, ok := deckTags(*req.Tags)
+		if !ok {
+			c.JSON(http.StatusBadRequest, Response{Success: false, Error: "标签最多 10 个"})
+			return
+		}
+		d.Tags = tags
+	}
+	if req.Public != nil {
+		d.Public = *req.Public
+	}
+
+	if err := sr.UpdateDeck(d); err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "更新卡组失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "卡组已更新", Data: deckData(d)})
+}
+
+func findOwnDeck(c *gin.Context, userID uint) (*models.Deck, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "无效的卡组 ID"})
+		return nil, false
+	}
+	var d models.Deck
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&d).Error; err != nil {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "卡组不存在"})
+		return nil, false
+	}
+	return &d, true
+}
+
+// unpublishDeckHandler stops sharing a deck. Subscribers keep their cards as
+// their own copies.
+func unpublishDeckHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "无效的卡组 ID"})
+		return
+	}
+	err = sr.UnpublishDeck(userID, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "卡组不存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "取消发布失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "卡组已取消发布"})
+}
+
+func findSharedDeck(c *gin.Context) (*models.Deck, bool) {
+	d, err := sr.GetDeckByCode(c.Param("code"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "分享码无效"})
+		return nil, false
+	}
+	return d, true
+}
+
+// getSharedDeckHandler shows a deck behind a share code before subscribing
+func getSharedDeckHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	d, ok := findSharedDeck(c)
+	if !ok {
+		return
+	}
+	var subscribed int64
+	db.Model(&models.DeckSubscription{}).Where("user_id = ? AND deck_id = ?", userID, d.ID).Count(&subscribed)
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: map[string]interface{}{
+		"title":       d.Title,
+		"description": d.Description,
+		"author":      deckAuthor(d.UserID),
+		"cards":       sr.DeckCards(d),
+		"subscribers": sr.DeckSubscribers(d.ID),
+		"subscribed":  subscribed > 0,
+		"own":         d.UserID == userID,
+	}})
+}
+
+// deckCategory is the default category of a deck's subscribed cards
+func deckCategory(d *models.Deck) string {
+	if validateCategoryName(d.Title) != "" {
+		return "deck_" + d.ShareCode
+	}
+	return d.Title
+}
+
+// subscribeHandler subscribes to a deck. Every card gets its own scheduling
+// state while the content stays with the author.
+func subscribeHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	d, ok := findSharedDeck(c)
+	if !ok {
+		return
+	}
+	subscribeDeck(c, userID, d)
+}
+
+// subscribeDeck subscribes the user to d. Questions the user already has are
+// linked to the deck instead of being duplicated.
+func subscribeDeck(c *gin.Context, userID uint, d *models.Deck) {
+	var req SubscribeRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, Response{Success: false, Error: "Invalid request format"})
+			return
+		}
+	}
+	category := strings.TrimSpace(req.Category)
+	if category == "" {
+		category = deckCategory(d)
+	}
+	if msg := validateCategoryName(category); msg != "" {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: msg})
+		return
+	}
+
+	sub, changes, err := sr.Subscribe(userID, d, category)
+	if errors.Is(err, spacedrepetition.ErrOwnDeck) {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "不能订阅自己的卡组"})
+		return
+	}
+	if errors.Is(err, spacedrepetition.ErrAlreadySubscribed) {
+		c.JSON(http.StatusConflict, Response{Success: false, Error: "已经订阅过该卡组"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "订阅失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Message: "成功订阅，新增 " + strconv.Itoa(changes.Added) + " 张卡片，关联 " + strconv.Itoa(changes.Linked) + " 个已有问题",
+		Data:    map[string]interface{}{"subscription": sub, "added": changes.Added, "linked": changes.Linked},
+	})
+}
+
+func listSubscriptionsHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	sr.RefreshSubscriptions(userID)
+	subs, decks, err := sr.ListSubscriptions(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取订阅失败"})
+		return
+	}
+
+	list := make([]map[string]interface{}, 0, len(subs))
+	for _, sub := range subs {
+		d, ok := decks[sub.DeckID]
+		if !ok {
+			continue
+		}
+		var cards int64
+		db.Model(&models.Question{}).Where("user_id = ? AND deck_id = ?", userID, d.ID).Count(&cards)
+		list = append(list, map[string]interface{}{
+			"id":          sub.ID,
+			"deck_id":     d.ID,
+			"category":    sub.Category,
+			"title":       d.Title,
+			"description": d.Description,
+			"author":      deckAuthor(d.UserID),
+			"cards":       cards,
+			"created_at":  sub.CreatedAt,
+		})
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: map[string]interface{}{"subscriptions": list}})
+}
+
+// unsubscribeHandler removes a subscription with its cards and their review
+// history
+func unsubscribeHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "无效的订阅 ID"})
+		return
+	}
+	err = sr.Unsubscribe(userID, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "订阅不存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "取消订阅失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "已取消订阅"})
+}
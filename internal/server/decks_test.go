@@ -0,0 +1,202 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"testing"
+
+	"self-improvement/internal/assets"
+)
+
+func TestE2E_SharedDecks(t *testing.T) {
+	router := setupE2E(t)
+	author := registerAndGetToken(t, router, "deckauthor")
+	reader := registerAndGetToken(t, router, "deckreader")
+
+	for _, q := range []map[string]string{
+		{"question": "什么是 goroutine？", "answer": "轻量级线程", "category": "go"},
+		{"question": "什么是 channel？", "answer": "通信管道", "category": "go/concurrency"},
+	} {
+		if code, resp := apiJSON(t, router, author, "POST", "/api/add-question", q); code != http.StatusOK {
+			t.Fatalf("add question failed: %d %v", code, resp)
+		}
+	}
+
+	if code, _ := apiJSON(t, router, author, "POST", "/api/decks", map[string]string{"category": "rust"}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for an empty category, got %d", code)
+	}
+	code, resp := apiJSON(t, router, author, "POST", "/api/decks", map[string]string{"category": "go", "title": "Go 基础"})
+	if code != http.StatusOK {
+		t.Fatalf("publish failed: %d %v", code, resp)
+	}
+	deck := resp.Data.(map[string]interface{})
+	shareCode := deck["share_code"].(string)
+	if deck["cards"].(float64) != 2 {
+		t.Errorf("unexpected deck: %v", deck)
+	}
+
+	code, resp = apiJSON(t, router, reader, "GET", "/api/decks/shared/"+shareCode, nil)
+	if code != http.StatusOK {
+		t.Fatalf("preview failed: %d %v", code, resp)
+	}
+	preview := resp.Data.(map[string]interface{})
+	if preview["title"] != "Go 基础" || preview["author"] != "deckauthor" || preview["subscribed"] != false {
+		t.Errorf("unexpected preview: %v", preview)
+	}
+	if code, _ := apiJSON(t, router, reader, "GET", "/api/decks/shared/NOPE1234", nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown code, got %d", code)
+	}
+	if code, _ := apiJSON(t, router, author, "POST", "/api/decks/shared/"+shareCode+"/subscribe", nil); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for subscribing to own deck, got %d", code)
+	}
+
+	code, resp = apiJSON(t, router, reader, "POST", "/api/decks/shared/"+shareCode+"/subscribe", nil)
+	if code != http.StatusOK || resp.Data.(map[string]interface{})["added"].(float64) != 2 {
+		t.Fatalf("subscribe failed: %d %v", code, resp)
+	}
+	if code, _ := apiJSON(t, router, reader, "POST", "/api/decks/shared/"+shareCode+"/subscribe", nil); code != http.StatusConflict {
+		t.Errorf("expected 409 for a second subscription, got %d", code)
+	}
+
+	_, resp = apiJSON(t, router, reader, "GET", "/api/due-questions?category="+url.QueryEscape("Go 基础"), nil)
+	due := resp.Data.(map[string]interface{})["questions"].([]interface{})
+	if len(due) != 2 {
+		t.Fatalf("expected 2 due cards, got %v", resp)
+	}
+	card := due[0].(map[string]interface{})
+	cardID := card["id"].(string)
+	if card["question"] == "" || card["deck_id"].(float64) != deck["id"].(float64) {
+		t.Errorf("unexpected card: %v", card)
+	}
+	reviewQuestion(t, router, reader, cardID, 1)
+
+	if code, _ := apiJSON(t, router, reader, "PATCH", "/api/questions/"+cardID, map[string]string{"answer": "x"}); code != http.StatusForbidden {
+		t.Errorf("expected 403 for editing a shared card, got %d", code)
+	}
+
+	// The author edits a card and adds one; the subscriber sees both and
+	// keeps the review
+	_, resp = apiJSON(t, router, author, "GET", "/api/questions?category=go", nil)
+	for _, item := range resp.Data.(map[string]interface{})["questions"].([]interface{}) {
+		q := item.(map[string]interface{})
+		apiJSON(t, router, author, "PATCH", "/api/questions/"+q["id"].(string), map[string]string{"answer": q["answer"].(string) + "（更新）"})
+	}
+	apiJSON(t, router, author, "POST", "/api/add-question", map[string]string{"question": "什么是 select？", "answer": "多路复用", "category": "go/concurrency"})
+
+	code, resp = apiJSON(t, router, reader, "GET", "/api/questions/"+cardID, nil)
+	if code != http.StatusOK {
+		t.Fatalf("get card failed: %d %v", code, resp)
+	}
+	got := resp.Data.(map[string]interface{})
+	if got["answer"] != card["answer"].(string)+"（更新）" || got["review_count"].(float64) != 1 {
+		t.Errorf("author update not visible or progress lost: %v", got)
+	}
+	cats := categoriesByName(t, router, reader)
+	if cats["Go 基础"]["total"].(float64) != 3 || cats["Go 基础/concurrency"]["total"].(float64) != 2 {
+		t.Errorf("new card not added: %v", cats)
+	}
+
+	code, resp = apiJSON(t, router, reader, "GET", "/api/subscriptions", nil)
+	subs := resp.Data.(map[string]interface{})["subscriptions"].([]interface{})
+	if code != http.StatusOK || len(subs) != 1 {
+		t.Fatalf("unexpected subscriptions: %d %v", code, resp)
+	}
+	sub := subs[0].(map[string]interface{})
+	if sub["cards"].(float64) != 3 || sub["author"] != "deckauthor" {
+		t.Errorf("unexpected subscription: %v", sub)
+	}
+
+	_, resp = apiJSON(t, router, author, "GET", "/api/decks", nil)
+	mine := resp.Data.(map[string]interface{})["decks"].([]interface{})[0].(map[string]interface{})
+	if mine["subscribers"].(float64) != 1 || mine["cards"].(float64) != 3 {
+		t.Errorf("unexpected deck stats: %v", mine)
+	}
+
+	code, _ = apiJSON(t, router, reader, "DELETE", fmt.Sprintf("/api/subscriptions/%d", int(sub["id"].(float64))), nil)
+	if code != http.StatusOK {
+		t.Fatalf("unsubscribe failed: %d", code)
+	}
+	_, resp = apiJSON(t, router, reader, "GET", "/api/questions", nil)
+	if n := resp.Data.(map[string]interface{})["total"].(float64); n != 0 {
+		t.Errorf("expected no cards after unsubscribe, got %v", n)
+	}
+
+	// Author cards are untouched
+	_, resp = apiJSON(t, router, author, "GET", "/api/questions", nil)
+	if n := resp.Data.(map[string]interface{})["total"].(float64); n != 3 {
+		t.Errorf("expected 3 author questions, got %v", n)
+	}
+	if code, _ := apiJSON(t, router, reader, "DELETE", fmt.Sprintf("/api/decks/%d", int(mine["id"].(float64))), nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 for unpublishing another user's deck, got %d", code)
+	}
+}
+
+func TestE2E_SharedDecks_SearchAndAssets(t *testing.T) {
+	router := setupE2E(t)
+	author := registerAndGetToken(t, router, "assetauthor")
+	reader := registerAndGetToken(t, router, "assetreader")
+	stranger := registerAndGetToken(t, router, "assetstranger")
+
+	png := "\x89PNG\r\n\x1a\nshared-image"
+	uploadZip(t, router, author, buildZip(t, map[string]string{
+		"kb/net/tcp.md":      "# q\nTCP 三次握手？\n# a\n![图](img/tcp.png)",
+		"kb/net/img/tcp.png": png,
+	}))
+	code, resp := apiJSON(t, router, author, "POST", "/api/decks", map[string]string{"category": "kb", "title": "网络"})
+	if code != http.StatusOK {
+		t.Fatalf("publish failed: %d %v", code, resp)
+	}
+	shareCode := resp.Data.(map[string]interface{})["share_code"].(string)
+	if code, resp := apiJSON(t, router, reader, "POST", "/api/decks/shared/"+shareCode+"/subscribe", nil); code != http.StatusOK {
+		t.Fatalf("subscribe failed: %d %v", code, resp)
+	}
+
+	_, resp = apiJSON(t, router, reader, "GET", "/api/search?q="+url.QueryEscape("握手"), nil)
+	results := resp.Data.(map[string]interface{})["results"].([]interface{})
+	if len(results) != 1 || results[0].(map[string]interface{})["question"] != "TCP 三次握手？" {
+		t.Errorf("expected the subscribed card in search results, got %v", resp.Data)
+	}
+
+	hash := assets.Hash([]byte(png))
+	if w := getAsset(router, reader, hash); w.Code != http.StatusOK || w.Body.String() != png {
+		t.Errorf("subscriber cannot read the author's asset: %d", w.Code)
+	}
+	if w := getAsset(router, stranger, hash); w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a user without the deck, got %d", w.Code)
+	}
+}
+
+func TestE2E_SharedDecks_Duplicates(t *testing.T) {
+	router := setupE2E(t)
+	wd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(wd)
+
+	author := registerAndGetToken(t, router, "dupauthor")
+	reader := registerAndGetToken(t, router, "dupreader")
+	addQuestion(t, router, author, "什么是 goroutine？", "轻量级线程")
+	apiJSON(t, router, author, "POST", "/api/decks", map[string]string{"category": "未分类", "title": "Go"})
+	_, resp := apiJSON(t, router, author, "GET", "/api/decks", nil)
+	shareCode := resp.Data.(map[string]interface{})["decks"].([]interface{})[0].(map[string]interface{})["share_code"].(string)
+	if code, resp := apiJSON(t, router, reader, "POST", "/api/decks/shared/"+shareCode+"/subscribe", nil); code != http.StatusOK {
+		t.Fatalf("subscribe failed: %d %v", code, resp)
+	}
+
+	// Subscribed cards store no text of their own
+	addQuestion(t, router, reader, "其他问题", "答案")
+	id := userQuestions(t, userByName(t, "dupreader").ID)["其他问题"].ID
+	if code, _ := apiJSON(t, router, reader, "PATCH", "/api/questions/"+id, map[string]string{"question": "什么是 goroutine？"}); code != http.StatusConflict {
+		t.Errorf("expected 409 editing into a subscribed card's text, got %d", code)
+	}
+
+	w := importBackup(t, router, reader, exportBackup(t, router, author))
+	var restored Response
+	json.Unmarshal(w.Body.Bytes(), &restored)
+	data := restored.Data.(map[string]interface{})
+	if w.Code != http.StatusOK || data["imported"].(float64) != 0 || data["skipped"].(float64) != 1 {
+		t.Errorf("expected the subscribed card skipped on restore: %d %v", w.Code, data)
+	}
+}
@@ -0,0 +1,353 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"net/mail"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+
+	"self-improvement/internal/digest"
+	"self-improvement/internal/models"
+)
+
+var (
+	// mailer sends digests, nil when no SMTP server is configured
+	mailer       digest.Mailer
+	digestConfig digest.Config
+)
+
+const (
+	// defaultDigestInterval is how often the scheduler looks for digests due
+	defaultDigestInterval = time.Minute
+	// digestHardest is how many of the hardest due cards a digest lists
+	digestHardest = 5
+	// maxDigestFailures is how often a scheduled digest is tried per day
+	maxDigestFailures = 3
+)
+
+// DigestRequest changes some of the user's digest settings
+type DigestRequest struct {
+	Enabled *bool   `json:"enabled"`
+	Email   *string `json:"email"`
+	Time    *string `json:"time"` // HH:MM in the user's timezone
+}
+
+func digestInterval() time.Duration {
+	if v := os.Getenv("DIGEST_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return defaultDigestInterval
+}
+
+// startDigests sends the digests of users whose send time has passed. A
+// non-positive interval or a missing SMTP server disables sending.
+func startDigests(interval time.Duration) {
+	if interval <= 0 || mailer == nil {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			sendDueDigests(time.Now())
+		}
+	}()
+}
+
+// sendDueDigests sends today's digest to every subscribed user whose send
+// time has passed at now and who did not get it yet
+func sendDueDigests(now time.Time) {
+	var settings []models.UserSettings
+	if err := db.Where("digest_enabled = ?", true).Find(&settings).Error; err != nil {
+		return
+	}
+	for i := range settings {
+		s := &settings[i]
+		loc, _ := sr.UserGoal(s.UserID)
+		date, due := digest.Due(now, loc, s.DigestTime)
+		if due && !digestDone(s.UserID, date) {
+			sendDigest(s, date, false)
+		}
+	}
+}
+
+// digestDone tells whether the scheduled digest of date was sent or skipped,
+// or failed too often to try again
+func digestDone(userID uint, date string) bool {
+	var statuses []string
+	db.Model(&models.DigestLog{}).Where("user_id = ? AND date = ? AND manual = ?", userID, date, false).
+		Pluck("status", &statuses)
+	failures := 0
+	for _, status := range statuses {
+		if status != models.DigestFailed {
+			return true
+		}
+		failures++
+	}
+	return failures >= maxDigestFailures
+}
+
+// sendDigest sends the user's digest of date and logs the outcome. Scheduled
+// digests are skipped when no card is due.
+func sendDigest(s *models.UserSettings, date string, manual bool) (*models.DigestLog, error) {
+	entry := &models.DigestLog{UserID: s.UserID, Date: date, Email: s.DigestEmail, Manual: manual}
+	d, err := buildDigest(s, date)
+	if err == nil {
+		entry.Subject, entry.Due = d.Subject(), d.Due
+	}
+	switch {
+	case err != nil:
+		entry.Status, entry.Error = models.DigestFailed, err.Error()
+	case d.Due == 0 && !manual:
+		entry.Status = models.DigestSkipped
+	default:
+		msg, err := d.Message(digestConfig.From, s.DigestEmail)
+		if err == nil {
+			err = mailer.Send(msg)
+		}
+		entry.Status = models.DigestSent
+		if err != nil {
+			entry.Status, entry.Error = models.DigestFailed, err.Error()
+		}
+	}
+	return entry, db.Create(entry).Error
+}
+
+// buildDigest gathers the due counts per top-level category, the streak and
+// the hardest due cards of the user
+func buildDigest(s *models.UserSettings, date string) (*digest.Digest, error) {
+	var user models.User
+	if err := db.First(&user, s.UserID).Error; err != nil {
+		return nil, err
+	}
+	roots, _, err := sr.CategoryTree(s.UserID)
+	if err != nil {
+		return nil, err
+	}
+	status, err := sr.GetStreakStatus(s.UserID)
+	if err != nil {
+		return nil, err
+	}
+	hardest, err := sr.HardestDue(s.UserID, digestHardest)
+	if err != nil {
+		return nil, err
+	}
+
+	d := &digest.Digest{
+		Username:       user.Username,
+		Date:           date,
+		Goal:           status.Goal,
+		Today:          status.Today,
+		CurrentStreak:  status.CurrentStreak,
+		LongestStreak:  status.LongestStreak,
+		AppURL:         digestConfig.BaseURL,
+		UnsubscribeURL: digestConfig.BaseURL + "/api/digest/unsubscribe?token=" + s.DigestToken,
+	}
+	for _, r := range roots {
+		if r.Due > 0 {
+			d.Due += r.Due
+			d.Categories = append(d.Categories, digest.Category{Label: r.Label, Due: r.Due})
+		}
+	}
+	for _, c := range hardest {
+		d.Hardest = append(d.Hardest, digest.Card{Question: c.Question, Category: c.Category, Level: c.Level})
+	}
+	return d, nil
+}
+
+func digestSettings(userID uint) *models.UserSettings {
+	settings := &models.UserSettings{UserID: userID}
+	db.Where("user_id = ?", userID).Limit(1).Find(settings)
+	return settings
+}
+
+func digestData(s *models.UserSettings) map[string]interface{} {
+	at := s.DigestTime
+	if at == "" {
+		at = digest.DefaultTime
+	}
+	var last []models.DigestLog
+	db.Where("user_id = ?", s.UserID).Order("id DESC").Limit(1).Find(&last)
+	data := map[string]interface{}{
+		"enabled":         s.DigestEnabled,
+		"email":           s.DigestEmail,
+		"time":            at,
+		"timezone":        s.Timezone,
+		"smtp_configured": mailer != nil,
+		"last":            nil,
+	}
+	if len(last) > 0 {
+		data["last"] = last[0]
+	}
+	return data
+}
+
+// getDigestHandler returns the user's digest settings and the latest send
+func getDigestHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: digestData(digestSettings(userID))})
+}
+
+// updateDigestHandler changes the digest address, send time or subscription
+func updateDigestHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	var req DigestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "Invalid request format"})
+		return
+	}
+	s := digestSettings(userID)
+	if req.Email != nil {
+		email := strings.TrimSpace(*req.Email)
+		if email != "" {
+			if addr, err := mail.ParseAddress(email); err != nil || addr.Address != email {
+				c.JSON(http.StatusBadRequest, Response{Success: false, Error: "无效的邮箱地址"})
+				return
+			}
+		}
+		s.DigestEmail = email
+	}
+	if req.Time != nil {
+		if !digest.ValidTime(*req.Time) {
+			c.JSON(http.StatusBadRequest, Response{Success: false, Error: "time 必须是 HH:MM 格式，如 08:00"})
+			return
+		}
+		s.DigestTime = *req.Time
+	}
+	if req.Enabled != nil {
+		s.DigestEnabled = *req.Enabled
+	}
+	if s.DigestEnabled && s.DigestEmail == "" {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "请先设置邮箱地址"})
+		return
+	}
+	if s.DigestEnabled && mailer == nil {
+		c.JSON(http.StatusServiceUnavailable, Response{Success: false, Error: "服务器未配置 SMTP，无法发送每日摘要"})
+		return
+	}
+	if s.DigestToken == "" {
+		s.DigestToken = digest.NewToken()
+	}
+
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"digest_enabled", "digest_email", "digest_time", "digest_token", "updated_at"}),
+	}).Create(s).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "保存设置失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: digestData(s)})
+}
+
+// sendDigestHandler sends today's digest right away, even when nothing is due
+// or the digest is turned off, so users can check their address
+func sendDigestHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	s := digestSettings(userID)
+	if s.DigestEmail == "" {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "请先设置邮箱地址"})
+		return
+	}
+	if mailer == nil {
+		c.JSON(http.StatusServiceUnavailable, Response{Success: false, Error: "服务器未配置 SMTP，无法发送每日摘要"})
+		return
+	}
+	loc, _ := sr.UserGoal(userID)
+	date, _ := digest.Due(time.Now(), loc, s.DigestTime)
+	entry, err := sendDigest(s, date, true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "保存发送记录失败"})
+		return
+	}
+	if entry.Status == models.DigestFailed {
+		c.JSON(http.StatusBadGateway, Response{Success: false, Error: "发送失败：" + entry.Error, Data: entry})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "每日摘要已发送到 " + s.DigestEmail, Data: entry})
+}
+
+// listDigestLogsHandler returns the user's digest send log, newest first.
+// Query: page, page_size, status (sent|failed|skipped).
+func listDigestLogsHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultPageSize)))
+	if pageSize < 1 || pageSize > maxPageSize {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "page_size 必须在 1 到 " + strconv.Itoa(maxPageSize) + " 之间"})
+		return
+	}
+
+	query := db.Model(&models.DigestLog{}).Where("user_id = ?", userID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var total int64
+	var logs []models.DigestLog
+	err := query.Count(&total).Error
+	if err == nil {
+		err = query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&logs).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取发送记录失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"logs":      logs,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+const unsubscribePage = `<!DOCTYPE html>
+<html><head><meta charset="utf-8"><title>每日摘要</title></head>
+<body style="font-family: sans-serif; text-align: center; margin-top: 80px;"><p>%s</p></body></html>
+`
+
+func unsubscribeResult(c *gin.Context, status int, text string) {
+	c.Data(status, "text/html; charset=utf-8", []byte(fmt.Sprintf(unsubscribePage, text)))
+}
+
+// unsubscribeDigestHandler turns the digest off from the link in an email. It
+// needs no login; the token identifies the user. POST supports one-click
+// unsubscribe from mail clients.
+func unsubscribeDigestHandler(c *gin.Context) {
+	token := c.Query("token")
+	var s models.UserSettings
+	if token == "" || db.Where("digest_token = ?", token).Limit(1).Find(&s).RowsAffected == 0 {
+		unsubscribeResult(c, http.StatusNotFound, "退订链接无效。")
+		return
+	}
+	if err := db.Model(&s).Update("digest_enabled", false).Error; err != nil {
+		unsubscribeResult(c, http.StatusInternalServerError, "退订失败，请稍后重试。")
+		return
+	}
+
+	unsubscribeResult(c, http.StatusOK, "已退订每日摘要，可以随时在设置中重新开启。")
+}
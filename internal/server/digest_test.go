@@ -0,0 +1,136 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"self-improvement/internal/digest"
+	"self-improvement/internal/models"
+)
+
+// fakeMailer records the messages it is asked to send, or fails with err
+type fakeMailer struct {
+	sent []*digest.Message
+	err  error
+}
+
+func (m *fakeMailer) Send(msg *digest.Message) error {
+	if m.err != nil {
+		return m.err
+	}
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+func TestE2E_Digest(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "digestuser")
+
+	code, resp := apiJSON(t, router, token, "GET", "/api/digest", nil)
+	data := resp.Data.(map[string]interface{})
+	if code != http.StatusOK || data["enabled"] != false || data["time"] != digest.DefaultTime || data["smtp_configured"] != false {
+		t.Fatalf("unexpected defaults: %d %v", code, data)
+	}
+	if code, _ := apiJSON(t, router, token, "PUT", "/api/digest", map[string]interface{}{"enabled": true, "email": "a@example.com"}); code != http.StatusServiceUnavailable {
+		t.Errorf("expected 503 without SMTP, got %d", code)
+	}
+
+	m := &fakeMailer{}
+	mailer = m
+	for _, body := range []map[string]interface{}{
+		{"enabled": true},
+		{"email": "not an address"},
+		{"email": "Alice <a@example.com>"},
+		{"time": "8am"},
+		{"time": "24:00"},
+	} {
+		if code, _ := apiJSON(t, router, token, "PUT", "/api/digest", body); code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %v, got %d", body, code)
+		}
+	}
+	// Sent as soon as the scheduler runs, whatever the time of day
+	code, resp = apiJSON(t, router, token, "PUT", "/api/digest", map[string]interface{}{"enabled": true, "email": "a@example.com", "time": "00:00"})
+	if code != http.StatusOK || resp.Data.(map[string]interface{})["enabled"] != true {
+		t.Fatalf("enable failed: %d %v", code, resp)
+	}
+
+	// Nothing due: logged as skipped, once a day
+	now := time.Now()
+	sendDueDigests(now)
+	sendDueDigests(now)
+	_, resp = apiJSON(t, router, token, "GET", "/api/digest/logs", nil)
+	logs := resp.Data.(map[string]interface{})
+	if logs["total"].(float64) != 1 || len(m.sent) != 0 {
+		t.Fatalf("expected 1 skipped digest and no email, got %v", logs)
+	}
+	if first := logs["logs"].([]interface{})[0].(map[string]interface{}); first["status"] != models.DigestSkipped {
+		t.Errorf("expected a skipped digest, got %v", first)
+	}
+
+	for _, q := range []string{"Q1", "Q2", "Q3"} {
+		addQuestion(t, router, token, q, "A")
+	}
+	user := userByName(t, "digestuser")
+	past := now.Add(-time.Hour)
+	db.Model(&models.Question{}).Where("user_id = ? AND question_text = ?", user.ID, "Q1").
+		Updates(map[string]interface{}{"review_count": 2, "level": 4, "last_reviewed": past.Add(-48 * time.Hour), "next_review": past})
+
+	code, resp = apiJSON(t, router, token, "POST", "/api/digest/send", nil)
+	if code != http.StatusOK || len(m.sent) != 1 {
+		t.Fatalf("send failed: %d %v", code, resp)
+	}
+	msg := m.sent[0]
+	if msg.To != "a@example.com" || msg.Subject != "今日待复习 3 张卡片" {
+		t.Errorf("unexpected message to %s: %s", msg.To, msg.Subject)
+	}
+	if !strings.Contains(msg.Text, "未分类：3 张") || !strings.Contains(msg.Text, "- Q1（未分类，完全忘记）") || strings.Contains(msg.Text, "- Q2") {
+		t.Errorf("unexpected digest:\n%s", msg.Text)
+	}
+
+	// The next day's scheduled digest goes out too
+	sendDueDigests(now.Add(24 * time.Hour))
+	if len(m.sent) != 2 {
+		t.Fatalf("expected a scheduled digest, got %d emails", len(m.sent))
+	}
+
+	// Failures are logged and retried a few times
+	m.err = errors.New("connection refused")
+	for i := 0; i < maxDigestFailures+1; i++ {
+		sendDueDigests(now.Add(48 * time.Hour))
+	}
+	_, resp = apiJSON(t, router, token, "GET", "/api/digest/logs?status=failed&page_size=1", nil)
+	logs = resp.Data.(map[string]interface{})
+	if logs["total"].(float64) != maxDigestFailures || len(logs["logs"].([]interface{})) != 1 {
+		t.Errorf("expected %d failed digests, got %v", maxDigestFailures, logs)
+	}
+	if code, resp := apiJSON(t, router, token, "POST", "/api/digest/send", nil); code != http.StatusBadGateway || !strings.Contains(resp.Error, "connection refused") {
+		t.Errorf("expected 502 for a failed send, got %d %v", code, resp)
+	}
+	m.err = nil
+
+	// Unsubscribe without logging in
+	link, _ := url.Parse(msg.Unsubscribe)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("GET", "/api/digest/unsubscribe?token=wrong", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("expected 404 for a wrong token, got %d", w.Code)
+	}
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, httptest.NewRequest("POST", link.Path+"?"+link.RawQuery, nil))
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), "已退订") {
+		t.Fatalf("unsubscribe failed: %d %s", w.Code, w.Body.String())
+	}
+	_, resp = apiJSON(t, router, token, "GET", "/api/digest", nil)
+	if resp.Data.(map[string]interface{})["enabled"] != false {
+		t.Errorf("expected the digest off after unsubscribing")
+	}
+	sendDueDigests(now.Add(72 * time.Hour))
+	if len(m.sent) != 2 {
+		t.Errorf("expected no digest after unsubscribing, got %d emails", len(m.sent))
+	}
+}
@@ -0,0 +1,281 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"self-improvement/internal/gitrepo"
+	"self-improvement/internal/models"
+	"self-improvement/internal/parser"
+)
+
+// AddGitSourceRequest registers a local git repository as a question source
+type AddGitSourceRequest struct {
+	Path   string `json:"path" binding:"required"`
+	Branch string `json:"branch"` // Defaults to the checked out branch
+}
+
+// gitSourcePrefix is the Question.Source prefix of all files from a repository.
+// The full source is the prefix followed by the file's path in the repository.
+func gitSourcePrefix(sourceID uint) string {
+	return fmt.Sprintf("git:%d/", sourceID)
+}
+
+// gitPathAllowed restricts registered repositories to GIT_SOURCE_ROOT when it
+// is set, since the server reads them with its own permissions
+func gitPathAllowed(path string) bool {
+	root := os.Getenv("GIT_SOURCE_ROOT")
+	if root == "" {
+		return true
+	}
+	root, err := filepath.Abs(root)
+	if err != nil {
+		return false
+	}
+	rel, err := filepath.Rel(root, path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// syncGitSource imports the markdown files committed on the source's branch.
+// After the first sync only files changed since the last synced commit are
+// processed; if that commit is no longer in the branch history (e.g. after a
+// force push) the whole tree is compared against the questions instead.
+func syncGitSource(src *models.GitSource) (*syncResult, error) {
+	defer lockUserSync(src.UserID)()
+
+	start := time.Now()
+	result := &syncResult{At: start}
+	defer func() { result.DurationMs = time.Since(start).Milliseconds() }()
+
+	repo, err := gitrepo.Open(src.Path)
+	if err != nil {
+		return nil, err
+	}
+	head, err := repo.Head(src.Branch)
+	if err != nil {
+		return nil, err
+	}
+	if head == src.LastCommit {
+		return result, nil
+	}
+
+	prefix := gitSourcePrefix(src.ID)
+	var changed, removed []string
+	if src.LastCommit != "" && repo.IsAncestor(src.LastCommit, head) {
+		diff, err := repo.Diff(src.LastCommit, head)
+		if err != nil {
+			return nil, err
+		}
+		for _, ch := range diff {
+			if !isMarkdown(ch.Path) {
+				continue
+			}
+			if ch.Status == 'D' {
+				removed = append(removed, prefix+ch.Path)
+			} else {
+				changed = append(changed, ch.Path)
+			}
+		}
+	} else {
+		files, err := repo.ListFiles(head)
+		if err != nil {
+			return nil, err
+		}
+		listed := make(map[string]bool)
+		for _, f := range files {
+			if isMarkdown(f) {
+				changed = append(changed, f)
+				listed[prefix+f] = true
+			}
+		}
+
+		var sources []string
+		db.Model(&models.Question{}).
+			Where("user_id = ? AND source LIKE ?", src.UserID, prefix+"%").
+			Distinct().Pluck("source", &sources)
+		for _, s := range sources {
+			if !listed[s] {
+				removed = append(removed, s)
+			}
+		}
+	}
+
+	p, err := parser.NewQuestionParser(repo.Path)
+	if err != nil {
+		return nil, err
+	}
+	var changes []*sourceChange
+	for _, path := range changed {
+		content, err := repo.ReadFile(head, path)
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			continue
+		}
+		commit, err := repo.LastChange(head, path)
+		if err != nil || commit == "" {
+			commit = head
+		}
+		changes = append(changes, &sourceChange{
+			source:    prefix + path,
+			category:  extractCategory(path),
+			commit:    commit,
+			questions: p.ParseContent(string(content), prefix+path),
+		})
+	}
+
+	applySourceChanges(src.UserID, changes, removed, result)
+	result.FilesChanged = len(changes)
+	result.FilesRemoved = len(removed)
+
+	now := time.Now()
+	src.LastCommit = head
+	src.LastSyncedAt = &now
+	if err := db.Model(src).Updates(map[string]interface{}{"last_commit": head, "last_synced_at": now}).Error; err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+func isMarkdown(path string) bool {
+	return strings.HasSuffix(strings.ToLower(path), ".md")
+}
+
+// findGitSource loads the user's git source from the :id route parameter
+func findGitSource(c *gin.Context, userID uint) (*models.GitSource, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "无效的来源 ID"})
+		return nil, false
+	}
+	var src models.GitSource
+	if err := db.Where("id = ? AND user_id = ?", id, userID).First(&src).Error; err != nil {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "来源不存在"})
+		return nil, false
+	}
+	return &src, true
+}
+
+func listGitSourcesHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	sources := []models.GitSource{}
+	if err := db.Where("user_id = ?", userID).Order("id ASC").Find(&sources).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取来源失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: map[string]interface{}{"sources": sources}})
+}
+
+// addGitSourceHandler registers a repository and runs the first import
+func addGitSourceHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	var req AddGitSourceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "Invalid request format"})
+		return
+	}
+
+	repo, err := gitrepo.Open(req.Path)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "路径不是 git 仓库"})
+		return
+	}
+	if !gitPathAllowed(repo.Path) {
+		c.JSON(http.StatusForbidden, Response{Success: false, Error: "不允许使用该路径"})
+		return
+	}
+
+	branch := strings.TrimSpace(req.Branch)
+	if branch == "" {
+		if branch, err = repo.CurrentBranch(); err != nil {
+			c.JSON(http.StatusBadRequest, Response{Success: false, Error: "无法确定分支，请指定 branch"})
+			return
+		}
+	}
+	if _, err := repo.Head(branch); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "分支不存在：" + branch})
+		return
+	}
+
+	var count int64
+	db.Model(&models.GitSource{}).Where("user_id = ? AND path = ? AND branch = ?", userID, repo.Path, branch).Count(&count)
+	if count > 0 {
+		c.JSON(http.StatusConflict, Response{Success: false, Error: "该仓库分支已添加"})
+		return
+	}
+
+	src := models.GitSource{UserID: userID, Path: repo.Path, Branch: branch}
+	if err := db.Create(&src).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "保存来源失败"})
+		return
+	}
+
+	result, err := syncGitSource(&src)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "同步失败：" + err.Error()})
+		return
+	}
+	emitGitImport(userID, result)
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data:    map[string]interface{}{"source": src, "sync": result},
+		Message: fmt.Sprintf("成功导入 %d 个新问题到知识库！", result.Added),
+	})
+}
+
+func syncGitSourceHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	src, ok := findGitSource(c, userID)
+	if !ok {
+		return
+	}
+
+	result, err := syncGitSource(src)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "同步失败：" + err.Error()})
+		return
+	}
+	emitGitImport(userID, result)
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: map[string]interface{}{"source": src, "sync": result}})
+}
+
+// deleteGitSourceHandler unregisters a repository. Questions imported from it
+// stay in the knowledge base.
+func deleteGitSourceHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	src, ok := findGitSource(c, userID)
+	if !ok {
+		return
+	}
+	if err := db.Delete(src).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "删除来源失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "来源已删除"})
+}
+
+// emitGitImport sends import.finished for a sync that changed any files
+func emitGitImport(userID uint, result *syncResult) {
+	if result.FilesChanged+result.FilesRemoved == 0 {
+		return
+	}
+	emitImport(userID, importEvent{Source: "git", Imported: result.Added, Updated: result.Updated, Removed: result.Removed})
+}
@@ -0,0 +1,187 @@
+package server
+
+import (
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func gitRun(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+	cmd := exec.Command("git", append([]string{"-C", dir, "-c", "user.name=test", "-c", "user.email=test@example.com"}, args...)...)
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func gitCommit(t *testing.T, dir string, files map[string]string) string {
+	t.Helper()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if content == "" {
+			os.Remove(path)
+			continue
+		}
+		os.MkdirAll(filepath.Dir(path), 0755)
+		os.WriteFile(path, []byte(content), 0644)
+	}
+	gitRun(t, dir, "add", "-A")
+	gitRun(t, dir, "commit", "-q", "-m", "update")
+	return gitRun(t, dir, "rev-parse", "HEAD")
+}
+
+func TestE2E_GitSourceSync(t *testing.T) {
+	router := setupE2E(t)
+
+	wd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(wd)
+
+	repoDir := t.TempDir()
+	gitRun(t, repoDir, "init", "-q", "-b", "main")
+	first := gitCommit(t, repoDir, map[string]string{
+		"go/a.md":    "# q\nQ1\n# a\nA1\n\n# q\nQ2\n# a\nA2\n",
+		"README.txt": "not questions",
+	})
+
+	token := registerAndGetToken(t, router, "gituser")
+	user := userByName(t, "gituser")
+
+	code, resp := apiJSON(t, router, token, "POST", "/api/git-sources", map[string]string{"path": repoDir})
+	if code != http.StatusOK {
+		t.Fatalf("add source failed: %d: %v", code, resp)
+	}
+	data := resp.Data.(map[string]interface{})
+	source := data["source"].(map[string]interface{})
+	if source["branch"] != "main" || source["last_commit"] != first {
+		t.Errorf("unexpected source: %v", source)
+	}
+	if added := data["sync"].(map[string]interface{})["added"].(float64); added != 2 {
+		t.Errorf("expected 2 added, got %v", added)
+	}
+
+	questions := userQuestions(t, user.ID)
+	q1 := questions["Q1"]
+	if q1.SourceCommit != first || q1.Category != "go" || !strings.HasSuffix(q1.Source, "/go/a.md") {
+		t.Errorf("unexpected imported question: %+v", q1)
+	}
+	reviewQuestion(t, router, token, q1.ID, 1)
+
+	// Only files changed since the last sync are processed
+	second := gitCommit(t, repoDir, map[string]string{
+		"go/a.md": "# q\nQ1\n# a\nA1 改\n\n# q\nQ2\n# a\nA2\n",
+		"db/b.md": "# q\nQ3\n# a\nA3\n",
+	})
+	// Uncommitted edits are not imported
+	os.WriteFile(filepath.Join(repoDir, "db", "c.md"), []byte("# q\nQ4\n# a\nA4\n"), 0644)
+
+	id := int(source["id"].(float64))
+	syncURL := "/api/git-sources/" + strconv.Itoa(id) + "/sync"
+	code, resp = apiJSON(t, router, token, "POST", syncURL, nil)
+	if code != http.StatusOK {
+		t.Fatalf("sync failed: %d: %v", code, resp)
+	}
+	result := resp.Data.(map[string]interface{})["sync"].(map[string]interface{})
+	if result["files_changed"].(float64) != 2 || result["added"].(float64) != 1 || result["updated"].(float64) != 1 {
+		t.Errorf("unexpected sync result: %v", result)
+	}
+
+	questions = userQuestions(t, user.ID)
+	if q := questions["Q1"]; q.AnswerText != "A1 改" || q.SourceCommit != second || q.ReviewCount != 1 {
+		t.Errorf("Q1 not updated in place: %+v", q)
+	}
+	if q := questions["Q2"]; q.SourceCommit != first {
+		t.Errorf("unchanged Q2 commit moved: %q", q.SourceCommit)
+	}
+	if q := questions["Q3"]; q.SourceCommit != second || q.Category != "db" {
+		t.Errorf("unexpected Q3: %+v", q)
+	}
+	if _, ok := questions["Q4"]; ok {
+		t.Error("uncommitted file was imported")
+	}
+
+	// Due questions show the commit
+	for _, item := range getDue(t, router, token) {
+		q := item.(map[string]interface{})
+		if q["question"] == "Q3" && q["commit"] != second {
+			t.Errorf("due question missing commit: %v", q)
+		}
+	}
+
+	// A rewritten history falls back to comparing the whole tree
+	os.Remove(filepath.Join(repoDir, "db", "c.md"))
+	gitRun(t, repoDir, "reset", "-q", "--hard", first)
+	gitCommit(t, repoDir, map[string]string{"go/a.md": "# q\nQ1\n# a\nA1\n"})
+	code, resp = apiJSON(t, router, token, "POST", syncURL, nil)
+	if code != http.StatusOK {
+		t.Fatalf("sync after reset failed: %d: %v", code, resp)
+	}
+	questions = userQuestions(t, user.ID)
+	if len(questions) != 1 || questions["Q1"].ReviewCount != 1 {
+		t.Errorf("expected only Q1 with its progress left, got %v", questions)
+	}
+
+	code, resp = apiJSON(t, router, token, "POST", "/api/git-sources", map[string]string{"path": repoDir})
+	if code != http.StatusConflict {
+		t.Errorf("expected 409 for duplicate source, got %d", code)
+	}
+
+	code, _ = apiJSON(t, router, token, "DELETE", "/api/git-sources/"+strconv.Itoa(id), nil)
+	if code != http.StatusOK {
+		t.Errorf("delete failed: %d", code)
+	}
+	_, resp = apiJSON(t, router, token, "GET", "/api/git-sources", nil)
+	if list := resp.Data.(map[string]interface{})["sources"].([]interface{}); len(list) != 0 {
+		t.Errorf("source not deleted: %v", list)
+	}
+	if len(userQuestions(t, user.ID)) != 1 {
+		t.Error("deleting the source removed its questions")
+	}
+}
+
+func TestE2E_GitSourceValidation(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "gitinvalid")
+	other := registerAndGetToken(t, router, "gitother")
+
+	code, _ := apiJSON(t, router, token, "POST", "/api/git-sources", map[string]string{"path": t.TempDir()})
+	if code != http.StatusBadRequest {
+		t.Errorf("expected 400 for non-repository, got %d", code)
+	}
+
+	repoDir := t.TempDir()
+	gitRun(t, repoDir, "init", "-q", "-b", "main")
+	gitCommit(t, repoDir, map[string]string{"a.md": "# q\nQ\n# a\nA\n"})
+
+	code, _ = apiJSON(t, router, token, "POST", "/api/git-sources", map[string]string{"path": repoDir, "branch": "nope"})
+	if code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown branch, got %d", code)
+	}
+
+	os.Setenv("GIT_SOURCE_ROOT", t.TempDir())
+	code, _ = apiJSON(t, router, token, "POST", "/api/git-sources", map[string]string{"path": repoDir})
+	os.Unsetenv("GIT_SOURCE_ROOT")
+	if code != http.StatusForbidden {
+		t.Errorf("expected 403 outside GIT_SOURCE_ROOT, got %d", code)
+	}
+
+	code, resp := apiJSON(t, router, token, "POST", "/api/git-sources", map[string]string{"path": repoDir})
+	if code != http.StatusOK {
+		t.Fatalf("add source failed: %d: %v", code, resp)
+	}
+	id := int(resp.Data.(map[string]interface{})["source"].(map[string]interface{})["id"].(float64))
+
+	// Other users cannot sync or delete it
+	if code, _ = apiJSON(t, router, other, "POST", "/api/git-sources/"+strconv.Itoa(id)+"/sync", nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 for another user's source, got %d", code)
+	}
+	if code, _ = apiJSON(t, router, other, "DELETE", "/api/git-sources/"+strconv.Itoa(id), nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 for another user's source, got %d", code)
+	}
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestE2E_ProfileGoalsAndStreaks(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "streakuser")
+
+	_, resp := apiJSON(t, router, token, "GET", "/api/profile", nil)
+	profile := resp.Data.(map[string]interface{})
+	goal := profile["goal"].(map[string]interface{})
+	if goal["type"] != "cards" || goal["target"].(float64) != 20 || profile["current_streak"].(float64) != 0 {
+		t.Errorf("unexpected default profile: %v", profile)
+	}
+	if len(profile["achievements"].([]interface{})) == 0 {
+		t.Errorf("expected the list of achievements, got %v", profile)
+	}
+
+	for _, body := range []map[string]interface{}{
+		{"timezone": "Mars/Olympus"},
+		{"goal": map[string]interface{}{"type": "pages", "target": 5}},
+		{"goal": map[string]interface{}{"type": "cards", "target": 0}},
+	} {
+		if code, _ := apiJSON(t, router, token, "PUT", "/api/profile", body); code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %v, got %d", body, code)
+		}
+	}
+	code, resp := apiJSON(t, router, token, "PUT", "/api/profile", map[string]interface{}{
+		"timezone": "America/New_York",
+		"goal":     map[string]interface{}{"type": "minutes", "target": 1},
+	})
+	if code != http.StatusOK {
+		t.Fatalf("update failed: %d %v", code, resp)
+	}
+	profile = resp.Data.(map[string]interface{})
+	if profile["timezone"] != "America/New_York" || profile["goal"].(map[string]interface{})["type"] != "minutes" {
+		t.Errorf("settings not saved: %v", profile)
+	}
+
+	for _, q := range []string{"Q1", "Q2"} {
+		apiJSON(t, router, token, "POST", "/api/add-question", map[string]string{"question": q, "answer": "A"})
+	}
+	_, resp = apiJSON(t, router, token, "GET", "/api/questions?page_size=10", nil)
+	questions := resp.Data.(map[string]interface{})["questions"].([]interface{})
+	first := questions[0].(map[string]interface{})["id"].(string)
+	apiJSON(t, router, token, "POST", "/api/update-review", map[string]interface{}{
+		"question_id": first, "feedback": 1, "question_ms": 20000, "answer_ms": 10000,
+	})
+
+	_, resp = apiJSON(t, router, token, "GET", "/api/profile", nil)
+	profile = resp.Data.(map[string]interface{})
+	today := profile["today"].(map[string]interface{})
+	ny, _ := time.LoadLocation("America/New_York")
+	if today["date"] != time.Now().In(ny).Format("2006-01-02") || today["reviews"].(float64) != 1 || profile["goal_progress"].(float64) != 0.5 {
+		t.Errorf("unexpected progress: %v", profile)
+	}
+	if profile["current_streak"].(float64) != 0 {
+		t.Errorf("expected no streak before the goal or a cleared queue, got %v", profile)
+	}
+
+	// Clearing the rest of the queue counts the day
+	reviewQuestion(t, router, token, questions[1].(map[string]interface{})["id"].(string), 1)
+	_, resp = apiJSON(t, router, token, "GET", "/api/profile", nil)
+	profile = resp.Data.(map[string]interface{})
+	today = profile["today"].(map[string]interface{})
+	if today["cleared"] != true || today["goal_met"] != false || profile["current_streak"].(float64) != 1 {
+		t.Errorf("expected the cleared queue to start a streak, got %v", profile)
+	}
+
+	code, resp = apiJSON(t, router, token, "PUT", "/api/profile", map[string]interface{}{"timezone": ""})
+	if code != http.StatusOK || resp.Data.(map[string]interface{})["timezone"] != "" {
+		t.Errorf("expected the timezone to reset: %d %v", code, resp)
+	}
+}
@@ -0,0 +1,72 @@
+package server
+
+import (
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"self-improvement/internal/parser"
+)
+
+// scanStream answers long running imports either with a single JSON response
+// or, when the client asks for "Accept: text/event-stream", with Server-Sent
+// Events: "progress" events while files are scanned, then one "result" or
+// "error" event carrying the usual Response.
+type scanStream struct {
+	c       *gin.Context
+	enabled bool
+	started bool
+}
+
+func newScanStream(c *gin.Context) *scanStream {
+	return &scanStream{
+		c:       c,
+		enabled: strings.Contains(c.GetHeader("Accept"), "text/event-stream"),
+	}
+}
+
+func (s *scanStream) start() {
+	if s.started {
+		return
+	}
+	s.started = true
+	s.c.Header("Content-Type", "text/event-stream")
+	s.c.Header("Cache-Control", "no-cache")
+	s.c.Header("Connection", "keep-alive")
+	s.c.Header("X-Accel-Buffering", "no")
+}
+
+func (s *scanStream) send(event string, data interface{}) {
+	s.start()
+	s.c.SSEvent(event, data)
+	s.c.Writer.Flush()
+}
+
+// progress is passed as parser.ScanOptions.Progress; it is nil when the
+// client did not ask for a stream. File paths are reported relative to root
+// so temporary server directories are not exposed.
+func (s *scanStream) progress(root string) func(parser.ProgressEvent) {
+	if !s.enabled {
+		return nil
+	}
+	return func(ev parser.ProgressEvent) {
+		if rel, err := filepath.Rel(root, ev.File); err == nil && ev.File != "" {
+			ev.File = filepath.ToSlash(rel)
+		}
+		s.send("progress", ev)
+	}
+}
+
+// respond sends the final response
+func (s *scanStream) respond(status int, resp Response) {
+	if !s.enabled {
+		s.c.JSON(status, resp)
+		return
+	}
+	event := "result"
+	if !resp.Success {
+		event = "error"
+	}
+	s.send(event, resp)
+}
@@ -0,0 +1,123 @@
+package server
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type sseEvent struct {
+	name string
+	data string
+}
+
+func parseSSE(t *testing.T, body string) []sseEvent {
+	t.Helper()
+	var events []sseEvent
+	var current sseEvent
+	scanner := bufio.NewScanner(strings.NewReader(body))
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch {
+		case strings.HasPrefix(line, "event:"):
+			current.name = strings.TrimPrefix(line, "event:")
+		case strings.HasPrefix(line, "data:"):
+			current.data = strings.TrimPrefix(line, "data:")
+		case line == "" && current.name != "":
+			events = append(events, current)
+			current = sseEvent{}
+		}
+	}
+	return events
+}
+
+func TestE2E_UploadZip_ProgressStream(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "sseuser")
+
+	zipData := buildZip(t, map[string]string{
+		"kb/a.md": "# q\nA1\n# a\nA\n\n# q\nA2\n# a\nA",
+		"kb/b.md": "# q\nB1\n# a\nB",
+		"kb/c.md": "# q\nC1\n# a\nC",
+	})
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "kb.zip")
+	part.Write(zipData)
+	writer.Close()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/upload-zip", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+
+	if ct := w.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/event-stream") {
+		t.Fatalf("expected event stream, got %s: %s", ct, w.Body.String())
+	}
+
+	events := parseSSE(t, w.Body.String())
+	if len(events) != 5 {
+		t.Fatalf("expected 3 file events, 1 done event and 1 result, got %d: %s", len(events), w.Body.String())
+	}
+
+	files := make(map[string]bool)
+	for _, ev := range events[:4] {
+		if ev.name != "progress" {
+			t.Fatalf("expected progress event, got %s", ev.name)
+		}
+		var progress map[string]interface{}
+		json.Unmarshal([]byte(ev.data), &progress)
+		if progress["type"] == "file" {
+			files[progress["file"].(string)] = true
+		}
+	}
+	if !files["kb/a.md"] || !files["kb/b.md"] || !files["kb/c.md"] {
+		t.Errorf("expected relative file names, got %v", files)
+	}
+
+	var done map[string]interface{}
+	json.Unmarshal([]byte(events[3].data), &done)
+	if done["type"] != "done" || done["questions_found"].(float64) != 4 || done["files_total"].(float64) != 3 {
+		t.Errorf("unexpected done event: %v", done)
+	}
+
+	last := events[4]
+	var resp Response
+	json.Unmarshal([]byte(last.data), &resp)
+	if last.name != "result" || !resp.Success {
+		t.Fatalf("unexpected final event %s: %s", last.name, last.data)
+	}
+	if resp.Data.(map[string]interface{})["imported"].(float64) != 4 {
+		t.Errorf("expected 4 imported, got %v", resp.Data)
+	}
+}
+
+func TestE2E_UploadZip_ProgressStreamError(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "sseerror")
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, _ := writer.CreateFormFile("file", "kb.zip")
+	part.Write([]byte("not a zip"))
+	writer.Close()
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/api/upload-zip", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+
+	events := parseSSE(t, w.Body.String())
+	if len(events) != 1 || events[0].name != "error" {
+		t.Fatalf("expected a single error event, got %v", events)
+	}
+}
@@ -0,0 +1,213 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"self-improvement/internal/models"
+	"self-improvement/internal/spacedrepetition"
+)
+
+// Page size limits for GET /api/questions
+const (
+	defaultPageSize = 20
+	maxPageSize     = 100
+)
+
+// EditQuestionRequest changes a question. Omitted fields are left unchanged.
+type EditQuestionRequest struct {
+	Question      *string `json:"question"`
+	Answer        *string `json:"answer"`
+	Category      *string `json:"category"`
+	Source        *string `json:"source"`
+	Priority      *int    `json:"priority"`       // 1=low, 2=normal, 3=high
+	ResetSchedule bool    `json:"reset_schedule"` // Start over as a new question
+}
+
+func questionData(q *models.Question) map[string]interface{} {
+	return map[string]interface{}{
+		"id": q.ID, "question": q.QuestionText, "answer": q.AnswerText,
+		"source": q.Source, "category": q.Category, "tags": q.Tags, "commit": q.SourceCommit,
+		"deck_id": q.DeckID, "level": q.Level, "priority": q.Priority, "next_review": q.NextReview, "last_reviewed": q.LastReviewed,
+		"review_count": q.ReviewCount, "correct_count": q.CorrectCount,
+		"created_at": q.CreatedAt, "updated_at": q.UpdatedAt,
+	}
+}
+
+// listQuestionsHandler lists the user's questions.
+// Query: page, page_size, sort, order (asc|desc), category, level, due (true|false), source.
+func listQuestionsHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultPageSize)))
+	if pageSize < 1 || pageSize > maxPageSize {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "page_size 必须在 1 到 " + strconv.Itoa(maxPageSize) + " 之间"})
+		return
+	}
+
+	filter := spacedrepetition.QuestionFilter{
+		Category: c.Query("category"),
+		Source:   c.Query("source"),
+		Desc:     strings.EqualFold(c.Query("order"), "desc"),
+		Offset:   (page - 1) * pageSize,
+		Limit:    pageSize,
+	}
+	if sort := c.Query("sort"); sort != "" {
+		valid := false
+		for _, field := range spacedrepetition.SortFields {
+			valid = valid || sort == field
+		}
+		if !valid {
+			c.JSON(http.StatusBadRequest, Response{Success: false, Error: "不支持的排序字段：" + sort})
+			return
+		}
+		filter.Sort = sort
+	}
+	if v := c.Query("level"); v != "" {
+		level, err := strconv.Atoi(v)
+		if err != nil || level < 1 || level > 4 {
+			c.JSON(http.StatusBadRequest, Response{Success: false, Error: "level 必须是 1-4"})
+			return
+		}
+		filter.Level = level
+	}
+	if v := c.Query("due"); v != "" {
+		due, err := strconv.ParseBool(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, Response{Success: false, Error: "due 必须是 true 或 false"})
+			return
+		}
+		filter.Due = &due
+	}
+
+	questions, total, err := sr.ListQuestions(userID, filter)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取问题失败"})
+		return
+	}
+
+	list := make([]map[string]interface{}, 0, len(questions))
+	for _, q := range questions {
+		list = append(list, questionData(q))
+	}
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"questions": list,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+		},
+	})
+}
+
+func getQuestionHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	q, err := sr.GetQuestion(userID, c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "Question not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: questionData(q)})
+}
+
+// editQuestionHandler changes a question's content. Scheduling state is kept
+// unless reset_schedule is set.
+func editQuestionHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+	id := c.Param("id")
+
+	var req EditQuestionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "Invalid request format"})
+		return
+	}
+
+	if req.Priority != nil && (*req.Priority < spacedrepetition.PriorityLow || *req.Priority > spacedrepetition.PriorityHigh) {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "priority 必须是 1-3"})
+		return
+	}
+
+	edit := spacedrepetition.QuestionEdit{Source: req.Source, Priority: req.Priority, ResetSchedule: req.ResetSchedule}
+	for _, field := range []struct {
+		value *string
+		dst   **string
+		name  string
+	}{
+		{req.Question, &edit.Question, "问题"},
+		{req.Answer, &edit.Answer, "答案"},
+		{req.Category, &edit.Category, "分类"},
+	} {
+		if field.value == nil {
+			continue
+		}
+		v := strings.TrimSpace(*field.value)
+		if v == "" {
+			c.JSON(http.StatusBadRequest, Response{Success: false, Error: field.name + "不能为空"})
+			return
+		}
+		*field.dst = &v
+	}
+	if edit.Category != nil {
+		if msg := validateCategoryName(*edit.Category); msg != "" {
+			c.JSON(http.StatusBadRequest, Response{Success: false, Error: msg})
+			return
+		}
+	}
+
+	if edit.Question != nil {
+		if existing, _ := sr.FindQuestionByText(userID, *edit.Question); existing != nil && existing.ID != id {
+			c.JSON(http.StatusConflict, Response{Success: false, Error: "该问题已存在"})
+			return
+		}
+	}
+
+	q, err := sr.EditQuestion(userID, id, edit)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "Question not found"})
+		return
+	}
+	if errors.Is(err, spacedrepetition.ErrSharedCard) {
+		c.JSON(http.StatusForbidden, Response{Success: false, Error: "订阅的卡片内容由作者维护，只能修改分类和优先级"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "更新问题失败"})
+		return
+	}
+	// Edited text may no longer reference some assets
+	gcAssets(userID)
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "问题已更新", Data: questionData(q)})
+}
+
+func deleteQuestionByIDHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	if _, err := sr.GetQuestion(userID, c.Param("id")); err != nil {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "Question not found"})
+		return
+	}
+	if err := sr.DeleteQuestion(userID, c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "删除问题失败"})
+		return
+	}
+	gcAssets(userID)
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "问题已删除"})
+}
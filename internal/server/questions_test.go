@@ -0,0 +1,125 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func apiJSON(t *testing.T, router *gin.Engine, token, method, url string, body interface{}) (int, Response) {
+	t.Helper()
+	var data []byte
+	if body != nil {
+		data, _ = json.Marshal(body)
+	}
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, url, bytes.NewReader(data))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+	var resp Response
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return w.Code, resp
+}
+
+func TestE2E_QuestionsCRUD(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "cruduser")
+	other := registerAndGetToken(t, router, "crudother")
+
+	for _, q := range []map[string]string{
+		{"question": "Q1", "answer": "A1", "category": "go"},
+		{"question": "Q2", "answer": "A2", "category": "go"},
+		{"question": "Q3", "answer": "A3"},
+	} {
+		if code, resp := apiJSON(t, router, token, "POST", "/api/add-question", q); code != http.StatusOK {
+			t.Fatalf("add question failed: %d %v", code, resp)
+		}
+	}
+
+	code, resp := apiJSON(t, router, token, "GET", "/api/questions?category=go&page_size=1&sort=created_at", nil)
+	if code != http.StatusOK {
+		t.Fatalf("list failed: %d %v", code, resp)
+	}
+	data := resp.Data.(map[string]interface{})
+	list := data["questions"].([]interface{})
+	if data["total"].(float64) != 2 || len(list) != 1 {
+		t.Fatalf("unexpected page: %v", data)
+	}
+	first := list[0].(map[string]interface{})
+	if first["question"] != "Q1" || first["category"] != "go" {
+		t.Errorf("unexpected first question: %v", first)
+	}
+	id := first["id"].(string)
+
+	_, resp = apiJSON(t, router, token, "GET", "/api/questions?category=未分类", nil)
+	if n := resp.Data.(map[string]interface{})["total"].(float64); n != 1 {
+		t.Errorf("expected Q3 to default to 未分类, got %v", n)
+	}
+
+	reviewQuestion(t, router, token, id, 1)
+
+	code, resp = apiJSON(t, router, token, "PATCH", "/api/questions/"+id, map[string]string{
+		"answer": "A1 改", "category": "golang", "source": "notes/go.md",
+	})
+	if code != http.StatusOK {
+		t.Fatalf("edit failed: %d %v", code, resp)
+	}
+	edited := resp.Data.(map[string]interface{})
+	if edited["answer"] != "A1 改" || edited["category"] != "golang" || edited["source"] != "notes/go.md" {
+		t.Errorf("fields not updated: %v", edited)
+	}
+	if edited["review_count"].(float64) != 1 || edited["updated_at"] == first["updated_at"] {
+		t.Errorf("scheduling changed or updated_at not set: %v", edited)
+	}
+
+	code, resp = apiJSON(t, router, token, "GET", "/api/questions/"+id, nil)
+	if code != http.StatusOK || resp.Data.(map[string]interface{})["answer"] != "A1 改" {
+		t.Errorf("get failed: %d %v", code, resp)
+	}
+
+	// Due filter: Q1 was reviewed and is no longer due
+	_, resp = apiJSON(t, router, token, "GET", "/api/questions?due=false", nil)
+	if n := resp.Data.(map[string]interface{})["total"].(float64); n != 1 {
+		t.Errorf("expected 1 scheduled question, got %v", n)
+	}
+
+	code, resp = apiJSON(t, router, token, "PATCH", "/api/questions/"+id, map[string]interface{}{"reset_schedule": true})
+	if code != http.StatusOK || resp.Data.(map[string]interface{})["review_count"].(float64) != 0 {
+		t.Errorf("reset failed: %d %v", code, resp)
+	}
+
+	// Validation and isolation
+	if code, _ = apiJSON(t, router, token, "PATCH", "/api/questions/"+id, map[string]string{"question": "Q2"}); code != http.StatusConflict {
+		t.Errorf("expected 409 for duplicate text, got %d", code)
+	}
+	if code, _ = apiJSON(t, router, token, "PATCH", "/api/questions/"+id, map[string]string{"answer": "  "}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for empty answer, got %d", code)
+	}
+	for _, category := range []string{"go,rust", "存储//ceph", strings.Repeat("长", maxCategoryNameLength+1)} {
+		if code, _ = apiJSON(t, router, token, "PATCH", "/api/questions/"+id, map[string]string{"category": category}); code != http.StatusBadRequest {
+			t.Errorf("expected 400 for category %q, got %d", category, code)
+		}
+	}
+	if code, _ = apiJSON(t, router, token, "GET", "/api/questions?sort=password", nil); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for unknown sort field, got %d", code)
+	}
+	if code, _ = apiJSON(t, router, other, "GET", "/api/questions/"+id, nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 for another user's question, got %d", code)
+	}
+	if code, _ = apiJSON(t, router, other, "PATCH", "/api/questions/"+id, map[string]string{"answer": "x"}); code != http.StatusNotFound {
+		t.Errorf("expected 404 editing another user's question, got %d", code)
+	}
+
+	if code, _ = apiJSON(t, router, token, "DELETE", "/api/questions/"+id, nil); code != http.StatusOK {
+		t.Errorf("delete failed: %d", code)
+	}
+	if code, _ = apiJSON(t, router, token, "GET", "/api/questions/"+id, nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 after delete, got %d", code)
+	}
+}
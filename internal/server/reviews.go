@@ -0,0 +1,100 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"self-improvement/internal/spacedrepetition"
+)
+
+// maxSyncReviews caps the number of reviews in one sync request
+const maxSyncReviews = 500
+
+// SyncReviewsRequest uploads reviews made on a client, e.g. while offline.
+// Each review is validated on its own so one bad entry does not block the
+// rest of the batch.
+type SyncReviewsRequest struct {
+	Device  string       `json:"device"`
+	Reviews []SyncReview `json:"reviews" binding:"required"`
+}
+
+// SyncReview is one review made on a client
+type SyncReview struct {
+	Key        string    `json:"key"` // Idempotency key, unique per review
+	QuestionID string    `json:"question_id"`
+	Feedback   int       `json:"feedback"`
+	ReviewedAt time.Time `json:"reviewed_at"`
+}
+
+// syncReviewsHandler replays client reviews and returns the authoritative
+// state of every card they touched
+func syncReviewsHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	var req SyncReviewsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "Invalid request format"})
+		return
+	}
+	if len(req.Reviews) > maxSyncReviews {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "单次最多同步 " + strconv.Itoa(maxSyncReviews) + " 条复习记录"})
+		return
+	}
+
+	events := make([]spacedrepetition.ReviewEvent, len(req.Reviews))
+	for i, r := range req.Reviews {
+		events[i] = spacedrepetition.ReviewEvent{
+			Key:        r.Key,
+			QuestionID: r.QuestionID,
+			Feedback:   r.Feedback,
+			ReviewedAt: r.ReviewedAt,
+			Device:     req.Device,
+		}
+	}
+
+	notify, goalMet := reviewHooks(userID)
+	results, questions, err := sr.SyncReviews(userID, events)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "同步复习记录失败"})
+		return
+	}
+	if notify {
+		var reviews []reviewEvent
+		for i, r := range results {
+			if r.Status == spacedrepetition.SyncDuplicate || r.Status == spacedrepetition.SyncRejected {
+				continue
+			}
+			reviews = append(reviews, reviewEvent{
+				QuestionID: r.QuestionID,
+				Feedback:   events[i].Feedback,
+				Correct:    events[i].Feedback <= 2,
+				Source:     "sync",
+			})
+		}
+		emitReviews(userID, reviews, goalMet)
+	}
+
+	states := make([]map[string]interface{}, 0, len(questions))
+	for _, q := range questions {
+		states = append(states, questionData(q))
+	}
+	stats, err := sr.GetStats(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取统计失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"results":     results,
+			"questions":   states,
+			"server_time": time.Now(),
+			"stats":       stats,
+		},
+	})
+}
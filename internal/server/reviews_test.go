@@ -0,0 +1,89 @@
+package server
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"self-improvement/internal/models"
+)
+
+func TestE2E_SyncReviews(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "syncuser")
+	other := registerAndGetToken(t, router, "syncother")
+
+	apiJSON(t, router, token, "POST", "/api/add-question", map[string]string{"question": "Q1", "answer": "A1"})
+	apiJSON(t, router, other, "POST", "/api/add-question", map[string]string{"question": "Q2", "answer": "A2"})
+	id := userQuestions(t, userByName(t, "syncuser").ID)["Q1"].ID
+	// Offline reviews below were made after the card was added
+	db.Model(&models.Question{}).Where("id = ?", id).Update("created_at", time.Now().AddDate(0, 0, -1))
+	otherID := userQuestions(t, userByName(t, "syncother").ID)["Q2"].ID
+
+	offline := time.Now().Add(-3 * time.Hour).UTC().Format(time.RFC3339)
+	batch := map[string]interface{}{
+		"device": "phone",
+		"reviews": []map[string]interface{}{
+			{"key": "r1", "question_id": id, "feedback": 1, "reviewed_at": offline},
+			{"key": "r2", "question_id": otherID, "feedback": 1, "reviewed_at": offline},
+			{"key": "r3", "question_id": id, "feedback": 9, "reviewed_at": offline},
+		},
+	}
+	code, resp := apiJSON(t, router, token, "POST", "/api/reviews/sync", batch)
+	if code != http.StatusOK {
+		t.Fatalf("sync failed: %d %v", code, resp)
+	}
+	data := resp.Data.(map[string]interface{})
+	results := data["results"].([]interface{})
+	want := []string{"applied", "rejected", "rejected"}
+	for i, r := range results {
+		if s := r.(map[string]interface{})["status"]; s != want[i] {
+			t.Errorf("review %d: got %v, want %s", i, s, want[i])
+		}
+	}
+
+	states := data["questions"].([]interface{})
+	if len(states) != 1 {
+		t.Fatalf("expected 1 card state, got %v", states)
+	}
+	state := states[0].(map[string]interface{})
+	lastReviewed, _ := time.Parse(time.RFC3339, state["last_reviewed"].(string))
+	if state["review_count"].(float64) != 1 || lastReviewed.Format(time.RFC3339) != offline {
+		t.Errorf("review not applied at its original time: %v", state)
+	}
+
+	// Another user's card is untouched
+	if q := userQuestions(t, userByName(t, "syncother").ID)["Q2"]; q.ReviewCount != 0 {
+		t.Errorf("another user's card was reviewed: %+v", q)
+	}
+
+	// Retrying after a dropped connection does not count twice
+	_, resp = apiJSON(t, router, token, "POST", "/api/reviews/sync", batch)
+	first := resp.Data.(map[string]interface{})["results"].([]interface{})[0].(map[string]interface{})
+	if first["status"] != "duplicate" {
+		t.Errorf("expected duplicate on retry, got %v", first)
+	}
+	if q := userQuestions(t, userByName(t, "syncuser").ID)["Q1"]; q.ReviewCount != 1 {
+		t.Errorf("retry counted twice: %d reviews", q.ReviewCount)
+	}
+
+	// A review made on a second device before an online review is merged
+	reviewQuestion(t, router, token, id, 1)
+	_, resp = apiJSON(t, router, token, "POST", "/api/reviews/sync", map[string]interface{}{
+		"device": "tablet",
+		"reviews": []map[string]interface{}{
+			{"key": "t1", "question_id": id, "feedback": 4, "reviewed_at": time.Now().Add(-time.Hour).UTC().Format(time.RFC3339)},
+		},
+	})
+	result := resp.Data.(map[string]interface{})["results"].([]interface{})[0].(map[string]interface{})
+	if result["status"] != "merged" {
+		t.Errorf("expected merged, got %v", result)
+	}
+	if q := userQuestions(t, userByName(t, "syncuser").ID)["Q1"]; q.ReviewCount != 3 {
+		t.Errorf("expected 3 reviews after merge, got %d", q.ReviewCount)
+	}
+
+	if code, _ = apiJSON(t, router, token, "POST", "/api/reviews/sync", map[string]interface{}{}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 without reviews, got %d", code)
+	}
+}
@@ -0,0 +1,65 @@
+package server
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"self-improvement/internal/search"
+)
+
+var searchIndex *search.Index
+
+// searchHandler finds questions containing all words of q, best matches first.
+// Query: q, category, page, page_size.
+func searchHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	q := strings.TrimSpace(c.Query("q"))
+	if q == "" {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "请输入搜索关键词"})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultPageSize)))
+	if pageSize < 1 || pageSize > maxPageSize {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "page_size 必须在 1 到 " + strconv.Itoa(maxPageSize) + " 之间"})
+		return
+	}
+
+	results, total, err := searchIndex.Search(userID, q, search.Options{
+		Category: c.Query("category"),
+		Offset:   (page - 1) * pageSize,
+		Limit:    pageSize,
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "搜索失败"})
+		return
+	}
+
+	list := make([]map[string]interface{}, 0, len(results))
+	for _, r := range results {
+		item := questionData(r.Question)
+		item["score"] = r.Score
+		item["question_highlight"] = r.QuestionHighlight
+		item["answer_snippet"] = r.AnswerSnippet
+		list = append(list, item)
+	}
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"results":   list,
+			"total":     total,
+			"page":      page,
+			"page_size": pageSize,
+			"engine":    searchIndex.Engine(),
+		},
+	})
+}
@@ -0,0 +1,66 @@
+package server
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func searchIDs(t *testing.T, router *gin.Engine, token, q string) ([]string, map[string]interface{}) {
+	t.Helper()
+	code, resp := apiJSON(t, router, token, "GET", "/api/search?q="+url.QueryEscape(q), nil)
+	if code != http.StatusOK {
+		t.Fatalf("search failed: %d %v", code, resp)
+	}
+	data := resp.Data.(map[string]interface{})
+	var ids []string
+	for _, r := range data["results"].([]interface{}) {
+		ids = append(ids, r.(map[string]interface{})["question"].(string))
+	}
+	return ids, data
+}
+
+func TestE2E_Search(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "searchuser")
+	other := registerAndGetToken(t, router, "searchother")
+
+	apiJSON(t, router, token, "POST", "/api/add-question", map[string]string{"question": "什么是闭包？", "answer": "能访问外部变量的函数"})
+	apiJSON(t, router, other, "POST", "/api/add-question", map[string]string{"question": "闭包的用途", "answer": "其他用户"})
+	if w := uploadZip(t, router, token, buildZip(t, map[string]string{
+		"go/gmp.md": "# q\nGoroutine 如何调度？\n# a\nGMP 模型，注意闭包捕获循环变量\n",
+	})); w.Code != http.StatusOK {
+		t.Fatalf("upload failed: %d %s", w.Code, w.Body.String())
+	}
+
+	ids, data := searchIDs(t, router, token, "闭包")
+	if len(ids) != 2 || ids[0] != "什么是闭包？" {
+		t.Fatalf("unexpected results (%s): %v", data["engine"], ids)
+	}
+	first := data["results"].([]interface{})[0].(map[string]interface{})
+	if first["question_highlight"] != "什么是<mark>闭包</mark>？" || first["score"] == nil {
+		t.Errorf("unexpected highlight: %v", first)
+	}
+
+	// Edits and deletes through the API are searchable right away
+	_, resp := apiJSON(t, router, token, "GET", "/api/questions?category=未分类", nil)
+	id := resp.Data.(map[string]interface{})["questions"].([]interface{})[0].(map[string]interface{})["id"].(string)
+	apiJSON(t, router, token, "PATCH", "/api/questions/"+id, map[string]string{"question": "JavaScript 作用域链"})
+	if ids, _ := searchIDs(t, router, token, "作用域"); len(ids) != 1 {
+		t.Errorf("edited question not found: %v", ids)
+	}
+	if ids, _ := searchIDs(t, router, token, "闭包"); len(ids) != 1 || ids[0] != "Goroutine 如何调度？" {
+		t.Errorf("old text still matched: %v", ids)
+	}
+
+	apiJSON(t, router, token, "DELETE", "/api/questions/"+id, nil)
+	if ids, _ := searchIDs(t, router, token, "作用域"); len(ids) != 0 {
+		t.Errorf("deleted question still found: %v", ids)
+	}
+
+	if code, _ := apiJSON(t, router, token, "GET", "/api/search?q=", nil); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for empty query, got %d", code)
+	}
+}
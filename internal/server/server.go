@@ -1,26 +1,32 @@
 package server
 
 import (
-	"archive/zip"
-	"bytes"
 	"errors"
 	"fmt"
 	"io"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 
+	"self-improvement/internal/assets"
+	"self-improvement/internal/digest"
 	"self-improvement/internal/middleware"
 	"self-improvement/internal/models"
 	"self-improvement/internal/parser"
+	"self-improvement/internal/search"
 	"self-improvement/internal/spacedrepetition"
+	"self-improvement/internal/streaks"
+	"self-improvement/internal/ziparchive"
 )
 
 // Response represents a standard API response
@@ -35,6 +41,9 @@ type Response struct {
 type UpdateReviewRequest struct {
 	QuestionID string `json:"question_id" binding:"required"`
 	Feedback   int    `json:"feedback" binding:"required,min=1,max=4"`
+	SessionID  uint   `json:"session_id"`                  // Study session, optional
+	QuestionMs int    `json:"question_ms" binding:"min=0"` // Time until the answer was shown
+	AnswerMs   int    `json:"answer_ms" binding:"min=0"`   // Time from the answer to the feedback
 }
 
 // DeleteQuestionRequest represents the request body for deleting a question
@@ -46,6 +55,7 @@ type DeleteQuestionRequest struct {
 type AddQuestionRequest struct {
 	Question string `json:"question" binding:"required"`
 	Answer   string `json:"answer" binding:"required"`
+	Category string `json:"category"` // Defaults to 未分类
 }
 
 var db *gorm.DB
@@ -74,23 +84,101 @@ func SetupRouter() *gin.Engine {
 	{
 		public.POST("/register", registerHandler)
 		public.POST("/login", loginHandler)
+		public.GET("/digest/unsubscribe", unsubscribeDigestHandler)
+		public.POST("/digest/unsubscribe", unsubscribeDigestHandler)
+		public.GET("/calendar/:token", calendarFeedHandler)
 	}
 
+	// Images in card text cannot send the Authorization header, so assets
+	// also accept the login cookie
+	r.GET("/api/assets/:hash", middleware.AssetAuthMiddleware(), getAssetHandler)
+
 	protected := r.Group("/api")
 	protected.Use(middleware.AuthMiddleware())
 	{
 		protected.GET("/profile", profileHandler)
+		protected.PUT("/profile", updateProfileHandler)
 		protected.GET("/stats", getStatsHandler)
 		protected.GET("/categories", getCategoriesHandler)
+		protected.GET("/categories/report", categoryReportHandler)
+		protected.POST("/categories", createCategoryHandler)
+		protected.PATCH("/categories/:id", updateCategoryHandler)
+		protected.DELETE("/categories/:id", deleteCategoryHandler)
+		protected.POST("/categories/:id/merge", mergeCategoryHandler)
+		protected.POST("/categories/:id/questions", moveQuestionsHandler)
 		protected.GET("/due-questions", getDueQuestionsHandler)
 		protected.POST("/update-review", updateReviewHandler)
+		protected.POST("/reviews/sync", syncReviewsHandler)
 		protected.POST("/delete-question", deleteQuestionHandler)
+		protected.GET("/questions", listQuestionsHandler)
+		protected.GET("/search", searchHandler)
+		protected.GET("/questions/:id", getQuestionHandler)
+		protected.PATCH("/questions/:id", editQuestionHandler)
+		protected.DELETE("/questions/:id", deleteQuestionByIDHandler)
 		protected.POST("/init", initDatabaseHandler)
 		protected.POST("/upload-zip", uploadZipHandler)
 		protected.POST("/upload-md", uploadMdHandler)
+		protected.POST("/import/anki", importAnkiHandler)
+		protected.POST("/import/csv", importCSVHandler)
+		protected.GET("/export/csv", exportCSVHandler)
+		protected.GET("/export", exportAccountHandler)
+		protected.POST("/import-backup", importBackupHandler)
+		protected.POST("/convert/preview", convertPreviewHandler)
+		protected.POST("/convert/import", convertImportHandler)
+		protected.GET("/watch", getWatchHandler)
+		protected.PUT("/watch", setWatchHandler)
+		protected.GET("/git-sources", listGitSourcesHandler)
+		protected.POST("/git-sources", addGitSourceHandler)
+		protected.POST("/git-sources/:id/sync", syncGitSourceHandler)
+		protected.DELETE("/git-sources/:id", deleteGitSourceHandler)
+		protected.GET("/decks", listDecksHandler)
+		protected.POST("/decks", publishDeckHandler)
+		protected.PATCH("/decks/:id", updateDeckHandler)
+		protected.DELETE("/decks/:id", unpublishDeckHandler)
+		protected.GET("/decks/shared/:code", getSharedDeckHandler)
+		protected.POST("/decks/shared/:code/subscribe", subscribeHandler)
+		protected.GET("/catalog", listCatalogHandler)
+		protected.GET("/catalog/:id", getCatalogDeckHandler)
+		protected.POST("/catalog/:id/subscribe", subscribeCatalogHandler)
+		protected.GET("/subscriptions", listSubscriptionsHandler)
+		protected.DELETE("/subscriptions/:id", unsubscribeHandler)
+		protected.GET("/teams", listTeamsHandler)
+		protected.POST("/teams", createTeamHandler)
+		protected.GET("/teams/:id", getTeamHandler)
+		protected.DELETE("/teams/:id", deleteTeamHandler)
+		protected.POST("/teams/:id/members", addTeamMemberHandler)
+		protected.POST("/teams/:id/accept", acceptTeamInvitationHandler)
+		protected.POST("/teams/:id/decline", declineTeamInvitationHandler)
+		protected.PATCH("/teams/:id/members/:user_id", updateTeamMemberHandler)
+		protected.DELETE("/teams/:id/members/:user_id", removeTeamMemberHandler)
+		protected.POST("/teams/:id/assignments", assignDeckHandler)
+		protected.DELETE("/teams/:id/assignments/:assignment_id", unassignDeckHandler)
+		protected.GET("/teams/:id/progress", teamProgressHandler)
+		protected.GET("/teams/:id/summary", teamSummaryHandler)
 		protected.POST("/add-question", addQuestionHandler)
 		protected.POST("/reset-demo", resetDemoHandler)
 		protected.GET("/forecast", getForecastHandler)
+		protected.GET("/analytics", getAnalyticsHandler)
+		protected.GET("/sessions", listSessionsHandler)
+		protected.POST("/sessions", startSessionHandler)
+		protected.GET("/sessions/:id", getSessionHandler)
+		protected.POST("/sessions/:id/finish", finishSessionHandler)
+		protected.GET("/webhooks", listWebhooksHandler)
+		protected.POST("/webhooks", createWebhookHandler)
+		protected.PATCH("/webhooks/:id", updateWebhookHandler)
+		protected.DELETE("/webhooks/:id", deleteWebhookHandler)
+		protected.POST("/webhooks/:id/test", testWebhookHandler)
+		protected.GET("/webhooks/:id/deliveries", listDeliveriesHandler)
+		protected.GET("/digest", getDigestHandler)
+		protected.PUT("/digest", updateDigestHandler)
+		protected.POST("/digest/send", sendDigestHandler)
+		protected.GET("/digest/logs", listDigestLogsHandler)
+		protected.GET("/calendar", getCalendarHandler)
+		protected.POST("/calendar/rotate", rotateCalendarHandler)
+		protected.GET("/exams", listExamsHandler)
+		protected.POST("/exams", createExamHandler)
+		protected.PATCH("/exams/:id", updateExamHandler)
+		protected.DELETE("/exams/:id", deleteExamHandler)
 	}
 
 	staticDir := os.Getenv("STATIC_DIR")
@@ -122,7 +210,18 @@ func Run() {
 		panic("failed to connect database")
 	}
 
-	err = db.AutoMigrate(&models.User{}, &models.Question{})
+	// Databases from before category management store only the top-level
+	// directory as category
+	expandCategories := !db.Migrator().HasTable(&models.Category{})
+	// Streaks of databases from before streak tracking are rebuilt from the
+	// review history
+	backfillActivity := !db.Migrator().HasTable(&models.DailyActivity{})
+
+	err = db.AutoMigrate(&models.User{}, &models.Question{}, &models.Asset{}, &models.ReviewLog{},
+		&models.UserSettings{}, &models.SourceFile{}, &models.GitSource{}, &models.Category{},
+		&models.Deck{}, &models.DeckSubscription{}, &models.Team{}, &models.TeamMember{},
+		&models.TeamAssignment{}, &models.StudySession{}, &models.DailyActivity{}, &models.Webhook{},
+		&models.WebhookDelivery{}, &models.DigestLog{}, &models.Exam{}, &models.SchemaMigration{})
 	if err != nil {
 		panic("failed to migrate database")
 	}
@@ -130,21 +229,37 @@ func Run() {
 	// Composite index for due-questions query: WHERE user_id + ORDER BY next_review
 	db.Exec("CREATE INDEX IF NOT EXISTS idx_questions_user_next_review ON questions(user_id, next_review)")
 
-	db.Model(&models.Question{}).Where("category = '' OR category IS NULL").Update("category", "未分类")
-	var questions []models.Question
-	db.Where("source LIKE ? AND category = ?", "questions/%", "未分类").Find(&questions)
-	for _, q := range questions {
-		cat := extractCategory(q.Source)
-		if cat != "未分类" {
-			db.Model(&q).Update("category", cat)
+	db.Model(&models.Question{}).Where("category = '' OR category IS NULL").Update("category", spacedrepetition.DefaultCategory)
+	migrateOnce("derive_categories", func() error { return deriveCategories(expandCategories) })
+
+	sr = spacedrepetition.NewSpacedRepetition(db)
+	if backfillActivity {
+		if err := sr.BackfillActivity(); err != nil {
+			log.Printf("Failed to backfill daily activity: %v", err)
 		}
 	}
+	assetStore = assets.NewStore(assetDir())
+	searchIndex, err = search.New(db)
+	if err != nil {
+		panic("failed to create search index")
+	}
 
-	sr = spacedrepetition.NewSpacedRepetition(db)
+	hooks = newHooks(db)
+	if err := hooks.Resume(); err != nil {
+		log.Printf("Failed to resume webhook deliveries: %v", err)
+	}
 
 	// 自动创建 demo 体验账户
 	seedDemoUser(db, sr)
 
+	startWatcher(watchInterval())
+
+	digestConfig = digest.ConfigFromEnv()
+	if digestConfig.Host != "" {
+		mailer = &digest.SMTPMailer{Config: digestConfig}
+	}
+	startDigests(digestInterval())
+
 	r := SetupRouter()
 
 	port := os.Getenv("PORT")
@@ -155,10 +270,67 @@ func Run() {
 	r.Run("0.0.0.0:" + port)
 }
 
+// migrateOnce runs a data migration unless it has already been applied, so
+// data the user changed afterwards is left alone
+func migrateOnce(name string, migrate func() error) {
+	var count int64
+	db.Model(&models.SchemaMigration{}).Where("name = ?", name).Count(&count)
+	if count > 0 {
+		return
+	}
+	if err := migrate(); err != nil {
+		log.Printf("Migration %s failed: %v", name, err)
+		return
+	}
+	db.Create(&models.SchemaMigration{Name: name, AppliedAt: time.Now()})
+}
+
+// deriveCategories files questions from the question directories under the
+// category derived from their path when their category is still a derived
+// one: uncategorized, derived with the user directory as root or, for
+// databases from before category management, the top-level directory only.
+func deriveCategories(expand bool) error {
+	var users []models.User
+	if err := db.Select("id", "username").Find(&users).Error; err != nil {
+		return err
+	}
+	usernames := make(map[uint]string)
+	for _, u := range users {
+		usernames[u.ID] = u.Username
+	}
+
+	var questions []models.Question
+	if err := db.Where("source LIKE ?", "questions/%").Find(&questions).Error; err != nil {
+		return err
+	}
+	for _, q := range questions {
+		username := usernames[q.UserID]
+		cat := extractUserCategory(username, q.Source)
+		if cat == q.Category {
+			continue
+		}
+		stale := q.Category == spacedrepetition.DefaultCategory || q.Category == extractCategory(q.Source)
+		if expand {
+			stale = stale || q.Category == username || strings.HasPrefix(cat, q.Category+spacedrepetition.CategorySeparator)
+		}
+		if stale {
+			if err := db.Model(&q).Update("category", cat).Error; err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // InitTestDB initializes an in-memory database for testing.
 func InitTestDB(database *gorm.DB) {
 	db = database
 	sr = spacedrepetition.NewSpacedRepetition(db)
+	assetStore = assets.NewStore(assetDir())
+	searchIndex, _ = search.New(db)
+	hooks = newHooks(db)
+	digestConfig = digest.Config{From: digest.DefaultFrom, BaseURL: digest.DefaultBaseURL}
+	mailer = nil
 }
 
 func registerHandler(c *gin.Context) {
@@ -196,6 +368,7 @@ func registerHandler(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "Failed to generate token"})
 		return
 	}
+	middleware.SetAssetCookie(c, token)
 
 	c.JSON(http.StatusOK, Response{
 		Success: true,
@@ -236,6 +409,7 @@ func loginHandler(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "Failed to generate token"})
 		return
 	}
+	middleware.SetAssetCookie(c, token)
 
 	c.JSON(http.StatusOK, Response{
 		Success: true,
@@ -244,44 +418,95 @@ func loginHandler(c *gin.Context) {
 	})
 }
 
+// profileHandler returns the user with their daily goal, streaks and
+// achievements
 func profileHandler(c *gin.Context) {
 	userId, _ := c.Get("user_id")
 	username, _ := c.Get("username")
 
+	status, err := sr.GetStreakStatus(userId.(uint))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取个人资料失败"})
+		return
+	}
+
 	c.JSON(http.StatusOK, Response{
 		Success: true,
-		Data:    map[string]interface{}{"user_id": userId, "username": username},
+		Data: map[string]interface{}{
+			"user_id":        userId,
+			"username":       username,
+			"timezone":       status.Timezone,
+			"goal":           status.Goal,
+			"today":          status.Today,
+			"goal_progress":  status.GoalProgress,
+			"current_streak": status.CurrentStreak,
+			"longest_streak": status.LongestStreak,
+			"achievements":   status.Achievements,
+		},
 	})
 }
 
-func getStatsHandler(c *gin.Context) {
+// UpdateProfileRequest changes the user's timezone or daily goal
+type UpdateProfileRequest struct {
+	Timezone *string       `json:"timezone"` // IANA name, empty for the server's timezone
+	Goal     *streaks.Goal `json:"goal"`
+}
+
+// updateProfileHandler sets the timezone days are counted in and the daily
+// goal. Days already recorded keep their date and whether their goal was met.
+func updateProfileHandler(c *gin.Context) {
 	userId, _ := c.Get("user_id")
 	userID := userId.(uint)
 
-	stats, err := sr.GetStats(userID)
+	var req UpdateProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "Invalid request format"})
+		return
+	}
+	var settings models.UserSettings
+	db.Where("user_id = ?", userID).Limit(1).Find(&settings)
+	settings.UserID = userID
+	if req.Timezone != nil {
+		tz := strings.TrimSpace(*req.Timezone)
+		if tz != "" {
+			if _, err := time.LoadLocation(tz); err != nil || tz == "Local" {
+				c.JSON(http.StatusBadRequest, Response{Success: false, Error: "无效的时区，请使用 IANA 时区名，如 Asia/Shanghai"})
+				return
+			}
+		}
+		settings.Timezone = tz
+	}
+	if req.Goal != nil {
+		if !req.Goal.Valid() {
+			c.JSON(http.StatusBadRequest, Response{Success: false, Error: "goal.type 必须是 cards 或 minutes，goal.target 必须在 1 到 " + strconv.Itoa(streaks.MaxGoalTarget) + " 之间"})
+			return
+		}
+		settings.GoalType, settings.GoalTarget = req.Goal.Type, req.Goal.Target
+	}
+
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"timezone", "goal_type", "goal_target", "updated_at"}),
+	}).Create(&settings).Error
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "Failed to get stats"})
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "保存设置失败"})
 		return
 	}
 
-	c.JSON(http.StatusOK, Response{Success: true, Data: map[string]interface{}{"stats": stats}})
+	profileHandler(c)
 }
 
-func getCategoriesHandler(c *gin.Context) {
+func getStatsHandler(c *gin.Context) {
 	userId, _ := c.Get("user_id")
 	userID := userId.(uint)
 
-	categories, err := sr.GetCategories(userID)
+	stats, err := sr.GetStats(userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "Failed to get categories"})
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "Failed to get stats"})
 		return
 	}
 
-	for _, cat := range categories {
-		cat["label"] = categoryLabel(cat["name"].(string))
-	}
-
-	c.JSON(http.StatusOK, Response{Success: true, Data: map[string]interface{}{"categories": categories}})
+	c.JSON(http.StatusOK, Response{Success: true, Data: map[string]interface{}{"stats": stats}})
 }
 
 func getDueQuestionsHandler(c *gin.Context) {
@@ -293,6 +518,10 @@ func getDueQuestionsHandler(c *gin.Context) {
 		category = c.Query("categories")
 	}
 
+	// Pick up cards added to or removed from subscribed decks. A failure
+	// only delays that, the existing cards can still be reviewed.
+	sr.RefreshSubscriptions(userID)
+
 	var dueQuestions []*models.Question
 	var err error
 	if category != "" {
@@ -342,7 +571,8 @@ func getDueQuestionsHandler(c *gin.Context) {
 		questionsData = append(questionsData, map[string]interface{}{
 			"id": q.ID, "question": q.QuestionText, "answer": q.AnswerText,
 			"review_count": q.ReviewCount, "correct_count": q.CorrectCount,
-			"source": q.Source, "category": q.Category,
+			"source": q.Source, "category": q.Category, "commit": q.SourceCommit,
+			"deck_id": q.DeckID,
 		})
 	}
 
@@ -362,10 +592,33 @@ func updateReviewHandler(c *gin.Context) {
 	userId, _ := c.Get("user_id")
 	userID := userId.(uint)
 
-	if err := sr.UpdateReview(userID, req.QuestionID, req.Feedback); err != nil {
+	notify, goalMet := reviewHooks(userID)
+	err := sr.UpdateTimedReview(userID, req.QuestionID, req.Feedback, spacedrepetition.ReviewTiming{
+		SessionID:  req.SessionID,
+		QuestionMs: req.QuestionMs,
+		AnswerMs:   req.AnswerMs,
+	})
+	if errors.Is(err, spacedrepetition.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "学习会话不存在"})
+		return
+	}
+	if errors.Is(err, spacedrepetition.ErrSessionFinished) {
+		c.JSON(http.StatusConflict, Response{Success: false, Error: "学习会话已结束"})
+		return
+	}
+	if err != nil {
 		c.JSON(http.StatusNotFound, Response{Success: false, Error: "Question not found or update failed"})
 		return
 	}
+	if notify {
+		emitReviews(userID, []reviewEvent{{
+			QuestionID: req.QuestionID,
+			Feedback:   req.Feedback,
+			Correct:    req.Feedback <= 2,
+			Source:     "app",
+			SessionID:  req.SessionID,
+		}}, goalMet)
+	}
 
 	stats, err := sr.GetStats(userID)
 	if err != nil {
@@ -390,6 +643,7 @@ func deleteQuestionHandler(c *gin.Context) {
 		c.JSON(http.StatusNotFound, Response{Success: false, Error: "Question not found"})
 		return
 	}
+	gcAssets(userID)
 
 	stats, err := sr.GetStats(userID)
 	if err != nil {
@@ -400,33 +654,43 @@ func deleteQuestionHandler(c *gin.Context) {
 	c.JSON(http.StatusOK, Response{Success: true, Data: map[string]interface{}{"stats": stats}})
 }
 
+// extractCategory derives a category path from the directories of a source
+// file, e.g. "questions/06_career/07_八股文/cpp/a.md" gives
+// "06_career/07_八股文/cpp". Files directly below questions/ keep their file
+// name as category.
 func extractCategory(source string) string {
 	if source == "" || source == "手动输入" {
-		return "未分类"
+		return spacedrepetition.DefaultCategory
+	}
+	var parts []string
+	for _, p := range strings.Split(filepath.ToSlash(source), "/") {
+		if p != "" && p != "." {
+			parts = append(parts, p)
+		}
 	}
-	source = filepath.ToSlash(source)
-	parts := strings.Split(source, "/")
 	for i, p := range parts {
 		if p == "questions" && i+1 < len(parts) {
-			return parts[i+1]
+			if i+2 == len(parts) {
+				return parts[i+1]
+			}
+			return strings.Join(parts[i+1:len(parts)-1], spacedrepetition.CategorySeparator)
 		}
 	}
 	if len(parts) >= 2 {
-		return parts[0]
+		return strings.Join(parts[:len(parts)-1], spacedrepetition.CategorySeparator)
 	}
-	return "未分类"
+	return spacedrepetition.DefaultCategory
 }
 
-var categoryLabelMap = map[string]string{
-	"00_summaries": "总结",
-	"01_storage":   "存储",
-	"01_调试命令集合.md": "调试命令集",
-	"03_languages": "编程语言",
-	"05_problems":  "问题案例",
-	"06_career":    "职场修炼",
-	"08_tools":     "工具",
-	"09_ai":        "AI",
-	"10_billing":   "计费",
+// extractUserCategory is extractCategory for a source in the user's question
+// directory, which is not part of the category: "questions/alice/go/a.md"
+// gives "go".
+func extractUserCategory(username, source string) string {
+	dir := filepath.ToSlash(userQuestionsDir(username)) + "/"
+	if username != "" && strings.HasPrefix(filepath.ToSlash(source), dir) {
+		source = "questions/" + strings.TrimPrefix(filepath.ToSlash(source), dir)
+	}
+	return extractCategory(source)
 }
 
 func splitCategories(raw string) []string {
@@ -441,13 +705,6 @@ func splitCategories(raw string) []string {
 	return result
 }
 
-func categoryLabel(name string) string {
-	if label, ok := categoryLabelMap[name]; ok {
-		return label
-	}
-	return name
-}
-
 func importQuestions(userID uint, questions []*parser.Question) (imported, skipped, duplicates int, err error) {
 	seenQuestions := make(map[string]bool)
 	var uniqueQuestions []*parser.Question
@@ -464,59 +721,77 @@ func importQuestions(userID uint, questions []*parser.Question) (imported, skipp
 	for _, q := range uniqueQuestions {
 		qID := fmt.Sprintf("q_%d_%s", userID, spacedrepetition.Hash(q.QuestionText))
 
-		var existingQuestion models.Question
-		err := db.Where("user_id = ? AND question_text = ?", userID, q.QuestionText).First(&existingQuestion).Error
-		if err == nil {
+		if existing, _ := sr.FindQuestionByText(userID, q.QuestionText); existing != nil {
 			skipped++
 			continue
 		}
 
-		if err := sr.AddQuestion(userID, qID, q.QuestionText, q.AnswerText, q.SourceFile, extractCategory(q.SourceFile)); err != nil {
+		category := q.Category
+		if category == "" {
+			category = extractCategory(q.SourceFile)
+		}
+		if err := sr.AddQuestion(userID, qID, q.QuestionText, q.AnswerText, q.SourceFile, category); err != nil {
 			continue
 		}
+		if len(q.Tags) > 0 {
+			db.Model(&models.Question{}).Where("user_id = ? AND id = ?", userID, qID).Update("tags", strings.Join(q.Tags, ","))
+		}
 		imported++
 	}
 
 	return imported, skipped, duplicates, nil
 }
 
+// userQuestionsDir returns the user's own markdown directory. Each user has a
+// separate directory so scanning never picks up other users' content.
+func userQuestionsDir(username string) string {
+	return filepath.Join("questions", username)
+}
+
 func initDatabaseHandler(c *gin.Context) {
 	userId, _ := c.Get("user_id")
 	userID := userId.(uint)
 	username, _ := c.Get("username")
+	stream := newScanStream(c)
 
-	// 每个用户有自己独立的题目目录，避免扫描到其他用户的内容
-	userDir := filepath.Join("questions", username.(string))
+	userDir := userQuestionsDir(username.(string))
 	p, err := parser.NewQuestionParser(userDir)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		stream.respond(http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
 		return
 	}
 
-	questions, err := p.ParseAllFiles()
+	// 客户端断开连接时停止扫描
+	questions, err := p.ParseAllFilesContext(c.Request.Context(), parser.ScanOptions{Progress: stream.progress(userDir)})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
+		stream.respond(http.StatusInternalServerError, Response{Success: false, Error: err.Error()})
 		return
 	}
 
 	if len(questions) == 0 {
-		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "没有找到任何问题！请先将 .md 文件放入 questions/" + username.(string) + "/ 目录，或使用上传功能添加题目。"})
+		stream.respond(http.StatusBadRequest, Response{Success: false, Error: "没有找到任何问题！请先将 .md 文件放入 questions/" + username.(string) + "/ 目录，或使用上传功能添加题目。"})
 		return
 	}
 
+	for _, q := range questions {
+		if q.Category == "" {
+			q.Category = extractUserCategory(username.(string), q.SourceFile)
+		}
+	}
 	imported, skipped, duplicates, err := importQuestions(userID, questions)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "导入问题失败"})
+		stream.respond(http.StatusInternalServerError, Response{Success: false, Error: "导入问题失败"})
 		return
 	}
+	emitImport(userID, importEvent{Source: "init", Imported: imported, Skipped: skipped, Duplicates: duplicates})
 
 	stats, err := sr.GetStats(userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "Failed to get updated stats"})
+		stream.respond(http.StatusInternalServerError, Response{Success: false, Error: "Failed to get updated stats"})
 		return
 	}
 
-	c.JSON(http.StatusOK, Response{
+	stream.respond(http.StatusOK, Response{
 		Success: true,
 		Data: map[string]interface{}{
 			"message":    "成功导入 " + strconv.Itoa(imported) + " 个新问题到知识库！",
@@ -531,102 +806,103 @@ func initDatabaseHandler(c *gin.Context) {
 func uploadZipHandler(c *gin.Context) {
 	userId, _ := c.Get("user_id")
 	userID := userId.(uint)
+	stream := newScanStream(c)
+
+	limits := zipLimits()
+	// Leave room for the multipart envelope around the archive
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, limits.MaxSize+1<<20)
 
 	file, err := c.FormFile("file")
 	if err != nil {
-		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "请上传文件"})
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			stream.respond(http.StatusRequestEntityTooLarge, Response{Success: false, Error: fmt.Sprintf("文件过大，最大 %d MB", limits.MaxSize>>20)})
+			return
+		}
+		stream.respond(http.StatusBadRequest, Response{Success: false, Error: "请上传文件"})
 		return
 	}
 
 	if filepath.Ext(file.Filename) != ".zip" {
-		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "只支持 .zip 格式的压缩文件"})
+		stream.respond(http.StatusBadRequest, Response{Success: false, Error: "只支持 .zip 格式的压缩文件"})
 		return
 	}
 
 	src, err := file.Open()
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "无法打开上传的文件"})
+		stream.respond(http.StatusInternalServerError, Response{Success: false, Error: "无法打开上传的文件"})
 		return
 	}
 	defer src.Close()
 
-	zipBytes, err := io.ReadAll(src)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "读取文件失败"})
+	// 直接从压缩包中读取，不解压到磁盘
+	archive, err := ziparchive.Open(src, file.Size, limits)
+	switch {
+	case errors.Is(err, ziparchive.ErrTooLarge):
+		stream.respond(http.StatusRequestEntityTooLarge, Response{Success: false, Error: fmt.Sprintf("文件过大：压缩包最大 %d MB，解压后最大 %d MB", limits.MaxSize>>20, limits.MaxUncompressed>>20)})
 		return
-	}
-
-	zipReader, err := zip.NewReader(bytes.NewReader(zipBytes), file.Size)
-	if err != nil {
-		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "无法解析 zip 文件，请确保文件未损坏"})
+	case errors.Is(err, ziparchive.ErrTooManyEntries):
+		stream.respond(http.StatusRequestEntityTooLarge, Response{Success: false, Error: fmt.Sprintf("压缩包中的文件过多，最多 %d 个", limits.MaxEntries)})
 		return
-	}
-
-	tempDir, err := os.MkdirTemp("", "spaced-repetition-zip-*")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "服务器内部错误"})
+	case err != nil:
+		stream.respond(http.StatusBadRequest, Response{Success: false, Error: "无法解析 zip 文件，请确保文件未损坏"})
 		return
 	}
-	defer os.RemoveAll(tempDir)
-
-	for _, f := range zipReader.File {
-		if f.FileInfo().IsDir() {
-			continue
-		}
-		dstPath := filepath.Join(tempDir, f.Name)
-		if err := os.MkdirAll(filepath.Dir(dstPath), 0755); err != nil {
-			continue
-		}
-		dst, err := os.Create(dstPath)
-		if err != nil {
-			continue
-		}
-		rc, err := f.Open()
-		if err != nil {
-			dst.Close()
-			continue
-		}
-		io.Copy(dst, rc)
-		rc.Close()
-		dst.Close()
-	}
 
-	p, err := parser.NewQuestionParser(tempDir)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "处理文件失败"})
-		return
-	}
-
-	questions, err := p.ParseAllFiles()
+	// 被拒绝或读取失败的文件逐个返回，而不是静默跳过
+	entryErrors := append([]ziparchive.EntryError{}, archive.Errors...)
+	progress := stream.progress(".")
+	p := &parser.QuestionParser{}
+	questions, err := p.ParseFilesContext(c.Request.Context(), archive.Match(isMarkdownEntry), archive.ReadFile, parser.ScanOptions{
+		Progress: func(ev parser.ProgressEvent) {
+			if ev.Type == parser.ProgressError {
+				entryErrors = append(entryErrors, ziparchive.EntryError{Name: ev.File, Error: ev.Error})
+			}
+			if progress != nil {
+				progress(ev)
+			}
+		},
+	})
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "解析文件失败"})
+		stream.respond(http.StatusInternalServerError, Response{Success: false, Error: "解析文件失败"})
 		return
 	}
 
 	if len(questions) == 0 {
-		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "zip 文件中没有找到有效的问题！请确保包含格式正确的 .md 文件。"})
+		stream.respond(http.StatusBadRequest, Response{
+			Success: false,
+			Error:   "zip 文件中没有找到有效的问题！请确保包含格式正确的 .md 文件。",
+			Data:    map[string]interface{}{"errors": entryErrors},
+		})
 		return
 	}
 
+	// 图片等附件存入用户的资源库，并改写答案中的链接
+	unlock := lockAssets(userID)
+	storeQuestionAssets(userID, questions, archiveAssetSource(archive))
 	imported, skipped, duplicates, err := importQuestions(userID, questions)
+	unlock()
+	gcAssets(userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "导入问题失败"})
+		stream.respond(http.StatusInternalServerError, Response{Success: false, Error: "导入问题失败"})
 		return
 	}
+	emitImport(userID, importEvent{Source: "zip", Imported: imported, Skipped: skipped, Duplicates: duplicates})
 
 	stats, err := sr.GetStats(userID)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取统计失败"})
+		stream.respond(http.StatusInternalServerError, Response{Success: false, Error: "获取统计失败"})
 		return
 	}
 
-	c.JSON(http.StatusOK, Response{
+	stream.respond(http.StatusOK, Response{
 		Success: true,
 		Data: map[string]interface{}{
 			"message":    "成功导入 " + strconv.Itoa(imported) + " 个新问题到知识库！",
 			"imported":   imported,
 			"skipped":    skipped,
 			"duplicates": duplicates,
+			"errors":     entryErrors,
 			"stats":      stats,
 		},
 	})
@@ -678,6 +954,7 @@ func uploadMdHandler(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "导入问题失败"})
 		return
 	}
+	emitImport(userID, importEvent{Source: "markdown", Imported: imported, Skipped: skipped, Duplicates: duplicates})
 
 	stats, err := sr.GetStats(userID)
 	if err != nil {
@@ -715,14 +992,18 @@ func addQuestionHandler(c *gin.Context) {
 		return
 	}
 
-	var existingQuestion models.Question
-	if err := db.Where("user_id = ? AND question_text = ?", userID, questionText).First(&existingQuestion).Error; err == nil {
+	if existing, _ := sr.FindQuestionByText(userID, questionText); existing != nil {
 		c.JSON(http.StatusConflict, Response{Success: false, Error: "该问题已存在"})
 		return
 	}
 
+	category := strings.TrimSpace(req.Category)
+	if category == "" {
+		category = spacedrepetition.DefaultCategory
+	}
+
 	qID := fmt.Sprintf("q_%d_%s", userID, spacedrepetition.Hash(questionText))
-	if err := sr.AddQuestion(userID, qID, questionText, answerText, "手动输入", "未分类"); err != nil {
+	if err := sr.AddQuestion(userID, qID, questionText, answerText, "手动输入", category); err != nil {
 		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "添加问题失败"})
 		return
 	}
@@ -760,7 +1041,7 @@ func getForecastHandler(c *gin.Context) {
 
 	daysStr := c.DefaultQuery("days", "7")
 	days, err := strconv.Atoi(daysStr)
-	if err != nil || days < 1 || days > 30 {
+	if err != nil || days < 1 || days > spacedrepetition.MaxForecastDays {
 		days = 7
 	}
 
@@ -770,7 +1051,13 @@ func getForecastHandler(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, Response{Success: true, Data: map[string]interface{}{"forecast": forecast}})
+	cardMs, timed := sr.CardTime(userID)
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: map[string]interface{}{
+		"forecast":      forecast,
+		"ms_per_card":   cardMs,
+		"timed_reviews": timed,
+	}})
 }
 
 // seedDemoUser creates a demo account with sample questions so first-time
@@ -2,12 +2,14 @@ package server
 
 import (
 	"bytes"
+	"crypto/rand"
 	"encoding/json"
 	"mime/multipart"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/gin-gonic/gin"
@@ -15,18 +17,24 @@ import (
 	"gorm.io/gorm"
 
 	"self-improvement/internal/models"
+	"self-improvement/internal/spacedrepetition"
 )
 
 func setupE2E(t *testing.T) *gin.Engine {
 	t.Helper()
 
 	os.Setenv("JWT_SECRET", "e2e-test-secret")
+	os.Setenv("ASSET_DIR", t.TempDir())
 
 	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
 	if err != nil {
 		t.Fatalf("failed to open test db: %v", err)
 	}
-	if err := db.AutoMigrate(&models.User{}, &models.Question{}); err != nil {
+	if err := db.AutoMigrate(&models.User{}, &models.Question{}, &models.Asset{}, &models.ReviewLog{},
+		&models.UserSettings{}, &models.SourceFile{}, &models.GitSource{}, &models.Category{},
+		&models.Deck{}, &models.DeckSubscription{}, &models.Team{}, &models.TeamMember{},
+		&models.TeamAssignment{}, &models.StudySession{}, &models.DailyActivity{}, &models.Webhook{},
+		&models.WebhookDelivery{}, &models.DigestLog{}, &models.Exam{}, &models.SchemaMigration{}); err != nil {
 		t.Fatalf("failed to migrate: %v", err)
 	}
 
@@ -445,6 +453,76 @@ func TestE2E_UploadZip_BadExtension(t *testing.T) {
 	}
 }
 
+func TestE2E_UploadZip_UnsafeEntries(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "zipslipuser")
+
+	wd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(wd)
+
+	w := uploadZip(t, router, token, buildZip(t, map[string]string{
+		"kb/a.md":             "# q\nQ1\n# a\nA1",
+		"../../escaped.md":    "# q\nQ2\n# a\nA2",
+		"kb/../../escaped.md": "# q\nQ3\n# a\nA3",
+	}))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d: %s", w.Code, w.Body.String())
+	}
+
+	var resp Response
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	data := resp.Data.(map[string]interface{})
+	if data["imported"].(float64) != 1 {
+		t.Errorf("expected only the safe entry imported, got %v", data["imported"])
+	}
+	errs := data["errors"].([]interface{})
+	if len(errs) != 2 {
+		t.Fatalf("expected 2 entry errors, got %v", errs)
+	}
+	for _, e := range errs {
+		if e.(map[string]interface{})["error"] != "unsafe path" {
+			t.Errorf("unexpected entry error: %v", e)
+		}
+	}
+	if _, err := os.Stat(filepath.Join("..", "escaped.md")); err == nil {
+		t.Error("entry was written outside the archive root")
+	}
+}
+
+func TestE2E_UploadZip_Limits(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "ziplimituser")
+
+	os.Setenv("ZIP_MAX_ENTRIES", "2")
+	defer os.Unsetenv("ZIP_MAX_ENTRIES")
+	w := uploadZip(t, router, token, buildZip(t, map[string]string{
+		"a.md": "# q\nA\n# a\nA", "b.md": "# q\nB\n# a\nB", "c.md": "# q\nC\n# a\nC",
+	}))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for too many entries, got %d: %s", w.Code, w.Body.String())
+	}
+
+	// A small archive that decompresses beyond the limit
+	os.Setenv("ZIP_MAX_UNCOMPRESSED_MB", "1")
+	defer os.Unsetenv("ZIP_MAX_UNCOMPRESSED_MB")
+	w = uploadZip(t, router, token, buildZip(t, map[string]string{
+		"a.md": "# q\nA\n# a\n" + strings.Repeat("0", 2<<20),
+	}))
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for zip bomb, got %d: %s", w.Code, w.Body.String())
+	}
+
+	os.Setenv("ZIP_MAX_SIZE_MB", "1")
+	defer os.Unsetenv("ZIP_MAX_SIZE_MB")
+	random := make([]byte, 3<<20)
+	rand.Read(random)
+	w = uploadZip(t, router, token, random)
+	if w.Code != http.StatusRequestEntityTooLarge {
+		t.Errorf("expected 413 for oversized upload, got %d", w.Code)
+	}
+}
+
 // ═══════════════════════════════════════════
 // Due Questions Tests
 // ═══════════════════════════════════════════
@@ -805,12 +883,15 @@ func TestExtractCategory(t *testing.T) {
 		source   string
 		expected string
 	}{
-		{"questions/01_storage/ceph/foo.md", "01_storage"},
-		{"questions/03_languages/go/basics.md", "03_languages"},
+		{"questions/01_storage/ceph/foo.md", "01_storage/ceph"},
+		{"questions/03_languages/go/basics.md", "03_languages/go"},
+		{"questions/06_career/07_八股文/cpp/a.md", "06_career/07_八股文/cpp"},
+		{"questions/02_network.md", "02_network.md"},
 		{"手动输入", "未分类"},
 		{"", "未分类"},
 		{"single-file.md", "未分类"},
-		{"my_dir/sub/file.md", "my_dir"},
+		{"my_dir/sub/file.md", "my_dir/sub"},
+		{"./my_dir/file.md", "my_dir"},
 	}
 
 	for _, tt := range tests {
@@ -821,6 +902,69 @@ func TestExtractCategory(t *testing.T) {
 	}
 }
 
+func TestExtractUserCategory(t *testing.T) {
+	tests := []struct {
+		source   string
+		expected string
+	}{
+		{"questions/alice/01_storage/ceph/foo.md", "01_storage/ceph"},
+		{"questions/alice/02_network.md", "02_network.md"},
+		{"questions/bob/go/a.md", "bob/go"},
+		{"kb/net/tcp.md", "kb/net"},
+	}
+
+	for _, tt := range tests {
+		result := extractUserCategory("alice", tt.source)
+		if result != tt.expected {
+			t.Errorf("extractUserCategory(%q) = %q, want %q", tt.source, result, tt.expected)
+		}
+	}
+}
+
+func TestDeriveCategories(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "derive")
+	for _, q := range []string{"Q1", "Q2", "Q3", "Q4"} {
+		addQuestion(t, router, token, q, "A")
+	}
+	user := userByName(t, "derive")
+	for text, update := range map[string]map[string]interface{}{
+		"Q1": {"source": "questions/derive/go/a.md", "category": spacedrepetition.DefaultCategory},
+		"Q2": {"source": "questions/derive/go/b.md", "category": "derive/go"},
+		"Q3": {"source": "questions/derive/go/c.md", "category": "精选"},
+		"Q4": {"source": "questions/derive/net/tcp/d.md", "category": "derive"},
+	} {
+		db.Model(&models.Question{}).Where("user_id = ? AND question_text = ?", user.ID, text).Updates(update)
+	}
+
+	ran := 0
+	for i := 0; i < 2; i++ {
+		migrateOnce("test_derive", func() error {
+			ran++
+			return deriveCategories(false)
+		})
+	}
+	if ran != 1 {
+		t.Errorf("expected the migration to run once, ran %d times", ran)
+	}
+
+	want := map[string]string{"Q1": "go", "Q2": "go", "Q3": "精选", "Q4": "derive"}
+	for text, q := range userQuestions(t, user.ID) {
+		if q.Category != want[text] {
+			t.Errorf("%s: category %q, want %q", text, q.Category, want[text])
+		}
+	}
+
+	// Databases from before category management only stored the top-level
+	// directory, the user directory for per-user question directories
+	if err := deriveCategories(true); err != nil {
+		t.Fatal(err)
+	}
+	if q := userQuestions(t, user.ID)["Q4"]; q.Category != "net/tcp" {
+		t.Errorf("expected the top-level category expanded, got %q", q.Category)
+	}
+}
+
 func TestCategoryLabel(t *testing.T) {
 	if label := categoryLabel("01_storage"); label != "存储" {
 		t.Errorf("expected '存储', got '%s'", label)
@@ -828,4 +972,10 @@ func TestCategoryLabel(t *testing.T) {
 	if label := categoryLabel("nonexistent"); label != "nonexistent" {
 		t.Errorf("expected 'nonexistent', got '%s'", label)
 	}
+	if label := categoryLabel("99_old/01_storage"); label != "存储" {
+		t.Errorf("expected '存储' for a subcategory, got '%s'", label)
+	}
+	if label := categoryLabel("06_career/cpp"); label != "cpp" {
+		t.Errorf("expected 'cpp', got '%s'", label)
+	}
 }
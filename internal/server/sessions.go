@@ -0,0 +1,130 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"self-improvement/internal/models"
+	"self-improvement/internal/spacedrepetition"
+	"self-improvement/internal/webhooks"
+)
+
+// StartSessionRequest opens a study session
+type StartSessionRequest struct {
+	Device string `json:"device"`
+}
+
+func sessionID(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "无效的会话 ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// startSessionHandler opens a study session. Reviews sent to update-review
+// with its session_id are counted in its summary.
+func startSessionHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	var req StartSessionRequest
+	if c.Request.ContentLength > 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, Response{Success: false, Error: "Invalid request format"})
+			return
+		}
+	}
+	device := []rune(strings.TrimSpace(req.Device))
+	if len(device) > 64 {
+		device = device[:64]
+	}
+
+	s := &models.StudySession{UserID: userID, Device: string(device)}
+	if err := sr.StartSession(s); err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "开始学习会话失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: s})
+}
+
+// finishSessionHandler closes a study session and returns its summary
+func finishSessionHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	id, ok := sessionID(c)
+	if !ok {
+		return
+	}
+	summary, err := sr.FinishSession(userID, id)
+	if errors.Is(err, spacedrepetition.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "学习会话不存在"})
+		return
+	}
+	if errors.Is(err, spacedrepetition.ErrSessionFinished) {
+		c.JSON(http.StatusConflict, Response{Success: false, Error: "学习会话已结束"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "结束学习会话失败"})
+		return
+	}
+	hooks.Emit(userID, webhooks.EventSessionFinished, summary)
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "学习会话已结束", Data: summary})
+}
+
+func getSessionHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	id, ok := sessionID(c)
+	if !ok {
+		return
+	}
+	summary, err := sr.GetSession(userID, id)
+	if errors.Is(err, spacedrepetition.ErrSessionNotFound) {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "学习会话不存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取学习会话失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: summary})
+}
+
+// listSessionsHandler lists study sessions with their summaries, newest
+// first. Query: page, page_size.
+func listSessionsHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultPageSize)))
+	if pageSize < 1 || pageSize > maxPageSize {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "page_size 必须在 1 到 " + strconv.Itoa(maxPageSize) + " 之间"})
+		return
+	}
+
+	sessions, total, err := sr.ListSessions(userID, (page-1)*pageSize, pageSize)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取学习会话失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: map[string]interface{}{
+		"sessions": sessions, "total": total, "page": page, "page_size": pageSize,
+	}})
+}
@@ -0,0 +1,78 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestE2E_StudySessions(t *testing.T) {
+	router := setupE2E(t)
+	token := registerAndGetToken(t, router, "sessionuser")
+	other := registerAndGetToken(t, router, "sessionother")
+
+	for _, q := range []string{"Q1", "Q2"} {
+		apiJSON(t, router, token, "POST", "/api/add-question", map[string]string{"question": q, "answer": "A"})
+	}
+	_, resp := apiJSON(t, router, token, "GET", "/api/questions?page_size=10", nil)
+	var ids []string
+	for _, item := range resp.Data.(map[string]interface{})["questions"].([]interface{}) {
+		ids = append(ids, item.(map[string]interface{})["id"].(string))
+	}
+
+	code, resp := apiJSON(t, router, token, "POST", "/api/sessions", map[string]string{"device": "web"})
+	if code != http.StatusOK {
+		t.Fatalf("start failed: %d %v", code, resp)
+	}
+	id := resp.Data.(map[string]interface{})["id"].(float64)
+	base := fmt.Sprintf("/api/sessions/%v", id)
+
+	for i, qid := range ids {
+		code, resp := apiJSON(t, router, token, "POST", "/api/update-review", map[string]interface{}{
+			"question_id": qid, "feedback": i + 1, "session_id": id, "question_ms": 6000, "answer_ms": 4000,
+		})
+		if code != http.StatusOK {
+			t.Fatalf("review failed: %d %v", code, resp)
+		}
+	}
+	if code, _ := apiJSON(t, router, token, "POST", "/api/update-review", map[string]interface{}{
+		"question_id": ids[0], "feedback": 1, "question_ms": -1,
+	}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a negative timing, got %d", code)
+	}
+	if code, _ := apiJSON(t, router, other, "GET", base, nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 for another user's session, got %d", code)
+	}
+
+	code, resp = apiJSON(t, router, token, "POST", base+"/finish", nil)
+	if code != http.StatusOK {
+		t.Fatalf("finish failed: %d %v", code, resp)
+	}
+	summary := resp.Data.(map[string]interface{})
+	if summary["reviews"].(float64) != 2 || summary["cards"].(float64) != 2 || summary["study_ms"].(float64) != 20000 ||
+		summary["accuracy"].(float64) != 1 || summary["new_cards"].(float64) != 2 || summary["review_cards"].(float64) != 0 {
+		t.Errorf("unexpected summary: %v", summary)
+	}
+	if code, _ := apiJSON(t, router, token, "POST", base+"/finish", nil); code != http.StatusConflict {
+		t.Errorf("expected 409 for finishing twice, got %d", code)
+	}
+	if code, _ := apiJSON(t, router, token, "POST", "/api/update-review", map[string]interface{}{
+		"question_id": ids[0], "feedback": 1, "session_id": id,
+	}); code != http.StatusConflict {
+		t.Errorf("expected 409 for a review in a finished session, got %d", code)
+	}
+
+	_, resp = apiJSON(t, router, token, "GET", "/api/sessions", nil)
+	if list := resp.Data.(map[string]interface{})["sessions"].([]interface{}); len(list) != 1 {
+		t.Errorf("expected one session, got %v", list)
+	}
+
+	_, resp = apiJSON(t, router, token, "GET", "/api/forecast?days=3", nil)
+	forecast := resp.Data.(map[string]interface{})
+	if forecast["ms_per_card"].(float64) != 10000 || forecast["timed_reviews"].(float64) != 2 {
+		t.Errorf("expected the time per card from timed reviews, got %v", forecast)
+	}
+	if _, ok := forecast["forecast"].([]interface{})[0].(map[string]interface{})["estimated_minutes"]; !ok {
+		t.Errorf("expected estimated minutes in the forecast, got %v", forecast)
+	}
+}
@@ -0,0 +1,143 @@
+package server
+
+import (
+	"sync"
+	"time"
+
+	"self-improvement/internal/models"
+	"self-improvement/internal/parser"
+)
+
+// syncResult is the outcome of one sync of a question source
+type syncResult struct {
+	At           time.Time `json:"at"`
+	DurationMs   int64     `json:"duration_ms"`
+	FilesChanged int       `json:"files_changed"`
+	FilesRemoved int       `json:"files_removed"`
+	Added        int       `json:"added"`
+	Updated      int       `json:"updated"`
+	Removed      int       `json:"removed"`
+	Errors       []string  `json:"errors,omitempty"`
+}
+
+// sourceChange is the new content of one added or changed source file
+type sourceChange struct {
+	source    string // Question.Source of the file's questions
+	category  string // Empty to derive it from source
+	commit    string // Git commit the content was read from, empty otherwise
+	questions []*parser.Question
+}
+
+// syncLocks prevents concurrent syncs (poller, watch toggle, git sync) from
+// applying changes to the same user's questions at the same time
+var syncLocks sync.Map
+
+func lockUserSync(userID uint) func() {
+	m, _ := syncLocks.LoadOrStore(userID, &sync.Mutex{})
+	mu := m.(*sync.Mutex)
+	mu.Lock()
+	return mu.Unlock
+}
+
+func (ch *sourceChange) categoryName() string {
+	if ch.category != "" {
+		return ch.category
+	}
+	return extractCategory(ch.source)
+}
+
+// applySourceChanges brings the user's questions in line with the changed and
+// removed source files. Questions keep their scheduling state as long as their
+// text stays the same, even when moved between files. Callers must hold the
+// user's sync lock.
+func applySourceChanges(userID uint, changes []*sourceChange, removed []string, result *syncResult) {
+	if len(changes) == 0 && len(removed) == 0 {
+		return
+	}
+
+	// Questions currently attributed to the affected files
+	affected := append([]string(nil), removed...)
+	for _, ch := range changes {
+		affected = append(affected, ch.source)
+	}
+	var existing []models.Question
+	if err := db.Where("user_id = ? AND source IN ?", userID, affected).Find(&existing).Error; err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return
+	}
+	existingByKey := make(map[string]*models.Question)
+	for i := range existing {
+		existingByKey[existing[i].Source+"\x00"+existing[i].QuestionText] = &existing[i]
+	}
+
+	// Questions no longer in their file may have moved to another one
+	kept := make(map[string]bool)
+	for _, ch := range changes {
+		for _, q := range ch.questions {
+			kept[ch.source+"\x00"+q.QuestionText] = true
+		}
+	}
+	orphans := make(map[string]*models.Question)
+	for key, q := range existingByKey {
+		if !kept[key] {
+			orphans[q.QuestionText] = q
+		}
+	}
+
+	var toImport []*parser.Question
+	for _, ch := range changes {
+		for _, q := range ch.questions {
+			if e, ok := existingByKey[ch.source+"\x00"+q.QuestionText]; ok {
+				if e.AnswerText != q.AnswerText {
+					db.Model(e).Updates(map[string]interface{}{
+						"answer_text":   q.AnswerText,
+						"source_commit": ch.commit,
+					})
+					result.Updated++
+				}
+				continue
+			}
+			if e, ok := orphans[q.QuestionText]; ok {
+				db.Model(e).Updates(map[string]interface{}{
+					"source":        ch.source,
+					"category":      ch.categoryName(),
+					"answer_text":   q.AnswerText,
+					"source_commit": ch.commit,
+				})
+				delete(orphans, q.QuestionText)
+				result.Updated++
+				continue
+			}
+			q.SourceFile = ch.source
+			if q.Category == "" {
+				q.Category = ch.category
+			}
+			toImport = append(toImport, q)
+		}
+	}
+
+	for _, q := range orphans {
+		if err := sr.DeleteQuestion(userID, q.ID); err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			continue
+		}
+		result.Removed++
+	}
+
+	if len(toImport) > 0 {
+		imported, _, _, err := importQuestions(userID, toImport)
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+		}
+		result.Added = imported
+
+		// Newly imported questions are the only ones without a commit yet
+		for _, ch := range changes {
+			if ch.commit != "" {
+				db.Model(&models.Question{}).
+					Where("user_id = ? AND source = ? AND source_commit = ''", userID, ch.source).
+					Update("source_commit", ch.commit)
+			}
+		}
+	}
+}
@@ -0,0 +1,661 @@
+package server
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"self-improvement/internal/models"
+	"self-improvement/internal/spacedrepetition"
+)
+
+// minSummaryLearners is the smallest number of learners for which the team
+// summary shows team totals. With fewer, a member could subtract their own
+// progress and get another member's.
+const minSummaryLearners = 3
+
+// CreateTeamRequest creates a team owned by the user
+type CreateTeamRequest struct {
+	Name string `json:"name" binding:"required"`
+}
+
+// AddTeamMemberRequest invites a user to a team by username
+type AddTeamMemberRequest struct {
+	Username string `json:"username" binding:"required"`
+	Role     string `json:"role"` // admin or member, default member
+}
+
+// UpdateTeamMemberRequest changes a member's role
+type UpdateTeamMemberRequest struct {
+	Role string `json:"role" binding:"required"`
+}
+
+// AssignDeckRequest assigns a deck to a team, given by ID (own or public
+// decks) or share code
+type AssignDeckRequest struct {
+	DeckID    uint       `json:"deck_id"`
+	ShareCode string     `json:"share_code"`
+	Deadline  *time.Time `json:"deadline"`
+}
+
+func canManageTeam(role string) bool {
+	return role == models.TeamRoleOwner || role == models.TeamRoleAdmin
+}
+
+func teamIDParam(c *gin.Context) (uint, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "无效的团队 ID"})
+		return 0, false
+	}
+	return uint(id), true
+}
+
+// findTeam returns a team the user is a member of with the user's membership
+func findTeam(c *gin.Context, userID uint) (*models.Team, *models.TeamMember, bool) {
+	id, ok := teamIDParam(c)
+	if !ok {
+		return nil, nil, false
+	}
+	m, err := sr.GetTeamMember(id, userID)
+	if err == nil && !m.Active() {
+		err = gorm.ErrRecordNotFound
+	}
+	var t models.Team
+	if err == nil {
+		err = db.First(&t, id).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "团队不存在"})
+		return nil, nil, false
+	}
+	return &t, m, true
+}
+
+// findManagedTeam is findTeam for owners and admins
+func findManagedTeam(c *gin.Context, userID uint) (*models.Team, *models.TeamMember, bool) {
+	t, m, ok := findTeam(c, userID)
+	if ok && !canManageTeam(m.Role) {
+		c.JSON(http.StatusForbidden, Response{Success: false, Error: "只有团队所有者和管理员可以执行此操作"})
+		return nil, nil, false
+	}
+	return t, m, ok
+}
+
+func teamUsernames(members []*models.TeamMember) map[uint]string {
+	ids := make([]uint, len(members))
+	for i, m := range members {
+		ids[i] = m.UserID
+	}
+	var users []models.User
+	db.Select("id", "username").Where("id IN ?", ids).Find(&users)
+	names := make(map[uint]string, len(users))
+	for _, u := range users {
+		names[u.ID] = u.Username
+	}
+	return names
+}
+
+// subscribeAssigned subscribes a team member to an assigned deck. Members who
+// wrote the deck or already subscribed to it are left alone.
+func subscribeAssigned(userID uint, d *models.Deck) error {
+	_, _, err := sr.Subscribe(userID, d, deckCategory(d))
+	if errors.Is(err, spacedrepetition.ErrOwnDeck) || errors.Is(err, spacedrepetition.ErrAlreadySubscribed) {
+		return nil
+	}
+	return err
+}
+
+func assignmentData(a *models.TeamAssignment, d *models.Deck) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         a.ID,
+		"deck_id":    d.ID,
+		"title":      d.Title,
+		"author":     deckAuthor(d.UserID),
+		"cards":      sr.DeckCards(d),
+		"deadline":   a.Deadline,
+		"created_at": a.CreatedAt,
+	}
+}
+
+func overdue(a *models.TeamAssignment, p *spacedrepetition.DeckProgress, now time.Time) bool {
+	return a.Deadline != nil && now.After(*a.Deadline) && !p.Completed()
+}
+
+func listTeamsHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	teams, memberships, err := sr.ListTeams(userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取团队失败"})
+		return
+	}
+	list := make([]map[string]interface{}, len(teams))
+	for i, t := range teams {
+		var members int64
+		db.Model(&models.TeamMember{}).Where("team_id = ? AND status = ?", t.ID, models.TeamMemberActive).Count(&members)
+		list[i] = map[string]interface{}{
+			"id":         t.ID,
+			"name":       t.Name,
+			"role":       memberships[t.ID].Role,
+			"status":     memberships[t.ID].Status,
+			"members":    members,
+			"created_at": t.CreatedAt,
+		}
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: map[string]interface{}{"teams": list}})
+}
+
+func createTeamHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	var req CreateTeamRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "Invalid request format"})
+		return
+	}
+	name := strings.TrimSpace(req.Name)
+	if name == "" || len([]rune(name)) > 64 {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "团队名称不能为空且不能超过 64 个字符"})
+		return
+	}
+
+	t := &models.Team{Name: name, OwnerID: userID}
+	if err := sr.CreateTeam(t); err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "创建团队失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "团队已创建", Data: t})
+}
+
+// getTeamHandler shows a team with its members and assignments
+func getTeamHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	t, me, ok := findTeam(c, userID)
+	if !ok {
+		return
+	}
+	members, err := sr.TeamMembers(t.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取团队失败"})
+		return
+	}
+	assignments, decks, err := sr.ListAssignments(t.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取团队失败"})
+		return
+	}
+
+	names := teamUsernames(members)
+	memberList := make([]map[string]interface{}, len(members))
+	for i, m := range members {
+		memberList[i] = map[string]interface{}{
+			"user_id":   m.UserID,
+			"username":  names[m.UserID],
+			"role":      m.Role,
+			"status":    m.Status,
+			"joined_at": m.CreatedAt,
+		}
+	}
+	assignmentList := make([]map[string]interface{}, 0, len(assignments))
+	for _, a := range assignments {
+		if d, ok := decks[a.DeckID]; ok {
+			assignmentList = append(assignmentList, assignmentData(a, d))
+		}
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: map[string]interface{}{
+		"id":          t.ID,
+		"name":        t.Name,
+		"role":        me.Role,
+		"members":     memberList,
+		"assignments": assignmentList,
+		"created_at":  t.CreatedAt,
+	}})
+}
+
+func deleteTeamHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	t, me, ok := findTeam(c, userID)
+	if !ok {
+		return
+	}
+	if me.Role != models.TeamRoleOwner {
+		c.JSON(http.StatusForbidden, Response{Success: false, Error: "只有团队所有者可以删除团队"})
+		return
+	}
+	if err := sr.DeleteTeam(t.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "删除团队失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "团队已删除"})
+}
+
+// addTeamMemberHandler invites a user to the team. The user becomes a member
+// once they accept. Only the owner can invite admins.
+func addTeamMemberHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	t, me, ok := findManagedTeam(c, userID)
+	if !ok {
+		return
+	}
+	var req AddTeamMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "Invalid request format"})
+		return
+	}
+	role := req.Role
+	if role == "" {
+		role = models.TeamRoleMember
+	}
+	if role != models.TeamRoleAdmin && role != models.TeamRoleMember {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "role 必须是 admin 或 member"})
+		return
+	}
+	if role == models.TeamRoleAdmin && me.Role != models.TeamRoleOwner {
+		c.JSON(http.StatusForbidden, Response{Success: false, Error: "只有团队所有者可以添加管理员"})
+		return
+	}
+	var user models.User
+	if err := db.Where("username = ?", strings.TrimSpace(req.Username)).First(&user).Error; err != nil {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "用户不存在"})
+		return
+	}
+
+	m := &models.TeamMember{TeamID: t.ID, UserID: user.ID, Role: role, Status: models.TeamMemberInvited}
+	err := sr.AddTeamMember(m)
+	if errors.Is(err, spacedrepetition.ErrTeamMember) {
+		c.JSON(http.StatusConflict, Response{Success: false, Error: "该用户已经是团队成员或已被邀请"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "邀请成员失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "邀请已发送", Data: map[string]interface{}{
+		"user_id": m.UserID, "username": user.Username, "role": m.Role, "status": m.Status,
+	}})
+}
+
+// acceptTeamInvitationHandler makes the user a member of the team they were
+// invited to and subscribes them to the team's assigned decks
+func acceptTeamInvitationHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	teamID, ok := teamIDParam(c)
+	if !ok {
+		return
+	}
+	m, err := sr.AcceptTeamInvitation(teamID, userID)
+	if errors.Is(err, spacedrepetition.ErrNoInvitation) {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "邀请不存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "接受邀请失败"})
+		return
+	}
+
+	assignments, decks, err := sr.ListAssignments(teamID)
+	if err == nil {
+		for _, a := range assignments {
+			if d, ok := decks[a.DeckID]; ok {
+				if err = subscribeAssigned(userID, d); err != nil {
+					break
+				}
+			}
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "已加入团队，但订阅团队卡组失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "已加入团队", Data: map[string]interface{}{
+		"team_id": m.TeamID, "role": m.Role, "status": m.Status,
+	}})
+}
+
+// declineTeamInvitationHandler removes the user's pending invitation
+func declineTeamInvitationHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	teamID, ok := teamIDParam(c)
+	if !ok {
+		return
+	}
+	err := sr.DeclineTeamInvitation(teamID, userID)
+	if errors.Is(err, spacedrepetition.ErrNoInvitation) {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "邀请不存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "拒绝邀请失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "已拒绝邀请"})
+}
+
+func findTeamMember(c *gin.Context, teamID uint) (*models.TeamMember, bool) {
+	id, err := strconv.ParseUint(c.Param("user_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "无效的用户 ID"})
+		return nil, false
+	}
+	m, err := sr.GetTeamMember(teamID, uint(id))
+	if err != nil {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "成员不存在"})
+		return nil, false
+	}
+	return m, true
+}
+
+// updateTeamMemberHandler promotes a member to admin or demotes an admin.
+// Only the owner can change roles.
+func updateTeamMemberHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	t, me, ok := findTeam(c, userID)
+	if !ok {
+		return
+	}
+	if me.Role != models.TeamRoleOwner {
+		c.JSON(http.StatusForbidden, Response{Success: false, Error: "只有团队所有者可以修改角色"})
+		return
+	}
+	m, ok := findTeamMember(c, t.ID)
+	if !ok {
+		return
+	}
+	var req UpdateTeamMemberRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "Invalid request format"})
+		return
+	}
+	if req.Role != models.TeamRoleAdmin && req.Role != models.TeamRoleMember {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "role 必须是 admin 或 member"})
+		return
+	}
+	if m.Role == models.TeamRoleOwner {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "不能修改团队所有者的角色"})
+		return
+	}
+	if err := sr.SetTeamRole(m, req.Role); err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "修改角色失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "角色已更新", Data: map[string]interface{}{
+		"user_id": m.UserID, "role": m.Role,
+	}})
+}
+
+// removeTeamMemberHandler removes a member or withdraws an invitation.
+// Members can leave by removing themselves; admins can remove members, the
+// owner anyone but themselves. Removed members keep their subscribed cards.
+func removeTeamMemberHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	t, me, ok := findTeam(c, userID)
+	if !ok {
+		return
+	}
+	m, ok := findTeamMember(c, t.ID)
+	if !ok {
+		return
+	}
+	if m.Role == models.TeamRoleOwner {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "不能移除团队所有者"})
+		return
+	}
+	allowed := m.UserID == userID || me.Role == models.TeamRoleOwner ||
+		(me.Role == models.TeamRoleAdmin && m.Role == models.TeamRoleMember)
+	if !allowed {
+		c.JSON(http.StatusForbidden, Response{Success: false, Error: "没有权限移除该成员"})
+		return
+	}
+	if err := sr.RemoveTeamMember(t.ID, m.UserID); err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "移除成员失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "成员已移除"})
+}
+
+// assignDeckHandler assigns a deck to the team and subscribes every member.
+// Invited users are subscribed when they accept.
+func assignDeckHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	t, _, ok := findManagedTeam(c, userID)
+	if !ok {
+		return
+	}
+	var req AssignDeckRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "Invalid request format"})
+		return
+	}
+
+	var d *models.Deck
+	switch {
+	case strings.TrimSpace(req.ShareCode) != "":
+		found, err := sr.GetDeckByCode(req.ShareCode)
+		if err != nil {
+			c.JSON(http.StatusNotFound, Response{Success: false, Error: "分享码无效"})
+			return
+		}
+		d = found
+	case req.DeckID != 0:
+		e, err := sr.GetCatalogEntry(req.DeckID)
+		if err != nil || (!e.Public && e.UserID != userID) {
+			c.JSON(http.StatusNotFound, Response{Success: false, Error: "卡组不存在"})
+			return
+		}
+		d = &e.Deck
+	default:
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "需要提供 deck_id 或 share_code"})
+		return
+	}
+	if req.Deadline != nil && !req.Deadline.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "截止时间必须晚于当前时间"})
+		return
+	}
+
+	a := &models.TeamAssignment{TeamID: t.ID, DeckID: d.ID, Deadline: req.Deadline, CreatedBy: userID}
+	err := sr.AssignDeck(a)
+	if errors.Is(err, spacedrepetition.ErrDeckAssigned) {
+		c.JSON(http.StatusConflict, Response{Success: false, Error: "该卡组已经分配给团队"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "分配卡组失败"})
+		return
+	}
+
+	members, err := sr.TeamMembers(t.ID)
+	if err == nil {
+		for _, m := range members {
+			if !m.Active() {
+				continue
+			}
+			if err = subscribeAssigned(m.UserID, d); err != nil {
+				break
+			}
+		}
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "卡组已分配，但为成员订阅失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "卡组已分配", Data: assignmentData(a, d)})
+}
+
+// unassignDeckHandler removes an assignment. Members keep their subscriptions.
+func unassignDeckHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	t, _, ok := findManagedTeam(c, userID)
+	if !ok {
+		return
+	}
+	id, err := strconv.ParseUint(c.Param("assignment_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "无效的分配 ID"})
+		return
+	}
+	err = sr.Unassign(t.ID, uint(id))
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "分配不存在"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "取消分配失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "已取消分配"})
+}
+
+// teamProgress computes every member's progress on each assignment. Invited
+// users who have not accepted are left out.
+func teamProgress(c *gin.Context, t *models.Team, each func(a *models.TeamAssignment, d *models.Deck, members []*models.TeamMember, progress map[uint]*spacedrepetition.DeckProgress) map[string]interface{}) {
+	members, err := sr.TeamMembers(t.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取团队进度失败"})
+		return
+	}
+	assignments, decks, err := sr.ListAssignments(t.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取团队进度失败"})
+		return
+	}
+
+	list := make([]map[string]interface{}, 0, len(assignments))
+	for _, a := range assignments {
+		d, ok := decks[a.DeckID]
+		if !ok {
+			continue
+		}
+		var learners []*models.TeamMember
+		var ids []uint
+		for _, m := range members {
+			if m.Active() {
+				learners = append(learners, m)
+				ids = append(ids, m.UserID)
+			}
+		}
+		progress, err := sr.DeckProgress(d, ids)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取团队进度失败"})
+			return
+		}
+		data := assignmentData(a, d)
+		for k, v := range each(a, d, learners, progress) {
+			data[k] = v
+		}
+		list = append(list, data)
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: map[string]interface{}{
+		"team_id": t.ID, "name": t.Name, "assignments": list,
+	}})
+}
+
+// teamProgressHandler shows owners and admins each member's due backlog,
+// accuracy and mastery per assigned deck
+func teamProgressHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	t, _, ok := findManagedTeam(c, userID)
+	if !ok {
+		return
+	}
+	members, _ := sr.TeamMembers(t.ID)
+	names := teamUsernames(members)
+	now := time.Now()
+
+	teamProgress(c, t, func(a *models.TeamAssignment, d *models.Deck, learners []*models.TeamMember, progress map[uint]*spacedrepetition.DeckProgress) map[string]interface{} {
+		rows := make([]map[string]interface{}, len(learners))
+		for i, m := range learners {
+			p := progress[m.UserID]
+			rows[i] = map[string]interface{}{
+				"user_id":   m.UserID,
+				"username":  names[m.UserID],
+				"role":      m.Role,
+				"progress":  p,
+				"completed": p.Completed(),
+				"overdue":   overdue(a, p, now),
+			}
+		}
+		return map[string]interface{}{"members": rows}
+	})
+}
+
+// teamSummaryHandler shows every member the team's aggregate progress per
+// assigned deck next to their own, without other members' numbers
+func teamSummaryHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	t, _, ok := findTeam(c, userID)
+	if !ok {
+		return
+	}
+	now := time.Now()
+
+	teamProgress(c, t, func(a *models.TeamAssignment, d *models.Deck, learners []*models.TeamMember, progress map[uint]*spacedrepetition.DeckProgress) map[string]interface{} {
+		total := &spacedrepetition.DeckProgress{}
+		completed, late := 0, 0
+		for _, m := range learners {
+			p := progress[m.UserID]
+			total.Add(p)
+			if p.Completed() {
+				completed++
+			}
+			if overdue(a, p, now) {
+				late++
+			}
+		}
+		data := map[string]interface{}{
+			"members":   len(learners),
+			"completed": completed,
+			"overdue":   late,
+			"team":      total,
+			"mine":      nil,
+		}
+		if len(learners) < minSummaryLearners {
+			data["completed"], data["overdue"], data["team"] = nil, nil, nil
+		}
+		if p, ok := progress[userID]; ok {
+			data["mine"] = p
+		}
+		return data
+	})
+}
@@ -0,0 +1,251 @@
+package server
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestE2E_Teams(t *testing.T) {
+	router := setupE2E(t)
+	author := registerAndGetToken(t, router, "teamauthor")
+	admin := registerAndGetToken(t, router, "teamadmin")
+	alice := registerAndGetToken(t, router, "teamalice")
+	bob := registerAndGetToken(t, router, "teambob")
+	outsider := registerAndGetToken(t, router, "teamoutsider")
+
+	for _, q := range []map[string]string{
+		{"question": "如何回滚部署？", "answer": "kubectl rollout undo", "category": "onboarding"},
+		{"question": "值班手册在哪？", "answer": "wiki", "category": "onboarding"},
+	} {
+		if code, resp := apiJSON(t, router, author, "POST", "/api/add-question", q); code != http.StatusOK {
+			t.Fatalf("add question failed: %d %v", code, resp)
+		}
+	}
+	_, resp := apiJSON(t, router, author, "POST", "/api/decks", map[string]string{"category": "onboarding", "title": "入职"})
+	shareCode := resp.Data.(map[string]interface{})["share_code"].(string)
+
+	code, resp := apiJSON(t, router, admin, "POST", "/api/teams", map[string]string{"name": "平台组"})
+	if code != http.StatusOK {
+		t.Fatalf("create team failed: %d %v", code, resp)
+	}
+	base := fmt.Sprintf("/api/teams/%v", resp.Data.(map[string]interface{})["id"])
+
+	for _, name := range []string{"teamalice", "teamauthor"} {
+		if code, resp := apiJSON(t, router, admin, "POST", base+"/members", map[string]string{"username": name}); code != http.StatusOK {
+			t.Fatalf("invite failed: %d %v", code, resp)
+		}
+	}
+	if code, _ := apiJSON(t, router, admin, "POST", base+"/members", map[string]string{"username": "teamalice"}); code != http.StatusConflict {
+		t.Errorf("expected 409 for a second invitation, got %d", code)
+	}
+	if code, _ := apiJSON(t, router, admin, "POST", base+"/members", map[string]string{"username": "nobody"}); code != http.StatusNotFound {
+		t.Errorf("expected 404 for an unknown user, got %d", code)
+	}
+	if code, _ := apiJSON(t, router, outsider, "GET", base, nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 for an outsider, got %d", code)
+	}
+
+	// Invited users see the invitation but not the team until they accept
+	_, resp = apiJSON(t, router, alice, "GET", "/api/teams", nil)
+	if teams := resp.Data.(map[string]interface{})["teams"].([]interface{}); len(teams) != 1 || teams[0].(map[string]interface{})["status"] != "invited" {
+		t.Errorf("expected a pending invitation, got %v", teams)
+	}
+	if code, _ := apiJSON(t, router, alice, "GET", base, nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 before accepting, got %d", code)
+	}
+	if code, _ := apiJSON(t, router, outsider, "POST", base+"/accept", nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 for accepting without invitation, got %d", code)
+	}
+	for _, token := range []string{alice, author} {
+		if code, resp := apiJSON(t, router, token, "POST", base+"/accept", nil); code != http.StatusOK {
+			t.Fatalf("accept failed: %d %v", code, resp)
+		}
+	}
+	if code, _ := apiJSON(t, router, alice, "POST", base+"/accept", nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 for accepting twice, got %d", code)
+	}
+	if code, _ := apiJSON(t, router, alice, "POST", base+"/members", map[string]string{"username": "teambob"}); code != http.StatusForbidden {
+		t.Errorf("expected 403 for a member adding members, got %d", code)
+	}
+
+	past := time.Now().Add(-time.Hour).Format(time.RFC3339)
+	if code, _ := apiJSON(t, router, admin, "POST", base+"/assignments", map[string]string{"share_code": shareCode, "deadline": past}); code != http.StatusBadRequest {
+		t.Errorf("expected 400 for a past deadline, got %d", code)
+	}
+	deadline := time.Now().Add(7 * 24 * time.Hour).Format(time.RFC3339)
+	code, resp = apiJSON(t, router, admin, "POST", base+"/assignments", map[string]string{"share_code": shareCode, "deadline": deadline})
+	if code != http.StatusOK {
+		t.Fatalf("assign failed: %d %v", code, resp)
+	}
+	assignment := resp.Data.(map[string]interface{})
+	if assignment["cards"].(float64) != 2 || assignment["deadline"] == nil {
+		t.Errorf("unexpected assignment: %v", assignment)
+	}
+	if code, _ := apiJSON(t, router, admin, "POST", base+"/assignments", map[string]string{"share_code": shareCode}); code != http.StatusConflict {
+		t.Errorf("expected 409 for assigning a deck twice, got %d", code)
+	}
+
+	// Members are subscribed on assignment and when they accept later
+	for _, name := range []string{"teambob", "teamoutsider"} {
+		if code, resp := apiJSON(t, router, admin, "POST", base+"/members", map[string]string{"username": name}); code != http.StatusOK {
+			t.Fatalf("invite failed: %d %v", code, resp)
+		}
+	}
+	_, resp = apiJSON(t, router, bob, "GET", "/api/subscriptions", nil)
+	if subs := resp.Data.(map[string]interface{})["subscriptions"].([]interface{}); len(subs) != 0 {
+		t.Fatalf("expected no subscription before accepting, got %v", subs)
+	}
+	if code, resp := apiJSON(t, router, bob, "POST", base+"/accept", nil); code != http.StatusOK {
+		t.Fatalf("accept failed: %d %v", code, resp)
+	}
+	for _, token := range []string{admin, alice, bob} {
+		_, resp := apiJSON(t, router, token, "GET", "/api/subscriptions", nil)
+		if subs := resp.Data.(map[string]interface{})["subscriptions"].([]interface{}); len(subs) != 1 {
+			t.Fatalf("expected an assigned subscription, got %v", subs)
+		}
+	}
+
+	_, resp = apiJSON(t, router, alice, "GET", "/api/due-questions?category=入职", nil)
+	for _, item := range resp.Data.(map[string]interface{})["questions"].([]interface{}) {
+		reviewQuestion(t, router, alice, item.(map[string]interface{})["id"].(string), 1)
+	}
+
+	if code, _ := apiJSON(t, router, alice, "GET", base+"/progress", nil); code != http.StatusForbidden {
+		t.Errorf("expected 403 for a member viewing member progress, got %d", code)
+	}
+	code, resp = apiJSON(t, router, admin, "GET", base+"/progress", nil)
+	if code != http.StatusOK {
+		t.Fatalf("progress failed: %d %v", code, resp)
+	}
+	rows := resp.Data.(map[string]interface{})["assignments"].([]interface{})[0].(map[string]interface{})["members"].([]interface{})
+	if len(rows) != 4 {
+		t.Fatalf("expected admin, alice, bob and the author without the pending invitation, got %v", rows)
+	}
+	for _, item := range rows {
+		row := item.(map[string]interface{})
+		p := row["progress"].(map[string]interface{})
+		switch row["username"] {
+		case "teamalice":
+			if p["due"].(float64) != 0 || p["accuracy"].(float64) != 1 || row["completed"] != true {
+				t.Errorf("unexpected progress for alice: %v", row)
+			}
+		case "teambob":
+			if p["due"].(float64) != 2 || p["reviews"].(float64) != 0 || row["completed"] != false || row["overdue"] != false {
+				t.Errorf("unexpected progress for bob: %v", row)
+			}
+		case "teamauthor":
+			// The author studies the deck's questions themselves
+			if p["cards"].(float64) != 2 || p["due"].(float64) != 2 {
+				t.Errorf("unexpected progress for the author: %v", row)
+			}
+		case "teamoutsider":
+			t.Errorf("pending invitation listed in progress: %v", row)
+		}
+	}
+
+	code, resp = apiJSON(t, router, bob, "GET", base+"/summary", nil)
+	if code != http.StatusOK {
+		t.Fatalf("summary failed: %d %v", code, resp)
+	}
+	summary := resp.Data.(map[string]interface{})["assignments"].([]interface{})[0].(map[string]interface{})
+	if _, ok := summary["members"].([]interface{}); ok {
+		t.Fatalf("summary must not list members: %v", summary)
+	}
+	team := summary["team"].(map[string]interface{})
+	mine := summary["mine"].(map[string]interface{})
+	if summary["members"].(float64) != 4 || summary["completed"].(float64) != 1 || team["due"].(float64) != 6 || mine["due"].(float64) != 2 {
+		t.Errorf("unexpected summary: %v", summary)
+	}
+
+	if code, _ := apiJSON(t, router, outsider, "POST", base+"/decline", nil); code != http.StatusOK {
+		t.Errorf("decline failed: %d", code)
+	}
+	if code, _ := apiJSON(t, router, outsider, "POST", base+"/accept", nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 for accepting a declined invitation, got %d", code)
+	}
+
+	// Roles: only the owner promotes; admins remove members, members leave
+	aliceID := teamMemberID(t, router, admin, base, "teamalice")
+	bobID := teamMemberID(t, router, admin, base, "teambob")
+	if code, _ := apiJSON(t, router, alice, "PATCH", base+"/members/"+bobID, map[string]string{"role": "admin"}); code != http.StatusForbidden {
+		t.Errorf("expected 403 for a member changing roles, got %d", code)
+	}
+	if code, _ := apiJSON(t, router, alice, "DELETE", base+"/members/"+bobID, nil); code != http.StatusForbidden {
+		t.Errorf("expected 403 for a member removing others, got %d", code)
+	}
+	if code, _ := apiJSON(t, router, admin, "PATCH", base+"/members/"+aliceID, map[string]string{"role": "admin"}); code != http.StatusOK {
+		t.Errorf("promote failed: %d", code)
+	}
+	if code, _ := apiJSON(t, router, alice, "GET", base+"/progress", nil); code != http.StatusOK {
+		t.Errorf("expected an admin to see progress, got %d", code)
+	}
+	if code, _ := apiJSON(t, router, bob, "DELETE", base+"/members/"+bobID, nil); code != http.StatusOK {
+		t.Errorf("leave failed: %d", code)
+	}
+	if code, _ := apiJSON(t, router, bob, "GET", base, nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 after leaving, got %d", code)
+	}
+	_, resp = apiJSON(t, router, bob, "GET", "/api/subscriptions", nil)
+	if subs := resp.Data.(map[string]interface{})["subscriptions"].([]interface{}); len(subs) != 1 {
+		t.Errorf("expected a former member to keep the subscription, got %v", subs)
+	}
+
+	if code, _ := apiJSON(t, router, alice, "DELETE", base, nil); code != http.StatusForbidden {
+		t.Errorf("expected 403 for an admin deleting the team, got %d", code)
+	}
+	if code, _ := apiJSON(t, router, admin, "DELETE", base, nil); code != http.StatusOK {
+		t.Errorf("delete failed: %d", code)
+	}
+	_, resp = apiJSON(t, router, alice, "GET", "/api/teams", nil)
+	if teams := resp.Data.(map[string]interface{})["teams"].([]interface{}); len(teams) != 0 {
+		t.Errorf("expected no teams after delete, got %v", teams)
+	}
+}
+
+// teamMemberID looks up a member's user ID in the team's roster
+func teamMemberID(t *testing.T, router *gin.Engine, token, base, username string) string {
+	t.Helper()
+	_, resp := apiJSON(t, router, token, "GET", base, nil)
+	for _, item := range resp.Data.(map[string]interface{})["members"].([]interface{}) {
+		m := item.(map[string]interface{})
+		if m["username"] == username {
+			return fmt.Sprintf("%v", m["user_id"])
+		}
+	}
+	t.Fatalf("member %s not found", username)
+	return ""
+}
+
+func TestE2E_TeamSummary_SmallTeam(t *testing.T) {
+	router := setupE2E(t)
+	owner := registerAndGetToken(t, router, "pairowner")
+	member := registerAndGetToken(t, router, "pairmember")
+
+	addQuestion(t, router, owner, "如何回滚部署？", "kubectl rollout undo")
+	_, resp := apiJSON(t, router, owner, "POST", "/api/decks", map[string]string{"category": "未分类", "title": "入职"})
+	shareCode := resp.Data.(map[string]interface{})["share_code"].(string)
+	_, resp = apiJSON(t, router, owner, "POST", "/api/teams", map[string]string{"name": "两人组"})
+	base := fmt.Sprintf("/api/teams/%v", resp.Data.(map[string]interface{})["id"])
+	apiJSON(t, router, owner, "POST", base+"/members", map[string]string{"username": "pairmember"})
+	apiJSON(t, router, member, "POST", base+"/accept", nil)
+	if code, resp := apiJSON(t, router, owner, "POST", base+"/assignments", map[string]string{"share_code": shareCode}); code != http.StatusOK {
+		t.Fatalf("assign failed: %d %v", code, resp)
+	}
+
+	// Team totals minus their own progress would be the owner's
+	code, resp := apiJSON(t, router, member, "GET", base+"/summary", nil)
+	if code != http.StatusOK {
+		t.Fatalf("summary failed: %d %v", code, resp)
+	}
+	summary := resp.Data.(map[string]interface{})["assignments"].([]interface{})[0].(map[string]interface{})
+	if summary["members"].(float64) != 2 || summary["team"] != nil || summary["completed"] != nil || summary["overdue"] != nil {
+		t.Errorf("expected team totals hidden for two learners: %v", summary)
+	}
+	if mine, ok := summary["mine"].(map[string]interface{}); !ok || mine["due"].(float64) != 1 {
+		t.Errorf("expected own progress, got %v", summary["mine"])
+	}
+}
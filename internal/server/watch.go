@@ -0,0 +1,252 @@
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm/clause"
+
+	"self-improvement/internal/models"
+	"self-improvement/internal/parser"
+)
+
+// defaultWatchInterval is how often watched question directories are polled
+const defaultWatchInterval = 30 * time.Second
+
+// WatchRequest toggles automatic syncing of the user's question directory
+type WatchRequest struct {
+	Enabled *bool `json:"enabled" binding:"required"`
+}
+
+// watchStatuses keeps the last sync result per user
+var watchStatuses sync.Map
+
+func watchInterval() time.Duration {
+	if v := os.Getenv("WATCH_INTERVAL"); v != "" {
+		if d, err := time.ParseDuration(v); err == nil {
+			return d
+		}
+	}
+	return defaultWatchInterval
+}
+
+// startWatcher polls the question directories of users who enabled watching.
+// A non-positive interval disables polling.
+func startWatcher(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for range ticker.C {
+			syncWatchedUsers()
+		}
+	}()
+}
+
+func syncWatchedUsers() {
+	var settings []models.UserSettings
+	if err := db.Where("watch_enabled = ?", true).Find(&settings).Error; err != nil {
+		return
+	}
+	for _, s := range settings {
+		var user models.User
+		if err := db.First(&user, s.UserID).Error; err != nil {
+			continue
+		}
+		syncUserDir(&user)
+	}
+}
+
+type fileChange struct {
+	sourceChange
+	info fs.FileInfo
+	hash string
+}
+
+// syncUserDir re-syncs the files in the user's question directory that were
+// added, changed or removed since the last sync. Unchanged files are detected
+// by mtime and size, then by content hash.
+func syncUserDir(user *models.User) *syncResult {
+	defer lockUserSync(user.ID)()
+
+	start := time.Now()
+	result := &syncResult{At: start}
+	defer func() {
+		result.DurationMs = time.Since(start).Milliseconds()
+		watchStatuses.Store(user.ID, result)
+	}()
+
+	root := userQuestionsDir(user.Username)
+	p, err := parser.NewQuestionParser(root)
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return result
+	}
+
+	var known []models.SourceFile
+	if err := db.Where("user_id = ?", user.ID).Find(&known).Error; err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return result
+	}
+	knownByPath := make(map[string]*models.SourceFile)
+	for i := range known {
+		knownByPath[known[i].Path] = &known[i]
+	}
+
+	// Find changed files
+	seen := make(map[string]bool)
+	var changes []*fileChange
+	err = filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if path == root {
+				return err
+			}
+			result.Errors = append(result.Errors, err.Error())
+			return nil
+		}
+		if d.IsDir() || !strings.HasSuffix(strings.ToLower(path), ".md") {
+			return nil
+		}
+		seen[path] = true
+
+		info, err := d.Info()
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			return nil
+		}
+		prev := knownByPath[path]
+		if prev != nil && prev.ModTime.Equal(info.ModTime()) && prev.Size == info.Size() {
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			result.Errors = append(result.Errors, err.Error())
+			return nil
+		}
+		sum := sha256.Sum256(content)
+		hash := hex.EncodeToString(sum[:])
+		if prev != nil && prev.Hash == hash {
+			// Touched but not edited
+			db.Model(prev).Updates(map[string]interface{}{"mod_time": info.ModTime(), "size": info.Size()})
+			return nil
+		}
+
+		changes = append(changes, &fileChange{
+			sourceChange: sourceChange{
+				source:    path,
+				category:  extractUserCategory(user.Username, path),
+				questions: p.ParseContent(string(content), path),
+			},
+			info: info,
+			hash: hash,
+		})
+		return nil
+	})
+	if err != nil {
+		result.Errors = append(result.Errors, err.Error())
+		return result
+	}
+
+	var removedFiles []string
+	for path := range knownByPath {
+		if !seen[path] {
+			removedFiles = append(removedFiles, path)
+		}
+	}
+	if len(changes) == 0 && len(removedFiles) == 0 {
+		return result
+	}
+
+	sourceChanges := make([]*sourceChange, len(changes))
+	for i, ch := range changes {
+		sourceChanges[i] = &ch.sourceChange
+	}
+	applySourceChanges(user.ID, sourceChanges, removedFiles, result)
+
+	now := time.Now()
+	for _, ch := range changes {
+		row := models.SourceFile{
+			UserID:    user.ID,
+			Path:      ch.source,
+			ModTime:   ch.info.ModTime(),
+			Size:      ch.info.Size(),
+			Hash:      ch.hash,
+			Questions: len(ch.questions),
+			SyncedAt:  now,
+		}
+		db.Clauses(clause.OnConflict{UpdateAll: true}).Create(&row)
+		result.FilesChanged++
+	}
+	for _, path := range removedFiles {
+		db.Where("user_id = ? AND path = ?", user.ID, path).Delete(&models.SourceFile{})
+		result.FilesRemoved++
+	}
+
+	return result
+}
+
+func watchStatus(userID uint) map[string]interface{} {
+	var settings models.UserSettings
+	db.Where("user_id = ?", userID).Limit(1).Find(&settings)
+
+	status := map[string]interface{}{
+		"enabled":          settings.WatchEnabled,
+		"interval_seconds": int(watchInterval().Seconds()),
+		"last_sync":        nil,
+	}
+	if r, ok := watchStatuses.Load(userID); ok {
+		status["last_sync"] = r
+	}
+	return status
+}
+
+func getWatchHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: watchStatus(userID)})
+}
+
+// setWatchHandler turns watching on or off. Turning it on syncs right away.
+func setWatchHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	var req WatchRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "Invalid request format"})
+		return
+	}
+
+	settings := models.UserSettings{UserID: userID, WatchEnabled: *req.Enabled}
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}},
+		DoUpdates: clause.AssignmentColumns([]string{"watch_enabled", "updated_at"}),
+	}).Create(&settings).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "保存设置失败"})
+		return
+	}
+
+	if *req.Enabled {
+		var user models.User
+		if err := db.First(&user, userID).Error; err != nil {
+			c.JSON(http.StatusNotFound, Response{Success: false, Error: "User not found"})
+			return
+		}
+		syncUserDir(&user)
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: watchStatus(userID)})
+}
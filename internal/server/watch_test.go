@@ -0,0 +1,154 @@
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+
+	"self-improvement/internal/models"
+)
+
+func setWatch(t *testing.T, router *gin.Engine, token string, enabled bool) map[string]interface{} {
+	t.Helper()
+	body, _ := json.Marshal(map[string]bool{"enabled": enabled})
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("PUT", "/api/watch", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("set watch failed: %d: %s", w.Code, w.Body.String())
+	}
+	var resp Response
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	return resp.Data.(map[string]interface{})
+}
+
+func userQuestions(t *testing.T, userID uint) map[string]models.Question {
+	t.Helper()
+	var list []models.Question
+	db.Where("user_id = ?", userID).Find(&list)
+	byText := make(map[string]models.Question)
+	for _, q := range list {
+		byText[q.QuestionText] = q
+	}
+	return byText
+}
+
+func TestE2E_WatchSync(t *testing.T) {
+	router := setupE2E(t)
+
+	wd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(wd)
+
+	token := registerAndGetToken(t, router, "watchuser")
+	user := userByName(t, "watchuser")
+	dir := filepath.Join("questions", "watchuser")
+	os.MkdirAll(filepath.Join(dir, "go"), 0755)
+	aPath := filepath.Join(dir, "go", "a.md")
+	os.WriteFile(aPath, []byte("# q\nQ1\n# a\nA1\n\n# q\nQ2\n# a\nA2\n"), 0644)
+
+	status := setWatch(t, router, token, true)
+	if status["enabled"] != true {
+		t.Fatalf("watch not enabled: %v", status)
+	}
+	last := status["last_sync"].(map[string]interface{})
+	if last["added"].(float64) != 2 || last["files_changed"].(float64) != 1 {
+		t.Fatalf("unexpected first sync: %v", last)
+	}
+
+	questions := userQuestions(t, user.ID)
+	if questions["Q1"].Category != "go" {
+		t.Errorf("expected category without the user directory, got %q", questions["Q1"].Category)
+	}
+	reviewQuestion(t, router, token, questions["Q1"].ID, 1)
+
+	// Nothing changed
+	if r := syncUserDir(&user); r.FilesChanged != 0 || r.Added != 0 {
+		t.Errorf("unchanged directory re-synced: %+v", r)
+	}
+
+	// Edit Q2's answer, move Q1 to another file, add Q3
+	os.WriteFile(aPath, []byte("# q\nQ2\n# a\nA2 改\n\n# q\nQ3\n# a\nA3\n"), 0644)
+	os.WriteFile(filepath.Join(dir, "go", "b.md"), []byte("# q\nQ1\n# a\nA1\n"), 0644)
+
+	r := syncUserDir(&user)
+	if r.FilesChanged != 2 || r.Added != 1 || r.Updated != 2 || r.Removed != 0 {
+		t.Errorf("unexpected sync result: %+v", r)
+	}
+	questions = userQuestions(t, user.ID)
+	if q := questions["Q1"]; q.ReviewCount != 1 || q.Source != filepath.Join(dir, "go", "b.md") {
+		t.Errorf("moved question lost its state: %+v", q)
+	}
+	if questions["Q2"].AnswerText != "A2 改" {
+		t.Errorf("answer not updated: %q", questions["Q2"].AnswerText)
+	}
+	if _, ok := questions["Q3"]; !ok {
+		t.Error("new question not imported")
+	}
+
+	// Removing a file removes its questions
+	os.Remove(aPath)
+	r = syncUserDir(&user)
+	if r.FilesRemoved != 1 || r.Removed != 2 {
+		t.Errorf("unexpected removal result: %+v", r)
+	}
+	if questions = userQuestions(t, user.ID); len(questions) != 1 {
+		t.Errorf("expected only Q1 left, got %d questions", len(questions))
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/api/watch", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+	var resp Response
+	json.Unmarshal(w.Body.Bytes(), &resp)
+	data := resp.Data.(map[string]interface{})
+	if data["enabled"] != true || data["last_sync"].(map[string]interface{})["files_removed"].(float64) != 1 {
+		t.Errorf("status does not show last sync: %v", data)
+	}
+}
+
+func TestE2E_WatchToggle(t *testing.T) {
+	router := setupE2E(t)
+
+	wd, _ := os.Getwd()
+	os.Chdir(t.TempDir())
+	defer os.Chdir(wd)
+
+	token := registerAndGetToken(t, router, "watchtoggle")
+	other := registerAndGetToken(t, router, "watchother")
+	os.MkdirAll(filepath.Join("questions", "watchother"), 0755)
+	os.WriteFile(filepath.Join("questions", "watchother", "a.md"), []byte("# q\nQ\n# a\nA\n"), 0644)
+
+	setWatch(t, router, token, true)
+	if status := setWatch(t, router, token, false); status["enabled"] != false {
+		t.Errorf("watch not disabled: %v", status)
+	}
+
+	// Only enabled users are polled
+	syncWatchedUsers()
+	if n := len(userQuestions(t, userByName(t, "watchother").ID)); n != 0 {
+		t.Errorf("user without watching was synced: %d questions", n)
+	}
+	setWatch(t, router, other, true)
+	if n := len(userQuestions(t, userByName(t, "watchother").ID)); n != 1 {
+		t.Errorf("expected 1 synced question, got %d", n)
+	}
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("PUT", "/api/watch", bytes.NewReader([]byte(`{}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+	router.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("expected 400 without enabled, got %d", w.Code)
+	}
+}
@@ -0,0 +1,366 @@
+package server
+
+import (
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"self-improvement/internal/models"
+	"self-improvement/internal/spacedrepetition"
+	"self-improvement/internal/webhooks"
+)
+
+var hooks *webhooks.Dispatcher
+
+const (
+	maxWebhooks         = 10
+	minWebhookSecretLen = 16
+)
+
+// newHooks returns the webhook dispatcher. WEBHOOK_ALLOW_PRIVATE=true lets
+// webhooks reach loopback and internal addresses, e.g. for a receiver running
+// next to the server.
+func newHooks(db *gorm.DB) *webhooks.Dispatcher {
+	d := webhooks.New(db)
+	if allowPrivateWebhooks() {
+		d.Client = webhooks.NewClient(true)
+	}
+	return d
+}
+
+func allowPrivateWebhooks() bool {
+	allow, _ := strconv.ParseBool(os.Getenv("WEBHOOK_ALLOW_PRIVATE"))
+	return allow
+}
+
+// blockedWebhookHost tells whether host obviously points into the server's
+// network. Host names resolving there are refused when delivering.
+func blockedWebhookHost(host string) bool {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && webhooks.Blocked(ip)
+}
+
+// WebhookRequest creates a webhook or changes some of its fields. A webhook
+// created without a secret gets a random one.
+type WebhookRequest struct {
+	URL    *string  `json:"url"`
+	Events []string `json:"events"`
+	Secret *string  `json:"secret"`
+	Active *bool    `json:"active"`
+}
+
+// apply validates the request and copies the given fields to hook
+func (req *WebhookRequest) apply(hook *models.Webhook) string {
+	if req.URL != nil {
+		raw := strings.TrimSpace(*req.URL)
+		u, err := url.Parse(raw)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return "URL 必须是 http 或 https 地址"
+		}
+		if !allowPrivateWebhooks() && blockedWebhookHost(u.Hostname()) {
+			return "不能使用本机或内网地址"
+		}
+		hook.URL = raw
+	}
+	if req.Events != nil {
+		if len(req.Events) == 0 {
+			return "至少订阅一个事件"
+		}
+		var events []string
+		seen := make(map[string]bool)
+		for _, e := range req.Events {
+			if !webhooks.ValidEvent(e) {
+				return "不支持的事件：" + e
+			}
+			if !seen[e] {
+				seen[e] = true
+				events = append(events, e)
+			}
+		}
+		hook.Events = strings.Join(events, ",")
+	}
+	if req.Secret != nil {
+		if utf8.RuneCountInString(*req.Secret) < minWebhookSecretLen {
+			return "secret 至少 " + strconv.Itoa(minWebhookSecretLen) + " 个字符"
+		}
+		hook.Secret = *req.Secret
+	}
+	if req.Active != nil {
+		hook.Active = *req.Active
+	}
+	return ""
+}
+
+func webhookData(hook *models.Webhook) map[string]interface{} {
+	return map[string]interface{}{
+		"id":         hook.ID,
+		"url":        hook.URL,
+		"events":     strings.Split(hook.Events, ","),
+		"active":     hook.Active,
+		"created_at": hook.CreatedAt,
+		"updated_at": hook.UpdatedAt,
+	}
+}
+
+func findWebhook(c *gin.Context, userID uint) (*models.Webhook, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "无效的 Webhook ID"})
+		return nil, false
+	}
+	var hook models.Webhook
+	if err := db.Where("user_id = ? AND id = ?", userID, id).First(&hook).Error; err != nil {
+		c.JSON(http.StatusNotFound, Response{Success: false, Error: "Webhook 不存在"})
+		return nil, false
+	}
+	return &hook, true
+}
+
+// listWebhooksHandler returns the user's webhooks and the events they can
+// subscribe to
+func listWebhooksHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	var list []models.Webhook
+	if err := db.Where("user_id = ?", userID).Order("id ASC").Find(&list).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取 Webhook 失败"})
+		return
+	}
+	data := make([]map[string]interface{}, len(list))
+	for i := range list {
+		data[i] = webhookData(&list[i])
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Data: map[string]interface{}{"webhooks": data, "events": webhooks.Events}})
+}
+
+// createWebhookHandler subscribes a URL to events. The response is the only
+// one that contains the secret.
+func createWebhookHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.URL == nil || req.Events == nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "Invalid request format"})
+		return
+	}
+	hook := &models.Webhook{UserID: userID, Secret: webhooks.NewSecret(), Active: true}
+	if msg := req.apply(hook); msg != "" {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: msg})
+		return
+	}
+
+	var count int64
+	db.Model(&models.Webhook{}).Where("user_id = ?", userID).Count(&count)
+	if count >= maxWebhooks {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "最多创建 " + strconv.Itoa(maxWebhooks) + " 个 Webhook"})
+		return
+	}
+	if err := db.Create(hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "创建 Webhook 失败"})
+		return
+	}
+
+	data := webhookData(hook)
+	data["secret"] = hook.Secret
+	c.JSON(http.StatusOK, Response{Success: true, Message: "Webhook 已创建", Data: data})
+}
+
+func updateWebhookHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	hook, ok := findWebhook(c, userID)
+	if !ok {
+		return
+	}
+	var req WebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "Invalid request format"})
+		return
+	}
+	if msg := req.apply(hook); msg != "" {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: msg})
+		return
+	}
+	// Select saves a false Active too
+	if err := db.Model(hook).Select("url", "events", "secret", "active").Updates(hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "更新 Webhook 失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "Webhook 已更新", Data: webhookData(hook)})
+}
+
+// deleteWebhookHandler deletes a webhook with its delivery log. Retries still
+// waiting are dropped.
+func deleteWebhookHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	hook, ok := findWebhook(c, userID)
+	if !ok {
+		return
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", hook.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(hook).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "删除 Webhook 失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{Success: true, Message: "Webhook 已删除"})
+}
+
+// testWebhookHandler sends a ping to the webhook and returns the delivery
+// once the receiver answered. Disabled webhooks can be tested too.
+func testWebhookHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	hook, ok := findWebhook(c, userID)
+	if !ok {
+		return
+	}
+	delivery, err := hooks.Test(hook)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "测试投递失败"})
+		return
+	}
+
+	message := "测试投递成功"
+	if delivery.Status != models.WebhookDeliverySucceeded {
+		message = "测试投递失败，接收方未返回 2xx"
+	}
+	c.JSON(http.StatusOK, Response{Success: true, Message: message, Data: delivery})
+}
+
+// listDeliveriesHandler returns the delivery log of a webhook, newest first.
+// Query: page, page_size, status (pending|succeeded|failed), event.
+func listDeliveriesHandler(c *gin.Context) {
+	userId, _ := c.Get("user_id")
+	userID := userId.(uint)
+
+	hook, ok := findWebhook(c, userID)
+	if !ok {
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	if page < 1 {
+		page = 1
+	}
+	pageSize, _ := strconv.Atoi(c.DefaultQuery("page_size", strconv.Itoa(defaultPageSize)))
+	if pageSize < 1 || pageSize > maxPageSize {
+		c.JSON(http.StatusBadRequest, Response{Success: false, Error: "page_size 必须在 1 到 " + strconv.Itoa(maxPageSize) + " 之间"})
+		return
+	}
+
+	query := db.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", hook.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if event := c.Query("event"); event != "" {
+		query = query.Where("event = ?", event)
+	}
+	var total int64
+	var deliveries []models.WebhookDelivery
+	err := query.Count(&total).Error
+	if err == nil {
+		err = query.Order("id DESC").Offset((page - 1) * pageSize).Limit(pageSize).Find(&deliveries).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, Response{Success: false, Error: "获取投递记录失败"})
+		return
+	}
+
+	c.JSON(http.StatusOK, Response{
+		Success: true,
+		Data: map[string]interface{}{
+			"deliveries": deliveries,
+			"total":      total,
+			"page":       page,
+			"page_size":  pageSize,
+		},
+	})
+}
+
+// reviewEvent is the data of a review.completed event
+type reviewEvent struct {
+	QuestionID string `json:"question_id"`
+	Feedback   int    `json:"feedback"`
+	Correct    bool   `json:"correct"`
+	Source     string `json:"source"` // "app" or "sync"
+	SessionID  uint   `json:"session_id,omitempty"`
+}
+
+// reviewHooks tells whether the user has webhooks to notify of reviews and,
+// if so, whether today's goal was met before them
+func reviewHooks(userID uint) (notify, goalMet bool) {
+	if !hooks.Enabled(userID) {
+		return false, false
+	}
+	day, _ := sr.TodayActivity(userID)
+	return true, day.GoalMet
+}
+
+// emitReviews sends review.completed for each review, leech.detected for
+// cards a failed review turned into leeches, and goal.met when the reviews
+// reached today's goal. goalMet tells whether the goal was met before.
+func emitReviews(userID uint, reviews []reviewEvent, goalMet bool) {
+	for _, r := range reviews {
+		hooks.Emit(userID, webhooks.EventReviewCompleted, r)
+		if r.Correct || sr.Lapses(userID, r.QuestionID) != spacedrepetition.LeechLapses {
+			continue
+		}
+		data := map[string]interface{}{"question_id": r.QuestionID, "lapses": spacedrepetition.LeechLapses}
+		if q, err := sr.GetQuestion(userID, r.QuestionID); err == nil {
+			data["question"] = q.QuestionText
+			data["category"] = q.Category
+		}
+		hooks.Emit(userID, webhooks.EventLeechDetected, data)
+	}
+	if goalMet {
+		return
+	}
+	if day, goal := sr.TodayActivity(userID); day.GoalMet {
+		hooks.Emit(userID, webhooks.EventGoalMet, map[string]interface{}{
+			"date":     day.Date,
+			"goal":     goal,
+			"reviews":  day.Reviews,
+			"correct":  day.Correct,
+			"study_ms": day.StudyMs,
+		})
+	}
+}
+
+// importEvent is the data of an import.finished event
+type importEvent struct {
+	Source     string `json:"source"` // init, zip, markdown, anki, csv, convert, backup or git
+	Imported   int    `json:"imported"`
+	Skipped    int    `json:"skipped"`
+	Duplicates int    `json:"duplicates"`
+	Updated    int    `json:"updated,omitempty"` // Git: questions whose answer changed
+	Removed    int    `json:"removed,omitempty"` // Git: questions whose file was removed
+}
+
+// emitImport sends import.finished
+func emitImport(userID uint, e importEvent) {
+	hooks.Emit(userID, webhooks.EventImportFinished, e)
+}
@@ -0,0 +1,190 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"self-improvement/internal/webhooks"
+)
+
+// hookReceiver collects the deliveries it gets and answers with status
+type hookReceiver struct {
+	mu       sync.Mutex
+	status   int
+	payloads []webhooks.Payload
+	valid    bool
+	secret   string
+}
+
+func (r *hookReceiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var p webhooks.Payload
+	json.Unmarshal(body, &p)
+	r.payloads = append(r.payloads, p)
+	r.valid = webhooks.Verify(r.secret, body, req.Header.Get(webhooks.HeaderSignature))
+	w.WriteHeader(r.status)
+}
+
+// events counts the received deliveries by event. Deliveries run
+// concurrently, so their order is not fixed.
+func (r *hookReceiver) events() map[string]int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	counts := make(map[string]int)
+	for _, p := range r.payloads {
+		counts[p.Event]++
+	}
+	return counts
+}
+
+// payload returns the data of the last delivery of event
+func (r *hookReceiver) payload(event string) map[string]interface{} {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for i := len(r.payloads) - 1; i >= 0; i-- {
+		if r.payloads[i].Event == event {
+			data, _ := r.payloads[i].Data.(map[string]interface{})
+			return data
+		}
+	}
+	return nil
+}
+
+func TestE2E_Webhooks(t *testing.T) {
+	router := setupE2E(t)
+	// Deliveries run in the background; every connection to :memory: would
+	// get its own empty database
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	hooks.Backoff = time.Millisecond
+	hooks.MaxAttempts = 2
+	token := registerAndGetToken(t, router, "hookuser")
+	other := registerAndGetToken(t, router, "hookother")
+
+	recv := &hookReceiver{status: http.StatusOK}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	for _, u := range []string{srv.URL, "http://localhost:8080/hook", "http://169.254.169.254/latest", "http://[::1]/hook"} {
+		body := map[string]interface{}{"url": u, "events": []string{webhooks.EventGoalMet}}
+		if code, _ := apiJSON(t, router, token, "POST", "/api/webhooks", body); code != http.StatusBadRequest {
+			t.Errorf("expected 400 for internal address %s, got %d", u, code)
+		}
+	}
+	// The receiver runs on the loopback address
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE", "true")
+	hooks.Client = webhooks.NewClient(true)
+
+	for _, body := range []map[string]interface{}{
+		{"url": "ftp://example.com", "events": []string{webhooks.EventGoalMet}},
+		{"url": srv.URL, "events": []string{}},
+		{"url": srv.URL, "events": []string{"card.deleted"}},
+		{"url": srv.URL, "events": []string{webhooks.EventGoalMet}, "secret": "short"},
+		{"events": []string{webhooks.EventGoalMet}},
+	} {
+		if code, _ := apiJSON(t, router, token, "POST", "/api/webhooks", body); code != http.StatusBadRequest {
+			t.Errorf("expected 400 for %v, got %d", body, code)
+		}
+	}
+
+	code, resp := apiJSON(t, router, token, "POST", "/api/webhooks", map[string]interface{}{
+		"url":    srv.URL,
+		"events": []string{webhooks.EventReviewCompleted, webhooks.EventGoalMet, webhooks.EventGoalMet},
+		"secret": "0123456789abcdef",
+	})
+	if code != http.StatusOK {
+		t.Fatalf("create failed: %d %v", code, resp)
+	}
+	hook := resp.Data.(map[string]interface{})
+	if hook["secret"] != "0123456789abcdef" || len(hook["events"].([]interface{})) != 2 {
+		t.Errorf("unexpected webhook %v", hook)
+	}
+	recv.secret = hook["secret"].(string)
+	base := fmt.Sprintf("/api/webhooks/%d", int(hook["id"].(float64)))
+
+	// The secret is only returned on creation
+	_, resp = apiJSON(t, router, token, "GET", "/api/webhooks", nil)
+	list := resp.Data.(map[string]interface{})["webhooks"].([]interface{})
+	if len(list) != 1 || list[0].(map[string]interface{})["secret"] != nil {
+		t.Errorf("unexpected webhook list %v", list)
+	}
+	if code, _ := apiJSON(t, router, other, "GET", base+"/deliveries", nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 for another user's webhook, got %d", code)
+	}
+
+	apiJSON(t, router, token, "PUT", "/api/profile", map[string]interface{}{
+		"goal": map[string]interface{}{"type": "cards", "target": 2},
+	})
+	for _, q := range []string{"Q1", "Q2"} {
+		addQuestion(t, router, token, q, "A")
+	}
+	_, resp = apiJSON(t, router, token, "GET", "/api/questions?page_size=10", nil)
+	questions := resp.Data.(map[string]interface{})["questions"].([]interface{})
+	for _, q := range questions {
+		reviewQuestion(t, router, token, q.(map[string]interface{})["id"].(string), 1)
+	}
+	hooks.Wait()
+
+	// The goal is met by the second review only
+	got := recv.events()
+	if len(got) != 2 || got[webhooks.EventReviewCompleted] != 2 || got[webhooks.EventGoalMet] != 1 {
+		t.Fatalf("expected 2 reviews and 1 goal, got %v", got)
+	}
+	if !recv.valid {
+		t.Error("expected a valid signature")
+	}
+	if data := recv.payload(webhooks.EventGoalMet); data["reviews"].(float64) != 2 {
+		t.Errorf("unexpected goal.met data %v", data)
+	}
+
+	// Failed deliveries are retried and logged
+	recv.status = http.StatusInternalServerError
+	code, resp = apiJSON(t, router, token, "POST", base+"/test", nil)
+	if code != http.StatusOK || resp.Data.(map[string]interface{})["status"] != "failed" {
+		t.Errorf("expected a failed test delivery, got %d %v", code, resp)
+	}
+	reviewQuestion(t, router, token, questions[0].(map[string]interface{})["id"].(string), 1)
+	hooks.Wait()
+
+	_, resp = apiJSON(t, router, token, "GET", base+"/deliveries?status=failed", nil)
+	data := resp.Data.(map[string]interface{})
+	deliveries := data["deliveries"].([]interface{})
+	if data["total"].(float64) != 2 || len(deliveries) != 2 {
+		t.Fatalf("expected 2 failed deliveries, got %v", data)
+	}
+	latest := deliveries[0].(map[string]interface{})
+	if latest["event"] != webhooks.EventReviewCompleted || latest["attempts"].(float64) != 2 || latest["status_code"].(float64) != 500 {
+		t.Errorf("unexpected failed delivery %v", latest)
+	}
+	_, resp = apiJSON(t, router, token, "GET", base+"/deliveries?page_size=2&event=review.completed", nil)
+	if data := resp.Data.(map[string]interface{}); data["total"].(float64) != 3 || len(data["deliveries"].([]interface{})) != 2 {
+		t.Errorf("unexpected filtered page %v", data)
+	}
+
+	// Disabled webhooks receive nothing
+	code, resp = apiJSON(t, router, token, "PATCH", base, map[string]interface{}{"active": false})
+	if code != http.StatusOK || resp.Data.(map[string]interface{})["active"] != false {
+		t.Fatalf("disable failed: %d %v", code, resp)
+	}
+	n := recv.events()[webhooks.EventReviewCompleted]
+	reviewQuestion(t, router, token, questions[1].(map[string]interface{})["id"].(string), 1)
+	hooks.Wait()
+	if recv.events()[webhooks.EventReviewCompleted] != n {
+		t.Errorf("expected no delivery to a disabled webhook")
+	}
+
+	if code, _ := apiJSON(t, router, token, "DELETE", base, nil); code != http.StatusOK {
+		t.Errorf("delete failed: %d", code)
+	}
+	if code, _ := apiJSON(t, router, token, "GET", base+"/deliveries", nil); code != http.StatusNotFound {
+		t.Errorf("expected 404 after delete, got %d", code)
+	}
+}
@@ -0,0 +1,57 @@
+package server
+
+import (
+	"os"
+	"path"
+	"strconv"
+	"strings"
+
+	"self-improvement/internal/ziparchive"
+)
+
+// Default limits for uploaded zip archives, in MB unless noted
+const (
+	defaultZipMaxSize         = 50
+	defaultZipMaxUncompressed = 200
+	defaultZipMaxEntries      = 10000 // Entries, directories included
+)
+
+func envInt(name string, def int) int {
+	if v := os.Getenv(name); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n > 0 {
+			return n
+		}
+	}
+	return def
+}
+
+// zipLimits returns the limits for uploaded archives, configurable with
+// ZIP_MAX_SIZE_MB, ZIP_MAX_UNCOMPRESSED_MB and ZIP_MAX_ENTRIES. Single entries
+// are capped like assets.
+func zipLimits() ziparchive.Limits {
+	return ziparchive.Limits{
+		MaxSize:         int64(envInt("ZIP_MAX_SIZE_MB", defaultZipMaxSize)) << 20,
+		MaxUncompressed: int64(envInt("ZIP_MAX_UNCOMPRESSED_MB", defaultZipMaxUncompressed)) << 20,
+		MaxEntries:      envInt("ZIP_MAX_ENTRIES", defaultZipMaxEntries),
+		MaxEntrySize:    maxAssetSize,
+	}
+}
+
+// archiveAssetSource resolves links against entries of the uploaded archive
+func archiveAssetSource(a *ziparchive.Archive) assetSource {
+	return func(sourceFile, link string) (string, []byte, bool) {
+		name, ok := ziparchive.CleanName(path.Join(path.Dir(sourceFile), link))
+		if !ok || !a.Has(name) {
+			return "", nil, false
+		}
+		data, err := a.ReadFile(name)
+		if err != nil {
+			return "", nil, false
+		}
+		return name, data, true
+	}
+}
+
+func isMarkdownEntry(name string) bool {
+	return strings.HasSuffix(strings.ToLower(name), ".md")
+}
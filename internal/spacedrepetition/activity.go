@@ -0,0 +1,202 @@
+package spacedrepetition
+
+import (
+	"time"
+	_ "time/tzdata" // Users pick any IANA timezone, whatever the host has installed
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"self-improvement/internal/models"
+	"self-improvement/internal/streaks"
+)
+
+// StreakStatus is the user's daily goal, streaks and achievements
+type StreakStatus struct {
+	Timezone      string                `json:"timezone"` // Empty for the server's timezone
+	Goal          streaks.Goal          `json:"goal"`
+	Today         streaks.Day           `json:"today"`
+	GoalProgress  float64               `json:"goal_progress"` // 0-1
+	CurrentStreak int                   `json:"current_streak"`
+	LongestStreak int                   `json:"longest_streak"`
+	Achievements  []streaks.Achievement `json:"achievements"`
+}
+
+// UserGoal returns the timezone the user's days are counted in and their
+// daily goal
+func (sr *SpacedRepetition) UserGoal(userID uint) (*time.Location, streaks.Goal) {
+	return userGoal(sr.DB, userID)
+}
+
+func userGoal(tx *gorm.DB, userID uint) (*time.Location, streaks.Goal) {
+	var s models.UserSettings
+	tx.Where("user_id = ?", userID).Limit(1).Find(&s)
+	loc := time.Local
+	if s.Timezone != "" {
+		if l, err := time.LoadLocation(s.Timezone); err == nil {
+			loc = l
+		}
+	}
+	goal := streaks.Goal{Type: s.GoalType, Target: s.GoalTarget}
+	if !goal.Valid() {
+		goal = streaks.DefaultGoal
+	}
+	return loc, goal
+}
+
+// recordActivity adds a review made at the given time to the user's day. It
+// runs after the question was saved, so a review that leaves no card due
+// marks today as cleared.
+func recordActivity(tx *gorm.DB, userID uint, at time.Time, correct bool, studyMs int64) error {
+	loc, goal := userGoal(tx, userID)
+	date := at.In(loc).Format(streaks.DateLayout)
+	day := models.DailyActivity{UserID: userID, Date: date}
+	if err := tx.Where("user_id = ? AND date = ?", userID, date).FirstOrCreate(&day).Error; err != nil {
+		return err
+	}
+
+	day.Reviews++
+	if correct {
+		day.Correct++
+	}
+	day.StudyMs += studyMs
+	day.GoalMet = day.GoalMet || goal.Met(day.Reviews, day.StudyMs)
+	now := time.Now()
+	if !day.Cleared && date == now.In(loc).Format(streaks.DateLayout) {
+		var due int64
+		tx.Model(&models.Question{}).Where("user_id = ? AND next_review <= ?", userID, now).Count(&due)
+		day.Cleared = due == 0
+	}
+	return tx.Save(&day).Error
+}
+
+// BackfillActivity builds the daily activity of every user from their review
+// history, for databases from before streak tracking. Whether the due queue
+// was cleared on those days is unknown, so only days that reached the goal
+// count for streaks.
+func (sr *SpacedRepetition) BackfillActivity() error {
+	var userIDs []uint
+	if err := sr.DB.Model(&models.ReviewLog{}).Distinct("user_id").Pluck("user_id", &userIDs).Error; err != nil {
+		return err
+	}
+	for _, userID := range userIDs {
+		var logs []*models.ReviewLog
+		err := sr.DB.Select("reviewed_at", "feedback", "question_ms", "answer_ms").
+			Where("user_id = ?", userID).Find(&logs).Error
+		if err != nil {
+			return err
+		}
+		if err := sr.DB.CreateInBatches(activityFromLogs(sr.DB, userID, logs), 200).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// activityFromLogs sums review logs into the user's days
+func activityFromLogs(tx *gorm.DB, userID uint, logs []*models.ReviewLog) []*models.DailyActivity {
+	loc, goal := userGoal(tx, userID)
+	days := make(map[string]*models.DailyActivity)
+	for _, log := range logs {
+		date := log.ReviewedAt.In(loc).Format(streaks.DateLayout)
+		day, ok := days[date]
+		if !ok {
+			day = &models.DailyActivity{UserID: userID, Date: date}
+			days[date] = day
+		}
+		day.Reviews++
+		if log.Feedback <= 2 {
+			day.Correct++
+		}
+		day.StudyMs += int64(log.QuestionMs + log.AnswerMs)
+	}
+	list := make([]*models.DailyActivity, 0, len(days))
+	for _, day := range days {
+		day.GoalMet = goal.Met(day.Reviews, day.StudyMs)
+		list = append(list, day)
+	}
+	return list
+}
+
+// RestoreActivity merges days restored from a backup into the user's
+// activity. Days the user already has keep the larger counts, so restoring
+// twice changes nothing. Without days, as in backups from before activity
+// was exported, they are rebuilt from the restored logs.
+func (sr *SpacedRepetition) RestoreActivity(userID uint, days []*models.DailyActivity, logs []*models.ReviewLog) error {
+	if len(days) == 0 {
+		days = activityFromLogs(sr.DB, userID, logs)
+	}
+	if len(days) == 0 {
+		return nil
+	}
+	for _, day := range days {
+		day.ID = 0
+		day.UserID = userID
+	}
+	return sr.DB.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "user_id"}, {Name: "date"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"reviews":    gorm.Expr("MAX(reviews, excluded.reviews)"),
+			"correct":    gorm.Expr("MAX(correct, excluded.correct)"),
+			"study_ms":   gorm.Expr("MAX(study_ms, excluded.study_ms)"),
+			"goal_met":   gorm.Expr("goal_met OR excluded.goal_met"),
+			"cleared":    gorm.Expr("cleared OR excluded.cleared"),
+			"updated_at": gorm.Expr("excluded.updated_at"),
+		}),
+	}).CreateInBatches(days, 200).Error
+}
+
+// TodayActivity returns the user's activity of today in their timezone, an
+// empty day before their first review, together with their goal
+func (sr *SpacedRepetition) TodayActivity(userID uint) (*models.DailyActivity, streaks.Goal) {
+	loc, goal := userGoal(sr.DB, userID)
+	day := &models.DailyActivity{UserID: userID, Date: time.Now().In(loc).Format(streaks.DateLayout)}
+	sr.DB.Where("user_id = ? AND date = ?", userID, day.Date).Limit(1).Find(day)
+	return day, goal
+}
+
+// GetStreakStatus returns the user's progress towards today's goal, their
+// current and longest streak and their achievements
+func (sr *SpacedRepetition) GetStreakStatus(userID uint) (*StreakStatus, error) {
+	var rows []*models.DailyActivity
+	if err := sr.DB.Where("user_id = ?", userID).Order("date ASC").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	loc, goal := userGoal(sr.DB, userID)
+	today := time.Now().In(loc).Format(streaks.DateLayout)
+
+	status := &StreakStatus{Goal: goal, Today: streaks.Day{Date: today}}
+	if loc != time.Local {
+		status.Timezone = loc.String()
+	}
+	days := make([]streaks.Day, len(rows))
+	perfect := 0
+	for i, r := range rows {
+		days[i] = streaks.Day{
+			Date:    r.Date,
+			Reviews: r.Reviews,
+			Correct: r.Correct,
+			StudyMs: r.StudyMs,
+			GoalMet: r.GoalMet,
+			Cleared: r.Cleared,
+		}
+		if days[i].Perfect() {
+			perfect++
+		}
+		if r.Date == today {
+			status.Today = days[i]
+		}
+	}
+	status.GoalProgress = goal.Progress(status.Today.Reviews, status.Today.StudyMs)
+	status.CurrentStreak, status.LongestStreak = streaks.Streaks(days, today)
+
+	totals := streaks.Totals{LongestStreak: status.LongestStreak, PerfectDays: perfect}
+	if err := sr.DB.Model(&models.ReviewLog{}).Where("user_id = ?", userID).Count(&totals.Reviews).Error; err != nil {
+		return nil, err
+	}
+	if err := sr.DB.Model(&models.Question{}).Where("user_id = ? AND level = 1", userID).Count(&totals.Mastered).Error; err != nil {
+		return nil, err
+	}
+	status.Achievements = streaks.Achievements(totals)
+	return status, nil
+}
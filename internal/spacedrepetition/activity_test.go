@@ -0,0 +1,141 @@
+package spacedrepetition
+
+import (
+	"testing"
+	"time"
+
+	"self-improvement/internal/models"
+	"self-improvement/internal/streaks"
+)
+
+func TestStreakStatus(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	db.Create(&models.UserSettings{UserID: 1, Timezone: "Pacific/Kiritimati", GoalType: streaks.GoalCards, GoalTarget: 2})
+	sr.AddQuestion(1, "q_1_a", "问题 A", "答案 A", "a.md", "go")
+	sr.AddQuestion(1, "q_1_b", "问题 B", "答案 B", "b.md", "go")
+	sr.AddQuestion(1, "q_1_c", "问题 C", "答案 C", "c.md", "go")
+
+	loc, goal := sr.UserGoal(1)
+	if loc.String() != "Pacific/Kiritimati" || goal.Target != 2 {
+		t.Fatalf("unexpected goal %v %+v", loc, goal)
+	}
+
+	sr.UpdateTimedReview(1, "q_1_a", 1, ReviewTiming{QuestionMs: 3000, AnswerMs: 1000})
+	status, err := sr.GetStreakStatus(1)
+	if err != nil {
+		t.Fatalf("GetStreakStatus failed: %v", err)
+	}
+	today := time.Now().In(loc).Format(streaks.DateLayout)
+	if status.Today.Date != today || status.Today.Reviews != 1 || status.Today.StudyMs != 4000 || status.GoalProgress != 0.5 {
+		t.Errorf("unexpected today %+v (%v)", status.Today, status.GoalProgress)
+	}
+	if status.CurrentStreak != 0 || status.Today.GoalMet || status.Today.Cleared {
+		t.Errorf("expected no streak before the goal, got %+v", status)
+	}
+
+	sr.UpdateReview(1, "q_1_b", 4)
+	status, _ = sr.GetStreakStatus(1)
+	if !status.Today.GoalMet || status.Today.Cleared || status.CurrentStreak != 1 || status.Timezone != "Pacific/Kiritimati" {
+		t.Errorf("expected the goal to be met, got %+v", status)
+	}
+
+	var achieved bool
+	for _, a := range status.Achievements {
+		achieved = achieved || (a.ID == "first_review" && a.Earned)
+	}
+	if !achieved {
+		t.Errorf("expected the first review achievement, got %+v", status.Achievements)
+	}
+
+	// Clearing the due queue counts a day without reaching the goal
+	db.Create(&models.UserSettings{UserID: 2})
+	sr.AddQuestion(2, "q_2_a", "问题", "答案", "a.md", "go")
+	sr.UpdateReview(2, "q_2_a", 1)
+	status, _ = sr.GetStreakStatus(2)
+	if status.Today.GoalMet || !status.Today.Cleared || status.CurrentStreak != 1 || status.Timezone != "" {
+		t.Errorf("expected the cleared queue to count, got %+v", status)
+	}
+
+	if err := sr.ResetUserQuestions(2); err != nil {
+		t.Fatalf("ResetUserQuestions failed: %v", err)
+	}
+	if status, _ = sr.GetStreakStatus(2); status.LongestStreak != 0 {
+		t.Errorf("expected reset to clear the streak, got %+v", status)
+	}
+}
+
+func TestBackfillActivity(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	sr.AddQuestion(1, "q_1_a", "问题 A", "答案 A", "a.md", "go")
+	now := time.Now()
+	var logs []models.ReviewLog
+	for d := 1; d <= 3; d++ {
+		for i := 0; i < streaks.DefaultGoal.Target; i++ {
+			logs = append(logs, models.ReviewLog{UserID: 1, QuestionID: "q_1_a", Feedback: 1, ReviewedAt: now.AddDate(0, 0, -d)})
+		}
+	}
+	// Short of the goal
+	logs = append(logs, models.ReviewLog{UserID: 1, QuestionID: "q_1_a", Feedback: 3, ReviewedAt: now.AddDate(0, 0, -5)})
+	db.Create(&logs)
+
+	if err := sr.BackfillActivity(); err != nil {
+		t.Fatalf("BackfillActivity failed: %v", err)
+	}
+	status, err := sr.GetStreakStatus(1)
+	if err != nil {
+		t.Fatalf("GetStreakStatus failed: %v", err)
+	}
+	if status.CurrentStreak != 3 || status.LongestStreak != 3 {
+		t.Errorf("expected a 3 day streak, got %+v", status)
+	}
+	var perfect bool
+	for _, a := range status.Achievements {
+		perfect = perfect || (a.ID == "perfect_day" && a.Earned)
+	}
+	if !perfect {
+		t.Errorf("expected a perfect day, got %+v", status.Achievements)
+	}
+}
+
+func TestRestoreActivity(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	// Without days they are rebuilt from the logs
+	now := time.Now()
+	var logs []*models.ReviewLog
+	for i := 0; i < streaks.DefaultGoal.Target; i++ {
+		logs = append(logs, &models.ReviewLog{Feedback: 1, ReviewedAt: now.AddDate(0, 0, -2), QuestionMs: 1000})
+	}
+	if err := sr.RestoreActivity(1, nil, logs); err != nil {
+		t.Fatalf("RestoreActivity failed: %v", err)
+	}
+	date := now.AddDate(0, 0, -2).Format(streaks.DateLayout)
+	var day models.DailyActivity
+	db.Where("user_id = 1 AND date = ?", date).First(&day)
+	if day.Reviews != streaks.DefaultGoal.Target || !day.GoalMet || day.StudyMs != int64(streaks.DefaultGoal.Target)*1000 {
+		t.Errorf("unexpected rebuilt day: %+v", day)
+	}
+
+	// Days already there keep the larger counts
+	err := sr.RestoreActivity(1, []*models.DailyActivity{
+		{Date: date, Reviews: 1, Correct: 1, Cleared: true},
+		{Date: now.AddDate(0, 0, -1).Format(streaks.DateLayout), Reviews: 3, Correct: 2},
+	}, logs)
+	if err != nil {
+		t.Fatalf("RestoreActivity failed: %v", err)
+	}
+	db.Where("user_id = 1 AND date = ?", date).First(&day)
+	if day.Reviews != streaks.DefaultGoal.Target || !day.GoalMet || !day.Cleared {
+		t.Errorf("unexpected merged day: %+v", day)
+	}
+	var count int64
+	db.Model(&models.DailyActivity{}).Where("user_id = 1").Count(&count)
+	if count != 2 {
+		t.Errorf("expected 2 days, got %d", count)
+	}
+}
@@ -0,0 +1,230 @@
+package spacedrepetition
+
+import (
+	"time"
+
+	"self-improvement/internal/models"
+)
+
+// Cards whose current interval reaches MatureDays are mature, shorter ones
+// are young, and cards scheduled less than a day out are still learning
+const MatureDays = 21
+
+// RetentionBuckets are the interval ranges retention is reported for, in
+// hours. The last bucket has no upper bound.
+var RetentionBuckets = []struct {
+	Label    string
+	MinHours float64
+}{
+	{"<1d", 0},
+	{"1-3d", 24},
+	{"3-7d", 72},
+	{"7-21d", 168},
+	{"21-60d", 504},
+	{">60d", 1440},
+}
+
+// HeatmapDay is the number of reviews made on one day
+type HeatmapDay struct {
+	Date    string `json:"date"`
+	Reviews int64  `json:"reviews"`
+	Correct int64  `json:"correct"`
+}
+
+// RetentionBucket is the share of reviews passed after an interval in the
+// bucket's range
+type RetentionBucket struct {
+	Label     string  `json:"label"`
+	MinHours  float64 `json:"min_hours"`
+	Reviews   int64   `json:"reviews"`
+	Passed    int64   `json:"passed"`
+	Retention float64 `json:"retention"`
+}
+
+// AccuracyPoint is the accuracy of one category in the week starting on Week
+type AccuracyPoint struct {
+	Week     string  `json:"week"`
+	Reviews  int64   `json:"reviews"`
+	Correct  int64   `json:"correct"`
+	Accuracy float64 `json:"accuracy"`
+}
+
+// CategoryTrend is the weekly accuracy of a top-level category
+type CategoryTrend struct {
+	Category string           `json:"category"`
+	Weeks    []*AccuracyPoint `json:"weeks"`
+}
+
+// LevelCount is the number of cards at a level
+type LevelCount struct {
+	Level int   `json:"level"`
+	Count int64 `json:"count"`
+}
+
+// Maturity splits cards by how far out they are scheduled
+type Maturity struct {
+	New      int64 `json:"new"`      // Never reviewed
+	Learning int64 `json:"learning"` // Interval under a day
+	Young    int64 `json:"young"`    // Interval under MatureDays
+	Mature   int64 `json:"mature"`
+}
+
+// Analytics is the user's learning history aggregated for charts
+type Analytics struct {
+	Heatmap       []*HeatmapDay      `json:"heatmap"`
+	Retention     []*RetentionBucket `json:"retention"`
+	AccuracyTrend []*CategoryTrend   `json:"accuracy_trend"`
+	Levels        []*LevelCount      `json:"levels"`
+	Maturity      Maturity           `json:"maturity"`
+}
+
+// GetAnalytics aggregates the user's reviews of the last days days into a
+// daily heatmap, the accuracy trend of the last weeks weeks, and retention
+// by interval over all reviews, together with the current level and
+// maturity of their cards. Every part is a single grouped query.
+func (sr *SpacedRepetition) GetAnalytics(userID uint, days, weeks int) (*Analytics, error) {
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	a := &Analytics{}
+	var err error
+
+	if a.Heatmap, err = sr.heatmap(userID, today.AddDate(0, 0, 1-days)); err != nil {
+		return nil, err
+	}
+	if a.Retention, err = sr.retention(userID); err != nil {
+		return nil, err
+	}
+	// Weeks start on Monday
+	monday := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+	if a.AccuracyTrend, err = sr.accuracyTrend(userID, monday.AddDate(0, 0, -7*(weeks-1))); err != nil {
+		return nil, err
+	}
+	if a.Levels, err = sr.levels(userID); err != nil {
+		return nil, err
+	}
+	if a.Maturity, err = sr.maturity(userID); err != nil {
+		return nil, err
+	}
+	return a, nil
+}
+
+// Times are stored with their offset, so the first ten characters are the
+// local date of the review
+const reviewDate = "substr(reviewed_at, 1, 10)"
+
+func (sr *SpacedRepetition) heatmap(userID uint, since time.Time) ([]*HeatmapDay, error) {
+	days := []*HeatmapDay{}
+	err := sr.DB.Model(&models.ReviewLog{}).
+		Select(reviewDate+` AS date, COUNT(*) AS reviews,
+			SUM(CASE WHEN feedback <= 2 THEN 1 ELSE 0 END) AS correct`).
+		Where("user_id = ? AND reviewed_at >= ?", userID, since).
+		Group("date").Order("date ASC").Scan(&days).Error
+	return days, err
+}
+
+// retention groups every review but a card's first by the interval the
+// previous review scheduled, and counts the reviews that passed
+func (sr *SpacedRepetition) retention(userID uint) ([]*RetentionBucket, error) {
+	bucket := "CASE"
+	args := []interface{}{}
+	for i := len(RetentionBuckets) - 1; i > 0; i-- {
+		bucket += " WHEN prev >= ? THEN ?"
+		args = append(args, RetentionBuckets[i].MinHours, i)
+	}
+	bucket += " ELSE 0 END"
+
+	var rows []struct {
+		Bucket  int
+		Reviews int64
+		Passed  int64
+	}
+	intervals := sr.DB.Model(&models.ReviewLog{}).
+		Select("feedback, LAG(interval_hours) OVER (PARTITION BY question_id ORDER BY reviewed_at, id) AS prev").
+		Where("user_id = ?", userID)
+	err := sr.DB.Table("(?) AS reviews", intervals).
+		Select(bucket+` AS bucket, COUNT(*) AS reviews,
+			SUM(CASE WHEN feedback <= 2 THEN 1 ELSE 0 END) AS passed`, args...).
+		Where("prev IS NOT NULL").Group("bucket").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	buckets := make([]*RetentionBucket, len(RetentionBuckets))
+	for i, b := range RetentionBuckets {
+		buckets[i] = &RetentionBucket{Label: b.Label, MinHours: b.MinHours}
+	}
+	for _, r := range rows {
+		b := buckets[r.Bucket]
+		b.Reviews, b.Passed = r.Reviews, r.Passed
+		if r.Reviews > 0 {
+			b.Retention = float64(r.Passed) / float64(r.Reviews)
+		}
+	}
+	return buckets, nil
+}
+
+func (sr *SpacedRepetition) accuracyTrend(userID uint, since time.Time) ([]*CategoryTrend, error) {
+	var rows []struct {
+		Category string
+		Week     string
+		Reviews  int64
+		Correct  int64
+	}
+	err := sr.DB.Table("review_logs").
+		Select(`substr(questions.category, 1, instr(questions.category || '/', '/') - 1) AS category,
+			date(`+reviewDate+`, '-6 days', 'weekday 1') AS week, COUNT(*) AS reviews,
+			SUM(CASE WHEN review_logs.feedback <= 2 THEN 1 ELSE 0 END) AS correct`).
+		Joins("JOIN questions ON questions.id = review_logs.question_id AND questions.deleted_at IS NULL").
+		Where("review_logs.user_id = ? AND review_logs.reviewed_at >= ?", userID, since).
+		Group("category, week").Order("category ASC, week ASC").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	trends := []*CategoryTrend{}
+	for _, r := range rows {
+		if len(trends) == 0 || trends[len(trends)-1].Category != r.Category {
+			trends = append(trends, &CategoryTrend{Category: r.Category})
+		}
+		t := trends[len(trends)-1]
+		p := &AccuracyPoint{Week: r.Week, Reviews: r.Reviews, Correct: r.Correct}
+		if r.Reviews > 0 {
+			p.Accuracy = float64(r.Correct) / float64(r.Reviews)
+		}
+		t.Weeks = append(t.Weeks, p)
+	}
+	return trends, nil
+}
+
+func (sr *SpacedRepetition) levels(userID uint) ([]*LevelCount, error) {
+	var rows []*LevelCount
+	err := sr.DB.Model(&models.Question{}).
+		Select("level, COUNT(*) AS count").
+		Where("user_id = ?", userID).Group("level").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+	levels := make([]*LevelCount, 4)
+	for i := range levels {
+		levels[i] = &LevelCount{Level: i + 1}
+	}
+	for _, r := range rows {
+		if r.Level >= 1 && r.Level <= 4 {
+			levels[r.Level-1].Count = r.Count
+		}
+	}
+	return levels, nil
+}
+
+func (sr *SpacedRepetition) maturity(userID uint) (Maturity, error) {
+	var m Maturity
+	err := sr.DB.Model(&models.Question{}).
+		Select(`COALESCE(SUM(CASE WHEN review_count = 0 THEN 1 ELSE 0 END), 0) AS new,
+			COALESCE(SUM(CASE WHEN review_count > 0 AND julianday(next_review) - julianday(last_reviewed) < 1 THEN 1 ELSE 0 END), 0) AS learning,
+			COALESCE(SUM(CASE WHEN review_count > 0 AND julianday(next_review) - julianday(last_reviewed) >= 1
+				AND julianday(next_review) - julianday(last_reviewed) < ? THEN 1 ELSE 0 END), 0) AS young,
+			COALESCE(SUM(CASE WHEN review_count > 0 AND julianday(next_review) - julianday(last_reviewed) >= ? THEN 1 ELSE 0 END), 0) AS mature`,
+			MatureDays, MatureDays).
+		Where("user_id = ?", userID).Scan(&m).Error
+	return m, err
+}
@@ -0,0 +1,92 @@
+package spacedrepetition
+
+import (
+	"testing"
+	"time"
+
+	"self-improvement/internal/models"
+)
+
+func TestGetAnalytics(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	sr.AddQuestion(1, "q_1_a", "问题 A", "答案 A", "a.md", "go/concurrency")
+	sr.AddQuestion(1, "q_1_b", "问题 B", "答案 B", "b.md", "linux")
+	sr.AddQuestion(1, "q_1_c", "问题 C", "答案 C", "c.md", "linux")
+	sr.AddQuestion(2, "q_2_a", "别人的问题", "答案", "a.md", "go")
+
+	now := time.Now()
+	day := func(n int) time.Time { return now.AddDate(0, 0, -n) }
+	logs := []models.ReviewLog{
+		// q_1_a: 2h after a 1d interval (failed), then passed after 2h
+		{QuestionID: "q_1_a", Feedback: 3, IntervalHours: 24, ReviewedAt: day(10)},
+		{QuestionID: "q_1_a", Feedback: 4, IntervalHours: 2, ReviewedAt: day(9)},
+		{QuestionID: "q_1_a", Feedback: 1, IntervalHours: 720, ReviewedAt: day(1)},
+		// q_1_b: passed after a 7d interval
+		{QuestionID: "q_1_b", Feedback: 1, IntervalHours: 168, ReviewedAt: day(8)},
+		{QuestionID: "q_1_b", Feedback: 2, IntervalHours: 200, ReviewedAt: day(1)},
+		// Outside the heatmap, but counted for retention
+		{QuestionID: "q_1_c", Feedback: 1, IntervalHours: 72, ReviewedAt: now.AddDate(-2, 0, 0)},
+		{QuestionID: "q_1_c", Feedback: 1, IntervalHours: 100, ReviewedAt: now.AddDate(-2, 0, 5)},
+	}
+	for i := range logs {
+		logs[i].UserID = 1
+		logs[i].Source = "app"
+	}
+	db.Create(&logs)
+	db.Create(&models.ReviewLog{UserID: 2, QuestionID: "q_2_a", Feedback: 4, ReviewedAt: day(1)})
+
+	// q_1_a is mature, q_1_b young and q_1_c new
+	last := day(1)
+	db.Model(&models.Question{}).Where("id = ?", "q_1_a").Updates(map[string]interface{}{
+		"review_count": 3, "level": 1, "last_reviewed": last, "next_review": last.Add(720 * time.Hour)})
+	db.Model(&models.Question{}).Where("id = ?", "q_1_b").Updates(map[string]interface{}{
+		"review_count": 2, "level": 2, "last_reviewed": last, "next_review": last.Add(200 * time.Hour)})
+
+	a, err := sr.GetAnalytics(1, 365, 12)
+	if err != nil {
+		t.Fatalf("GetAnalytics failed: %v", err)
+	}
+
+	if len(a.Heatmap) != 4 {
+		t.Fatalf("expected 4 days with reviews, got %+v", a.Heatmap)
+	}
+	latest := a.Heatmap[len(a.Heatmap)-1]
+	if latest.Date != day(1).Format("2006-01-02") || latest.Reviews != 2 || latest.Correct != 2 {
+		t.Errorf("unexpected latest day %+v", latest)
+	}
+
+	want := map[string][2]int64{"<1d": {1, 1}, "1-3d": {1, 0}, "3-7d": {1, 1}, "7-21d": {1, 1}, "21-60d": {0, 0}}
+	for _, b := range a.Retention {
+		if w, ok := want[b.Label]; ok && (b.Reviews != w[0] || b.Passed != w[1]) {
+			t.Errorf("bucket %s: expected %v, got %+v", b.Label, w, b)
+		}
+	}
+
+	if len(a.AccuracyTrend) != 2 || a.AccuracyTrend[0].Category != "go" || a.AccuracyTrend[1].Category != "linux" {
+		t.Fatalf("expected trends for go and linux, got %+v", a.AccuracyTrend)
+	}
+	var goReviews int64
+	for _, w := range a.AccuracyTrend[0].Weeks {
+		goReviews += w.Reviews
+		if d, err := time.Parse("2006-01-02", w.Week); err != nil || d.Weekday() != time.Monday {
+			t.Errorf("expected weeks starting on Monday, got %s", w.Week)
+		}
+	}
+	if goReviews != 3 {
+		t.Errorf("expected 3 go reviews, got %d", goReviews)
+	}
+
+	if a.Levels[0].Count != 1 || a.Levels[1].Count != 1 || a.Levels[3].Count != 1 {
+		t.Errorf("unexpected levels %+v %+v %+v %+v", a.Levels[0], a.Levels[1], a.Levels[2], a.Levels[3])
+	}
+	if a.Maturity != (Maturity{New: 1, Young: 1, Mature: 1}) {
+		t.Errorf("unexpected maturity %+v", a.Maturity)
+	}
+
+	empty, err := sr.GetAnalytics(3, 365, 12)
+	if err != nil || len(empty.Heatmap) != 0 || empty.Maturity != (Maturity{}) || len(empty.Retention) != len(RetentionBuckets) {
+		t.Errorf("unexpected analytics without data: %+v (%v)", empty, err)
+	}
+}
@@ -0,0 +1,101 @@
+package spacedrepetition
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+
+	"self-improvement/internal/models"
+)
+
+// CatalogSorts lists the orders of the deck catalog, the first is the default
+var CatalogSorts = []string{"popular", "cards", "newest", "title"}
+
+var catalogOrders = map[string]string{
+	"popular": "subscribers DESC, cards DESC",
+	"cards":   "cards DESC",
+	"newest":  "decks.created_at DESC",
+	"title":   "decks.title ASC",
+}
+
+// CatalogFilter selects and orders public decks
+type CatalogFilter struct {
+	Query  string // Matches title, description, tags and author
+	Tag    string
+	Sort   string // One of CatalogSorts
+	Offset int
+	Limit  int
+}
+
+// CatalogEntry is a deck with the numbers shown in the catalog
+type CatalogEntry struct {
+	models.Deck
+	Author      string `json:"author"`
+	Cards       int64  `json:"cards"`
+	Subscribers int64  `json:"subscribers"`
+}
+
+// catalogQuery selects decks with their author, card and subscriber counts
+func (sr *SpacedRepetition) catalogQuery() *gorm.DB {
+	return sr.DB.Table("decks").
+		Select(`decks.*, users.username AS author,
+			(SELECT COUNT(*) FROM questions q WHERE q.user_id = decks.user_id AND q.deck_id = 0 AND q.deleted_at IS NULL
+				AND (q.category = decks.category OR substr(q.category, 1, length(decks.category) + 1) = decks.category || '/')) AS cards,
+			(SELECT COUNT(*) FROM deck_subscriptions s WHERE s.deck_id = decks.id) AS subscribers`).
+		Joins("JOIN users ON users.id = decks.user_id AND users.deleted_at IS NULL")
+}
+
+// ListCatalog returns one page of public decks matching the filter together
+// with the total number of matches
+func (sr *SpacedRepetition) ListCatalog(f CatalogFilter) ([]*CatalogEntry, int64, error) {
+	query := sr.catalogQuery().Where("decks.public = ?", true)
+	if q := strings.TrimSpace(f.Query); q != "" {
+		pattern := "%" + escapeLike(q) + "%"
+		query = query.Where(`(decks.title LIKE ? ESCAPE '\' OR decks.description LIKE ? ESCAPE '\'
+			OR decks.tags LIKE ? ESCAPE '\' OR users.username LIKE ? ESCAPE '\')`, pattern, pattern, pattern, pattern)
+	}
+	if tag := strings.TrimSpace(f.Tag); tag != "" {
+		query = query.Where(`',' || decks.tags || ',' LIKE ? ESCAPE '\'`, "%,"+escapeLike(tag)+",%")
+	}
+
+	var total int64
+	if err := sr.DB.Table("(?) AS catalog", query).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	order, ok := catalogOrders[f.Sort]
+	if !ok {
+		order = catalogOrders[CatalogSorts[0]]
+	}
+	var entries []*CatalogEntry
+	err := query.Order(order).Order("decks.id ASC").Offset(f.Offset).Limit(f.Limit).Scan(&entries).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return entries, total, nil
+}
+
+// GetCatalogEntry returns a deck with its catalog numbers, public or not
+func (sr *SpacedRepetition) GetCatalogEntry(id uint) (*CatalogEntry, error) {
+	var entries []*CatalogEntry
+	if err := sr.catalogQuery().Where("decks.id = ?", id).Scan(&entries).Error; err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, gorm.ErrRecordNotFound
+	}
+	return entries[0], nil
+}
+
+// SampleCards returns up to n of a deck's cards, oldest first
+func (sr *SpacedRepetition) SampleCards(d *models.Deck, n int) ([]*models.Question, error) {
+	var questions []*models.Question
+	err := inCategories(sr.DB.Where("user_id = ? AND deck_id = 0", d.UserID), "category", []string{d.Category}).
+		Order("created_at ASC").Order("id ASC").Limit(n).Find(&questions).Error
+	return questions, err
+}
+
+// escapeLike escapes the LIKE wildcards in s for use with ESCAPE '\'
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
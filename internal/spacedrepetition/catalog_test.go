@@ -0,0 +1,82 @@
+package spacedrepetition
+
+import (
+	"testing"
+
+	"self-improvement/internal/models"
+)
+
+func TestListCatalog(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	db.Create(&models.User{ID: 1, Username: "alice", Password: "x"})
+	db.Create(&models.User{ID: 2, Username: "bob", Password: "x"})
+	db.Create(&models.User{ID: 3, Username: "carol", Password: "x"})
+	sr.AddQuestion(1, "q1", "Q1", "A1", "a.md", "go")
+	sr.AddQuestion(1, "q2", "Q2", "A2", "a.md", "go/concurrency")
+	sr.AddQuestion(1, "q3", "Q3", "A3", "a.md", "go_old") // Not below go
+	sr.AddQuestion(2, "q4", "Q4", "A4", "a.md", "rust")
+	sr.AddQuestion(2, "q5", "Q5", "A5", "a.md", "secret")
+
+	goDeck := &models.Deck{UserID: 1, Category: "go", Title: "Go 基础", Tags: "go,后端", Public: true}
+	rustDeck := &models.Deck{UserID: 2, Category: "rust", Title: "Rust 入门", Description: "所有权与借用", Tags: "rust", Public: true}
+	hidden := &models.Deck{UserID: 2, Category: "secret", Title: "私有", Public: false}
+	for _, d := range []*models.Deck{goDeck, rustDeck, hidden} {
+		if err := sr.PublishDeck(d); err != nil {
+			t.Fatalf("PublishDeck failed: %v", err)
+		}
+	}
+	sr.Subscribe(3, rustDeck, "rust")
+
+	entries, total, err := sr.ListCatalog(CatalogFilter{Limit: 10})
+	if err != nil {
+		t.Fatalf("ListCatalog failed: %v", err)
+	}
+	if total != 2 || len(entries) != 2 {
+		t.Fatalf("expected 2 public decks, got %d", total)
+	}
+	// Most subscribers first
+	if entries[0].ID != rustDeck.ID || entries[0].Subscribers != 1 || entries[0].Author != "bob" {
+		t.Errorf("unexpected first entry: %+v", entries[0])
+	}
+	if entries[1].Cards != 2 {
+		t.Errorf("expected 2 cards in the go deck, got %d", entries[1].Cards)
+	}
+
+	entries, _, _ = sr.ListCatalog(CatalogFilter{Sort: "cards", Limit: 10})
+	if entries[0].ID != goDeck.ID {
+		t.Errorf("expected the go deck first by cards, got %s", entries[0].Title)
+	}
+	entries, _, _ = sr.ListCatalog(CatalogFilter{Sort: "title", Limit: 1, Offset: 1})
+	if len(entries) != 1 || entries[0].ID != rustDeck.ID {
+		t.Errorf("unexpected second page: %+v", entries)
+	}
+
+	for _, tt := range []struct {
+		filter CatalogFilter
+		want   int64
+	}{
+		{CatalogFilter{Query: "借用"}, 1},
+		{CatalogFilter{Query: "alice"}, 1},
+		{CatalogFilter{Query: "后端"}, 1},
+		{CatalogFilter{Query: "%"}, 0},
+		{CatalogFilter{Tag: "go"}, 1},
+		{CatalogFilter{Tag: "后"}, 0},
+		{CatalogFilter{Query: "私有"}, 0},
+	} {
+		tt.filter.Limit = 10
+		if _, total, err := sr.ListCatalog(tt.filter); err != nil || total != tt.want {
+			t.Errorf("ListCatalog(%+v) = %d (%v), want %d", tt.filter, total, err, tt.want)
+		}
+	}
+
+	e, err := sr.GetCatalogEntry(hidden.ID)
+	if err != nil || e.Public || e.Cards != 1 {
+		t.Errorf("unexpected hidden entry: %+v (%v)", e, err)
+	}
+	samples, _ := sr.SampleCards(goDeck, 5)
+	if len(samples) != 2 || samples[0].ID != "q1" {
+		t.Errorf("unexpected samples: %+v", samples)
+	}
+}
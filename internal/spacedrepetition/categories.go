@@ -0,0 +1,380 @@
+package spacedrepetition
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"self-improvement/internal/models"
+)
+
+// DefaultCategory holds questions without a category
+const DefaultCategory = "未分类"
+
+// CategorySeparator separates the levels of a category path such as
+// "06_career/07_八股文/cpp"
+const CategorySeparator = "/"
+
+// Interval multiplier bounds for categories
+const (
+	MinIntervalMultiplier = 0.1
+	MaxIntervalMultiplier = 10
+)
+
+var (
+	// ErrCategoryExists is returned when a category name is already taken
+	ErrCategoryExists = errors.New("category already exists")
+	// ErrCategoryCycle is returned when a category would be moved below itself
+	ErrCategoryCycle = errors.New("category cannot be moved below itself")
+	// ErrCategoryPublished is returned when deleting or merging away a
+	// category that is, or contains, a published deck
+	ErrCategoryPublished = errors.New("category is published as a deck")
+)
+
+// CategoryNode is a category in the user's category tree. Total, Due and
+// Mastery include all descendants, OwnTotal and OwnDue only the category
+// itself.
+type CategoryNode struct {
+	*models.Category
+	Parent   string          `json:"parent"`
+	Depth    int             `json:"depth"`
+	OwnTotal int64           `json:"own_total"`
+	OwnDue   int64           `json:"own_due"`
+	Total    int64           `json:"total"`
+	Due      int64           `json:"due"`
+	Mastery  MasteryStats    `json:"mastery"`
+	Children []*CategoryNode `json:"children"`
+}
+
+// CategoryParent returns the parent path of a category, "" for a top-level one
+func CategoryParent(name string) string {
+	if i := strings.LastIndex(name, CategorySeparator); i >= 0 {
+		return name[:i]
+	}
+	return ""
+}
+
+// CategoryAncestors returns the paths above a category, outermost first
+func CategoryAncestors(name string) []string {
+	var ancestors []string
+	for i, r := range name {
+		if string(r) == CategorySeparator {
+			ancestors = append(ancestors, name[:i])
+		}
+	}
+	return ancestors
+}
+
+// inCategories matches questions in any of the categories or below them
+func inCategories(db *gorm.DB, column string, categories []string) *gorm.DB {
+	var conds []string
+	var args []interface{}
+	conds = append(conds, column+" IN ?")
+	args = append(args, categories)
+	for _, c := range categories {
+		prefix := c + CategorySeparator
+		conds = append(conds, "substr("+column+", 1, ?) = ?")
+		args = append(args, utf8.RuneCountInString(prefix), prefix)
+	}
+	return db.Where("("+strings.Join(conds, " OR ")+")", args...)
+}
+
+// EnsureCategories creates category rows for categories, and their parents,
+// that so far only exist on questions. label returns the initial label for a
+// name.
+func (sr *SpacedRepetition) EnsureCategories(userID uint, label func(name string) string) error {
+	var names []string
+	err := sr.DB.Model(&models.Question{}).
+		Where("user_id = ? AND category != '' AND category NOT IN (?)", userID,
+			sr.DB.Model(&models.Category{}).Select("name").Where("user_id = ?", userID)).
+		Distinct().Pluck("category", &names).Error
+	if err != nil || len(names) == 0 {
+		return err
+	}
+
+	seen := make(map[string]bool)
+	var rows []models.Category
+	for _, name := range names {
+		for _, n := range append(CategoryAncestors(name), name) {
+			if !seen[n] {
+				seen[n] = true
+				rows = append(rows, models.Category{UserID: userID, Name: n, Label: label(n), IntervalMultiplier: 1})
+			}
+		}
+	}
+	return sr.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&rows).Error
+}
+
+// ListCategories returns the user's categories in display order
+func (sr *SpacedRepetition) ListCategories(userID uint) ([]*models.Category, error) {
+	var categories []*models.Category
+	err := sr.DB.Where("user_id = ?", userID).Order("sort_order ASC, name ASC").Find(&categories).Error
+	return categories, err
+}
+
+// CategoryTree returns the user's categories as a tree with question counts
+// rolled up to the parents. all lists every node, parents before children.
+func (sr *SpacedRepetition) CategoryTree(userID uint) (roots, all []*CategoryNode, err error) {
+	rows, err := sr.ListCategories(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+	var counts []struct {
+		Category string
+		Total    int64
+		Due      int64
+	}
+	err = sr.DB.Model(&models.Question{}).
+		Select("category, COUNT(*) AS total, SUM(CASE WHEN next_review <= ? THEN 1 ELSE 0 END) AS due", time.Now()).
+		Where("user_id = ? AND category != ''", userID).
+		Group("category").
+		Find(&counts).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	mastery, err := sr.categoryMastery(userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nodes := make(map[string]*CategoryNode)
+	var node func(c *models.Category) *CategoryNode
+	node = func(c *models.Category) *CategoryNode {
+		if n, ok := nodes[c.Name]; ok {
+			return n
+		}
+		n := &CategoryNode{Category: c, Parent: CategoryParent(c.Name), Children: []*CategoryNode{}}
+		n.Depth = len(CategoryAncestors(c.Name))
+		nodes[c.Name] = n
+		if n.Parent == "" {
+			roots = append(roots, n)
+		} else {
+			parent, ok := nodes[n.Parent]
+			if !ok {
+				// Parents normally have rows, see EnsureCategories
+				parent = node(&models.Category{UserID: userID, Name: n.Parent, Label: n.Parent, IntervalMultiplier: 1})
+			}
+			parent.Children = append(parent.Children, n)
+		}
+		return n
+	}
+	// Parents first so that children keep the display order below them
+	byDepth := make([]*models.Category, len(rows))
+	copy(byDepth, rows)
+	sort.SliceStable(byDepth, func(i, j int) bool {
+		return strings.Count(byDepth[i].Name, CategorySeparator) < strings.Count(byDepth[j].Name, CategorySeparator)
+	})
+	for _, c := range byDepth {
+		node(c)
+	}
+	for _, cnt := range counts {
+		n, ok := nodes[cnt.Category]
+		if !ok {
+			n = node(&models.Category{UserID: userID, Name: cnt.Category, Label: cnt.Category, IntervalMultiplier: 1})
+		}
+		n.OwnTotal, n.OwnDue = cnt.Total, cnt.Due
+		if m, ok := mastery[cnt.Category]; ok {
+			n.Mastery = *m
+		}
+	}
+
+	var walk func(n *CategoryNode)
+	walk = func(n *CategoryNode) {
+		all = append(all, n)
+		n.Total, n.Due = n.OwnTotal, n.OwnDue
+		for _, child := range n.Children {
+			walk(child)
+			n.Total += child.Total
+			n.Due += child.Due
+			n.Mastery.merge(&child.Mastery)
+		}
+	}
+	for _, root := range roots {
+		walk(root)
+	}
+	return roots, all, nil
+}
+
+// GetCategory returns one of the user's categories
+func (sr *SpacedRepetition) GetCategory(userID, id uint) (*models.Category, error) {
+	var c models.Category
+	if err := sr.DB.Where("user_id = ? AND id = ?", userID, id).First(&c).Error; err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// CreateCategory adds a category. Existing questions with the same category
+// name belong to it right away.
+func (sr *SpacedRepetition) CreateCategory(c *models.Category) error {
+	var count int64
+	sr.DB.Model(&models.Category{}).Where("user_id = ? AND name = ?", c.UserID, c.Name).Count(&count)
+	if count > 0 {
+		return ErrCategoryExists
+	}
+	return sr.DB.Create(c).Error
+}
+
+// UpdateCategory saves a category's settings. A changed name renames the
+// category on all of its questions and moves its subcategories along.
+func (sr *SpacedRepetition) UpdateCategory(c *models.Category) error {
+	return sr.DB.Transaction(func(tx *gorm.DB) error {
+		var old models.Category
+		if err := tx.Where("user_id = ? AND id = ?", c.UserID, c.ID).First(&old).Error; err != nil {
+			return err
+		}
+		if c.Name != old.Name {
+			if strings.HasPrefix(c.Name, old.Name+CategorySeparator) {
+				return ErrCategoryCycle
+			}
+			var count int64
+			tx.Model(&models.Category{}).Where("user_id = ? AND name = ?", c.UserID, c.Name).Count(&count)
+			if count > 0 {
+				return ErrCategoryExists
+			}
+			if err := moveCategory(tx, c.UserID, old.Name, c.Name); err != nil {
+				return err
+			}
+		}
+		return tx.Save(c).Error
+	})
+}
+
+// MergeCategories moves all questions and subcategories of category from into
+// category into and deletes from. Published categories cannot be merged away,
+// their subscribers would lose every card.
+func (sr *SpacedRepetition) MergeCategories(userID, from, into uint) error {
+	return sr.DB.Transaction(func(tx *gorm.DB) error {
+		var src, dst models.Category
+		if err := tx.Where("user_id = ? AND id = ?", userID, from).First(&src).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ? AND id = ?", userID, into).First(&dst).Error; err != nil {
+			return err
+		}
+		if strings.HasPrefix(dst.Name, src.Name+CategorySeparator) {
+			return ErrCategoryCycle
+		}
+		if published(tx, userID, src.Name) {
+			return ErrCategoryPublished
+		}
+		if err := moveCategory(tx, userID, src.Name, dst.Name); err != nil {
+			return err
+		}
+		return tx.Delete(&src).Error
+	})
+}
+
+// DeleteCategory deletes a category with its subcategories. Their questions
+// are kept and moved to the category named moveTo, which is created if
+// needed. Published categories have to be unpublished first.
+func (sr *SpacedRepetition) DeleteCategory(userID, id uint, moveTo string) error {
+	return sr.DB.Transaction(func(tx *gorm.DB) error {
+		var c models.Category
+		if err := tx.Where("user_id = ? AND id = ?", userID, id).First(&c).Error; err != nil {
+			return err
+		}
+		if moveTo == c.Name || strings.HasPrefix(moveTo, c.Name+CategorySeparator) {
+			return ErrCategoryCycle
+		}
+		if published(tx, userID, c.Name) {
+			return ErrCategoryPublished
+		}
+		if err := ensureCategory(tx, userID, moveTo); err != nil {
+			return err
+		}
+		scope := []string{c.Name}
+		err := inCategories(tx.Model(&models.Question{}).Where("user_id = ?", userID), "category", scope).
+			Update("category", moveTo).Error
+		if err != nil {
+			return err
+		}
+		return inCategories(tx.Where("user_id = ?", userID), "name", scope).Delete(&models.Category{}).Error
+	})
+}
+
+// MoveQuestions sets the category of the given questions, creating the
+// category if needed. It returns the number of questions moved.
+func (sr *SpacedRepetition) MoveQuestions(userID uint, ids []string, category string) (int64, error) {
+	var moved int64
+	err := sr.DB.Transaction(func(tx *gorm.DB) error {
+		if err := ensureCategory(tx, userID, category); err != nil {
+			return err
+		}
+		result := tx.Model(&models.Question{}).
+			Where("user_id = ? AND id IN ?", userID, ids).
+			Update("category", category)
+		moved = result.RowsAffected
+		return result.Error
+	})
+	return moved, err
+}
+
+// intervalMultiplier returns the category's interval multiplier, 1 if the
+// category has no settings
+func intervalMultiplier(tx *gorm.DB, userID uint, category string) float64 {
+	var c models.Category
+	if err := tx.Where("user_id = ? AND name = ?", userID, category).Limit(1).Find(&c).Error; err != nil || c.ID == 0 {
+		return 1
+	}
+	if c.IntervalMultiplier < MinIntervalMultiplier || c.IntervalMultiplier > MaxIntervalMultiplier {
+		return 1
+	}
+	return c.IntervalMultiplier
+}
+
+func ensureCategory(tx *gorm.DB, userID uint, name string) error {
+	return tx.Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&models.Category{UserID: userID, Name: name, Label: name, IntervalMultiplier: 1}).Error
+}
+
+// published tells whether the user published category or one of its
+// subcategories as a deck
+func published(tx *gorm.DB, userID uint, category string) bool {
+	var count int64
+	inCategories(tx.Model(&models.Deck{}).Where("user_id = ?", userID), "category", []string{category}).Count(&count)
+	return count > 0
+}
+
+// moveCategory renames category from to to on questions, published decks and
+// subcategory rows. Subcategories that already exist below to absorb the
+// moved ones. The row of from itself is left to the caller.
+func moveCategory(tx *gorm.DB, userID uint, from, to string) error {
+	scope := []string{from}
+	rename := gorm.Expr("? || substr(category, ?)", to, utf8.RuneCountInString(from)+1)
+	err := inCategories(tx.Model(&models.Question{}).Where("user_id = ?", userID), "category", scope).
+		Update("category", rename).Error
+	if err != nil {
+		return err
+	}
+	// Subscriptions look up the deck's cards by its category
+	err = inCategories(tx.Model(&models.Deck{}).Where("user_id = ?", userID), "category", scope).
+		Update("category", rename).Error
+	if err != nil {
+		return err
+	}
+
+	var rows []models.Category
+	if err := inCategories(tx.Where("user_id = ? AND name != ?", userID, from), "name", scope).Find(&rows).Error; err != nil {
+		return err
+	}
+	for _, row := range rows {
+		name := to + strings.TrimPrefix(row.Name, from)
+		var count int64
+		tx.Model(&models.Category{}).Where("user_id = ? AND name = ?", userID, name).Count(&count)
+		if count > 0 {
+			err = tx.Delete(&row).Error
+		} else {
+			err = tx.Model(&row).Update("name", name).Error
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
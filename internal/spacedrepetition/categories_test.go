@@ -0,0 +1,265 @@
+package spacedrepetition
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"self-improvement/internal/models"
+)
+
+func categoryCount(t *testing.T, sr *SpacedRepetition, userID uint, category string) int64 {
+	t.Helper()
+	var n int64
+	sr.DB.Model(&models.Question{}).Where("user_id = ? AND category = ?", userID, category).Count(&n)
+	return n
+}
+
+func TestEnsureCategories(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	sr.AddQuestion(1, "q1", "Q1", "A1", "a.md", "go")
+	sr.AddQuestion(1, "q2", "Q2", "A2", "b.md", "go")
+	sr.AddQuestion(1, "q3", "Q3", "A3", "c.md", "rust")
+	sr.AddQuestion(2, "q4", "Q4", "A4", "d.md", "java")
+	sr.CreateCategory(&models.Category{UserID: 1, Name: "go", Label: "Go 语言"})
+
+	label := func(name string) string { return "L-" + name }
+	if err := sr.EnsureCategories(1, label); err != nil {
+		t.Fatalf("EnsureCategories failed: %v", err)
+	}
+	// A second run must not duplicate rows
+	if err := sr.EnsureCategories(1, label); err != nil {
+		t.Fatalf("EnsureCategories failed: %v", err)
+	}
+
+	categories, err := sr.ListCategories(1)
+	if err != nil {
+		t.Fatalf("ListCategories failed: %v", err)
+	}
+	if len(categories) != 2 {
+		t.Fatalf("expected 2 categories, got %d", len(categories))
+	}
+	if categories[0].Name != "go" || categories[0].Label != "Go 语言" {
+		t.Errorf("existing category changed: %+v", categories[0])
+	}
+	if categories[1].Name != "rust" || categories[1].Label != "L-rust" || categories[1].IntervalMultiplier != 1 {
+		t.Errorf("unexpected created category: %+v", categories[1])
+	}
+}
+
+func TestCreateCategoryDuplicate(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	if err := sr.CreateCategory(&models.Category{UserID: 1, Name: "go"}); err != nil {
+		t.Fatalf("CreateCategory failed: %v", err)
+	}
+	if err := sr.CreateCategory(&models.Category{UserID: 1, Name: "go"}); !errors.Is(err, ErrCategoryExists) {
+		t.Errorf("expected ErrCategoryExists, got %v", err)
+	}
+	// Names are per user
+	if err := sr.CreateCategory(&models.Category{UserID: 2, Name: "go"}); err != nil {
+		t.Errorf("CreateCategory for another user failed: %v", err)
+	}
+}
+
+func TestRenameMergeDeleteCategory(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	sr.AddQuestion(1, "q1", "Q1", "A1", "a.md", "go")
+	sr.AddQuestion(1, "q2", "Q2", "A2", "b.md", "golang")
+	sr.AddQuestion(2, "q3", "Q3", "A3", "c.md", "go")
+	sr.EnsureCategories(1, func(name string) string { return name })
+	categories, _ := sr.ListCategories(1)
+	goCat, golangCat := categories[0], categories[1]
+
+	// Renaming onto an existing name is refused
+	goCat.Name = "golang"
+	if err := sr.UpdateCategory(goCat); !errors.Is(err, ErrCategoryExists) {
+		t.Fatalf("expected ErrCategoryExists, got %v", err)
+	}
+
+	goCat.Name = "Go"
+	if err := sr.UpdateCategory(goCat); err != nil {
+		t.Fatalf("UpdateCategory failed: %v", err)
+	}
+	if categoryCount(t, sr, 1, "Go") != 1 || categoryCount(t, sr, 1, "go") != 0 {
+		t.Error("rename did not move the user's questions")
+	}
+	if categoryCount(t, sr, 2, "go") != 1 {
+		t.Error("rename touched another user's questions")
+	}
+
+	if err := sr.MergeCategories(1, golangCat.ID, goCat.ID); err != nil {
+		t.Fatalf("MergeCategories failed: %v", err)
+	}
+	if categoryCount(t, sr, 1, "Go") != 2 {
+		t.Error("merge did not move questions")
+	}
+	if _, err := sr.GetCategory(1, golangCat.ID); err == nil {
+		t.Error("merged category still exists")
+	}
+
+	if err := sr.DeleteCategory(1, goCat.ID, DefaultCategory); err != nil {
+		t.Fatalf("DeleteCategory failed: %v", err)
+	}
+	if categoryCount(t, sr, 1, DefaultCategory) != 2 {
+		t.Error("delete did not keep the questions")
+	}
+	categories, _ = sr.ListCategories(1)
+	if len(categories) != 1 || categories[0].Name != DefaultCategory {
+		t.Errorf("expected only the default category, got %+v", categories)
+	}
+}
+
+func TestMoveQuestions(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	sr.AddQuestion(1, "q1", "Q1", "A1", "a.md", "go")
+	sr.AddQuestion(1, "q2", "Q2", "A2", "a.md", "go")
+	sr.AddQuestion(2, "q3", "Q3", "A3", "a.md", "go")
+
+	moved, err := sr.MoveQuestions(1, []string{"q1", "q3", "missing"}, "basics")
+	if err != nil {
+		t.Fatalf("MoveQuestions failed: %v", err)
+	}
+	if moved != 1 {
+		t.Errorf("expected 1 moved question, got %d", moved)
+	}
+	if categoryCount(t, sr, 1, "basics") != 1 || categoryCount(t, sr, 2, "go") != 1 {
+		t.Error("unexpected categories after move")
+	}
+	categories, _ := sr.ListCategories(1)
+	if len(categories) != 1 || categories[0].Name != "basics" {
+		t.Errorf("target category was not created: %+v", categories)
+	}
+}
+
+func TestIntervalMultiplier(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	sr.AddQuestion(1, "q1", "Q1", "A1", "a.md", "hard")
+	sr.AddQuestion(1, "q2", "Q2", "A2", "a.md", "plain")
+	sr.CreateCategory(&models.Category{UserID: 1, Name: "hard", IntervalMultiplier: 0.5})
+
+	sr.UpdateReview(1, "q1", 3)
+	sr.UpdateReview(1, "q2", 3)
+
+	var logs []models.ReviewLog
+	db.Order("question_id").Find(&logs)
+	if len(logs) != 2 {
+		t.Fatalf("expected 2 review logs, got %d", len(logs))
+	}
+	if logs[0].IntervalHours*2 != logs[1].IntervalHours {
+		t.Errorf("expected half interval for the hard category, got %v and %v", logs[0].IntervalHours, logs[1].IntervalHours)
+	}
+}
+
+func TestCategoryTree(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	sr.AddQuestion(1, "q1", "Q1", "A1", "a.md", "06_career/07_八股文/cpp")
+	sr.AddQuestion(1, "q2", "Q2", "A2", "a.md", "06_career/07_八股文/cpp")
+	sr.AddQuestion(1, "q3", "Q3", "A3", "a.md", "06_career/07_八股文")
+	sr.AddQuestion(1, "q4", "Q4", "A4", "a.md", "06_career/interview")
+	sr.AddQuestion(1, "q5", "Q5", "A5", "a.md", "go")
+	sr.UpdateReview(1, "q1", 1) // No longer due
+	if err := sr.EnsureCategories(1, func(name string) string { return name }); err != nil {
+		t.Fatalf("EnsureCategories failed: %v", err)
+	}
+
+	roots, all, err := sr.CategoryTree(1)
+	if err != nil {
+		t.Fatalf("CategoryTree failed: %v", err)
+	}
+	if len(roots) != 2 || len(all) != 5 {
+		t.Fatalf("expected 2 roots and 5 nodes, got %d and %d", len(roots), len(all))
+	}
+	career := roots[0]
+	if career.Name != "06_career" || career.OwnTotal != 0 || career.Total != 4 || career.Due != 3 {
+		t.Errorf("unexpected roll-up: %+v", career)
+	}
+	if len(career.Children) != 2 || career.Children[0].Name != "06_career/07_八股文" {
+		t.Fatalf("unexpected children: %+v", career.Children)
+	}
+	bagu := career.Children[0]
+	if bagu.Parent != "06_career" || bagu.Depth != 1 || bagu.OwnTotal != 1 || bagu.Total != 3 || bagu.Due != 2 {
+		t.Errorf("unexpected node: %+v", bagu)
+	}
+	for i, n := range all[:3] {
+		want := []string{"06_career", "06_career/07_八股文", "06_career/07_八股文/cpp"}[i]
+		if n.Name != want {
+			t.Errorf("all[%d] = %s, want %s", i, n.Name, want)
+		}
+	}
+}
+
+func TestCategoryDescendants(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	sr.AddQuestion(1, "q1", "Q1", "A1", "a.md", "a/b")
+	sr.AddQuestion(1, "q2", "Q2", "A2", "a.md", "a")
+	sr.AddQuestion(1, "q3", "Q3", "A3", "a.md", "ab")
+	sr.AddQuestion(1, "q4", "Q4", "A4", "a.md", "0_x/y")
+	sr.AddQuestion(1, "q5", "Q5", "A5", "a.md", "01x")
+
+	due, err := sr.GetDueQuestionsByCategories(1, []string{"a"})
+	if err != nil {
+		t.Fatalf("GetDueQuestionsByCategories failed: %v", err)
+	}
+	if len(due) != 2 {
+		t.Errorf("expected a and a/b, got %d questions", len(due))
+	}
+	// "_" must not act as a wildcard
+	if due, _ := sr.GetDueQuestionsByCategory(1, "0_x"); len(due) != 1 {
+		t.Errorf("expected only 0_x/y, got %d questions", len(due))
+	}
+	if _, total, _ := sr.ListQuestions(1, QuestionFilter{Category: "a"}); total != 2 {
+		t.Errorf("expected 2 listed questions, got %d", total)
+	}
+
+	// Renaming a parent moves the subtree
+	sr.EnsureCategories(1, func(name string) string { return name })
+	categories, _ := sr.ListCategories(1)
+	var a *models.Category
+	for _, c := range categories {
+		if c.Name == "a" {
+			a = c
+		}
+	}
+	a.Name = "a/b/c"
+	if err := sr.UpdateCategory(a); !errors.Is(err, ErrCategoryCycle) {
+		t.Errorf("expected ErrCategoryCycle, got %v", err)
+	}
+	a.Name = "z"
+	if err := sr.UpdateCategory(a); err != nil {
+		t.Fatalf("UpdateCategory failed: %v", err)
+	}
+	if categoryCount(t, sr, 1, "z/b") != 1 || categoryCount(t, sr, 1, "z") != 1 || categoryCount(t, sr, 1, "ab") != 1 {
+		t.Error("rename did not move exactly the subtree")
+	}
+	var names []string
+	db.Model(&models.Category{}).Where("user_id = 1").Order("name").Pluck("name", &names)
+	if strings.Join(names, ",") != "01x,0_x,0_x/y,ab,z,z/b" {
+		t.Errorf("unexpected category rows: %v", names)
+	}
+
+	// Deleting removes the subtree and keeps its questions
+	if err := sr.DeleteCategory(1, a.ID, DefaultCategory); err != nil {
+		t.Fatalf("DeleteCategory failed: %v", err)
+	}
+	if categoryCount(t, sr, 1, DefaultCategory) != 2 {
+		t.Error("delete did not move subtree questions")
+	}
+	db.Model(&models.Category{}).Where("user_id = 1").Order("name").Pluck("name", &names)
+	if strings.Join(names, ",") != "01x,0_x,0_x/y,ab,未分类" {
+		t.Errorf("unexpected category rows after delete: %v", names)
+	}
+}
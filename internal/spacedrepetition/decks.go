@@ -0,0 +1,399 @@
+package spacedrepetition
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"self-improvement/internal/models"
+)
+
+const shareCodeAlphabet = "ABCDEFGHJKLMNPQRSTUVWXYZ23456789"
+
+// ShareCodeLength is the length of deck share codes
+const ShareCodeLength = 8
+
+var (
+	// ErrDeckExists is returned when a category is already published
+	ErrDeckExists = errors.New("category already published")
+	// ErrAlreadySubscribed is returned when subscribing to a deck twice
+	ErrAlreadySubscribed = errors.New("already subscribed")
+	// ErrOwnDeck is returned when subscribing to one's own deck
+	ErrOwnDeck = errors.New("cannot subscribe to own deck")
+	// ErrSharedCard is returned when changing the content of a subscribed card
+	ErrSharedCard = errors.New("shared cards are read-only")
+)
+
+// DeckChanges counts the cards a subscription refresh changed
+type DeckChanges struct {
+	Added   int `json:"added"`   // New cards
+	Linked  int `json:"linked"`  // Own questions with the same text that became deck cards
+	Removed int `json:"removed"` // Cards the author removed from the deck
+}
+
+// DeckSource is the source of the cards of a subscribed deck
+func DeckSource(deckID uint) string {
+	return fmt.Sprintf("deck:%d", deckID)
+}
+
+func newShareCode() (string, error) {
+	b := make([]byte, ShareCodeLength)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	for i := range b {
+		b[i] = shareCodeAlphabet[int(b[i])%len(shareCodeAlphabet)]
+	}
+	return string(b), nil
+}
+
+// PublishDeck publishes the author's category as a deck with a new share code
+func (sr *SpacedRepetition) PublishDeck(d *models.Deck) error {
+	var count int64
+	sr.DB.Model(&models.Deck{}).Where("user_id = ? AND category = ?", d.UserID, d.Category).Count(&count)
+	if count > 0 {
+		return ErrDeckExists
+	}
+	code, err := newShareCode()
+	if err != nil {
+		return err
+	}
+	d.ShareCode = code
+	return sr.DB.Create(d).Error
+}
+
+// UpdateDeck saves a deck's title, description, tags and visibility
+func (sr *SpacedRepetition) UpdateDeck(d *models.Deck) error {
+	return sr.DB.Model(d).Select("title", "description", "tags", "public").Updates(d).Error
+}
+
+// ListDecks returns the decks the user published
+func (sr *SpacedRepetition) ListDecks(userID uint) ([]*models.Deck, error) {
+	var decks []*models.Deck
+	err := sr.DB.Where("user_id = ?", userID).Order("id ASC").Find(&decks).Error
+	return decks, err
+}
+
+// GetDeckByCode returns the deck with the given share code
+func (sr *SpacedRepetition) GetDeckByCode(code string) (*models.Deck, error) {
+	var d models.Deck
+	if err := sr.DB.Where("share_code = ?", strings.ToUpper(strings.TrimSpace(code))).First(&d).Error; err != nil {
+		return nil, err
+	}
+	return &d, nil
+}
+
+// DeckCards returns the number of cards in a deck
+func (sr *SpacedRepetition) DeckCards(d *models.Deck) int64 {
+	var count int64
+	inCategories(sr.DB.Model(&models.Question{}).Where("user_id = ? AND deck_id = 0", d.UserID), "category", []string{d.Category}).
+		Count(&count)
+	return count
+}
+
+// DeckSubscribers returns the number of subscribers of a deck
+func (sr *SpacedRepetition) DeckSubscribers(deckID uint) int64 {
+	var count int64
+	sr.DB.Model(&models.DeckSubscription{}).Where("deck_id = ?", deckID).Count(&count)
+	return count
+}
+
+// UnpublishDeck deletes one of the author's decks. Subscribers keep their
+// cards and progress as their own copies. Team assignments of the deck are
+// removed.
+func (sr *SpacedRepetition) UnpublishDeck(userID, id uint) error {
+	return sr.DB.Transaction(func(tx *gorm.DB) error {
+		var d models.Deck
+		if err := tx.Where("user_id = ? AND id = ?", userID, id).First(&d).Error; err != nil {
+			return err
+		}
+
+		var cards []*models.Question
+		if err := tx.Unscoped().Where("deck_id = ?", d.ID).Find(&cards).Error; err != nil {
+			return err
+		}
+		if err := fillSharedContent(tx, cards); err != nil {
+			return err
+		}
+		for _, q := range cards {
+			err := tx.Unscoped().Model(&models.Question{}).Where("id = ?", q.ID).Updates(map[string]interface{}{
+				"question_text": q.QuestionText,
+				"answer_text":   q.AnswerText,
+				"deck_id":       0,
+				"origin_id":     "",
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+
+		if err := tx.Where("deck_id = ?", d.ID).Delete(&models.DeckSubscription{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("deck_id = ?", d.ID).Delete(&models.TeamAssignment{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&d).Error
+	})
+}
+
+// Subscribe subscribes the user to a deck. The cards are filed below
+// category, keeping the deck's subcategories. Questions the user already has
+// are linked to the deck instead of being added again.
+func (sr *SpacedRepetition) Subscribe(userID uint, d *models.Deck, category string) (*models.DeckSubscription, DeckChanges, error) {
+	if d.UserID == userID {
+		return nil, DeckChanges{}, ErrOwnDeck
+	}
+	sub := &models.DeckSubscription{DeckID: d.ID, UserID: userID, Category: category}
+	var changes DeckChanges
+	err := sr.DB.Transaction(func(tx *gorm.DB) error {
+		var count int64
+		tx.Model(&models.DeckSubscription{}).Where("user_id = ? AND deck_id = ?", userID, d.ID).Count(&count)
+		if count > 0 {
+			return ErrAlreadySubscribed
+		}
+		if err := tx.Create(sub).Error; err != nil {
+			return err
+		}
+		var err error
+		changes, err = refreshSubscription(tx, sub, d)
+		return err
+	})
+	if err != nil {
+		return nil, DeckChanges{}, err
+	}
+	return sub, changes, nil
+}
+
+// ListSubscriptions returns the user's subscriptions with their decks
+func (sr *SpacedRepetition) ListSubscriptions(userID uint) ([]*models.DeckSubscription, map[uint]*models.Deck, error) {
+	var subs []*models.DeckSubscription
+	if err := sr.DB.Where("user_id = ?", userID).Order("id ASC").Find(&subs).Error; err != nil {
+		return nil, nil, err
+	}
+	decks := make(map[uint]*models.Deck, len(subs))
+	if len(subs) == 0 {
+		return subs, decks, nil
+	}
+	ids := make([]uint, len(subs))
+	for i, s := range subs {
+		ids[i] = s.DeckID
+	}
+	var list []*models.Deck
+	if err := sr.DB.Where("id IN ?", ids).Find(&list).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, d := range list {
+		decks[d.ID] = d
+	}
+	return subs, decks, nil
+}
+
+// Unsubscribe removes a subscription together with its cards and their
+// review history. Linked questions are kept as the user's own.
+func (sr *SpacedRepetition) Unsubscribe(userID, id uint) error {
+	return sr.DB.Transaction(func(tx *gorm.DB) error {
+		var sub models.DeckSubscription
+		if err := tx.Where("user_id = ? AND id = ?", userID, id).First(&sub).Error; err != nil {
+			return err
+		}
+		return deleteDeckCards(tx, userID, sub.DeckID, nil, &sub)
+	})
+}
+
+// RefreshSubscriptions brings the user's subscribed cards in line with the
+// authors' decks: cards the author added are created, cards the author
+// removed are deleted. Content changes need no refresh, it is always read
+// from the author's questions.
+func (sr *SpacedRepetition) RefreshSubscriptions(userID uint) error {
+	subs, decks, err := sr.ListSubscriptions(userID)
+	if err != nil {
+		return err
+	}
+	for _, sub := range subs {
+		d, ok := decks[sub.DeckID]
+		if !ok {
+			continue
+		}
+		err := sr.DB.Transaction(func(tx *gorm.DB) error {
+			_, err := refreshSubscription(tx, sub, d)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func refreshSubscription(tx *gorm.DB, sub *models.DeckSubscription, d *models.Deck) (DeckChanges, error) {
+	var changes DeckChanges
+	var origins []*models.Question
+	err := inCategories(tx.Select("id, question_text, category").Where("user_id = ? AND deck_id = 0", d.UserID), "category", []string{d.Category}).
+		Find(&origins).Error
+	if err != nil {
+		return changes, err
+	}
+	// Cards the subscriber deleted count as present so that they stay away
+	var have []string
+	if err := tx.Unscoped().Model(&models.Question{}).Where("user_id = ? AND deck_id = ?", sub.UserID, d.ID).Pluck("origin_id", &have).Error; err != nil {
+		return changes, err
+	}
+
+	existing := make(map[string]bool, len(have))
+	for _, id := range have {
+		existing[id] = true
+	}
+	current := make(map[string]bool, len(origins))
+	now := time.Now()
+	for _, o := range origins {
+		current[o.ID] = true
+		if existing[o.ID] {
+			continue
+		}
+		category := sub.Category + strings.TrimPrefix(o.Category, d.Category)
+
+		own, err := findQuestionByText(tx, sub.UserID, o.QuestionText)
+		if err != nil {
+			return changes, err
+		}
+		if own != nil {
+			if own.DeckID != 0 {
+				// Already a card of another subscribed deck
+				continue
+			}
+			// The own question keeps its progress and text, which comes
+			// back if the user unsubscribes
+			err := tx.Model(own).Updates(map[string]interface{}{"deck_id": d.ID, "origin_id": o.ID}).Error
+			if err != nil {
+				return changes, err
+			}
+			changes.Linked++
+			continue
+		}
+
+		result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.Question{
+			ID:         fmt.Sprintf("q_%d_%s", sub.UserID, Hash(o.QuestionText)),
+			UserID:     sub.UserID,
+			Source:     DeckSource(d.ID),
+			Category:   category,
+			DeckID:     d.ID,
+			OriginID:   o.ID,
+			Level:      4,
+			NextReview: now,
+			CreatedAt:  now,
+		})
+		if result.Error != nil {
+			return changes, result.Error
+		}
+		changes.Added += int(result.RowsAffected)
+	}
+
+	var gone []string
+	for _, id := range have {
+		if !current[id] {
+			gone = append(gone, id)
+		}
+	}
+	if len(gone) > 0 {
+		if err := deleteDeckCards(tx, sub.UserID, d.ID, gone, nil); err != nil {
+			return changes, err
+		}
+	}
+	changes.Removed = len(gone)
+	return changes, nil
+}
+
+// FindQuestionByText returns the user's question with the given text, also
+// matching subscribed cards by their author's text, or nil if there is none.
+// Imports and subscriptions use it to avoid duplicates.
+func (sr *SpacedRepetition) FindQuestionByText(userID uint, text string) (*models.Question, error) {
+	return findQuestionByText(sr.DB, userID, text)
+}
+
+func findQuestionByText(tx *gorm.DB, userID uint, text string) (*models.Question, error) {
+	var q models.Question
+	err := tx.Where("user_id = ? AND question_text = ?", userID, text).Limit(1).Find(&q).Error
+	if err != nil || q.ID != "" {
+		return &q, err
+	}
+	err = tx.Where("user_id = ? AND deck_id != 0 AND origin_id IN (?)", userID,
+		tx.Model(&models.Question{}).Select("id").Where("question_text = ?", text)).
+		Limit(1).Find(&q).Error
+	if err != nil || q.ID == "" {
+		return nil, err
+	}
+	return &q, nil
+}
+
+// deleteDeckCards deletes the user's cards of a deck, only those with the
+// given origins if origins is not nil, and then sub if given. Linked
+// questions, which still have their own text, are unlinked instead.
+func deleteDeckCards(tx *gorm.DB, userID, deckID uint, origins []string, sub *models.DeckSubscription) error {
+	scope := func() *gorm.DB {
+		query := tx.Unscoped().Model(&models.Question{}).Where("user_id = ? AND deck_id = ?", userID, deckID)
+		if origins != nil {
+			query = query.Where("origin_id IN ?", origins)
+		}
+		return query
+	}
+	err := scope().Where("question_text != ''").Updates(map[string]interface{}{"deck_id": 0, "origin_id": ""}).Error
+	if err != nil {
+		return err
+	}
+	query := scope()
+	var ids []string
+	if err := query.Pluck("id", &ids).Error; err != nil {
+		return err
+	}
+	if len(ids) > 0 {
+		if err := tx.Where("user_id = ? AND question_id IN ?", userID, ids).Delete(&models.ReviewLog{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Unscoped().Where("user_id = ? AND id IN ?", userID, ids).Delete(&models.Question{}).Error; err != nil {
+			return err
+		}
+	}
+	if sub != nil {
+		return tx.Delete(sub).Error
+	}
+	return nil
+}
+
+// FillSharedContent sets the content of subscribed cards from the author's
+// questions. Cards whose origin was deleted keep empty content until the
+// next refresh removes them.
+func (sr *SpacedRepetition) FillSharedContent(questions []*models.Question) error {
+	return fillSharedContent(sr.DB, questions)
+}
+
+func fillSharedContent(tx *gorm.DB, questions []*models.Question) error {
+	var ids []string
+	for _, q := range questions {
+		if q.OriginID != "" {
+			ids = append(ids, q.OriginID)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+	var origins []*models.Question
+	if err := tx.Select("id, question_text, answer_text, source_commit").Where("id IN ?", ids).Find(&origins).Error; err != nil {
+		return err
+	}
+	byID := make(map[string]*models.Question, len(origins))
+	for _, o := range origins {
+		byID[o.ID] = o
+	}
+	for _, q := range questions {
+		if o, ok := byID[q.OriginID]; ok {
+			q.QuestionText, q.AnswerText, q.SourceCommit = o.QuestionText, o.AnswerText, o.SourceCommit
+		}
+	}
+	return nil
+}
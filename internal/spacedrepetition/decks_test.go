@@ -0,0 +1,245 @@
+package spacedrepetition
+
+import (
+	"errors"
+	"testing"
+
+	"self-improvement/internal/models"
+)
+
+func TestDeckSubscription(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	sr.AddQuestion(1, "q_1_a", "什么是 inode？", "索引节点", "a.md", "fs")
+	sr.AddQuestion(1, "q_1_b", "什么是 ext4？", "文件系统", "b.md", "fs/linux")
+	sr.AddQuestion(1, "q_1_c", "其他", "不在卡组中", "c.md", "misc")
+
+	deck := &models.Deck{UserID: 1, Category: "fs", Title: "文件系统"}
+	if err := sr.PublishDeck(deck); err != nil {
+		t.Fatalf("PublishDeck failed: %v", err)
+	}
+	if len(deck.ShareCode) != ShareCodeLength {
+		t.Errorf("unexpected share code %q", deck.ShareCode)
+	}
+	if err := sr.PublishDeck(&models.Deck{UserID: 1, Category: "fs"}); !errors.Is(err, ErrDeckExists) {
+		t.Errorf("expected ErrDeckExists, got %v", err)
+	}
+	if n := sr.DeckCards(deck); n != 2 {
+		t.Errorf("expected 2 cards, got %d", n)
+	}
+
+	found, err := sr.GetDeckByCode(" " + deck.ShareCode + " ")
+	if err != nil || found.ID != deck.ID {
+		t.Fatalf("GetDeckByCode failed: %v", err)
+	}
+	if _, _, err := sr.Subscribe(1, deck, "x"); !errors.Is(err, ErrOwnDeck) {
+		t.Errorf("expected ErrOwnDeck, got %v", err)
+	}
+
+	sub, added, err := sr.Subscribe(2, deck, "存储")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if added.Added != 2 || added.Linked != 0 {
+		t.Errorf("expected 2 added cards, got %+v", added)
+	}
+	if _, _, err := sr.Subscribe(2, deck, "存储"); !errors.Is(err, ErrAlreadySubscribed) {
+		t.Errorf("expected ErrAlreadySubscribed, got %v", err)
+	}
+
+	// Content is not copied but read from the author's questions
+	var stored []models.Question
+	db.Where("user_id = 2").Order("category").Find(&stored)
+	if len(stored) != 2 || stored[0].QuestionText != "" || stored[0].Category != "存储" || stored[1].Category != "存储/linux" {
+		t.Fatalf("unexpected stored cards: %+v", stored)
+	}
+	due, err := sr.GetDueQuestionsByCategory(2, "存储")
+	if err != nil || len(due) != 2 {
+		t.Fatalf("expected 2 due cards, got %d (%v)", len(due), err)
+	}
+	for _, q := range due {
+		if q.QuestionText == "" || q.AnswerText == "" {
+			t.Errorf("content not filled: %+v", q)
+		}
+	}
+
+	// Reviews are the subscriber's own
+	cardID := stored[0].ID
+	if err := sr.UpdateReview(2, cardID, 1); err != nil {
+		t.Fatalf("UpdateReview failed: %v", err)
+	}
+	var card models.Question
+	db.Where("id = ?", cardID).First(&card)
+	if card.ReviewCount != 1 || card.QuestionText != "" {
+		t.Errorf("unexpected card after review: %+v", card)
+	}
+	author, _ := sr.GetQuestion(1, "q_1_a")
+	if author.ReviewCount != 0 {
+		t.Error("subscriber review changed the author's question")
+	}
+
+	// Author updates reach the subscriber, progress is kept
+	db.Model(&models.Question{}).Where("id = ?", "q_1_a").Update("answer_text", "索引节点（新）")
+	sr.AddQuestion(1, "q_1_d", "什么是 xfs？", "文件系统", "d.md", "fs/linux")
+	sr.DeleteQuestion(1, "q_1_b")
+	if err := sr.RefreshSubscriptions(2); err != nil {
+		t.Fatalf("RefreshSubscriptions failed: %v", err)
+	}
+	got, _ := sr.GetQuestion(2, cardID)
+	if got.AnswerText != "索引节点（新）" || got.ReviewCount != 1 {
+		t.Errorf("update not applied or progress lost: %+v", got)
+	}
+	list, total, _ := sr.ListQuestions(2, QuestionFilter{Sort: "category", Limit: 10})
+	if total != 2 || list[1].QuestionText != "什么是 xfs？" {
+		t.Errorf("unexpected cards after refresh: %d %+v", total, list)
+	}
+
+	// Content of shared cards is read-only
+	text := "改"
+	if _, err := sr.EditQuestion(2, cardID, QuestionEdit{Answer: &text}); !errors.Is(err, ErrSharedCard) {
+		t.Errorf("expected ErrSharedCard, got %v", err)
+	}
+	if _, err := sr.EditQuestion(2, cardID, QuestionEdit{Category: &text}); err != nil {
+		t.Errorf("category edit failed: %v", err)
+	}
+
+	// A card the subscriber deleted is not added again
+	sr.DeleteQuestion(2, cardID)
+	sr.RefreshSubscriptions(2)
+	if _, total, _ := sr.ListQuestions(2, QuestionFilter{Limit: 10}); total != 1 {
+		t.Errorf("deleted card came back, %d cards", total)
+	}
+
+	if err := sr.Unsubscribe(2, sub.ID); err != nil {
+		t.Fatalf("Unsubscribe failed: %v", err)
+	}
+	var left int64
+	db.Unscoped().Model(&models.Question{}).Where("user_id = 2").Count(&left)
+	if left != 0 {
+		t.Errorf("expected no cards after unsubscribe, got %d", left)
+	}
+	var logs int64
+	db.Model(&models.ReviewLog{}).Where("user_id = 2").Count(&logs)
+	if logs != 0 {
+		t.Errorf("expected review logs removed, got %d", logs)
+	}
+}
+
+func TestUnpublishDeckKeepsCopies(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	sr.AddQuestion(1, "q_1_a", "Q", "A", "a.md", "go")
+	deck := &models.Deck{UserID: 1, Category: "go"}
+	sr.PublishDeck(deck)
+	sr.Subscribe(2, deck, "go")
+	due, _ := sr.GetDueQuestions(2)
+	sr.UpdateReview(2, due[0].ID, 2)
+
+	if err := sr.UnpublishDeck(1, deck.ID); err != nil {
+		t.Fatalf("UnpublishDeck failed: %v", err)
+	}
+	var q models.Question
+	db.Where("user_id = 2").First(&q)
+	if q.QuestionText != "Q" || q.AnswerText != "A" || q.DeckID != 0 || q.OriginID != "" || q.ReviewCount != 1 {
+		t.Errorf("subscriber did not keep a copy: %+v", q)
+	}
+	var subs int64
+	db.Model(&models.DeckSubscription{}).Count(&subs)
+	if subs != 0 {
+		t.Errorf("expected subscriptions removed, got %d", subs)
+	}
+}
+
+func TestSubscribeLinksExistingQuestions(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	sr.AddQuestion(1, "q_1_a", "什么是 inode？", "索引节点", "a.md", "fs")
+	sr.AddQuestion(1, "q_1_b", "什么是 ext4？", "文件系统", "b.md", "fs")
+	// The subscriber already studies one of the questions
+	sr.AddQuestion(2, "q_2_a", "什么是 inode？", "我的答案", "notes.md", "notes")
+	sr.UpdateReview(2, "q_2_a", 1)
+
+	deck := &models.Deck{UserID: 1, Category: "fs"}
+	sr.PublishDeck(deck)
+	sub, changes, err := sr.Subscribe(2, deck, "fs")
+	if err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+	if changes.Added != 1 || changes.Linked != 1 {
+		t.Errorf("expected 1 added and 1 linked card, got %+v", changes)
+	}
+
+	linked, _ := sr.GetQuestion(2, "q_2_a")
+	if linked.DeckID != deck.ID || linked.AnswerText != "索引节点" || linked.ReviewCount != 1 || linked.Category != "notes" {
+		t.Errorf("unexpected linked card: %+v", linked)
+	}
+
+	// Subscribed cards count as existing for later imports
+	if q, _ := sr.FindQuestionByText(2, "什么是 ext4？"); q == nil || q.DeckID != deck.ID {
+		t.Errorf("expected the subscribed card, got %+v", q)
+	}
+	if q, _ := sr.FindQuestionByText(2, "没有的问题"); q != nil {
+		t.Errorf("expected nil, got %+v", q)
+	}
+
+	// Unsubscribing keeps the linked question with its own text
+	if err := sr.Unsubscribe(2, sub.ID); err != nil {
+		t.Fatalf("Unsubscribe failed: %v", err)
+	}
+	var left []models.Question
+	db.Where("user_id = 2").Find(&left)
+	if len(left) != 1 || left[0].ID != "q_2_a" || left[0].AnswerText != "我的答案" || left[0].DeckID != 0 || left[0].ReviewCount != 1 {
+		t.Errorf("unexpected questions after unsubscribe: %+v", left)
+	}
+}
+
+func TestRenamePublishedCategory(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	sr.AddQuestion(1, "q_1_a", "什么是 inode？", "索引节点", "a.md", "fs")
+	sr.AddQuestion(1, "q_1_b", "什么是 ext4？", "文件系统", "b.md", "fs/linux")
+	sr.AddQuestion(1, "q_1_c", "其他", "不在卡组中", "c.md", "misc")
+	sr.EnsureCategories(1, func(name string) string { return name })
+	deck := &models.Deck{UserID: 1, Category: "fs/linux"}
+	sr.PublishDeck(deck)
+	sr.Subscribe(2, deck, "linux")
+	due, _ := sr.GetDueQuestions(2)
+	sr.UpdateReview(2, due[0].ID, 2)
+
+	// Renaming the parent moves the deck along
+	var fs, misc models.Category
+	db.Where("user_id = 1 AND name = ?", "fs").First(&fs)
+	db.Where("user_id = 1 AND name = ?", "misc").First(&misc)
+	fs.Name = "filesystems"
+	if err := sr.UpdateCategory(&fs); err != nil {
+		t.Fatalf("UpdateCategory failed: %v", err)
+	}
+	if err := sr.RefreshSubscriptions(2); err != nil {
+		t.Fatalf("RefreshSubscriptions failed: %v", err)
+	}
+	db.First(deck, deck.ID)
+	if deck.Category != "filesystems/linux" || sr.DeckCards(deck) != 1 {
+		t.Errorf("deck did not follow the rename: %q with %d cards", deck.Category, sr.DeckCards(deck))
+	}
+	var cards, logs int64
+	db.Model(&models.Question{}).Where("user_id = 2 AND deck_id = ? AND review_count = 1", deck.ID).Count(&cards)
+	db.Model(&models.ReviewLog{}).Where("user_id = 2").Count(&logs)
+	if cards != 1 || logs != 1 {
+		t.Errorf("subscriber lost progress: %d cards, %d logs", cards, logs)
+	}
+
+	// Published categories are not deleted or merged away
+	if err := sr.DeleteCategory(1, fs.ID, "misc"); !errors.Is(err, ErrCategoryPublished) {
+		t.Errorf("expected ErrCategoryPublished deleting, got %v", err)
+	}
+	if err := sr.MergeCategories(1, fs.ID, misc.ID); !errors.Is(err, ErrCategoryPublished) {
+		t.Errorf("expected ErrCategoryPublished merging, got %v", err)
+	}
+	if err := sr.MergeCategories(1, misc.ID, fs.ID); err != nil {
+		t.Errorf("merging into a published category failed: %v", err)
+	}
+}
@@ -0,0 +1,267 @@
+package spacedrepetition
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"self-improvement/internal/models"
+)
+
+// Card priorities. Readiness weights each card by its priority.
+const (
+	PriorityLow    = 1
+	PriorityNormal = 2
+	PriorityHigh   = 3
+)
+
+// TargetRecall is the recall the scheduler aims for at a card's next review.
+// Recall decays exponentially from 1 right after a review, reaching
+// TargetRecall when the card is due.
+const TargetRecall = 0.9
+
+// PredictedRecall estimates the chance that the card is recalled at now from
+// the interval its last review scheduled. Cards never reviewed have 0.
+func PredictedRecall(q *models.Question, now time.Time) float64 {
+	if q.ReviewCount == 0 || q.LastReviewed == nil {
+		return 0
+	}
+	interval := q.NextReview.Sub(*q.LastReviewed)
+	if interval < time.Hour {
+		interval = time.Hour
+	}
+	elapsed := now.Sub(*q.LastReviewed)
+	if elapsed <= 0 {
+		return 1
+	}
+	return math.Pow(TargetRecall, float64(elapsed)/float64(interval))
+}
+
+// CardMastery combines the predicted recall with the card's level, 1 for a
+// proficient card that is fresh in memory and 0 for a card never reviewed
+func CardMastery(q *models.Question, now time.Time) float64 {
+	if q.ReviewCount == 0 {
+		return 0
+	}
+	level := q.Level
+	if level < 1 || level > 4 {
+		level = 4
+	}
+	return (PredictedRecall(q, now) + float64(4-level)/3) / 2
+}
+
+// priorityWeight returns the weight of a card in readiness
+func priorityWeight(priority int) float64 {
+	if priority < PriorityLow || priority > PriorityHigh {
+		return PriorityNormal
+	}
+	return float64(priority)
+}
+
+// MasteryStats sums up the mastery of a set of cards. Readiness is the mean
+// mastery weighted by priority, so weak high-priority cards lower it most.
+type MasteryStats struct {
+	Cards      int64   `json:"cards"`
+	Unreviewed int64   `json:"unreviewed"`
+	Recall     float64 `json:"recall"`    // Mean predicted recall
+	Mastery    float64 `json:"mastery"`   // Mean card mastery
+	Readiness  float64 `json:"readiness"` // Priority-weighted mean card mastery
+
+	recall, mastery, weighted, weight float64
+}
+
+func (m *MasteryStats) add(c *CardMasteryInfo) {
+	m.Cards++
+	if c.ReviewCount == 0 {
+		m.Unreviewed++
+	}
+	w := priorityWeight(c.Priority)
+	m.recall += c.Recall
+	m.mastery += c.Mastery
+	m.weighted += w * c.Mastery
+	m.weight += w
+	m.update()
+}
+
+func (m *MasteryStats) merge(o *MasteryStats) {
+	m.Cards += o.Cards
+	m.Unreviewed += o.Unreviewed
+	m.recall += o.recall
+	m.mastery += o.mastery
+	m.weighted += o.weighted
+	m.weight += o.weight
+	m.update()
+}
+
+func (m *MasteryStats) update() {
+	if m.Cards > 0 {
+		m.Recall = m.recall / float64(m.Cards)
+		m.Mastery = m.mastery / float64(m.Cards)
+	}
+	if m.weight > 0 {
+		m.Readiness = m.weighted / m.weight
+	}
+}
+
+// CardMasteryInfo is a card with its predicted recall and mastery
+type CardMasteryInfo struct {
+	ID           string     `json:"id"`
+	Question     string     `json:"question"`
+	Category     string     `json:"category"`
+	Level        int        `json:"level"`
+	Priority     int        `json:"priority"`
+	ReviewCount  int        `json:"review_count"`
+	LastReviewed *time.Time `json:"last_reviewed"`
+	NextReview   time.Time  `json:"next_review"`
+	Recall       float64    `json:"recall"`
+	Mastery      float64    `json:"mastery"`
+}
+
+// MasteryReport is the mastery of a category, subcategories included, with
+// its weakest reviewed cards
+type MasteryReport struct {
+	Category string `json:"category"`
+	MasteryStats
+	Weakest []*CardMasteryInfo `json:"weakest"`
+}
+
+// cardMastery loads the user's cards without their content and scores them
+func (sr *SpacedRepetition) cardMastery(userID uint, now time.Time) ([]*CardMasteryInfo, error) {
+	var questions []*models.Question
+	err := sr.DB.Select("id, category, level, priority, review_count, last_reviewed, next_review").
+		Where("user_id = ?", userID).Find(&questions).Error
+	if err != nil {
+		return nil, err
+	}
+	cards := make([]*CardMasteryInfo, len(questions))
+	for i, q := range questions {
+		cards[i] = &CardMasteryInfo{
+			ID: q.ID, Category: q.Category, Level: q.Level, Priority: q.Priority,
+			ReviewCount: q.ReviewCount, LastReviewed: q.LastReviewed, NextReview: q.NextReview,
+			Recall: PredictedRecall(q, now), Mastery: CardMastery(q, now),
+		}
+	}
+	return cards, nil
+}
+
+// categoryMastery returns the mastery of each category's own cards
+func (sr *SpacedRepetition) categoryMastery(userID uint) (map[string]*MasteryStats, error) {
+	cards, err := sr.cardMastery(userID, time.Now())
+	if err != nil {
+		return nil, err
+	}
+	stats := make(map[string]*MasteryStats)
+	for _, c := range cards {
+		s, ok := stats[c.Category]
+		if !ok {
+			s = &MasteryStats{}
+			stats[c.Category] = s
+		}
+		s.add(c)
+	}
+	return stats, nil
+}
+
+// GetMasteryReport returns the mastery of each of the categories,
+// subcategories included, with up to weakest of their reviewed cards, weakest
+// first. Cards of equal mastery are listed by priority, highest first. The
+// overall stats cover every card in any of the categories.
+func (sr *SpacedRepetition) GetMasteryReport(userID uint, categories []string, weakest int) ([]*MasteryReport, *MasteryStats, error) {
+	cards, err := sr.cardMastery(userID, time.Now())
+	if err != nil {
+		return nil, nil, err
+	}
+	sortWeakest(cards)
+
+	reports := make([]*MasteryReport, len(categories))
+	for i, name := range categories {
+		reports[i] = &MasteryReport{Category: name, Weakest: []*CardMasteryInfo{}}
+	}
+	overall := &MasteryStats{}
+	listed := make(map[string]*CardMasteryInfo)
+	for _, c := range cards {
+		inAny := false
+		for _, r := range reports {
+			if c.Category != r.Category && !strings.HasPrefix(c.Category, r.Category+CategorySeparator) {
+				continue
+			}
+			inAny = true
+			r.add(c)
+			if c.ReviewCount > 0 && len(r.Weakest) < weakest {
+				r.Weakest = append(r.Weakest, c)
+				listed[c.ID] = c
+			}
+		}
+		if inAny {
+			overall.add(c)
+		}
+	}
+
+	// Only the listed cards need their text
+	if err := sr.fillQuestionText(userID, listed); err != nil {
+		return nil, nil, err
+	}
+	return reports, overall, nil
+}
+
+// HardestDue returns up to limit of the user's due cards, weakest first.
+// New cards are left out, as in GetMasteryReport.
+func (sr *SpacedRepetition) HardestDue(userID uint, limit int) ([]*CardMasteryInfo, error) {
+	now := time.Now()
+	cards, err := sr.cardMastery(userID, now)
+	if err != nil {
+		return nil, err
+	}
+	sortWeakest(cards)
+	hardest := []*CardMasteryInfo{}
+	listed := make(map[string]*CardMasteryInfo)
+	for _, c := range cards {
+		if len(hardest) == limit {
+			break
+		}
+		if c.ReviewCount == 0 || c.NextReview.After(now) {
+			continue
+		}
+		hardest = append(hardest, c)
+		listed[c.ID] = c
+	}
+	return hardest, sr.fillQuestionText(userID, listed)
+}
+
+// sortWeakest orders cards by mastery, lowest first. Cards of equal mastery
+// are ordered by priority, highest first.
+func sortWeakest(cards []*CardMasteryInfo) {
+	sort.SliceStable(cards, func(i, j int) bool {
+		if cards[i].Mastery != cards[j].Mastery {
+			return cards[i].Mastery < cards[j].Mastery
+		}
+		if cards[i].Priority != cards[j].Priority {
+			return cards[i].Priority > cards[j].Priority
+		}
+		return cards[i].ID < cards[j].ID
+	})
+}
+
+// fillQuestionText sets the question text of the cards, keyed by ID
+func (sr *SpacedRepetition) fillQuestionText(userID uint, cards map[string]*CardMasteryInfo) error {
+	if len(cards) == 0 {
+		return nil
+	}
+	ids := make([]string, 0, len(cards))
+	for id := range cards {
+		ids = append(ids, id)
+	}
+	var questions []*models.Question
+	err := sr.DB.Select("id, question_text, origin_id").Where("user_id = ? AND id IN ?", userID, ids).Find(&questions).Error
+	if err == nil {
+		err = sr.FillSharedContent(questions)
+	}
+	if err != nil {
+		return err
+	}
+	for _, q := range questions {
+		cards[q.ID].Question = q.QuestionText
+	}
+	return nil
+}
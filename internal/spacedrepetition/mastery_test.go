@@ -0,0 +1,154 @@
+package spacedrepetition
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"self-improvement/internal/models"
+)
+
+func TestPredictedRecall(t *testing.T) {
+	now := time.Now()
+	last := now.Add(-48 * time.Hour)
+	q := &models.Question{Level: 2, ReviewCount: 1, LastReviewed: &last, NextReview: last.Add(48 * time.Hour)}
+
+	// Due now: the scheduler's target recall
+	if r := PredictedRecall(q, now); math.Abs(r-TargetRecall) > 1e-9 {
+		t.Errorf("expected recall %v when due, got %v", TargetRecall, r)
+	}
+	// Twice the interval
+	if r := PredictedRecall(q, now.Add(48*time.Hour)); math.Abs(r-TargetRecall*TargetRecall) > 1e-9 {
+		t.Errorf("expected recall %v after twice the interval, got %v", TargetRecall*TargetRecall, r)
+	}
+	if r := PredictedRecall(q, last); r != 1 {
+		t.Errorf("expected recall 1 right after the review, got %v", r)
+	}
+	if m := CardMastery(q, now); math.Abs(m-(TargetRecall+2.0/3)/2) > 1e-9 {
+		t.Errorf("unexpected mastery %v", m)
+	}
+
+	fresh := &models.Question{Level: 4, NextReview: now}
+	if PredictedRecall(fresh, now) != 0 || CardMastery(fresh, now) != 0 {
+		t.Errorf("expected 0 for a card never reviewed")
+	}
+}
+
+func TestMasteryReport(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	sr.AddQuestion(1, "q_1_a", "问题 A", "答案 A", "a.md", "存储/ceph")
+	sr.AddQuestion(1, "q_1_b", "问题 B", "答案 B", "b.md", "存储")
+	sr.AddQuestion(1, "q_1_c", "问题 C", "答案 C", "c.md", "存储")
+	sr.AddQuestion(1, "q_1_d", "问题 D", "答案 D", "d.md", "编程语言/go")
+	sr.AddQuestion(2, "q_2_a", "别人的问题", "答案", "a.md", "存储")
+
+	// q_1_a proficient and fresh, q_1_b forgotten and overdue with high
+	// priority, q_1_c never reviewed, q_1_d fair
+	now := time.Now()
+	reviewed := func(id string, level, priority int, last time.Time, interval time.Duration) {
+		db.Model(&models.Question{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"review_count": 3, "level": level, "priority": priority,
+			"last_reviewed": last, "next_review": last.Add(interval)})
+	}
+	reviewed("q_1_a", 1, PriorityNormal, now, 7*24*time.Hour)
+	reviewed("q_1_b", 4, PriorityHigh, now.Add(-240*time.Hour), 24*time.Hour)
+	reviewed("q_1_d", 2, PriorityLow, now.Add(-24*time.Hour), 72*time.Hour)
+
+	reports, overall, err := sr.GetMasteryReport(1, []string{"存储", "编程语言"}, 5)
+	if err != nil {
+		t.Fatalf("GetMasteryReport failed: %v", err)
+	}
+	if len(reports) != 2 {
+		t.Fatalf("expected 2 reports, got %d", len(reports))
+	}
+
+	storage := reports[0]
+	if storage.Cards != 3 || storage.Unreviewed != 1 {
+		t.Errorf("expected 3 storage cards with 1 unreviewed, got %+v", storage.MasteryStats)
+	}
+	// Weakest first, cards never reviewed are left out
+	if len(storage.Weakest) != 2 || storage.Weakest[0].ID != "q_1_b" || storage.Weakest[1].ID != "q_1_a" {
+		t.Fatalf("unexpected weakest cards %+v", storage.Weakest)
+	}
+	if storage.Weakest[0].Question != "问题 B" {
+		t.Errorf("expected question text on weakest cards, got %q", storage.Weakest[0].Question)
+	}
+	// The weak high-priority card pulls readiness below plain mastery
+	if storage.Readiness >= storage.Mastery {
+		t.Errorf("expected readiness %v below mastery %v", storage.Readiness, storage.Mastery)
+	}
+	a, b := storage.Weakest[1].Mastery, storage.Weakest[0].Mastery
+	if math.Abs(storage.Mastery-(a+b)/3) > 1e-9 || math.Abs(storage.Readiness-(2*a+3*b)/7) > 1e-9 {
+		t.Errorf("unexpected storage stats %+v", storage.MasteryStats)
+	}
+
+	if reports[1].Cards != 1 || overall.Cards != 4 {
+		t.Errorf("expected 1 language card and 4 overall, got %d and %d", reports[1].Cards, overall.Cards)
+	}
+
+	// A limit of 0 lists no cards
+	reports, _, _ = sr.GetMasteryReport(1, []string{"存储"}, 0)
+	if len(reports[0].Weakest) != 0 {
+		t.Errorf("expected no weakest cards, got %d", len(reports[0].Weakest))
+	}
+}
+
+func TestCategoryTreeMastery(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	sr.AddQuestion(1, "q_1_a", "问题 A", "答案 A", "a.md", "存储/ceph")
+	sr.AddQuestion(1, "q_1_b", "问题 B", "答案 B", "b.md", "存储")
+	now := time.Now()
+	db.Model(&models.Question{}).Where("id = ?", "q_1_a").Updates(map[string]interface{}{
+		"review_count": 1, "level": 1, "last_reviewed": now, "next_review": now.Add(24 * time.Hour)})
+
+	_, all, err := sr.CategoryTree(1)
+	if err != nil {
+		t.Fatalf("CategoryTree failed: %v", err)
+	}
+	byName := make(map[string]*CategoryNode)
+	for _, n := range all {
+		byName[n.Name] = n
+	}
+	// The parent rolls up its own unreviewed card and the child's mastered one
+	if m := byName["存储/ceph"].Mastery; m.Cards != 1 || math.Abs(m.Mastery-1) > 1e-6 {
+		t.Errorf("unexpected child mastery %+v", m)
+	}
+	if m := byName["存储"].Mastery; m.Cards != 2 || m.Unreviewed != 1 || math.Abs(m.Mastery-0.5) > 1e-6 {
+		t.Errorf("unexpected parent mastery %+v", m)
+	}
+}
+
+func TestHardestDue(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	sr.AddQuestion(1, "q_1_a", "问题 A", "答案 A", "a.md", "存储")
+	sr.AddQuestion(1, "q_1_b", "问题 B", "答案 B", "b.md", "存储")
+	sr.AddQuestion(1, "q_1_c", "问题 C", "答案 C", "c.md", "存储")
+	sr.AddQuestion(1, "q_1_d", "问题 D", "答案 D", "d.md", "存储")
+
+	// q_1_a due and forgotten, q_1_b due and fair, q_1_c not due, q_1_d new
+	now := time.Now()
+	reviewed := func(id string, level int, last time.Time, interval time.Duration) {
+		db.Model(&models.Question{}).Where("id = ?", id).Updates(map[string]interface{}{
+			"review_count": 2, "level": level, "last_reviewed": last, "next_review": last.Add(interval)})
+	}
+	reviewed("q_1_a", 4, now.Add(-72*time.Hour), 24*time.Hour)
+	reviewed("q_1_b", 2, now.Add(-48*time.Hour), 24*time.Hour)
+	reviewed("q_1_c", 4, now, 24*time.Hour)
+
+	hardest, err := sr.HardestDue(1, 5)
+	if err != nil {
+		t.Fatalf("HardestDue failed: %v", err)
+	}
+	if len(hardest) != 2 || hardest[0].ID != "q_1_a" || hardest[1].ID != "q_1_b" || hardest[0].Question != "问题 A" {
+		t.Errorf("unexpected hardest due cards %+v", hardest)
+	}
+	if hardest, _ = sr.HardestDue(1, 1); len(hardest) != 1 {
+		t.Errorf("expected the limit to apply, got %d cards", len(hardest))
+	}
+}
@@ -0,0 +1,194 @@
+package spacedrepetition
+
+import (
+	"errors"
+	"time"
+
+	"self-improvement/internal/models"
+)
+
+// DefaultCardMs is the time per card assumed until the user has timed reviews
+const DefaultCardMs = 20000
+
+// Review times are capped at maxCardMs. Reviews that reach it (the user
+// walked away) and all but the latest cardTimeSamples are left out of the
+// time per card.
+const (
+	maxCardMs       = 10 * 60 * 1000
+	cardTimeSamples = 200
+)
+
+var (
+	// ErrSessionNotFound is returned for an unknown study session
+	ErrSessionNotFound = errors.New("session not found")
+	// ErrSessionFinished is returned when reviewing in or finishing a finished session
+	ErrSessionFinished = errors.New("session already finished")
+)
+
+// ReviewTiming tells which session a review belongs to and how long it took
+type ReviewTiming struct {
+	SessionID  uint // 0 for a review outside a session
+	QuestionMs int
+	AnswerMs   int
+}
+
+// clamp caps both times at maxCardMs, so a card left open does not inflate
+// the study time of the day or the session
+func (t *ReviewTiming) clamp() {
+	if t.QuestionMs > maxCardMs {
+		t.QuestionMs = maxCardMs
+	}
+	if t.AnswerMs > maxCardMs {
+		t.AnswerMs = maxCardMs
+	}
+}
+
+// SessionSummary is a study session with the numbers of its reviews
+type SessionSummary struct {
+	models.StudySession
+	Cards       int64   `json:"cards"` // Distinct cards reviewed
+	Reviews     int64   `json:"reviews"`
+	Correct     int64   `json:"correct"`
+	Accuracy    float64 `json:"accuracy"`
+	NewCards    int64   `json:"new_cards"`    // Reviews of cards seen for the first time
+	ReviewCards int64   `json:"review_cards"` // Reviews of cards seen before
+	StudyMs     int64   `json:"study_ms"`     // Time on cards, from the reported timings
+	DurationMs  int64   `json:"duration_ms"`  // From start to finish, or to now while open
+}
+
+// StartSession opens a study session
+func (sr *SpacedRepetition) StartSession(s *models.StudySession) error {
+	s.StartedAt = time.Now()
+	s.FinishedAt = nil
+	return sr.DB.Create(s).Error
+}
+
+func (sr *SpacedRepetition) getSession(userID, id uint) (*models.StudySession, error) {
+	var s models.StudySession
+	if err := sr.DB.Where("user_id = ? AND id = ?", userID, id).First(&s).Error; err != nil {
+		return nil, ErrSessionNotFound
+	}
+	return &s, nil
+}
+
+// FinishSession closes a study session and returns its summary
+func (sr *SpacedRepetition) FinishSession(userID, id uint) (*SessionSummary, error) {
+	s, err := sr.getSession(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	if s.FinishedAt != nil {
+		return nil, ErrSessionFinished
+	}
+	now := time.Now()
+	if err := sr.DB.Model(s).Update("finished_at", now).Error; err != nil {
+		return nil, err
+	}
+	s.FinishedAt = &now
+	summaries, err := sr.summarize([]*models.StudySession{s})
+	if err != nil {
+		return nil, err
+	}
+	return summaries[0], nil
+}
+
+// GetSession returns the summary of one of the user's study sessions
+func (sr *SpacedRepetition) GetSession(userID, id uint) (*SessionSummary, error) {
+	s, err := sr.getSession(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	summaries, err := sr.summarize([]*models.StudySession{s})
+	if err != nil {
+		return nil, err
+	}
+	return summaries[0], nil
+}
+
+// ListSessions returns one page of the user's study sessions, newest first,
+// together with the total number of sessions
+func (sr *SpacedRepetition) ListSessions(userID uint, offset, limit int) ([]*SessionSummary, int64, error) {
+	query := sr.DB.Model(&models.StudySession{}).Where("user_id = ?", userID)
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var sessions []*models.StudySession
+	if err := query.Order("started_at DESC, id DESC").Offset(offset).Limit(limit).Find(&sessions).Error; err != nil {
+		return nil, 0, err
+	}
+	summaries, err := sr.summarize(sessions)
+	return summaries, total, err
+}
+
+// summarize counts the reviews of the sessions in one grouped query
+func (sr *SpacedRepetition) summarize(sessions []*models.StudySession) ([]*SessionSummary, error) {
+	summaries := make([]*SessionSummary, len(sessions))
+	if len(sessions) == 0 {
+		return summaries, nil
+	}
+	ids := make([]uint, len(sessions))
+	for i, s := range sessions {
+		ids[i] = s.ID
+	}
+
+	var rows []struct {
+		SessionID uint
+		Cards     int64
+		Reviews   int64
+		Correct   int64
+		NewCards  int64
+		StudyMs   int64
+	}
+	err := sr.DB.Model(&models.ReviewLog{}).
+		Select(`session_id, COUNT(DISTINCT question_id) AS cards, COUNT(*) AS reviews,
+			SUM(CASE WHEN feedback <= 2 THEN 1 ELSE 0 END) AS correct,
+			SUM(CASE WHEN new_card THEN 1 ELSE 0 END) AS new_cards,
+			SUM(question_ms + answer_ms) AS study_ms`).
+		Where("session_id IN ?", ids).Group("session_id").Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	for i, s := range sessions {
+		sum := &SessionSummary{StudySession: *s}
+		for _, r := range rows {
+			if r.SessionID != s.ID {
+				continue
+			}
+			sum.Cards, sum.Reviews, sum.Correct = r.Cards, r.Reviews, r.Correct
+			sum.NewCards, sum.ReviewCards = r.NewCards, r.Reviews-r.NewCards
+			sum.StudyMs = r.StudyMs
+			if r.Reviews > 0 {
+				sum.Accuracy = float64(r.Correct) / float64(r.Reviews)
+			}
+		}
+		end := now
+		if s.FinishedAt != nil {
+			end = *s.FinishedAt
+		}
+		sum.DurationMs = end.Sub(s.StartedAt).Milliseconds()
+		summaries[i] = sum
+	}
+	return summaries, nil
+}
+
+// CardTime returns the user's average time per card in milliseconds from
+// their latest timed reviews, and the number of reviews it is based on.
+// Without timed reviews it returns DefaultCardMs and 0.
+func (sr *SpacedRepetition) CardTime(userID uint) (int64, int64) {
+	var row struct {
+		Ms      float64
+		Samples int64
+	}
+	latest := sr.DB.Model(&models.ReviewLog{}).
+		Select("question_ms + answer_ms AS ms").
+		Where("user_id = ? AND question_ms + answer_ms > 0 AND question_ms + answer_ms < ?", userID, maxCardMs).
+		Order("reviewed_at DESC").Limit(cardTimeSamples)
+	sr.DB.Table("(?) AS latest", latest).Select("AVG(ms) AS ms, COUNT(*) AS samples").Scan(&row)
+	if row.Samples == 0 {
+		return DefaultCardMs, 0
+	}
+	return int64(row.Ms), row.Samples
+}
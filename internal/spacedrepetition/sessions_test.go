@@ -0,0 +1,100 @@
+package spacedrepetition
+
+import (
+	"errors"
+	"testing"
+
+	"self-improvement/internal/models"
+)
+
+func TestStudySession(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	sr.AddQuestion(1, "q_1_a", "问题 A", "答案 A", "a.md", "go")
+	sr.AddQuestion(1, "q_1_b", "问题 B", "答案 B", "b.md", "go")
+	sr.UpdateReview(1, "q_1_b", 3)
+
+	if ms, n := sr.CardTime(1); ms != DefaultCardMs || n != 0 {
+		t.Errorf("expected the default time per card, got %d from %d", ms, n)
+	}
+
+	s := &models.StudySession{UserID: 1, Device: "web"}
+	if err := sr.StartSession(s); err != nil {
+		t.Fatalf("StartSession failed: %v", err)
+	}
+	reviews := []struct {
+		id       string
+		feedback int
+		timing   ReviewTiming
+	}{
+		{"q_1_a", 1, ReviewTiming{SessionID: s.ID, QuestionMs: 8000, AnswerMs: 2000}},
+		{"q_1_b", 4, ReviewTiming{SessionID: s.ID, QuestionMs: 15000, AnswerMs: 5000}},
+		{"q_1_b", 2, ReviewTiming{SessionID: s.ID, QuestionMs: 20000, AnswerMs: 10000}},
+		// Left out of the time per card: the user walked away
+		{"q_1_a", 1, ReviewTiming{QuestionMs: 3600000}},
+	}
+	for _, r := range reviews {
+		if err := sr.UpdateTimedReview(1, r.id, r.feedback, r.timing); err != nil {
+			t.Fatalf("UpdateTimedReview failed: %v", err)
+		}
+	}
+	if err := sr.UpdateTimedReview(2, "q_1_a", 1, ReviewTiming{SessionID: s.ID}); !errors.Is(err, ErrSessionNotFound) {
+		t.Errorf("expected ErrSessionNotFound for another user's session, got %v", err)
+	}
+
+	summary, err := sr.FinishSession(1, s.ID)
+	if err != nil {
+		t.Fatalf("FinishSession failed: %v", err)
+	}
+	if summary.Cards != 2 || summary.Reviews != 3 || summary.Correct != 2 || summary.StudyMs != 60000 {
+		t.Errorf("unexpected summary %+v", summary)
+	}
+	if summary.NewCards != 1 || summary.ReviewCards != 2 || summary.FinishedAt == nil || summary.DurationMs < 0 {
+		t.Errorf("unexpected split %+v", summary)
+	}
+	if _, err := sr.FinishSession(1, s.ID); !errors.Is(err, ErrSessionFinished) {
+		t.Errorf("expected ErrSessionFinished, got %v", err)
+	}
+	if err := sr.UpdateTimedReview(1, "q_1_a", 1, ReviewTiming{SessionID: s.ID}); !errors.Is(err, ErrSessionFinished) {
+		t.Errorf("expected ErrSessionFinished for a review after finishing, got %v", err)
+	}
+
+	// The walked-away review counts with the capped time
+	var walkedAway models.ReviewLog
+	db.Where("user_id = 1 AND session_id = 0 AND question_ms > 0").First(&walkedAway)
+	if walkedAway.QuestionMs != maxCardMs {
+		t.Errorf("expected the time capped at %d, got %d", maxCardMs, walkedAway.QuestionMs)
+	}
+	var day models.DailyActivity
+	db.Where("user_id = 1").First(&day)
+	if day.StudyMs != 60000+maxCardMs {
+		t.Errorf("expected %d ms of study time, got %d", 60000+maxCardMs, day.StudyMs)
+	}
+
+	if ms, n := sr.CardTime(1); ms != 20000 || n != 3 {
+		t.Errorf("expected 20000ms from 3 reviews, got %d from %d", ms, n)
+	}
+	forecast, _ := sr.GetForecast(1, 1)
+	if forecast[0]["estimated_minutes"].(int64) != estimateMinutes(forecast[0]["count"].(int64), 20000) {
+		t.Errorf("unexpected estimate %v", forecast[0])
+	}
+
+	list, total, err := sr.ListSessions(1, 0, 10)
+	if err != nil || total != 1 || len(list) != 1 || list[0].Reviews != 3 {
+		t.Errorf("unexpected sessions %v %d (%v)", list, total, err)
+	}
+}
+
+func TestEstimateMinutes(t *testing.T) {
+	for _, tc := range []struct{ n, ms, want int64 }{
+		{0, 20000, 0},
+		{1, 20000, 1},
+		{3, 20000, 1},
+		{4, 20000, 2},
+	} {
+		if got := estimateMinutes(tc.n, tc.ms); got != tc.want {
+			t.Errorf("estimateMinutes(%d, %d) = %d, want %d", tc.n, tc.ms, got, tc.want)
+		}
+	}
+}
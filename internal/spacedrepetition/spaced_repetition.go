@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"gorm.io/gorm"
 
@@ -59,15 +60,16 @@ func (sr *SpacedRepetition) GetDueQuestions(userID uint) ([]*models.Question, er
 		return nil, err
 	}
 
-	return questions, nil
+	return questions, sr.FillSharedContent(questions)
 }
 
-// GetDueQuestionsByCategory returns due questions filtered by category
+// GetDueQuestionsByCategory returns due questions in a category or its
+// subcategories
 func (sr *SpacedRepetition) GetDueQuestionsByCategory(userID uint, category string) ([]*models.Question, error) {
 	now := time.Now()
 	var questions []*models.Question
 
-	err := sr.DB.Where("user_id = ? AND next_review <= ? AND category = ?", userID, now, category).
+	err := inCategories(sr.DB.Where("user_id = ? AND next_review <= ?", userID, now), "category", []string{category}).
 		Order("next_review ASC").
 		Find(&questions).Error
 
@@ -75,15 +77,16 @@ func (sr *SpacedRepetition) GetDueQuestionsByCategory(userID uint, category stri
 		return nil, err
 	}
 
-	return questions, nil
+	return questions, sr.FillSharedContent(questions)
 }
 
-// GetDueQuestionsByCategories returns due questions filtered by multiple categories
+// GetDueQuestionsByCategories returns due questions in any of the categories
+// or their subcategories
 func (sr *SpacedRepetition) GetDueQuestionsByCategories(userID uint, categories []string) ([]*models.Question, error) {
 	now := time.Now()
 	var questions []*models.Question
 
-	err := sr.DB.Where("user_id = ? AND next_review <= ? AND category IN ?", userID, now, categories).
+	err := inCategories(sr.DB.Where("user_id = ? AND next_review <= ?", userID, now), "category", categories).
 		Order("next_review ASC").
 		Limit(100).
 		Find(&questions).Error
@@ -92,7 +95,7 @@ func (sr *SpacedRepetition) GetDueQuestionsByCategories(userID uint, categories
 		return nil, err
 	}
 
-	return questions, nil
+	return questions, sr.FillSharedContent(questions)
 }
 
 // GetCategories returns all distinct categories with stats for a user
@@ -134,6 +137,16 @@ func (sr *SpacedRepetition) GetCategories(userID uint) ([]map[string]interface{}
 
 // GetQuestion returns a specific question by ID for the user
 func (sr *SpacedRepetition) GetQuestion(userID uint, id string) (*models.Question, error) {
+	q, err := sr.getQuestion(userID, id)
+	if err != nil {
+		return nil, err
+	}
+	return q, sr.FillSharedContent([]*models.Question{q})
+}
+
+// getQuestion returns the stored question, without the content of
+// subscribed cards, for updates
+func (sr *SpacedRepetition) getQuestion(userID uint, id string) (*models.Question, error) {
 	var q models.Question
 
 	err := sr.DB.Where("user_id = ? AND id = ?", userID, id).First(&q).Error
@@ -144,16 +157,72 @@ func (sr *SpacedRepetition) GetQuestion(userID uint, id string) (*models.Questio
 	return &q, nil
 }
 
+// LeechLapses is the number of failed reviews after which a card counts as a
+// leech: one that keeps being forgotten and is better rewritten or split
+const LeechLapses = 8
+
+// Lapses returns how often the user failed a question
+func (sr *SpacedRepetition) Lapses(userID uint, id string) int64 {
+	var n int64
+	sr.DB.Model(&models.ReviewLog{}).Where("user_id = ? AND question_id = ? AND feedback >= 3", userID, id).Count(&n)
+	return n
+}
+
 // UpdateReview updates review results for a question
 func (sr *SpacedRepetition) UpdateReview(userID uint, id string, feedback int) error {
-	question, err := sr.GetQuestion(userID, id)
+	return sr.UpdateTimedReview(userID, id, feedback, ReviewTiming{})
+}
+
+// UpdateTimedReview is UpdateReview for a review made in a study session,
+// recording how long the question and the answer were shown
+func (sr *SpacedRepetition) UpdateTimedReview(userID uint, id string, feedback int, timing ReviewTiming) error {
+	timing.clamp()
+	if timing.SessionID != 0 {
+		s, err := sr.getSession(userID, timing.SessionID)
+		if err != nil {
+			return err
+		}
+		if s.FinishedAt != nil {
+			return ErrSessionFinished
+		}
+	}
+	question, err := sr.getQuestion(userID, id)
 	if err != nil {
 		return err
 	}
 
 	now := time.Now()
+	newCard := question.ReviewCount == 0
+	intervalHours := schedule(question, feedback, now, intervalMultiplier(sr.DB, userID, question.Category))
+
+	return sr.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(question).Error; err != nil {
+			return err
+		}
+		if err := tx.Create(&models.ReviewLog{
+			UserID:        userID,
+			QuestionID:    question.ID,
+			Feedback:      feedback,
+			IntervalHours: intervalHours,
+			ReviewedAt:    now,
+			Source:        "app",
+			SessionID:     timing.SessionID,
+			QuestionMs:    timing.QuestionMs,
+			AnswerMs:      timing.AnswerMs,
+			NewCard:       newCard,
+		}).Error; err != nil {
+			return err
+		}
+		return recordActivity(tx, userID, now, feedback <= 2, int64(timing.QuestionMs+timing.AnswerMs))
+	})
+}
+
+// schedule applies one review made at the given time to the question's state
+// and returns the interval until the next review in hours. scale is the
+// category's interval multiplier.
+func schedule(question *models.Question, feedback int, at time.Time, scale float64) float64 {
 	question.ReviewCount++
-	question.LastReviewed = &now
+	question.LastReviewed = &at
 
 	// Update statistics
 	if feedback <= 2 { // Proficient or fair counts as correct
@@ -189,8 +258,8 @@ func (sr *SpacedRepetition) UpdateReview(userID uint, id string, feedback int) e
 	}
 
 	// Calculate final interval
-	intervalHours := float64(baseInterval.Hours()) * multiplier
-	nextReview := now.Add(time.Duration(intervalHours * float64(time.Hour)))
+	intervalHours := float64(baseInterval.Hours()) * multiplier * scale
+	nextReview := at.Add(time.Duration(intervalHours * float64(time.Hour)))
 
 	question.NextReview = nextReview
 
@@ -205,60 +274,278 @@ func (sr *SpacedRepetition) UpdateReview(userID uint, id string, feedback int) e
 		question.Level = min(4, question.Level+1)
 	}
 
-	return sr.DB.Save(question).Error
+	return intervalHours
+}
+
+// ImportProgress overwrites the scheduling state of an existing question with
+// progress carried over from elsewhere (another app or a backup) and stores
+// the accompanying review history.
+func (sr *SpacedRepetition) ImportProgress(userID uint, id string, state *models.Question, logs []models.ReviewLog) error {
+	return sr.DB.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.Question{}).
+			Where("user_id = ? AND id = ?", userID, id).
+			Updates(map[string]interface{}{
+				"level":         state.Level,
+				"next_review":   state.NextReview,
+				"review_count":  state.ReviewCount,
+				"correct_count": state.CorrectCount,
+				"last_reviewed": state.LastReviewed,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return gorm.ErrRecordNotFound
+		}
+
+		for i := range logs {
+			logs[i].ID = 0
+			logs[i].UserID = userID
+			logs[i].QuestionID = id
+		}
+		if len(logs) > 0 {
+			return tx.CreateInBatches(logs, 100).Error
+		}
+		return nil
+	})
+}
+
+// QuestionFilter selects and orders questions for ListQuestions
+type QuestionFilter struct {
+	Category string // Includes subcategories
+	Source   string // Source prefix, e.g. a directory or "git:1/"
+	Level    int    // 1-4, 0 for any
+	Due      *bool  // Due now (true), scheduled later (false) or either (nil)
+	Sort     string // One of SortFields, defaults to next_review
+	Desc     bool
+	Offset   int
+	Limit    int
+}
+
+// SortFields lists the columns questions can be sorted by
+var SortFields = []string{"next_review", "created_at", "updated_at", "level", "review_count", "category", "priority"}
+
+// ListQuestions returns one page of the user's questions matching the filter
+// together with the total number of matches
+func (sr *SpacedRepetition) ListQuestions(userID uint, f QuestionFilter) ([]*models.Question, int64, error) {
+	query := sr.DB.Model(&models.Question{}).Where("user_id = ?", userID)
+	if f.Category != "" {
+		query = inCategories(query, "category", []string{f.Category})
+	}
+	if f.Source != "" {
+		query = query.Where("substr(source, 1, ?) = ?", utf8.RuneCountInString(f.Source), f.Source)
+	}
+	if f.Level > 0 {
+		query = query.Where("level = ?", f.Level)
+	}
+	if f.Due != nil {
+		if *f.Due {
+			query = query.Where("next_review <= ?", time.Now())
+		} else {
+			query = query.Where("next_review > ?", time.Now())
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	sort := "next_review"
+	for _, field := range SortFields {
+		if f.Sort == field {
+			sort = field
+		}
+	}
+	order := sort + " ASC"
+	if f.Desc {
+		order = sort + " DESC"
+	}
+
+	var questions []*models.Question
+	err := query.Order(order).Order("id ASC").Offset(f.Offset).Limit(f.Limit).Find(&questions).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	return questions, total, sr.FillSharedContent(questions)
+}
+
+// QuestionEdit holds the fields to change on a question. Nil fields are left
+// as they are.
+type QuestionEdit struct {
+	Question *string
+	Answer   *string
+	Category *string
+	Source   *string
+	Priority *int
+	// ResetSchedule starts the question over as if it were new and clears
+	// its review history. Edits keep the scheduling state otherwise.
+	ResetSchedule bool
+}
+
+// EditQuestion changes a question's content. The question keeps its ID even
+// when its text changes. The content and source of subscribed cards belong to
+// the deck's author and cannot be changed.
+func (sr *SpacedRepetition) EditQuestion(userID uint, id string, edit QuestionEdit) (*models.Question, error) {
+	updates := make(map[string]interface{})
+	if edit.Question != nil {
+		updates["question_text"] = *edit.Question
+	}
+	if edit.Answer != nil {
+		updates["answer_text"] = *edit.Answer
+	}
+	if edit.Category != nil {
+		updates["category"] = *edit.Category
+	}
+	if edit.Source != nil {
+		updates["source"] = *edit.Source
+	}
+	if edit.Priority != nil {
+		updates["priority"] = *edit.Priority
+	}
+	if edit.ResetSchedule {
+		updates["level"] = 4
+		updates["next_review"] = time.Now()
+		updates["review_count"] = 0
+		updates["correct_count"] = 0
+		updates["last_reviewed"] = nil
+	}
+
+	err := sr.DB.Transaction(func(tx *gorm.DB) error {
+		var q models.Question
+		if err := tx.Where("user_id = ? AND id = ?", userID, id).First(&q).Error; err != nil {
+			return err
+		}
+		if q.DeckID != 0 && (edit.Question != nil || edit.Answer != nil || edit.Source != nil) {
+			return ErrSharedCard
+		}
+		if len(updates) > 0 {
+			if err := tx.Model(&q).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if edit.ResetSchedule {
+			return tx.Where("user_id = ? AND question_id = ?", userID, id).Delete(&models.ReviewLog{}).Error
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return sr.GetQuestion(userID, id)
 }
 
 // ResetUserQuestions resets all questions for a user to fresh state.
 // All questions become due immediately with review counts zeroed.
 func (sr *SpacedRepetition) ResetUserQuestions(userID uint) error {
 	now := time.Now()
-	return sr.DB.Model(&models.Question{}).
-		Where("user_id = ?", userID).
-		Updates(map[string]interface{}{
-			"level":         4,
-			"next_review":   now,
-			"review_count":  0,
-			"correct_count": 0,
-			"last_reviewed": nil,
-		}).Error
+	return sr.DB.Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(&models.Question{}).
+			Where("user_id = ?", userID).
+			Updates(map[string]interface{}{
+				"level":         4,
+				"next_review":   now,
+				"review_count":  0,
+				"correct_count": 0,
+				"last_reviewed": nil,
+			}).Error
+		if err != nil {
+			return err
+		}
+		if err := tx.Where("user_id = ?", userID).Delete(&models.ReviewLog{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ?", userID).Delete(&models.DailyActivity{}).Error
+	})
 }
 
-// GetForecast returns review counts for the next N days
+// MaxForecastDays is the longest horizon GetForecast covers
+const MaxForecastDays = 365
+
+// GetForecast returns review counts for the next N days with the minutes
+// they are expected to take at the user's time per card. Days are counted in
+// the server's timezone; today counts the cards due by now, overdue ones
+// included. Each day also counts its cards per top-level category.
 func (sr *SpacedRepetition) GetForecast(userID uint, days int) ([]map[string]interface{}, error) {
-	now := time.Now()
-	var forecast []map[string]interface{}
+	return sr.forecast(userID, days, time.Local, false)
+}
 
-	for i := 0; i < days; i++ {
-		dayStart := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).AddDate(0, 0, i)
-		dayEnd := dayStart.AddDate(0, 0, 1).Add(-time.Second)
+// GetCalendarForecast is GetForecast for calendars: days are counted in the
+// user's timezone and today holds every card due before tomorrow.
+func (sr *SpacedRepetition) GetCalendarForecast(userID uint, days int) ([]map[string]interface{}, error) {
+	loc, _ := sr.UserGoal(userID)
+	return sr.forecast(userID, days, loc, true)
+}
 
-		var count int64
-		sr.DB.Model(&models.Question{}).
-			Where("user_id = ? AND next_review BETWEEN ? AND ?", userID, dayStart, dayEnd).
-			Count(&count)
+// forecast counts due cards per day of loc. Overdue cards go to today, which
+// also holds the cards due later today if wholeToday is set. A horizon under
+// one day has no today to hold the overdue cards, so it yields no days.
+func (sr *SpacedRepetition) forecast(userID uint, days int, loc *time.Location, wholeToday bool) ([]map[string]interface{}, error) {
+	if days < 1 {
+		return []map[string]interface{}{}, nil
+	}
+	if days > MaxForecastDays {
+		days = MaxForecastDays
+	}
+	now := time.Now()
+	cardMs, _ := sr.CardTime(userID)
+	local := now.In(loc)
+	start := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, loc)
 
-		forecast = append(forecast, map[string]interface{}{
-			"date":  dayStart.Format("2006-01-02"),
-			"count": count,
-		})
+	var rows []struct {
+		NextReview time.Time
+		Category   string
+	}
+	err := sr.DB.Model(&models.Question{}).Select("next_review, category").
+		Where("user_id = ? AND next_review < ?", userID, start.AddDate(0, 0, days)).
+		Find(&rows).Error
+	if err != nil {
+		return nil, err
 	}
 
-	// Also include overdue items (next_review <= now) in today's count
-	if len(forecast) > 0 {
-		var overdue int64
-		sr.DB.Model(&models.Question{}).
-			Where("user_id = ? AND next_review <= ?", userID, now).
-			Count(&overdue)
-		forecast[0]["count"] = overdue
+	forecast := make([]map[string]interface{}, days)
+	counts := make([]int64, days)
+	categories := make([]map[string]int64, days)
+	byDate := make(map[string]int, days)
+	for i := range forecast {
+		date := start.AddDate(0, 0, i).Format("2006-01-02")
+		forecast[i] = map[string]interface{}{"date": date}
+		categories[i] = make(map[string]int64)
+		byDate[date] = i
+	}
+	for _, r := range rows {
+		i := 0
+		if r.NextReview.After(now) {
+			var ok bool
+			if i, ok = byDate[r.NextReview.In(loc).Format("2006-01-02")]; !ok || (i == 0 && !wholeToday) {
+				continue
+			}
+		}
+		counts[i]++
+		categories[i][strings.SplitN(r.Category, CategorySeparator, 2)[0]]++
+	}
+	for i, day := range forecast {
+		day["count"] = counts[i]
+		day["categories"] = categories[i]
+		day["estimated_minutes"] = estimateMinutes(counts[i], cardMs)
 	}
 
 	return forecast, nil
 }
 
+// estimateMinutes is the time n cards take, rounded up to whole minutes
+func estimateMinutes(n, cardMs int64) int64 {
+	return (n*cardMs + 59999) / 60000
+}
+
 // DeleteQuestion removes a question from the user's knowledge base
 func (sr *SpacedRepetition) DeleteQuestion(userID uint, id string) error {
-	result := sr.DB.Where("user_id = ? AND id = ?", userID, id).Delete(&models.Question{})
-	return result.Error
+	return sr.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND id = ?", userID, id).Delete(&models.Question{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ? AND question_id = ?", userID, id).Delete(&models.ReviewLog{}).Error
+	})
 }
 
 // GetStats returns learning statistics for a specific user
@@ -16,7 +16,9 @@ func setupTestDB(t *testing.T) *gorm.DB {
 	if err != nil {
 		t.Fatalf("failed to open test db: %v", err)
 	}
-	if err := db.AutoMigrate(&models.User{}, &models.Question{}); err != nil {
+	if err := db.AutoMigrate(&models.User{}, &models.Question{}, &models.ReviewLog{}, &models.Category{}, &models.Deck{}, &models.DeckSubscription{},
+		&models.Team{}, &models.TeamMember{}, &models.TeamAssignment{}, &models.StudySession{},
+		&models.UserSettings{}, &models.DailyActivity{}); err != nil {
 		t.Fatalf("failed to migrate: %v", err)
 	}
 	return db
@@ -198,6 +200,140 @@ func TestUpdateReview_AccuracyBoost(t *testing.T) {
 	}
 }
 
+func TestUpdateReview_Logged(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	sr.AddQuestion(1, "q1", "Q1", "A1", "test.md", "go")
+	sr.UpdateReview(1, "q1", 2)
+
+	var logs []models.ReviewLog
+	db.Where("user_id = ? AND question_id = ?", 1, "q1").Find(&logs)
+	if len(logs) != 1 {
+		t.Fatalf("expected 1 review log, got %d", len(logs))
+	}
+	if logs[0].Feedback != 2 || logs[0].Source != "app" || logs[0].IntervalHours <= 0 {
+		t.Errorf("unexpected log: %+v", logs[0])
+	}
+}
+
+func TestImportProgress(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	sr.AddQuestion(1, "q1", "Q1", "A1", "test.md", "go")
+
+	reviewed := time.Now().Add(-24 * time.Hour)
+	state := &models.Question{
+		Level:        2,
+		NextReview:   time.Now().Add(72 * time.Hour),
+		ReviewCount:  2,
+		CorrectCount: 1,
+		LastReviewed: &reviewed,
+	}
+	logs := []models.ReviewLog{
+		{Feedback: 3, ReviewedAt: reviewed.Add(-48 * time.Hour), Source: "anki"},
+		{Feedback: 1, ReviewedAt: reviewed, Source: "anki"},
+	}
+	if err := sr.ImportProgress(1, "q1", state, logs); err != nil {
+		t.Fatalf("ImportProgress failed: %v", err)
+	}
+
+	q, _ := sr.GetQuestion(1, "q1")
+	if q.Level != 2 || q.ReviewCount != 2 || q.CorrectCount != 1 || q.LastReviewed == nil {
+		t.Errorf("progress not applied: %+v", q)
+	}
+
+	var count int64
+	db.Model(&models.ReviewLog{}).Where("user_id = ? AND question_id = ?", 1, "q1").Count(&count)
+	if count != 2 {
+		t.Errorf("expected 2 review logs, got %d", count)
+	}
+
+	if err := sr.ImportProgress(2, "q1", state, nil); err == nil {
+		t.Error("expected error for another user's question")
+	}
+}
+
+func TestListQuestions(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	sr.AddQuestion(1, "q1", "Q1", "A1", "questions/u/go/a.md", "go")
+	sr.AddQuestion(1, "q2", "Q2", "A2", "questions/u/go/b.md", "go")
+	sr.AddQuestion(1, "q3", "Q3", "A3", "git:1/db/c.md", "db")
+	sr.AddQuestion(2, "q4", "Q4", "A4", "questions/v/go/a.md", "go")
+	sr.UpdateReview(1, "q2", 1)
+
+	questions, total, err := sr.ListQuestions(1, QuestionFilter{Category: "go", Limit: 10})
+	if err != nil {
+		t.Fatalf("ListQuestions failed: %v", err)
+	}
+	if total != 2 || len(questions) != 2 || questions[0].ID != "q1" {
+		t.Errorf("unexpected category listing: total=%d %v", total, questions)
+	}
+
+	due := false
+	if _, total, _ := sr.ListQuestions(1, QuestionFilter{Due: &due, Limit: 10}); total != 1 {
+		t.Errorf("expected 1 scheduled question, got %d", total)
+	}
+	if qs, total, _ := sr.ListQuestions(1, QuestionFilter{Source: "git:1/", Limit: 10}); total != 1 || qs[0].ID != "q3" {
+		t.Errorf("source prefix filter mismatch: %d", total)
+	}
+	if _, total, _ := sr.ListQuestions(1, QuestionFilter{Source: "questions/u/go%", Limit: 10}); total != 0 {
+		t.Errorf("source filter treated %% as a wildcard")
+	}
+	// substr counts characters, not bytes
+	sr.AddQuestion(3, "q5", "Q5", "A5", "questions/07_八股文/a.md", "八股文")
+	if qs, total, _ := sr.ListQuestions(3, QuestionFilter{Source: "questions/07_八股文/", Limit: 10}); total != 1 || qs[0].ID != "q5" {
+		t.Errorf("non-ASCII source prefix mismatch: %d", total)
+	}
+
+	// Paging keeps the total
+	qs, total, _ := sr.ListQuestions(1, QuestionFilter{Sort: "next_review", Desc: true, Offset: 0, Limit: 1})
+	if total != 3 || len(qs) != 1 || qs[0].ID != "q2" {
+		t.Errorf("unexpected first page: total=%d %v", total, qs)
+	}
+}
+
+func TestEditQuestion(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	sr.AddQuestion(1, "q1", "Q1", "A1", "test.md", "go")
+	sr.UpdateReview(1, "q1", 1)
+	before, _ := sr.GetQuestion(1, "q1")
+
+	answer, category := "A1 new", "golang"
+	q, err := sr.EditQuestion(1, "q1", QuestionEdit{Answer: &answer, Category: &category})
+	if err != nil {
+		t.Fatalf("EditQuestion failed: %v", err)
+	}
+	if q.AnswerText != answer || q.Category != category || q.QuestionText != "Q1" {
+		t.Errorf("fields not updated: %+v", q)
+	}
+	if q.ReviewCount != 1 || !q.NextReview.Equal(before.NextReview) {
+		t.Errorf("edit changed scheduling state: %+v", q)
+	}
+	if !q.UpdatedAt.After(before.UpdatedAt) {
+		t.Errorf("UpdatedAt not advanced: %v -> %v", before.UpdatedAt, q.UpdatedAt)
+	}
+
+	q, _ = sr.EditQuestion(1, "q1", QuestionEdit{ResetSchedule: true})
+	if q.ReviewCount != 0 || q.Level != 4 || q.LastReviewed != nil {
+		t.Errorf("schedule not reset: %+v", q)
+	}
+	var logs int64
+	db.Model(&models.ReviewLog{}).Where("question_id = ?", "q1").Count(&logs)
+	if logs != 0 {
+		t.Errorf("expected review history cleared, got %d logs", logs)
+	}
+
+	if _, err := sr.EditQuestion(2, "q1", QuestionEdit{Answer: &answer}); err == nil {
+		t.Error("expected error editing another user's question")
+	}
+}
+
 func TestDeleteQuestion(t *testing.T) {
 	db := setupTestDB(t)
 	sr := NewSpacedRepetition(db)
@@ -337,3 +473,84 @@ func TestMinMax(t *testing.T) {
 		t.Error("max(2,1) should be 2")
 	}
 }
+
+func TestGetCalendarForecast(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+	db.Create(&models.UserSettings{UserID: 1, Timezone: "Asia/Shanghai"})
+	shanghai, _ := time.LoadLocation("Asia/Shanghai")
+
+	sr.AddQuestion(1, "q_1_a", "A", "A", "a.md", "存储/ceph")
+	sr.AddQuestion(1, "q_1_b", "B", "B", "b.md", "存储")
+	sr.AddQuestion(1, "q_1_c", "C", "C", "c.md", "编程语言/go")
+	sr.AddQuestion(1, "q_1_d", "D", "D", "d.md", "编程语言")
+	sr.AddQuestion(1, "q_1_e", "E", "E", "e.md", "编程语言")
+
+	// q_1_a overdue, q_1_b and q_1_c due in two days, q_1_d after the
+	// horizon, q_1_e at noon 100 days ahead in Shanghai
+	now := time.Now()
+	local := now.In(shanghai)
+	noon := time.Date(local.Year(), local.Month(), local.Day(), 12, 0, 0, 0, shanghai)
+	set := func(id string, at time.Time) {
+		db.Model(&models.Question{}).Where("id = ?", id).Update("next_review", at)
+	}
+	set("q_1_a", now.Add(-72*time.Hour))
+	set("q_1_b", noon.AddDate(0, 0, 2))
+	set("q_1_c", noon.AddDate(0, 0, 2))
+	set("q_1_d", noon.AddDate(0, 0, 10))
+	set("q_1_e", noon.AddDate(0, 0, 100))
+
+	forecast, err := sr.GetCalendarForecast(1, 7)
+	if err != nil {
+		t.Fatalf("GetCalendarForecast failed: %v", err)
+	}
+	if len(forecast) != 7 || forecast[0]["date"] != local.Format("2006-01-02") {
+		t.Fatalf("expected 7 days from today in Shanghai, got %v", forecast)
+	}
+	if forecast[0]["count"].(int64) != 1 || forecast[1]["count"].(int64) != 0 || forecast[2]["count"].(int64) != 2 {
+		t.Errorf("unexpected counts %v", forecast)
+	}
+	cats := forecast[2]["categories"].(map[string]int64)
+	if len(cats) != 2 || cats["存储"] != 1 || cats["编程语言"] != 1 {
+		t.Errorf("expected counts per top-level category, got %v", cats)
+	}
+
+	// Long horizons
+	forecast, _ = sr.GetCalendarForecast(1, 120)
+	if forecast[100]["count"].(int64) != 1 || forecast[10]["count"].(int64) != 1 {
+		t.Errorf("expected cards 10 and 100 days ahead, got %v and %v", forecast[10], forecast[100])
+	}
+	if forecast, _ = sr.GetCalendarForecast(1, 1000); len(forecast) != MaxForecastDays {
+		t.Errorf("expected at most %d days, got %d", MaxForecastDays, len(forecast))
+	}
+
+	// No days, with q_1_a still overdue
+	for _, days := range []int{0, -3} {
+		if forecast, err = sr.GetCalendarForecast(1, days); err != nil || len(forecast) != 0 {
+			t.Errorf("expected no days for %d, got %v %v", days, forecast, err)
+		}
+	}
+}
+
+func TestGetForecastServerDays(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+	// The user's timezone only applies to the calendar feed
+	db.Create(&models.UserSettings{UserID: 1, Timezone: "Etc/GMT+12"})
+	sr.AddQuestion(1, "q_1_a", "A", "A", "a.md", "存储")
+	sr.AddQuestion(1, "q_1_b", "B", "B", "b.md", "存储")
+
+	now := time.Now()
+	midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	db.Model(&models.Question{}).Where("id = ?", "q_1_a").Update("next_review", now.Add(-72*time.Hour))
+	db.Model(&models.Question{}).Where("id = ?", "q_1_b").Update("next_review", midnight.AddDate(0, 0, 2).Add(30*time.Minute))
+
+	forecast, err := sr.GetForecast(1, 3)
+	if err != nil {
+		t.Fatalf("GetForecast failed: %v", err)
+	}
+	if forecast[0]["date"] != midnight.Format("2006-01-02") || forecast[0]["count"].(int64) != 1 ||
+		forecast[1]["count"].(int64) != 0 || forecast[2]["count"].(int64) != 1 {
+		t.Errorf("expected server days with the overdue card today, got %v", forecast)
+	}
+}
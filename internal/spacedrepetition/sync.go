@@ -0,0 +1,250 @@
+package spacedrepetition
+
+import (
+	"errors"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+
+	"self-improvement/internal/models"
+)
+
+// Outcomes of a synced review
+const (
+	SyncApplied   = "applied"   // Scheduled like an online review at its original time
+	SyncDuplicate = "duplicate" // The idempotency key was synced before
+	SyncMerged    = "merged"    // Older than another device's review; the card's history was replayed in order
+	SyncRecorded  = "recorded"  // Older than another device's review; counted without rescheduling
+	SyncRejected  = "rejected"  // Invalid review or unknown question
+)
+
+// ReviewEvent is a review made on a client, possibly while offline
+type ReviewEvent struct {
+	Key        string // Idempotency key chosen by the client
+	QuestionID string
+	Feedback   int
+	ReviewedAt time.Time
+	Device     string
+}
+
+// SyncResult is the outcome of one ReviewEvent
+type SyncResult struct {
+	Key        string `json:"key"`
+	QuestionID string `json:"question_id"`
+	Status     string `json:"status"`
+	Error      string `json:"error,omitempty"`
+}
+
+// MaxSyncAge is how old a synced review may be. Older reviews are rejected
+// so a client cannot backfill activity for arbitrary past days.
+const MaxSyncAge = 30 * 24 * time.Hour
+
+type pendingReview struct {
+	index int
+	event ReviewEvent
+}
+
+// SyncReviews applies a batch of client reviews. Reviews are replayed per
+// question in the order they were made, using their original time. Keys that
+// were already synced are skipped, so a client can safely resend a batch
+// after a dropped connection.
+//
+// When another device already reviewed a question after a synced review was
+// made, the question's full history is replayed with the late review in
+// place, provided the server holds that complete history. Otherwise (e.g. for
+// progress imported from Anki) the late review is counted but the newer
+// schedule is kept.
+//
+// It returns one result per event, in input order, and the resulting state of
+// every question that was touched.
+func (sr *SpacedRepetition) SyncReviews(userID uint, events []ReviewEvent) ([]SyncResult, []*models.Question, error) {
+	results := make([]SyncResult, len(events))
+	now := time.Now()
+
+	var keys []string
+	for i, ev := range events {
+		results[i] = SyncResult{Key: ev.Key, QuestionID: ev.QuestionID}
+		keys = append(keys, ev.Key)
+	}
+
+	var touched []string
+	err := sr.DB.Transaction(func(tx *gorm.DB) error {
+		var synced []string
+		if len(keys) > 0 {
+			if err := tx.Model(&models.ReviewLog{}).
+				Where("user_id = ? AND client_key IN ?", userID, keys).
+				Pluck("client_key", &synced).Error; err != nil {
+				return err
+			}
+		}
+		seen := make(map[string]bool)
+		for _, k := range synced {
+			seen[k] = true
+		}
+
+		byQuestion := make(map[string][]pendingReview)
+		var order []string
+		for i, ev := range events {
+			switch {
+			case ev.Key == "" || len(ev.Key) > 64:
+				results[i].Status, results[i].Error = SyncRejected, "invalid key"
+				continue
+			case ev.Feedback < 1 || ev.Feedback > 4:
+				results[i].Status, results[i].Error = SyncRejected, "feedback must be 1-4"
+				continue
+			case ev.ReviewedAt.IsZero():
+				results[i].Status, results[i].Error = SyncRejected, "missing reviewed_at"
+				continue
+			case ev.ReviewedAt.Before(now.Add(-MaxSyncAge)):
+				results[i].Status, results[i].Error = SyncRejected, "reviewed_at is too old"
+				continue
+			case seen[ev.Key]:
+				results[i].Status = SyncDuplicate
+				continue
+			}
+			seen[ev.Key] = true
+
+			// Clients with a fast clock must not schedule into the future
+			if ev.ReviewedAt.After(now) {
+				ev.ReviewedAt = now
+			}
+			if _, ok := byQuestion[ev.QuestionID]; !ok {
+				order = append(order, ev.QuestionID)
+			}
+			byQuestion[ev.QuestionID] = append(byQuestion[ev.QuestionID], pendingReview{index: i, event: ev})
+		}
+
+		for _, id := range order {
+			pending := byQuestion[id]
+			var q models.Question
+			err := tx.Where("user_id = ? AND id = ?", userID, id).First(&q).Error
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				for _, p := range pending {
+					results[p.index].Status, results[p.index].Error = SyncRejected, "question not found"
+				}
+				continue
+			}
+			if err != nil {
+				return err
+			}
+			kept := pending[:0]
+			for _, p := range pending {
+				if p.event.ReviewedAt.Before(q.CreatedAt) {
+					results[p.index].Status, results[p.index].Error = SyncRejected, "reviewed_at is before the question was added"
+					continue
+				}
+				kept = append(kept, p)
+			}
+			if pending = kept; len(pending) == 0 {
+				continue
+			}
+
+			sort.SliceStable(pending, func(i, j int) bool {
+				return pending[i].event.ReviewedAt.Before(pending[j].event.ReviewedAt)
+			})
+			if err := replayReviews(tx, &q, pending, results, intervalMultiplier(tx, userID, q.Category)); err != nil {
+				return err
+			}
+			for _, p := range pending {
+				if err := recordActivity(tx, userID, p.event.ReviewedAt, p.event.Feedback <= 2, 0); err != nil {
+					return err
+				}
+			}
+			touched = append(touched, id)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var questions []*models.Question
+	if len(touched) > 0 {
+		if err := sr.DB.Where("user_id = ? AND id IN ?", userID, touched).Find(&questions).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	return results, questions, sr.FillSharedContent(questions)
+}
+
+func newSyncedLog(q *models.Question, ev ReviewEvent) *models.ReviewLog {
+	key := ev.Key
+	return &models.ReviewLog{
+		UserID:     q.UserID,
+		QuestionID: q.ID,
+		Feedback:   ev.Feedback,
+		ReviewedAt: ev.ReviewedAt,
+		Source:     "app",
+		ClientKey:  &key,
+		Device:     ev.Device,
+	}
+}
+
+// replayReviews applies a question's pending reviews, sorted by time
+func replayReviews(tx *gorm.DB, q *models.Question, pending []pendingReview, results []SyncResult, scale float64) error {
+	late := q.LastReviewed != nil && pending[0].event.ReviewedAt.Before(*q.LastReviewed)
+	if !late {
+		for _, p := range pending {
+			log := newSyncedLog(q, p.event)
+			log.NewCard = q.ReviewCount == 0
+			log.IntervalHours = schedule(q, p.event.Feedback, p.event.ReviewedAt, scale)
+			if err := tx.Create(log).Error; err != nil {
+				return err
+			}
+			results[p.index].Status = SyncApplied
+		}
+		return tx.Save(q).Error
+	}
+
+	var history []*models.ReviewLog
+	if err := tx.Where("user_id = ? AND question_id = ?", q.UserID, q.ID).
+		Order("reviewed_at ASC, id ASC").Find(&history).Error; err != nil {
+		return err
+	}
+
+	if len(history) != q.ReviewCount {
+		// Without the full history the schedule cannot be rebuilt: count the
+		// late reviews, keep the newer schedule and apply the rest normally
+		for _, p := range pending {
+			log := newSyncedLog(q, p.event)
+			if p.event.ReviewedAt.Before(*q.LastReviewed) {
+				q.ReviewCount++
+				if p.event.Feedback <= 2 {
+					q.CorrectCount++
+				}
+				results[p.index].Status = SyncRecorded
+			} else {
+				log.NewCard = q.ReviewCount == 0
+				log.IntervalHours = schedule(q, p.event.Feedback, p.event.ReviewedAt, scale)
+				results[p.index].Status = SyncApplied
+			}
+			if err := tx.Create(log).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Save(q).Error
+	}
+
+	// Rebuild the schedule from a fresh card through every review in order
+	logs := history
+	for _, p := range pending {
+		logs = append(logs, newSyncedLog(q, p.event))
+		if p.event.ReviewedAt.Before(*q.LastReviewed) {
+			results[p.index].Status = SyncMerged
+		} else {
+			results[p.index].Status = SyncApplied
+		}
+	}
+	sort.SliceStable(logs, func(i, j int) bool { return logs[i].ReviewedAt.Before(logs[j].ReviewedAt) })
+
+	q.Level, q.ReviewCount, q.CorrectCount, q.LastReviewed = 4, 0, 0, nil
+	for _, log := range logs {
+		log.NewCard = q.ReviewCount == 0
+		log.IntervalHours = schedule(q, log.Feedback, log.ReviewedAt, scale)
+		if err := tx.Save(log).Error; err != nil {
+			return err
+		}
+	}
+	return tx.Save(q).Error
+}
@@ -0,0 +1,180 @@
+package spacedrepetition
+
+import (
+	"testing"
+	"time"
+
+	"self-improvement/internal/models"
+)
+
+func statuses(results []SyncResult) []string {
+	var out []string
+	for _, r := range results {
+		out = append(out, r.Status)
+	}
+	return out
+}
+
+// addOldQuestion adds a question created a week ago, so reviews synced from
+// the past few days are not rejected as older than the card
+func addOldQuestion(t *testing.T, sr *SpacedRepetition, id string) {
+	t.Helper()
+	if err := sr.AddQuestion(1, id, "Q-"+id, "A", "test.md", "go"); err != nil {
+		t.Fatalf("AddQuestion failed: %v", err)
+	}
+	sr.DB.Model(&models.Question{}).Where("id = ?", id).Update("created_at", time.Now().AddDate(0, 0, -7))
+}
+
+func TestSyncReviews_AppliesAtOriginalTime(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+	addOldQuestion(t, sr, "q1")
+
+	at := time.Now().Add(-48 * time.Hour)
+	results, questions, err := sr.SyncReviews(1, []ReviewEvent{
+		{Key: "k2", QuestionID: "q1", Feedback: 1, ReviewedAt: at.Add(time.Hour)},
+		{Key: "k1", QuestionID: "q1", Feedback: 3, ReviewedAt: at},
+		{Key: "k3", QuestionID: "missing", Feedback: 1, ReviewedAt: at},
+		{Key: "k4", QuestionID: "q1", Feedback: 7, ReviewedAt: at},
+	})
+	if err != nil {
+		t.Fatalf("SyncReviews failed: %v", err)
+	}
+	want := []string{SyncApplied, SyncApplied, SyncRejected, SyncRejected}
+	for i, s := range statuses(results) {
+		if s != want[i] {
+			t.Errorf("result %d: got %s, want %s", i, s, want[i])
+		}
+	}
+
+	if len(questions) != 1 {
+		t.Fatalf("expected 1 question state, got %d", len(questions))
+	}
+	q := questions[0]
+	// Replayed in time order: forgotten first, then proficient
+	if q.ReviewCount != 2 || q.CorrectCount != 1 || !q.LastReviewed.Equal(at.Add(time.Hour)) {
+		t.Errorf("unexpected state: %+v", q)
+	}
+	wantNext := at.Add(time.Hour).Add(7 * 24 * time.Hour * 5 / 2)
+	if d := q.NextReview.Sub(wantNext); d > time.Second || d < -time.Second {
+		t.Errorf("next review %v not based on original time, want %v", q.NextReview, wantNext)
+	}
+
+	// Resending the batch is a no-op
+	results, _, _ = sr.SyncReviews(1, []ReviewEvent{{Key: "k1", QuestionID: "q1", Feedback: 3, ReviewedAt: at}})
+	if results[0].Status != SyncDuplicate {
+		t.Errorf("expected duplicate, got %s", results[0].Status)
+	}
+	var logs int64
+	db.Model(&models.ReviewLog{}).Where("question_id = ?", "q1").Count(&logs)
+	if logs != 2 {
+		t.Errorf("expected 2 logs, got %d", logs)
+	}
+}
+
+func TestSyncReviews_LateReviewReplaysHistory(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+	addOldQuestion(t, sr, "q1")
+
+	// Device A reviews online, device B synced a review made an hour earlier
+	sr.UpdateReview(1, "q1", 1)
+	results, questions, err := sr.SyncReviews(1, []ReviewEvent{
+		{Key: "b1", QuestionID: "q1", Feedback: 4, ReviewedAt: time.Now().Add(-time.Hour), Device: "phone"},
+	})
+	if err != nil || results[0].Status != SyncMerged {
+		t.Fatalf("expected merged, got %v %v", results, err)
+	}
+
+	// Same result as if the reviews had arrived in order
+	db2 := setupTestDB(t)
+	ref := NewSpacedRepetition(db2)
+	addOldQuestion(t, ref, "q1")
+	ref.SyncReviews(1, []ReviewEvent{
+		{Key: "1", QuestionID: "q1", Feedback: 4, ReviewedAt: time.Now().Add(-time.Hour)},
+		{Key: "2", QuestionID: "q1", Feedback: 1, ReviewedAt: time.Now()},
+	})
+	want, _ := ref.GetQuestion(1, "q1")
+	got := questions[0]
+	if got.ReviewCount != want.ReviewCount || got.CorrectCount != want.CorrectCount || got.Level != want.Level {
+		t.Errorf("merged state %+v differs from in-order state %+v", got, want)
+	}
+	if d := got.NextReview.Sub(want.NextReview); d > time.Minute || d < -time.Minute {
+		t.Errorf("merged next review %v, in-order %v", got.NextReview, want.NextReview)
+	}
+}
+
+func TestSyncReviews_LateReviewWithoutHistory(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+	addOldQuestion(t, sr, "q1")
+
+	// Progress imported without its history
+	last := time.Now().Add(-time.Hour)
+	next := time.Now().Add(30 * 24 * time.Hour)
+	sr.ImportProgress(1, "q1", &models.Question{Level: 1, NextReview: next, ReviewCount: 10, CorrectCount: 9, LastReviewed: &last}, nil)
+
+	results, questions, _ := sr.SyncReviews(1, []ReviewEvent{
+		{Key: "k1", QuestionID: "q1", Feedback: 4, ReviewedAt: last.Add(-time.Hour)},
+	})
+	if results[0].Status != SyncRecorded {
+		t.Fatalf("expected recorded, got %s", results[0].Status)
+	}
+	q := questions[0]
+	if q.ReviewCount != 11 || q.CorrectCount != 9 || !q.NextReview.Equal(next) {
+		t.Errorf("newer schedule not kept: %+v", q)
+	}
+}
+
+func TestSyncReviews_FutureTimestampClamped(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+	addOldQuestion(t, sr, "q1")
+
+	_, questions, _ := sr.SyncReviews(1, []ReviewEvent{
+		{Key: "k1", QuestionID: "q1", Feedback: 4, ReviewedAt: time.Now().Add(24 * time.Hour)},
+	})
+	if questions[0].LastReviewed.After(time.Now()) {
+		t.Errorf("review time in the future: %v", questions[0].LastReviewed)
+	}
+}
+
+func TestSyncReviews_RejectsOldReviews(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+	addOldQuestion(t, sr, "q1")
+
+	results, questions, _ := sr.SyncReviews(1, []ReviewEvent{
+		{Key: "k1", QuestionID: "q1", Feedback: 1, ReviewedAt: time.Now().Add(-MaxSyncAge - time.Hour)},
+		{Key: "k2", QuestionID: "q1", Feedback: 1, ReviewedAt: time.Now().AddDate(0, 0, -8)},
+	})
+	if s := statuses(results); s[0] != SyncRejected || s[1] != SyncRejected {
+		t.Errorf("expected both rejected, got %v", results)
+	}
+	if len(questions) != 0 {
+		t.Errorf("expected no question touched, got %v", questions)
+	}
+	var activity int64
+	db.Model(&models.DailyActivity{}).Count(&activity)
+	if activity != 0 {
+		t.Errorf("expected no activity backfilled, got %d days", activity)
+	}
+}
+
+func TestSyncReviews_SubscribedCardContent(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+	sr.AddQuestion(1, "q_1_a", "Q", "A", "a.md", "go")
+	deck := &models.Deck{UserID: 1, Category: "go"}
+	sr.PublishDeck(deck)
+	sr.Subscribe(2, deck, "go")
+	due, _ := sr.GetDueQuestions(2)
+
+	_, questions, err := sr.SyncReviews(2, []ReviewEvent{{Key: "k1", QuestionID: due[0].ID, Feedback: 1, ReviewedAt: time.Now()}})
+	if err != nil || len(questions) != 1 {
+		t.Fatalf("SyncReviews failed: %v %v", questions, err)
+	}
+	if questions[0].QuestionText != "Q" || questions[0].AnswerText != "A" {
+		t.Errorf("subscribed card synced without content: %+v", questions[0])
+	}
+}
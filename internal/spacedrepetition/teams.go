@@ -0,0 +1,279 @@
+package spacedrepetition
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"self-improvement/internal/models"
+)
+
+var (
+	// ErrTeamMember is returned when adding a user to a team twice
+	ErrTeamMember = errors.New("already a team member")
+	// ErrNoInvitation is returned when accepting or declining without a
+	// pending invitation
+	ErrNoInvitation = errors.New("no pending invitation")
+	// ErrDeckAssigned is returned when assigning a deck to a team twice
+	ErrDeckAssigned = errors.New("deck already assigned")
+)
+
+// DeckProgress is a user's progress on the cards of a subscribed deck,
+// computed from the cards' scheduling state
+type DeckProgress struct {
+	Cards    int64   `json:"cards"`
+	Reviewed int64   `json:"reviewed"` // Cards reviewed at least once
+	Due      int64   `json:"due"`      // Cards due now
+	Mastered int64   `json:"mastered"` // Cards at level 1-2
+	Reviews  int64   `json:"reviews"`
+	Correct  int64   `json:"correct"`
+	Accuracy float64 `json:"accuracy"` // Correct reviews / reviews
+	Mastery  float64 `json:"mastery"`  // Mastered / cards
+}
+
+// Completed reports whether every card was reviewed at least once
+func (p *DeckProgress) Completed() bool {
+	return p.Cards > 0 && p.Reviewed == p.Cards
+}
+
+// Add adds other's counts to p and recomputes the ratios
+func (p *DeckProgress) Add(other *DeckProgress) {
+	p.Cards += other.Cards
+	p.Reviewed += other.Reviewed
+	p.Due += other.Due
+	p.Mastered += other.Mastered
+	p.Reviews += other.Reviews
+	p.Correct += other.Correct
+	p.rates()
+}
+
+func (p *DeckProgress) rates() {
+	p.Accuracy, p.Mastery = 0, 0
+	if p.Reviews > 0 {
+		p.Accuracy = float64(p.Correct) / float64(p.Reviews)
+	}
+	if p.Cards > 0 {
+		p.Mastery = float64(p.Mastered) / float64(p.Cards)
+	}
+}
+
+// CreateTeam creates a team owned by t.OwnerID
+func (sr *SpacedRepetition) CreateTeam(t *models.Team) error {
+	return sr.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(t).Error; err != nil {
+			return err
+		}
+		return tx.Create(&models.TeamMember{TeamID: t.ID, UserID: t.OwnerID, Role: models.TeamRoleOwner, Status: models.TeamMemberActive}).Error
+	})
+}
+
+// ListTeams returns the teams the user belongs to or is invited to, with the
+// user's membership in each
+func (sr *SpacedRepetition) ListTeams(userID uint) ([]*models.Team, map[uint]*models.TeamMember, error) {
+	var members []*models.TeamMember
+	if err := sr.DB.Where("user_id = ?", userID).Find(&members).Error; err != nil {
+		return nil, nil, err
+	}
+	memberships := make(map[uint]*models.TeamMember, len(members))
+	ids := make([]uint, len(members))
+	for i, m := range members {
+		memberships[m.TeamID] = m
+		ids[i] = m.TeamID
+	}
+	var teams []*models.Team
+	if len(ids) > 0 {
+		if err := sr.DB.Where("id IN ?", ids).Order("id ASC").Find(&teams).Error; err != nil {
+			return nil, nil, err
+		}
+	}
+	return teams, memberships, nil
+}
+
+// GetTeamMember returns the user's membership in a team
+func (sr *SpacedRepetition) GetTeamMember(teamID, userID uint) (*models.TeamMember, error) {
+	var m models.TeamMember
+	if err := sr.DB.Where("team_id = ? AND user_id = ?", teamID, userID).First(&m).Error; err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// TeamMembers returns the members and invited users of a team in the order
+// they were added
+func (sr *SpacedRepetition) TeamMembers(teamID uint) ([]*models.TeamMember, error) {
+	var members []*models.TeamMember
+	err := sr.DB.Where("team_id = ?", teamID).Order("id ASC").Find(&members).Error
+	return members, err
+}
+
+// AddTeamMember adds a user to a team, or invites them when m.Status is
+// TeamMemberInvited
+func (sr *SpacedRepetition) AddTeamMember(m *models.TeamMember) error {
+	var count int64
+	sr.DB.Model(&models.TeamMember{}).Where("team_id = ? AND user_id = ?", m.TeamID, m.UserID).Count(&count)
+	if count > 0 {
+		return ErrTeamMember
+	}
+	return sr.DB.Create(m).Error
+}
+
+// AcceptTeamInvitation makes an invited user a member of the team
+func (sr *SpacedRepetition) AcceptTeamInvitation(teamID, userID uint) (*models.TeamMember, error) {
+	m, err := sr.GetTeamMember(teamID, userID)
+	if err != nil || m.Active() {
+		return nil, ErrNoInvitation
+	}
+	m.Status = models.TeamMemberActive
+	return m, sr.DB.Model(m).Update("status", m.Status).Error
+}
+
+// DeclineTeamInvitation removes a pending invitation
+func (sr *SpacedRepetition) DeclineTeamInvitation(teamID, userID uint) error {
+	res := sr.DB.Where("team_id = ? AND user_id = ? AND status = ?", teamID, userID, models.TeamMemberInvited).
+		Delete(&models.TeamMember{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return ErrNoInvitation
+	}
+	return nil
+}
+
+// SetTeamRole changes a member's role
+func (sr *SpacedRepetition) SetTeamRole(m *models.TeamMember, role string) error {
+	m.Role = role
+	return sr.DB.Model(m).Update("role", role).Error
+}
+
+// RemoveTeamMember removes a user from a team. The user keeps the
+// subscriptions of the team's assignments.
+func (sr *SpacedRepetition) RemoveTeamMember(teamID, userID uint) error {
+	return sr.DB.Where("team_id = ? AND user_id = ?", teamID, userID).Delete(&models.TeamMember{}).Error
+}
+
+// DeleteTeam deletes a team with its memberships and assignments
+func (sr *SpacedRepetition) DeleteTeam(teamID uint) error {
+	return sr.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("team_id = ?", teamID).Delete(&models.TeamAssignment{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("team_id = ?", teamID).Delete(&models.TeamMember{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.Team{}, teamID).Error
+	})
+}
+
+// AssignDeck assigns a deck to a team
+func (sr *SpacedRepetition) AssignDeck(a *models.TeamAssignment) error {
+	var count int64
+	sr.DB.Model(&models.TeamAssignment{}).Where("team_id = ? AND deck_id = ?", a.TeamID, a.DeckID).Count(&count)
+	if count > 0 {
+		return ErrDeckAssigned
+	}
+	return sr.DB.Create(a).Error
+}
+
+// ListAssignments returns a team's assignments, earliest deadline first, with
+// their decks. Assignments without a deadline come last.
+func (sr *SpacedRepetition) ListAssignments(teamID uint) ([]*models.TeamAssignment, map[uint]*models.Deck, error) {
+	var list []*models.TeamAssignment
+	err := sr.DB.Where("team_id = ?", teamID).
+		Order("deadline IS NULL, deadline ASC, id ASC").Find(&list).Error
+	if err != nil {
+		return nil, nil, err
+	}
+	decks := make(map[uint]*models.Deck, len(list))
+	if len(list) == 0 {
+		return list, decks, nil
+	}
+	ids := make([]uint, len(list))
+	for i, a := range list {
+		ids[i] = a.DeckID
+	}
+	var found []*models.Deck
+	if err := sr.DB.Where("id IN ?", ids).Find(&found).Error; err != nil {
+		return nil, nil, err
+	}
+	for _, d := range found {
+		decks[d.ID] = d
+	}
+	return list, decks, nil
+}
+
+// AssignmentDeadline is an assignment with a deadline in one of the user's
+// teams
+type AssignmentDeadline struct {
+	ID       uint      `json:"id"`
+	Team     string    `json:"team"`
+	Deck     string    `json:"deck"`
+	Deadline time.Time `json:"deadline"`
+}
+
+// AssignmentDeadlines returns the deadlines of the assignments in all teams
+// the user is a member of, earliest first
+func (sr *SpacedRepetition) AssignmentDeadlines(userID uint) ([]*AssignmentDeadline, error) {
+	var list []*AssignmentDeadline
+	err := sr.DB.Table("team_assignments").
+		Select("team_assignments.id, teams.name AS team, COALESCE(decks.title, '') AS deck, team_assignments.deadline").
+		Joins("JOIN team_members ON team_members.team_id = team_assignments.team_id").
+		Joins("JOIN teams ON teams.id = team_assignments.team_id").
+		Joins("LEFT JOIN decks ON decks.id = team_assignments.deck_id").
+		Where("team_members.user_id = ? AND team_members.status = ? AND team_assignments.deadline IS NOT NULL", userID, models.TeamMemberActive).
+		Order("team_assignments.deadline ASC, team_assignments.id ASC").
+		Scan(&list).Error
+	return list, err
+}
+
+// Unassign removes an assignment. Members keep their subscriptions.
+func (sr *SpacedRepetition) Unassign(teamID, id uint) error {
+	res := sr.DB.Where("team_id = ? AND id = ?", teamID, id).Delete(&models.TeamAssignment{})
+	if res.Error != nil {
+		return res.Error
+	}
+	if res.RowsAffected == 0 {
+		return gorm.ErrRecordNotFound
+	}
+	return nil
+}
+
+// DeckProgress returns each user's progress on their cards of a deck: the
+// subscribed cards of subscribers and the deck's own questions of the
+// author. Users without cards of the deck get zero progress.
+func (sr *SpacedRepetition) DeckProgress(d *models.Deck, userIDs []uint) (map[uint]*DeckProgress, error) {
+	progress := make(map[uint]*DeckProgress, len(userIDs))
+	for _, id := range userIDs {
+		progress[id] = &DeckProgress{}
+	}
+	if len(userIDs) == 0 {
+		return progress, nil
+	}
+
+	queries := []*gorm.DB{sr.DB.Model(&models.Question{}).Where("deck_id = ? AND user_id IN ?", d.ID, userIDs)}
+	if _, ok := progress[d.UserID]; ok {
+		queries = append(queries, inCategories(sr.DB.Model(&models.Question{}).
+			Where("user_id = ? AND deck_id = 0", d.UserID), "category", []string{d.Category}))
+	}
+	now := time.Now()
+	for _, query := range queries {
+		var rows []struct {
+			UserID uint
+			DeckProgress
+		}
+		err := query.Select(`user_id, COUNT(*) AS cards,
+			SUM(CASE WHEN review_count > 0 THEN 1 ELSE 0 END) AS reviewed,
+			SUM(CASE WHEN next_review <= ? THEN 1 ELSE 0 END) AS due,
+			SUM(CASE WHEN level <= 2 THEN 1 ELSE 0 END) AS mastered,
+			SUM(review_count) AS reviews, SUM(correct_count) AS correct`, now).
+			Group("user_id").Scan(&rows).Error
+		if err != nil {
+			return nil, err
+		}
+		for i := range rows {
+			progress[rows[i].UserID].Add(&rows[i].DeckProgress)
+		}
+	}
+	return progress, nil
+}
@@ -0,0 +1,158 @@
+package spacedrepetition
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"self-improvement/internal/models"
+)
+
+func TestTeamMembership(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	team := &models.Team{Name: "后端组", OwnerID: 1}
+	if err := sr.CreateTeam(team); err != nil {
+		t.Fatalf("CreateTeam failed: %v", err)
+	}
+	owner, err := sr.GetTeamMember(team.ID, 1)
+	if err != nil || owner.Role != models.TeamRoleOwner {
+		t.Fatalf("expected owner membership, got %+v (%v)", owner, err)
+	}
+	if err := sr.AddTeamMember(&models.TeamMember{TeamID: team.ID, UserID: 2, Role: models.TeamRoleMember, Status: models.TeamMemberInvited}); err != nil {
+		t.Fatalf("AddTeamMember failed: %v", err)
+	}
+	if err := sr.AddTeamMember(&models.TeamMember{TeamID: team.ID, UserID: 2, Role: models.TeamRoleAdmin}); !errors.Is(err, ErrTeamMember) {
+		t.Errorf("expected ErrTeamMember, got %v", err)
+	}
+
+	teams, memberships, err := sr.ListTeams(2)
+	if err != nil || len(teams) != 1 || memberships[team.ID].Role != models.TeamRoleMember || memberships[team.ID].Active() {
+		t.Errorf("unexpected teams %v %v (%v)", teams, memberships, err)
+	}
+	if _, err := sr.AcceptTeamInvitation(team.ID, 3); !errors.Is(err, ErrNoInvitation) {
+		t.Errorf("expected ErrNoInvitation for a user without invitation, got %v", err)
+	}
+	if m, err := sr.AcceptTeamInvitation(team.ID, 2); err != nil || !m.Active() {
+		t.Fatalf("AcceptTeamInvitation failed: %+v (%v)", m, err)
+	}
+	if _, err := sr.AcceptTeamInvitation(team.ID, 2); !errors.Is(err, ErrNoInvitation) {
+		t.Errorf("expected ErrNoInvitation for an accepted invitation, got %v", err)
+	}
+	if err := sr.DeclineTeamInvitation(team.ID, 2); !errors.Is(err, ErrNoInvitation) {
+		t.Errorf("members cannot decline, got %v", err)
+	}
+	sr.AddTeamMember(&models.TeamMember{TeamID: team.ID, UserID: 3, Role: models.TeamRoleMember, Status: models.TeamMemberInvited})
+	if err := sr.DeclineTeamInvitation(team.ID, 3); err != nil {
+		t.Errorf("DeclineTeamInvitation failed: %v", err)
+	}
+	if _, err := sr.GetTeamMember(team.ID, 3); err == nil {
+		t.Error("declined invitation still present")
+	}
+
+	a := &models.TeamAssignment{TeamID: team.ID, DeckID: 7}
+	if err := sr.AssignDeck(a); err != nil {
+		t.Fatalf("AssignDeck failed: %v", err)
+	}
+	if err := sr.AssignDeck(&models.TeamAssignment{TeamID: team.ID, DeckID: 7}); !errors.Is(err, ErrDeckAssigned) {
+		t.Errorf("expected ErrDeckAssigned, got %v", err)
+	}
+
+	if err := sr.DeleteTeam(team.ID); err != nil {
+		t.Fatalf("DeleteTeam failed: %v", err)
+	}
+	var members, assignments int64
+	db.Model(&models.TeamMember{}).Count(&members)
+	db.Model(&models.TeamAssignment{}).Count(&assignments)
+	if members != 0 || assignments != 0 {
+		t.Errorf("expected memberships and assignments to be deleted, got %d and %d", members, assignments)
+	}
+}
+
+func TestDeckProgress(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	sr.AddQuestion(1, "q_1_a", "问题 A", "答案 A", "a.md", "ops")
+	sr.AddQuestion(1, "q_1_b", "问题 B", "答案 B", "b.md", "ops")
+	deck := &models.Deck{UserID: 1, Category: "ops", Title: "运维"}
+	if err := sr.PublishDeck(deck); err != nil {
+		t.Fatalf("PublishDeck failed: %v", err)
+	}
+	if _, _, err := sr.Subscribe(2, deck, "运维"); err != nil {
+		t.Fatalf("Subscribe failed: %v", err)
+	}
+
+	var cards []models.Question
+	db.Where("user_id = 2").Order("id").Find(&cards)
+	// Three correct reviews make the first card proficient
+	for i := 0; i < 3; i++ {
+		if err := sr.UpdateReview(2, cards[0].ID, 1); err != nil {
+			t.Fatalf("UpdateReview failed: %v", err)
+		}
+	}
+	sr.UpdateReview(2, cards[0].ID, 4)
+	for i := 0; i < 3; i++ {
+		sr.UpdateReview(2, cards[0].ID, 1)
+	}
+
+	sr.UpdateReview(1, "q_1_a", 1)
+
+	progress, err := sr.DeckProgress(deck, []uint{1, 2, 3})
+	if err != nil {
+		t.Fatalf("DeckProgress failed: %v", err)
+	}
+	p := progress[2]
+	if p.Cards != 2 || p.Reviewed != 1 || p.Due != 1 || p.Reviews != 7 || p.Correct != 6 {
+		t.Errorf("unexpected progress %+v", p)
+	}
+	if p.Mastered != 1 || p.Mastery != 0.5 || p.Accuracy != 6.0/7 || p.Completed() {
+		t.Errorf("unexpected rates %+v", p)
+	}
+	if q := progress[3]; q.Cards != 0 || q.Accuracy != 0 {
+		t.Errorf("expected zero progress for a user without cards, got %+v", q)
+	}
+	// The author studies the deck's questions themselves
+	if a := progress[1]; a.Cards != 2 || a.Reviewed != 1 || a.Reviews != 1 {
+		t.Errorf("unexpected author progress %+v", a)
+	}
+
+	total := &DeckProgress{}
+	total.Add(progress[2])
+	total.Add(progress[3])
+	if total.Cards != 2 || total.Accuracy != p.Accuracy {
+		t.Errorf("unexpected total %+v", total)
+	}
+}
+
+func TestAssignmentDeadlines(t *testing.T) {
+	db := setupTestDB(t)
+	sr := NewSpacedRepetition(db)
+
+	team := &models.Team{Name: "后端组", OwnerID: 1}
+	sr.CreateTeam(team)
+	sr.AddTeamMember(&models.TeamMember{TeamID: team.ID, UserID: 2, Role: models.TeamRoleMember, Status: models.TeamMemberActive})
+	sr.AddTeamMember(&models.TeamMember{TeamID: team.ID, UserID: 3, Role: models.TeamRoleMember, Status: models.TeamMemberInvited})
+	ops := &models.Deck{UserID: 1, Category: "ops", Title: "运维"}
+	golang := &models.Deck{UserID: 1, Category: "go", Title: "Go"}
+	sr.PublishDeck(ops)
+	sr.PublishDeck(golang)
+
+	later := time.Now().Add(14 * 24 * time.Hour).Truncate(time.Second)
+	sooner := time.Now().Add(7 * 24 * time.Hour).Truncate(time.Second)
+	sr.AssignDeck(&models.TeamAssignment{TeamID: team.ID, DeckID: ops.ID, Deadline: &later})
+	sr.AssignDeck(&models.TeamAssignment{TeamID: team.ID, DeckID: golang.ID, Deadline: &sooner})
+	sr.AssignDeck(&models.TeamAssignment{TeamID: team.ID, DeckID: 99}) // No deadline
+
+	list, err := sr.AssignmentDeadlines(2)
+	if err != nil {
+		t.Fatalf("AssignmentDeadlines failed: %v", err)
+	}
+	if len(list) != 2 || list[0].Deck != "Go" || list[1].Deck != "运维" || list[0].Team != "后端组" || !list[0].Deadline.Equal(sooner) {
+		t.Errorf("unexpected deadlines %+v", list)
+	}
+	if list, _ := sr.AssignmentDeadlines(3); len(list) != 0 {
+		t.Errorf("expected no deadlines before accepting the invitation, got %d", len(list))
+	}
+}
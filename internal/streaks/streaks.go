@@ -0,0 +1,173 @@
+// Package streaks evaluates daily goals, study streaks and achievements from
+// per-day review activity. It is shared by the web server and the CLI.
+package streaks
+
+import (
+	"sort"
+	"time"
+)
+
+// DateLayout is the format of Day.Date
+const DateLayout = "2006-01-02"
+
+// Goal types
+const (
+	GoalCards   = "cards"   // Reviews per day
+	GoalMinutes = "minutes" // Minutes on cards per day
+)
+
+// MaxGoalTarget bounds the daily goal for both goal types
+const MaxGoalTarget = 1000
+
+// PerfectDayReviews is the number of reviews a perfect day needs, all correct
+const PerfectDayReviews = 20
+
+// DefaultGoal is the goal of users who did not set one
+var DefaultGoal = Goal{Type: GoalCards, Target: 20}
+
+// Goal is a daily study goal
+type Goal struct {
+	Type   string `json:"type"`
+	Target int    `json:"target"`
+}
+
+// Valid reports whether the goal has a known type and a target in range
+func (g Goal) Valid() bool {
+	return (g.Type == GoalCards || g.Type == GoalMinutes) && g.Target >= 1 && g.Target <= MaxGoalTarget
+}
+
+// Progress is how far a day's reviews and study time are towards the goal,
+// capped at 1
+func (g Goal) Progress(reviews int, studyMs int64) float64 {
+	if g.Target <= 0 {
+		return 1
+	}
+	done := float64(reviews)
+	if g.Type == GoalMinutes {
+		done = float64(studyMs) / float64(time.Minute/time.Millisecond)
+	}
+	if p := done / float64(g.Target); p < 1 {
+		return p
+	}
+	return 1
+}
+
+// Met reports whether the goal is reached
+func (g Goal) Met(reviews int, studyMs int64) bool {
+	return g.Progress(reviews, studyMs) >= 1
+}
+
+// Day is the review activity of one day in the user's timezone
+type Day struct {
+	Date    string `json:"date"`
+	Reviews int    `json:"reviews"`
+	Correct int    `json:"correct"`
+	StudyMs int64  `json:"study_ms"`
+	GoalMet bool   `json:"goal_met"` // The goal of that day was reached
+	Cleared bool   `json:"cleared"`  // No card was due after a review that day
+}
+
+// Counts reports whether the day extends a streak
+func (d Day) Counts() bool {
+	return d.GoalMet || d.Cleared
+}
+
+// Perfect reports whether every one of at least PerfectDayReviews reviews
+// was correct
+func (d Day) Perfect() bool {
+	return d.Reviews >= PerfectDayReviews && d.Correct == d.Reviews
+}
+
+// Streaks returns the current and the longest run of consecutive days that
+// count. The current streak still holds when today does not count yet but
+// yesterday did.
+func Streaks(days []Day, today string) (current, longest int) {
+	var dates []time.Time
+	for _, d := range days {
+		if !d.Counts() {
+			continue
+		}
+		if t, err := time.Parse(DateLayout, d.Date); err == nil {
+			dates = append(dates, t)
+		}
+	}
+	sort.Slice(dates, func(i, j int) bool { return dates[i].Before(dates[j]) })
+
+	run := 0
+	var prev time.Time
+	for i, t := range dates {
+		switch {
+		case i > 0 && t.Equal(prev):
+			continue
+		case i > 0 && t.Equal(prev.AddDate(0, 0, 1)):
+			run++
+		default:
+			run = 1
+		}
+		prev = t
+		if run > longest {
+			longest = run
+		}
+	}
+
+	end, err := time.Parse(DateLayout, today)
+	if err != nil || len(dates) == 0 {
+		return 0, longest
+	}
+	if prev.Equal(end) || prev.Equal(end.AddDate(0, 0, -1)) {
+		current = run
+	}
+	return current, longest
+}
+
+// Totals are the numbers achievements are computed from
+type Totals struct {
+	Reviews       int64 // All reviews
+	Mastered      int64 // Cards at level 1
+	LongestStreak int
+	PerfectDays   int
+}
+
+// Achievement is a badge with the progress towards it
+type Achievement struct {
+	ID          string `json:"id"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Progress    int64  `json:"progress"`
+	Target      int64  `json:"target"`
+	Earned      bool   `json:"earned"`
+}
+
+var achievements = []struct {
+	id, name, description string
+	target                int64
+	value                 func(Totals) int64
+}{
+	{"first_review", "初次复习", "完成第一次复习", 1, func(t Totals) int64 { return t.Reviews }},
+	{"reviews_100", "百题斩", "累计复习 100 次", 100, func(t Totals) int64 { return t.Reviews }},
+	{"reviews_1000", "千锤百炼", "累计复习 1000 次", 1000, func(t Totals) int64 { return t.Reviews }},
+	{"streak_7", "坚持一周", "连续 7 天完成目标或清空待复习", 7, func(t Totals) int64 { return int64(t.LongestStreak) }},
+	{"streak_30", "月度坚持", "连续 30 天完成目标或清空待复习", 30, func(t Totals) int64 { return int64(t.LongestStreak) }},
+	{"perfect_day", "完美一天", "一天内复习至少 20 次且全部答对", 1, func(t Totals) int64 { return int64(t.PerfectDays) }},
+	{"mastered_50", "熟能生巧", "50 张卡片达到熟练", 50, func(t Totals) int64 { return t.Mastered }},
+}
+
+// Achievements returns every achievement with the progress towards it
+func Achievements(t Totals) []Achievement {
+	list := make([]Achievement, len(achievements))
+	for i, a := range achievements {
+		progress := a.value(t)
+		if progress > a.target {
+			progress = a.target
+		}
+		list[i] = Achievement{
+			ID:          a.id,
+			Name:        a.name,
+			Description: a.description,
+			Progress:    progress,
+			Target:      a.target,
+			Earned:      progress >= a.target,
+		}
+	}
+	return list
+}
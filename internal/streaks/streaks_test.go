@@ -0,0 +1,66 @@
+package streaks
+
+import "testing"
+
+func TestGoal(t *testing.T) {
+	cards := Goal{Type: GoalCards, Target: 20}
+	if !cards.Valid() || cards.Met(19, 0) || !cards.Met(20, 0) || cards.Progress(10, 0) != 0.5 {
+		t.Errorf("unexpected cards goal behaviour")
+	}
+	minutes := Goal{Type: GoalMinutes, Target: 10}
+	if minutes.Met(100, 9*60000) || !minutes.Met(0, 10*60000) || minutes.Progress(0, 20*60000) != 1 {
+		t.Errorf("unexpected minutes goal behaviour")
+	}
+	for _, g := range []Goal{{Type: "pages", Target: 5}, {Type: GoalCards}, {Type: GoalMinutes, Target: MaxGoalTarget + 1}} {
+		if g.Valid() {
+			t.Errorf("expected %+v to be invalid", g)
+		}
+	}
+}
+
+func TestStreaks(t *testing.T) {
+	days := []Day{
+		{Date: "2026-10-01", GoalMet: true},
+		{Date: "2026-10-02", Cleared: true},
+		{Date: "2026-10-03", GoalMet: true},
+		{Date: "2026-10-04", Reviews: 3}, // Neither goal nor queue
+		{Date: "2026-10-05", GoalMet: true},
+		{Date: "2026-10-06", GoalMet: true},
+	}
+	for _, tc := range []struct {
+		today            string
+		current, longest int
+	}{
+		{"2026-10-06", 2, 3},
+		{"2026-10-07", 2, 3}, // Today does not count yet
+		{"2026-10-08", 0, 3}, // Yesterday was missed
+	} {
+		current, longest := Streaks(days, tc.today)
+		if current != tc.current || longest != tc.longest {
+			t.Errorf("Streaks(%s) = %d, %d, want %d, %d", tc.today, current, longest, tc.current, tc.longest)
+		}
+	}
+	if current, longest := Streaks(nil, "2026-10-06"); current != 0 || longest != 0 {
+		t.Errorf("expected no streak without days, got %d, %d", current, longest)
+	}
+}
+
+func TestAchievements(t *testing.T) {
+	list := Achievements(Totals{Reviews: 150, LongestStreak: 8, PerfectDays: 0, Mastered: 10})
+	earned := make(map[string]Achievement)
+	for _, a := range list {
+		earned[a.ID] = a
+	}
+	if !earned["first_review"].Earned || !earned["reviews_100"].Earned || earned["reviews_1000"].Earned {
+		t.Errorf("unexpected review achievements: %+v", list)
+	}
+	if !earned["streak_7"].Earned || earned["streak_30"].Progress != 8 || earned["perfect_day"].Earned {
+		t.Errorf("unexpected streak achievements: %+v", list)
+	}
+	if earned["reviews_100"].Progress != 100 {
+		t.Errorf("expected progress to be capped at the target, got %d", earned["reviews_100"].Progress)
+	}
+	if (Day{Reviews: 20, Correct: 20}).Perfect() == false || (Day{Reviews: 19, Correct: 19}).Perfect() {
+		t.Errorf("unexpected perfect day")
+	}
+}
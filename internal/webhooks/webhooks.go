@@ -0,0 +1,364 @@
+// Package webhooks delivers a user's learning events to the URLs they
+// subscribed.
+//
+// Every delivery is a JSON POST of a Payload. The body is signed with
+// HMAC-SHA256 under the webhook's secret and the signature sent as
+// "X-Webhook-Signature: sha256=<hex>", so receivers can check that a request
+// came from this server. Failed attempts (network errors and non-2xx
+// responses) are retried with exponential backoff. Each delivery is stored in
+// the webhook_deliveries table together with the outcome of its latest
+// attempt, which makes up the delivery log.
+//
+// The default client refuses to connect to loopback, private and link-local
+// addresses. The check runs on the resolved address when dialing, so host
+// names pointing into the server's network and redirects are caught too.
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"gorm.io/gorm"
+
+	"self-improvement/internal/models"
+)
+
+// Events users can subscribe to
+const (
+	EventReviewCompleted = "review.completed"
+	EventSessionFinished = "session.finished"
+	EventGoalMet         = "goal.met"
+	EventImportFinished  = "import.finished"
+	EventLeechDetected   = "leech.detected"
+)
+
+// EventPing is sent by test deliveries. Every webhook receives it.
+const EventPing = "ping"
+
+// Events lists the events users can subscribe to
+var Events = []string{EventReviewCompleted, EventSessionFinished, EventGoalMet, EventImportFinished, EventLeechDetected}
+
+// Request headers besides the signature
+const (
+	HeaderEvent     = "X-Webhook-Event"
+	HeaderDelivery  = "X-Webhook-Delivery"
+	HeaderSignature = "X-Webhook-Signature"
+)
+
+// ErrBlockedAddress is returned when connecting to an address deliveries may
+// not reach
+var ErrBlockedAddress = errors.New("webhook address is not allowed")
+
+const (
+	// maxResponse is how much of a successful response body is kept in the
+	// log
+	maxResponse = 1024
+	// maxDeliveries is how many deliveries are kept per webhook
+	maxDeliveries = 100
+	// maxBackoff caps the delay between retries
+	maxBackoff = time.Hour
+)
+
+// Payload is the body of every delivery
+type Payload struct {
+	ID        uint        `json:"id"` // Delivery ID, also sent in HeaderDelivery
+	Event     string      `json:"event"`
+	CreatedAt time.Time   `json:"created_at"`
+	Data      interface{} `json:"data"`
+}
+
+// Dispatcher sends events to webhooks
+type Dispatcher struct {
+	DB     *gorm.DB
+	Client *http.Client
+	// MaxAttempts is the number of attempts before a delivery fails
+	MaxAttempts int
+	// Backoff is the delay before the first retry. It doubles with every
+	// further retry.
+	Backoff time.Duration
+
+	wg sync.WaitGroup
+}
+
+// New returns a Dispatcher that tries each delivery 5 times over about 2.5
+// minutes and refuses blocked addresses
+func New(db *gorm.DB) *Dispatcher {
+	return &Dispatcher{
+		DB:          db,
+		Client:      NewClient(false),
+		MaxAttempts: 5,
+		Backoff:     10 * time.Second,
+	}
+}
+
+// NewClient returns the HTTP client for deliveries. Unless allowPrivate is
+// set, it refuses to connect to blocked addresses with ErrBlockedAddress.
+func NewClient(allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 10 * time.Second}
+	if !allowPrivate {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || Blocked(ip) {
+				return ErrBlockedAddress
+			}
+			return nil
+		}
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	// A proxy would make the connection on our behalf, past the check
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: 10 * time.Second, Transport: transport}
+}
+
+// Blocked tells whether ip is a loopback, private, link-local or unspecified
+// address, which would let deliveries reach the server's own network
+func Blocked(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() || ip.IsUnspecified()
+}
+
+// NewSecret returns a random signing secret
+func NewSecret() string {
+	b := make([]byte, 24)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// Sign returns the signature header value of body under secret
+func Sign(secret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify tells whether signature is the signature of body under secret
+func Verify(secret string, body []byte, signature string) bool {
+	return hmac.Equal([]byte(Sign(secret, body)), []byte(signature))
+}
+
+// ValidEvent tells whether users can subscribe to event
+func ValidEvent(event string) bool {
+	for _, e := range Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Subscribed tells whether the webhook receives event
+func Subscribed(hook *models.Webhook, event string) bool {
+	if event == EventPing {
+		return true
+	}
+	for _, e := range strings.Split(hook.Events, ",") {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Enabled tells whether the user has an active webhook, so callers can skip
+// gathering event data nobody receives
+func (d *Dispatcher) Enabled(userID uint) bool {
+	var n int64
+	d.DB.Model(&models.Webhook{}).Where("user_id = ? AND active = ?", userID, true).Count(&n)
+	return n > 0
+}
+
+// Emit queues event for every active webhook of the user subscribed to it
+// and delivers it in the background
+func (d *Dispatcher) Emit(userID uint, event string, data interface{}) {
+	var hooks []models.Webhook
+	if err := d.DB.Where("user_id = ? AND active = ?", userID, true).Find(&hooks).Error; err != nil {
+		return
+	}
+	for i := range hooks {
+		hook := &hooks[i]
+		if !Subscribed(hook, event) {
+			continue
+		}
+		delivery, err := d.create(hook, event, data)
+		if err != nil {
+			continue
+		}
+		d.prune(hook.ID)
+		d.wg.Add(1)
+		go d.run(*hook, delivery, 0)
+	}
+}
+
+// Test sends a ping to the webhook and waits for the response. Test
+// deliveries are not retried.
+func (d *Dispatcher) Test(hook *models.Webhook) (*models.WebhookDelivery, error) {
+	delivery, err := d.create(hook, EventPing, map[string]interface{}{"webhook_id": hook.ID, "url": hook.URL})
+	if err != nil {
+		return nil, err
+	}
+	d.prune(hook.ID)
+	ok := d.attempt(hook, delivery)
+	delivery.Status = models.WebhookDeliverySucceeded
+	if !ok {
+		delivery.Status = models.WebhookDeliveryFailed
+	}
+	return delivery, d.DB.Save(delivery).Error
+}
+
+// Resume schedules the retries of deliveries that were pending when the
+// server stopped
+func (d *Dispatcher) Resume() error {
+	var pending []*models.WebhookDelivery
+	if err := d.DB.Where("status = ?", models.WebhookDeliveryPending).Find(&pending).Error; err != nil {
+		return err
+	}
+	for _, delivery := range pending {
+		var hook models.Webhook
+		if err := d.DB.Where("id = ?", delivery.WebhookID).First(&hook).Error; err != nil {
+			continue
+		}
+		var wait time.Duration
+		if delivery.NextAttemptAt != nil {
+			wait = time.Until(*delivery.NextAttemptAt)
+		}
+		d.wg.Add(1)
+		go d.run(hook, delivery, wait)
+	}
+	return nil
+}
+
+// Wait blocks until no delivery is in flight or waiting for a retry
+func (d *Dispatcher) Wait() {
+	d.wg.Wait()
+}
+
+// create stores a pending delivery with its payload
+func (d *Dispatcher) create(hook *models.Webhook, event string, data interface{}) (*models.WebhookDelivery, error) {
+	delivery := &models.WebhookDelivery{
+		WebhookID: hook.ID,
+		UserID:    hook.UserID,
+		Event:     event,
+		Status:    models.WebhookDeliveryPending,
+	}
+	if err := d.DB.Create(delivery).Error; err != nil {
+		return nil, err
+	}
+	body, err := json.Marshal(Payload{ID: delivery.ID, Event: event, CreatedAt: delivery.CreatedAt, Data: data})
+	if err != nil {
+		return nil, err
+	}
+	delivery.Payload = string(body)
+	return delivery, d.DB.Model(delivery).Update("payload", delivery.Payload).Error
+}
+
+// prune drops all but the latest maxDeliveries finished deliveries of a
+// webhook
+func (d *Dispatcher) prune(webhookID uint) {
+	cutoff := d.DB.Model(&models.WebhookDelivery{}).Select("id").
+		Where("webhook_id = ?", webhookID).Order("id DESC").Offset(maxDeliveries - 1).Limit(1)
+	d.DB.Where("webhook_id = ? AND status != ? AND id < (?)", webhookID, models.WebhookDeliveryPending, cutoff).
+		Delete(&models.WebhookDelivery{})
+}
+
+// run attempts a delivery after wait until it succeeds or runs out of
+// attempts. It stops early when the webhook was deleted or disabled.
+func (d *Dispatcher) run(hook models.Webhook, delivery *models.WebhookDelivery, wait time.Duration) {
+	defer d.wg.Done()
+	for {
+		if wait > 0 {
+			time.Sleep(wait)
+			if err := d.DB.Where("id = ?", hook.ID).First(&hook).Error; err != nil {
+				return
+			}
+			if !hook.Active {
+				delivery.Status = models.WebhookDeliveryFailed
+				delivery.Error = "webhook disabled"
+				delivery.NextAttemptAt = nil
+				d.DB.Save(delivery)
+				return
+			}
+		}
+
+		ok := d.attempt(&hook, delivery)
+		switch {
+		case ok:
+			delivery.Status = models.WebhookDeliverySucceeded
+		case delivery.Attempts >= d.MaxAttempts:
+			delivery.Status = models.WebhookDeliveryFailed
+		default:
+			wait = d.backoff(delivery.Attempts)
+			next := time.Now().Add(wait)
+			delivery.NextAttemptAt = &next
+		}
+		if err := d.DB.Save(delivery).Error; err != nil || delivery.Status != models.WebhookDeliveryPending {
+			return
+		}
+	}
+}
+
+// backoff returns the delay after the given number of failed attempts
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	wait := d.Backoff
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
+
+// attempt sends the delivery once and records the outcome on it. It reports
+// whether the receiver answered with a 2xx status.
+func (d *Dispatcher) attempt(hook *models.Webhook, delivery *models.WebhookDelivery) bool {
+	delivery.Attempts++
+	delivery.NextAttemptAt = nil
+	delivery.StatusCode, delivery.Response, delivery.Error = 0, "", ""
+
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		delivery.Error = err.Error()
+		return false
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "self-improvement-webhooks/1")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, body))
+
+	start := time.Now()
+	resp, err := d.Client.Do(req)
+	delivery.DurationMs = time.Since(start).Milliseconds()
+	if err != nil {
+		delivery.Error = err.Error()
+		return false
+	}
+	defer resp.Body.Close()
+	delivery.StatusCode = resp.StatusCode
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		// Error pages may echo internal details; only the status is kept
+		delivery.Error = resp.Status
+		return false
+	}
+	snippet, _ := io.ReadAll(io.LimitReader(resp.Body, maxResponse))
+	delivery.Response = strings.ToValidUTF8(string(snippet), "")
+	return true
+}
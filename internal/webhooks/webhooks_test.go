@@ -0,0 +1,258 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+
+	"self-improvement/internal/models"
+)
+
+func setupTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := gorm.Open(sqlite.Open(":memory:"), &gorm.Config{})
+	if err != nil {
+		t.Fatalf("failed to open test db: %v", err)
+	}
+	// Deliveries run in the background; every connection to :memory: would
+	// get its own empty database
+	sqlDB, _ := db.DB()
+	sqlDB.SetMaxOpenConns(1)
+	if err := db.AutoMigrate(&models.Webhook{}, &models.WebhookDelivery{}); err != nil {
+		t.Fatalf("failed to migrate: %v", err)
+	}
+	return db
+}
+
+// receiver records the requests it gets and answers with the given
+// statuses in turn, the last one for all further requests
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.requests = append(r.requests, req)
+	r.bodies = append(r.bodies, body)
+	status := r.statuses[len(r.statuses)-1]
+	if len(r.requests) <= len(r.statuses) {
+		status = r.statuses[len(r.requests)-1]
+	}
+	w.WriteHeader(status)
+	w.Write([]byte("ok"))
+}
+
+// newDispatcher returns a Dispatcher that may reach the local test servers
+func newDispatcher(db *gorm.DB) *Dispatcher {
+	d := New(db)
+	d.Client = NewClient(true)
+	d.Backoff = time.Millisecond
+	d.MaxAttempts = 3
+	return d
+}
+
+func TestSign(t *testing.T) {
+	body := []byte(`{"event":"ping"}`)
+	sig := Sign("secret", body)
+	if len(sig) != len("sha256=")+64 || !Verify("secret", body, sig) {
+		t.Errorf("unexpected signature %q", sig)
+	}
+	if Verify("other", body, sig) || Verify("secret", []byte(`{}`), sig) {
+		t.Error("signature should not verify with another secret or body")
+	}
+}
+
+func TestEmit(t *testing.T) {
+	db := setupTestDB(t)
+	d := newDispatcher(db)
+	recv := &receiver{statuses: []int{http.StatusOK}}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	hook := &models.Webhook{UserID: 1, URL: srv.URL, Secret: "s3cret", Events: EventReviewCompleted + "," + EventGoalMet, Active: true}
+	db.Create(hook)
+	db.Create(&models.Webhook{UserID: 2, URL: srv.URL, Secret: "x", Events: EventReviewCompleted, Active: true})
+
+	d.Emit(1, EventReviewCompleted, map[string]interface{}{"question_id": "q1"})
+	d.Emit(1, EventSessionFinished, nil) // Not subscribed
+	d.Wait()
+
+	if len(recv.requests) != 1 {
+		t.Fatalf("expected 1 request, got %d", len(recv.requests))
+	}
+	req, body := recv.requests[0], recv.bodies[0]
+	if req.Header.Get(HeaderEvent) != EventReviewCompleted || !Verify("s3cret", body, req.Header.Get(HeaderSignature)) {
+		t.Errorf("unexpected headers %v", req.Header)
+	}
+	var payload struct {
+		ID    uint
+		Event string
+		Data  map[string]interface{}
+	}
+	json.Unmarshal(body, &payload)
+	if payload.Event != EventReviewCompleted || payload.Data["question_id"] != "q1" {
+		t.Errorf("unexpected payload %s", body)
+	}
+
+	var delivery models.WebhookDelivery
+	db.First(&delivery, payload.ID)
+	if delivery.Status != models.WebhookDeliverySucceeded || delivery.Attempts != 1 || delivery.StatusCode != 200 || delivery.Response != "ok" {
+		t.Errorf("unexpected delivery %+v", delivery)
+	}
+	if req.Header.Get(HeaderDelivery) != "1" {
+		t.Errorf("expected delivery ID 1 in header, got %q", req.Header.Get(HeaderDelivery))
+	}
+}
+
+func TestRetry(t *testing.T) {
+	db := setupTestDB(t)
+	d := newDispatcher(db)
+	recv := &receiver{statuses: []int{http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK}}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	db.Create(&models.Webhook{UserID: 1, URL: srv.URL, Secret: "s", Events: EventGoalMet, Active: true})
+	d.Emit(1, EventGoalMet, nil)
+	d.Wait()
+
+	var delivery models.WebhookDelivery
+	db.First(&delivery)
+	if len(recv.requests) != 3 || delivery.Status != models.WebhookDeliverySucceeded || delivery.Attempts != 3 {
+		t.Errorf("expected success on the third attempt, got %d requests and %+v", len(recv.requests), delivery)
+	}
+	// Retries resend the same signed body
+	if string(recv.bodies[0]) != string(recv.bodies[2]) {
+		t.Error("expected identical bodies on retry")
+	}
+
+	// Gives up after MaxAttempts
+	recv.statuses = []int{http.StatusInternalServerError}
+	recv.requests, recv.bodies = nil, nil
+	d.Emit(1, EventGoalMet, nil)
+	d.Wait()
+	var failed models.WebhookDelivery
+	db.Last(&failed)
+	if len(recv.requests) != 3 || failed.Status != models.WebhookDeliveryFailed || failed.StatusCode != 500 || failed.NextAttemptAt != nil {
+		t.Errorf("expected failure after 3 attempts, got %d requests and %+v", len(recv.requests), failed)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	d := New(nil)
+	want := []time.Duration{10 * time.Second, 20 * time.Second, 40 * time.Second, 80 * time.Second}
+	for i, w := range want {
+		if got := d.backoff(i + 1); got != w {
+			t.Errorf("backoff after %d attempts: expected %v, got %v", i+1, w, got)
+		}
+	}
+	if got := d.backoff(20); got != maxBackoff {
+		t.Errorf("expected backoff capped at %v, got %v", maxBackoff, got)
+	}
+}
+
+func TestTestDelivery(t *testing.T) {
+	db := setupTestDB(t)
+	d := newDispatcher(db)
+	recv := &receiver{statuses: []int{http.StatusNotFound}}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	hook := &models.Webhook{UserID: 1, URL: srv.URL, Secret: "s", Events: EventGoalMet, Active: true}
+	db.Create(hook)
+	delivery, err := d.Test(hook)
+	if err != nil {
+		t.Fatalf("Test failed: %v", err)
+	}
+	// Test deliveries are not retried; error responses keep only the status
+	if len(recv.requests) != 1 || delivery.Event != EventPing || delivery.Status != models.WebhookDeliveryFailed || delivery.StatusCode != 404 {
+		t.Errorf("unexpected test delivery %+v", delivery)
+	}
+	if delivery.Response != "" || delivery.Error != "404 Not Found" {
+		t.Errorf("expected no response body for a failed delivery, got %+v", delivery)
+	}
+
+	// Unreachable receivers are recorded with the error
+	srv.Close()
+	delivery, _ = d.Test(hook)
+	if delivery.Status != models.WebhookDeliveryFailed || delivery.StatusCode != 0 || delivery.Error == "" {
+		t.Errorf("expected a connection error, got %+v", delivery)
+	}
+}
+
+func TestBlockedAddresses(t *testing.T) {
+	for addr, want := range map[string]bool{
+		"127.0.0.1":        true,
+		"::1":              true,
+		"10.1.2.3":         true,
+		"172.16.0.1":       true,
+		"192.168.1.1":      true,
+		"169.254.169.254":  true,
+		"fe80::1":          true,
+		"fd00::1":          true,
+		"0.0.0.0":          true,
+		"::ffff:127.0.0.1": true,
+		"8.8.8.8":          false,
+		"2001:4860::8888":  false,
+	} {
+		if got := Blocked(net.ParseIP(addr)); got != want {
+			t.Errorf("Blocked(%s) = %v, want %v", addr, got, want)
+		}
+	}
+
+	db := setupTestDB(t)
+	d := New(db)
+	recv := &receiver{statuses: []int{http.StatusOK}}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	// Also through a host name that resolves to the loopback address
+	for _, url := range []string{srv.URL, strings.Replace(srv.URL, "127.0.0.1", "localhost", 1)} {
+		hook := &models.Webhook{UserID: 1, URL: url, Secret: "s", Events: EventGoalMet, Active: true}
+		db.Create(hook)
+		delivery, _ := d.Test(hook)
+		if delivery.Status != models.WebhookDeliveryFailed || !strings.Contains(delivery.Error, ErrBlockedAddress.Error()) {
+			t.Errorf("expected %s to be blocked, got %+v", url, delivery)
+		}
+	}
+	if len(recv.requests) != 0 {
+		t.Errorf("blocked deliveries reached the receiver: %d", len(recv.requests))
+	}
+}
+
+func TestResume(t *testing.T) {
+	db := setupTestDB(t)
+	d := newDispatcher(db)
+	recv := &receiver{statuses: []int{http.StatusOK}}
+	srv := httptest.NewServer(recv)
+	defer srv.Close()
+
+	hook := &models.Webhook{UserID: 1, URL: srv.URL, Secret: "s", Events: EventGoalMet, Active: true}
+	db.Create(hook)
+	past := time.Now().Add(-time.Minute)
+	db.Create(&models.WebhookDelivery{WebhookID: hook.ID, UserID: 1, Event: EventGoalMet, Payload: `{}`,
+		Status: models.WebhookDeliveryPending, Attempts: 1, NextAttemptAt: &past})
+
+	if err := d.Resume(); err != nil {
+		t.Fatalf("Resume failed: %v", err)
+	}
+	d.Wait()
+	var delivery models.WebhookDelivery
+	db.First(&delivery)
+	if len(recv.requests) != 1 || delivery.Status != models.WebhookDeliverySucceeded || delivery.Attempts != 2 {
+		t.Errorf("expected the pending delivery to be sent, got %+v", delivery)
+	}
+}
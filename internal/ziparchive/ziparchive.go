@@ -0,0 +1,165 @@
+// Package ziparchive reads uploaded zip archives without extracting them.
+// Entries are read straight from the archive under configurable limits, and
+// entries whose paths would escape the archive root are rejected.
+package ziparchive
+
+import (
+	"archive/zip"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"sync/atomic"
+)
+
+var (
+	// ErrTooLarge is returned when the archive or its content exceeds the limits
+	ErrTooLarge = errors.New("archive too large")
+	// ErrTooManyEntries is returned when the archive has more entries than allowed
+	ErrTooManyEntries = errors.New("archive has too many entries")
+)
+
+// Limits guards against zip bombs. Zero values disable a limit.
+type Limits struct {
+	MaxSize         int64 // Compressed archive size
+	MaxUncompressed int64 // Total uncompressed size of all entries
+	MaxEntries      int   // Number of entries, directories included
+	MaxEntrySize    int64 // Uncompressed size of a single entry
+}
+
+// EntryError describes an entry that was rejected or could not be read
+type EntryError struct {
+	Name  string `json:"file"`
+	Error string `json:"error"`
+}
+
+// Archive is an opened zip archive
+type Archive struct {
+	// Names lists the accepted regular files in archive order, cleaned
+	Names []string
+	// Errors lists the entries rejected when opening the archive
+	Errors []EntryError
+
+	files  map[string]*zip.File
+	limits Limits
+	read   int64 // Uncompressed bytes read so far
+}
+
+// Open checks an archive against the limits. The reader must stay open for
+// as long as the archive is used.
+func Open(r io.ReaderAt, size int64, limits Limits) (*Archive, error) {
+	if limits.MaxSize > 0 && size > limits.MaxSize {
+		return nil, ErrTooLarge
+	}
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, err
+	}
+	if limits.MaxEntries > 0 && len(zr.File) > limits.MaxEntries {
+		return nil, ErrTooManyEntries
+	}
+
+	a := &Archive{files: make(map[string]*zip.File), limits: limits}
+	var total uint64
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		reject := func(reason string) {
+			a.Errors = append(a.Errors, EntryError{Name: f.Name, Error: reason})
+		}
+
+		name, ok := CleanName(f.Name)
+		switch {
+		case !ok:
+			reject("unsafe path")
+		case !f.Mode().IsRegular():
+			reject("not a regular file")
+		case limits.MaxEntrySize > 0 && f.UncompressedSize64 > uint64(limits.MaxEntrySize):
+			reject("file too large")
+		case a.files[name] != nil:
+			reject("duplicate entry")
+		default:
+			total += f.UncompressedSize64
+			a.files[name] = f
+			a.Names = append(a.Names, name)
+		}
+	}
+	// Declared sizes can be forged, ReadFile enforces the limits again
+	if limits.MaxUncompressed > 0 && total > uint64(limits.MaxUncompressed) {
+		return nil, ErrTooLarge
+	}
+	return a, nil
+}
+
+// CleanName normalizes an entry path and rejects paths that would escape the
+// archive root, such as absolute paths, drive letters and "../" components
+func CleanName(name string) (string, bool) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if path.IsAbs(name) || (len(name) >= 2 && name[1] == ':') {
+		return "", false
+	}
+	for _, part := range strings.Split(name, "/") {
+		if part == ".." {
+			return "", false
+		}
+	}
+	name = path.Clean(name)
+	if name == "." {
+		return "", false
+	}
+	return name, true
+}
+
+// Has reports whether a regular file with the cleaned name was accepted
+func (a *Archive) Has(name string) bool {
+	return a.files[name] != nil
+}
+
+// ReadFile returns the content of an accepted entry. It is safe for
+// concurrent use and fails once an entry or the archive as a whole
+// decompresses to more than the limits allow.
+func (a *Archive) ReadFile(name string) ([]byte, error) {
+	f := a.files[name]
+	if f == nil {
+		return nil, fmt.Errorf("%s: file not found", name)
+	}
+
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	limit := int64(f.UncompressedSize64)
+	if a.limits.MaxEntrySize > 0 && limit > a.limits.MaxEntrySize {
+		limit = a.limits.MaxEntrySize
+	}
+	data, err := io.ReadAll(io.LimitReader(rc, limit+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(data)) > limit {
+		return nil, fmt.Errorf("%s: %w", name, ErrTooLarge)
+	}
+
+	read := atomic.AddInt64(&a.read, int64(len(data)))
+	if a.limits.MaxUncompressed > 0 && read > a.limits.MaxUncompressed {
+		return nil, ErrTooLarge
+	}
+	return data, nil
+}
+
+// Match returns the accepted names matching pred, sorted
+func (a *Archive) Match(pred func(name string) bool) []string {
+	var names []string
+	for _, name := range a.Names {
+		if pred(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,123 @@
+package ziparchive
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"os"
+	"strings"
+	"testing"
+)
+
+type entry struct {
+	name    string
+	content string
+	mode    os.FileMode
+}
+
+func build(t *testing.T, entries ...entry) *bytes.Reader {
+	t.Helper()
+	buf := &bytes.Buffer{}
+	zw := zip.NewWriter(buf)
+	for _, e := range entries {
+		h := &zip.FileHeader{Name: e.name, Method: zip.Deflate}
+		if e.mode != 0 {
+			h.SetMode(e.mode)
+		}
+		w, err := zw.CreateHeader(h)
+		if err != nil {
+			t.Fatalf("zip create: %v", err)
+		}
+		w.Write([]byte(e.content))
+	}
+	zw.Close()
+	return bytes.NewReader(buf.Bytes())
+}
+
+func TestOpen_RejectsUnsafeEntries(t *testing.T) {
+	r := build(t,
+		entry{name: "kb/a.md", content: "a"},
+		entry{name: "kb/"},
+		entry{name: "../evil.md", content: "x"},
+		entry{name: "kb/../../evil.md", content: "x"},
+		entry{name: "/etc/passwd", content: "x"},
+		entry{name: `..\evil.md`, content: "x"},
+		entry{name: "C:/evil.md", content: "x"},
+		entry{name: "kb/link.md", content: "/etc/passwd", mode: os.ModeSymlink | 0777},
+		entry{name: "kb/./a.md", content: "again"},
+	)
+
+	a, err := Open(r, r.Size(), Limits{})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if len(a.Names) != 1 || a.Names[0] != "kb/a.md" {
+		t.Errorf("unexpected accepted entries: %v", a.Names)
+	}
+
+	reasons := make(map[string]string)
+	for _, e := range a.Errors {
+		reasons[e.Name] = e.Error
+	}
+	for _, name := range []string{"../evil.md", "kb/../../evil.md", "/etc/passwd", `..\evil.md`, "C:/evil.md"} {
+		if reasons[name] != "unsafe path" {
+			t.Errorf("%s: expected unsafe path, got %q", name, reasons[name])
+		}
+	}
+	if reasons["kb/link.md"] != "not a regular file" {
+		t.Errorf("symlink not rejected: %q", reasons["kb/link.md"])
+	}
+	if reasons["kb/./a.md"] != "duplicate entry" {
+		t.Errorf("duplicate not rejected: %q", reasons["kb/./a.md"])
+	}
+
+	if data, err := a.ReadFile("kb/a.md"); err != nil || string(data) != "a" {
+		t.Errorf("ReadFile = %q, %v", data, err)
+	}
+	if _, err := a.ReadFile("../evil.md"); err == nil {
+		t.Error("rejected entry was readable")
+	}
+}
+
+func TestOpen_Limits(t *testing.T) {
+	big := strings.Repeat("0", 1<<20)
+	r := build(t, entry{name: "a.md", content: big}, entry{name: "b.md", content: big})
+
+	if _, err := Open(r, r.Size(), Limits{MaxSize: r.Size() - 1}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge for compressed size, got %v", err)
+	}
+	if _, err := Open(r, r.Size(), Limits{MaxUncompressed: 1<<21 - 1}); !errors.Is(err, ErrTooLarge) {
+		t.Errorf("expected ErrTooLarge for uncompressed size, got %v", err)
+	}
+	if _, err := Open(r, r.Size(), Limits{MaxEntries: 1}); !errors.Is(err, ErrTooManyEntries) {
+		t.Errorf("expected ErrTooManyEntries, got %v", err)
+	}
+
+	a, err := Open(r, r.Size(), Limits{MaxEntrySize: 1 << 19})
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if len(a.Names) != 0 || len(a.Errors) != 2 || a.Errors[0].Error != "file too large" {
+		t.Errorf("oversized entries not rejected: %v %v", a.Names, a.Errors)
+	}
+}
+
+func TestCleanName(t *testing.T) {
+	tests := map[string]string{
+		"a.md":       "a.md",
+		"kb//a.md":   "kb/a.md",
+		`kb\a.md`:    "kb/a.md",
+		"kb/./a.md":  "kb/a.md",
+		"notes:1.md": "notes:1.md",
+		"..":         "",
+		"a/../../b":  "",
+		"/abs":       "",
+		"D:\\x.md":   "",
+	}
+	for in, want := range tests {
+		got, ok := CleanName(in)
+		if ok != (want != "") || got != want {
+			t.Errorf("CleanName(%q) = %q, %v; want %q", in, got, ok, want)
+		}
+	}
+}
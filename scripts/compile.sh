@@ -38,11 +38,11 @@ echo "========================================="
 # 检查是否需要交叉编译为 Linux
 if [ "$BUILD_TARGET" = "linux" ]; then
     echo "编译目标: Linux (GOOS=linux GOARCH=amd64)"
-    CGO_ENABLED=1 GOOS=linux GOARCH=amd64 CC=x86_64-linux-gnu-gcc go build -ldflags="-linkmode external -extldflags '-static'" -o bin/web_server web_server.go 2>/dev/null || \
+    CGO_ENABLED=1 GOOS=linux GOARCH=amd64 CC=x86_64-linux-gnu-gcc go build -tags sqlite_fts5 -ldflags="-linkmode external -extldflags '-static'" -o bin/web_server web_server.go 2>/dev/null || \
     CGO_ENABLED=0 GOOS=linux GOARCH=amd64 go build -tags sqlite_omit_load_extension -o bin/web_server web_server.go
 else
     echo "编译目标: 本地平台"
-    go build -o bin/web_server web_server.go
+    go build -tags sqlite_fts5 -o bin/web_server web_server.go
 fi
 echo "Go 编译完成: bin/web_server"
 echo ""
@@ -113,7 +113,7 @@ cd "$BUILD_DIR"
 export GOPROXY=https://goproxy.cn,direct
 export PATH=/usr/local/go/bin:$PATH
 $GO mod download 2>/dev/null || true
-$GO build -o web_server . || {
+$GO build -tags sqlite_fts5 -o web_server . || {
     echo "编译失败，查看错误..."
     exit 1
 }
@@ -53,7 +53,7 @@ echo ""
 # 启动后端服务
 cd "$PROJECT_DIR" || exit 1
 echo "[$(date '+%Y-%m-%d %H:%M:%S')] 启动后端服务..." >> "$BACKEND_LOG_FILE"
-PORT="$BACKEND_PORT" JWT_SECRET="$JWT_SECRET" nohup go run web_server.go >> "$BACKEND_LOG_FILE" 2>&1 &
+PORT="$BACKEND_PORT" JWT_SECRET="$JWT_SECRET" nohup go run -tags sqlite_fts5 web_server.go >> "$BACKEND_LOG_FILE" 2>&1 &
 BACKEND_PID=$!
 echo "$BACKEND_PID" > "$BACKEND_PID_FILE"
 